@@ -4,58 +4,87 @@ import (
 	"fmt"
 	"helena/core"
 	"helena/helena_dialect"
+	"helena/native/go_crypto"
+	"helena/native/go_csv"
+	"helena/native/go_exec"
+	"helena/native/go_http"
+	"helena/native/go_json"
 	"helena/native/go_os"
 	"helena/native/go_regexp"
 	"helena/native/go_slog"
+	"helena/native/go_strings"
+	"helena/native/go_time"
 	"helena/picol_dialect"
+	"io/fs"
 	"os"
 
 	"github.com/ergochat/readline"
 	"github.com/fatih/color"
 )
 
-var moduleRegistry = helena_dialect.NewModuleRegistry(&helena_dialect.ModuleOptions{
-	CaptureErrorStack: true,
-	CapturePositions:  true,
-})
+var moduleRegistry *helena_dialect.ModuleRegistry
 
-func sourceFile(path string, scope *helena_dialect.Scope) core.Result {
-	data, err := os.ReadFile(path)
+func newModuleRegistry(searchPath []string) *helena_dialect.ModuleRegistry {
+	return helena_dialect.NewModuleRegistry(&helena_dialect.ModuleOptions{
+		CaptureErrorStack: true,
+		CapturePositions:  true,
+		SearchPath:        searchPath,
+	})
+}
+
+var eventLoop = helena_dialect.NewEventLoop(helena_dialect.SystemClock)
+
+func sourceFile(fsys fs.FS, path string, scope *helena_dialect.Scope) core.Result {
+	result, path := helena_dialect.JoinModulePath(fsys, "", path)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	data, err := fs.ReadFile(fsys, path)
 	if err != nil {
 		return core.ERROR("error reading file: " + fmt.Sprint(err))
 	}
 	tokens := core.Tokenizer{}.Tokenize(string(data))
-	result := core.NewParser(nil).ParseTokens(tokens, nil)
-	if !result.Success {
-		return core.ERROR(result.Message)
+	parseResult := core.NewParser(nil).ParseTokens(tokens, nil)
+	if !parseResult.Success {
+		return core.ERROR(parseResult.Message)
 	}
-	program := scope.Compile(*result.Script)
-	process := scope.PrepareProcess(program)
-	return process.Run()
+	program := scope.Compile(*parseResult.Script)
+	task := eventLoop.Spawn(scope, program)
+	if loopResult := eventLoop.Run(); loopResult.Code != core.ResultCode_OK {
+		eventLoop.DiscardReady()
+		return loopResult
+	}
+	return task.Result()
 }
 
-type sourceCmd struct{}
+type sourceCmd struct {
+	fsys fs.FS
+}
 
-func (sourceCmd) Execute(args []core.Value, context any) core.Result {
+func (cmd sourceCmd) Execute(args []core.Value, context any) core.Result {
 	scope := context.(*helena_dialect.Scope)
 	if len(args) != 2 {
 		return helena_dialect.ARITY_ERROR("source path")
 	}
 	_, path := core.ValueToString(args[1])
-	data, err := os.ReadFile(path)
+	result, path := helena_dialect.JoinModulePath(cmd.fsys, "", path)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	data, err := fs.ReadFile(cmd.fsys, path)
 	if err != nil {
 		return core.ERROR("error reading file: " + fmt.Sprint(err))
 	}
 	input := core.NewStringStreamFromFile(string(data), path)
 	output := core.NewArrayTokenStream([]core.Token{}, input.Source())
 	(&core.Tokenizer{}).TokenizeStream(input, output)
-	result := core.NewParser(&core.ParserOptions{
+	parseResult := core.NewParser(&core.ParserOptions{
 		CapturePositions: true,
 	}).Parse(output)
-	if !result.Success {
-		return core.ERROR(result.Message)
+	if !parseResult.Success {
+		return core.ERROR(parseResult.Message)
 	}
-	program := scope.Compile(*result.Script)
+	program := scope.Compile(*parseResult.Script)
 	return helena_dialect.CreateContinuationValue(scope, program)
 }
 
@@ -112,9 +141,12 @@ func initScope() *helena_dialect.Scope {
 	helena_dialect.InitCommandsForModule(rootScope, moduleRegistry, cwd)
 
 	// Interactive mode functions
-	rootScope.RegisterNamedCommand("source", sourceCmd{})
+	rootScope.RegisterNamedCommand("source", sourceCmd{helena_dialect.SystemFS})
 	rootScope.RegisterNamedCommand("exit", exitCmd{})
 
+	// Timers and background tasks
+	eventLoop.RegisterCommands(rootScope)
+
 	// Embedded picol dialect
 	rootScope.RegisterNamedCommand("picol", picolCmd{})
 
@@ -123,11 +155,25 @@ func initScope() *helena_dialect.Scope {
 
 	// Built-in native modules
 	StaticLoad("native/go_slog", go_slog.Initmodule)
+	StaticLoad("native/go_crypto", go_crypto.Initmodule)
+	StaticLoad("native/go_csv", go_csv.Initmodule)
+	StaticLoad("native/go_exec", go_exec.Initmodule)
+	StaticLoad("native/go_http", go_http.Initmodule)
+	StaticLoad("native/go_json", go_json.Initmodule)
 	StaticLoad("native/go_os", go_os.Initmodule)
 	StaticLoad("native/go_regexp", go_regexp.Initmodule)
+	StaticLoad("native/go_strings", go_strings.Initmodule)
+	StaticLoad("native/go_time", go_time.Initmodule)
 	loadNativeModule("native/go_slog", "go:slog")
+	loadNativeModule("native/go_crypto", "go:crypto")
+	loadNativeModule("native/go_csv", "go:csv")
+	loadNativeModule("native/go_exec", "go:exec")
+	loadNativeModule("native/go_http", "go:http")
+	loadNativeModule("native/go_json", "go:json")
 	loadNativeModule("native/go_os", "go:os")
 	loadNativeModule("native/go_regexp", "go:regexp")
+	loadNativeModule("native/go_strings", "go:strings")
+	loadNativeModule("native/go_time", "go:time")
 
 	return rootScope
 }
@@ -149,7 +195,7 @@ func loadNativeModule(path string, moduleName string) error {
 
 func source(path string) {
 	rootScope := initScope()
-	result := sourceFile(path, rootScope)
+	result := sourceFile(helena_dialect.SystemFS, path, rootScope)
 	value, err := processResult(result)
 	if err == nil {
 		os.Stdout.WriteString(resultWriter(value) + "\n")
@@ -160,6 +206,30 @@ func source(path string) {
 	}
 }
 
+// Print the help of builtin commands and module exports as Markdown
+func doc() {
+	rootScope := initScope()
+	os.Stdout.WriteString(helena_dialect.MarkdownHelp(
+		"Builtin commands",
+		"",
+		rootScope,
+		rootScope.GetLocalCommandNames(),
+	))
+	for _, name := range moduleRegistry.Names() {
+		module := moduleRegistry.Get(name)
+		names := make([]string, 0, len(*module.Exports))
+		for export := range *module.Exports {
+			names = append(names, export)
+		}
+		os.Stdout.WriteString(helena_dialect.MarkdownHelp(
+			"Module `"+name+"`",
+			module.Doc(),
+			module.Scope,
+			names,
+		))
+	}
+}
+
 func prompt() {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt: "> ",
@@ -171,18 +241,48 @@ func prompt() {
 
 	rootScope := initScope()
 
+	// Read lines in the background so that the event loop keeps running timers
+	// and background tasks while waiting for input
+	type input struct {
+		line string
+		err  error
+	}
+	inputs := make(chan input, 1)
+	ready := make(chan struct{})
+	next := make(chan struct{})
+	go func() {
+		for {
+			line, err := rl.Readline()
+			inputs <- input{line, err}
+			ready <- struct{}{}
+			if err != nil {
+				return
+			}
+			<-next
+		}
+	}()
+
 	cmd := ""
 	for {
-		line, err := rl.Readline()
-		if err != nil {
+		result, interrupted := eventLoop.RunUntil(ready)
+		if result.Code != core.ResultCode_OK {
+			reportError(result)
+			continue
+		}
+		if !interrupted {
+			<-ready
+		}
+		input := <-inputs
+		if input.err != nil {
 			break
 		}
-		cmd += line
+		cmd += input.line
 		value, err := run(rootScope, cmd)
 		if err != nil {
 			if _, ok := err.(recoverableError); ok {
 				rl.SetPrompt("... ")
 				cmd += "\n"
+				next <- struct{}{}
 				continue
 			}
 			os.Stdout.WriteString(resultWriter(err) + "\n")
@@ -191,10 +291,21 @@ func prompt() {
 		}
 		rl.SetPrompt("> ")
 		cmd = ""
+		next <- struct{}{}
 	}
 
 }
 
+// Print the error of a failed task and drop the other ready tasks
+func reportError(result core.Result) {
+	eventLoop.DiscardReady()
+	if errorStack, ok := result.Data.(*core.ErrorStack); ok {
+		printErrorStack(errorStack)
+	}
+	_, err := processResult(result)
+	os.Stdout.WriteString(resultWriter(err) + "\n")
+}
+
 var lastResult = core.OK(core.NIL)
 
 func run(scope *helena_dialect.Scope, cmd string) (core.Value, error) {
@@ -224,10 +335,17 @@ func run(scope *helena_dialect.Scope, cmd string) (core.Value, error) {
 	program := scope.Compile(*parseResult.Script)
 	process := scope.PrepareProcess(program)
 	process.SetResult(lastResult)
-	result := process.Run()
+
+	// Run on the global event loop so that timers and background tasks
+	// scheduled at the prompt make progress
+	task := eventLoop.SpawnProcess(process)
+	result := eventLoop.RunTask(task)
 	lastResult = result
 	if result.Code == core.ResultCode_ERROR {
-		printErrorStack(result.Data.(*core.ErrorStack))
+		eventLoop.DiscardReady()
+	}
+	if errorStack, ok := result.Data.(*core.ErrorStack); ok {
+		printErrorStack(errorStack)
 	}
 	return processResult(result)
 }
@@ -290,8 +408,7 @@ func processResult(result core.Result) (core.Value, error) {
 	case core.ResultCode_OK:
 		return result.Value, nil
 	case core.ResultCode_ERROR:
-		_, s := core.ValueToString(result.Value)
-		return nil, basicError{s}
+		return nil, basicError{helena_dialect.ErrorMessage(result.Value)}
 	default:
 		return nil, (basicError{"unexpected " + core.RESULT_CODE_NAME(result)})
 	}
@@ -332,12 +449,31 @@ func resultWriter(output any) string {
 	}
 }
 
+func usage() {
+	os.Stderr.WriteString("Usage: helena [-path dir]... [script | doc]\n")
+	os.Exit(0)
+}
+
 func Cli() {
-	if len(os.Args) > 2 {
-		os.Stderr.WriteString("Usage: helena [script]\n")
-		os.Exit(0)
-	} else if len(os.Args) == 2 {
-		source(os.Args[1])
+	// Search path flags take precedence over HELENA_PATH
+	searchPath := []string{}
+	args := os.Args[1:]
+	for len(args) > 0 && args[0] == "-path" {
+		if len(args) < 2 {
+			usage()
+		}
+		searchPath = append(searchPath, args[1])
+		args = args[2:]
+	}
+	searchPath = append(searchPath, helena_dialect.SearchPathFromEnv()...)
+	moduleRegistry = newModuleRegistry(searchPath)
+
+	if len(args) > 1 {
+		usage()
+	} else if len(args) == 1 && args[0] == "doc" {
+		doc()
+	} else if len(args) == 1 {
+		source(args[0])
 	} else {
 		prompt()
 	}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"go/version"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load the Go versions that introduced the exported symbols of a package
+//
+// Versions are read from the api/go1.*.txt files of the given GOROOT. The
+// result maps function and type names, and methods as Type.Method, to their
+// earliest Go version, e.g. go1.20
+func LoadAPI(goroot string, packagePath string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(goroot, "api", "go1*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	api := map[string]string{}
+	for _, file := range files {
+		v := strings.TrimSuffix(filepath.Base(file), ".txt")
+		if !version.IsValid(v) {
+			continue
+		}
+		if err := readAPIFile(file, packagePath, v, api); err != nil {
+			return nil, err
+		}
+	}
+	return api, nil
+}
+
+func readAPIFile(file string, packagePath string, v string, api map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, ok := parseAPILine(scanner.Text(), packagePath)
+		if !ok {
+			continue
+		}
+		if since, found := api[name]; !found || version.Compare(v, since) < 0 {
+			api[name] = v
+		}
+	}
+	return scanner.Err()
+}
+
+// Parse an API line such as "pkg strings, method (*Builder) Grow(int)" into
+// a symbol name of the given package
+func parseAPILine(line string, packagePath string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "pkg "+packagePath)
+	if !ok {
+		return "", false
+	}
+	if strings.HasPrefix(rest, " (") {
+		// Platform-specific symbol, e.g. "pkg syscall (linux-386), ..."
+		_, rest, _ = strings.Cut(rest, ")")
+	}
+	rest, ok = strings.CutPrefix(rest, ", ")
+	if !ok {
+		return "", false
+	}
+	kind, rest, _ := strings.Cut(rest, " ")
+	switch kind {
+	case "func":
+		name, _, _ := strings.Cut(rest, "(")
+		name, _, _ = strings.Cut(name, "[")
+		return name, true
+	case "type":
+		name, _, _ := strings.Cut(rest, " ")
+		name, _, _ = strings.Cut(name, "[")
+		if strings.Contains(rest, ", ") {
+			// Struct field or interface method
+			return "", false
+		}
+		return name, true
+	case "method":
+		receiver, rest, ok := strings.Cut(rest, ") ")
+		if !ok {
+			return "", false
+		}
+		receiver = strings.TrimLeft(receiver, "(*")
+		receiver, _, _ = strings.Cut(receiver, "[")
+		name, _, _ := strings.Cut(rest, "(")
+		return receiver + "." + name, true
+	}
+	return "", false
+}
+
+// Return the Go version declared in the go.mod file of the module containing
+// the given directory, e.g. go1.23.4
+func ModuleGoVersion(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(line), "go "); ok {
+					return "go" + strings.TrimSpace(v), nil
+				}
+			}
+			return "", nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,748 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/types"
+	"go/version"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Options struct {
+	// Name of the generated Go package
+	Package string
+
+	// Name of the Helena module, e.g. go:strings
+	Module string
+
+	// Name of the command wrapping package-level functions
+	Command string
+
+	// Go version required by the generated module, e.g. go1.23.4
+	GoVersion string
+
+	// Go versions that introduced the package symbols, as given by LoadAPI
+	API map[string]string
+}
+
+// Bound Go function or method
+type boundFunc struct {
+	name      string
+	signature *types.Signature
+
+	// Argument names used in help strings, including the receiver
+	params []string
+}
+
+// Bound struct type
+type boundType struct {
+	name    string
+	methods []boundFunc
+}
+
+type generator struct {
+	pkg     *types.Package
+	options Options
+	funcs   []boundFunc
+	types   []boundType
+	skipped []string
+	newer   []string
+	out     strings.Builder
+}
+
+// Generate the source of a module binding the exported API of a package
+//
+// Functions and methods whose parameter or result types are not supported
+// are skipped and listed in a comment at the end of the generated file, as
+// are symbols introduced by Go versions later than options.GoVersion
+func Generate(pkg *types.Package, options Options) ([]byte, error) {
+	g := newGenerator(pkg, options)
+	g.generate()
+	source, err := format.Source([]byte(g.out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, g.out.String())
+	}
+	return source, nil
+}
+
+// Generate the test scaffolding of a generated module
+func GenerateTests(pkg *types.Package, options Options) ([]byte, error) {
+	g := newGenerator(pkg, options)
+	g.generateTests()
+	source, err := format.Source([]byte(g.out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated tests: %w\n%s", err, g.out.String())
+	}
+	return source, nil
+}
+
+func newGenerator(pkg *types.Package, options Options) *generator {
+	if options.Package == "" {
+		options.Package = "go_" + pkg.Name()
+	}
+	if options.Module == "" {
+		options.Module = "go:" + pkg.Name()
+	}
+	if options.Command == "" {
+		options.Command = pkg.Name()
+	}
+	g := &generator{pkg: pkg, options: options}
+	g.collect()
+	return g
+}
+
+//
+// API collection
+//
+
+func (g *generator) collect() {
+	scope := g.pkg.Scope()
+	names := scope.Names()
+	slices.Sort(names)
+
+	// Struct types first, so that functions can use them
+	for _, name := range names {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !typeName.Exported() || typeName.IsAlias() {
+			continue
+		}
+		named, ok := typeName.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 {
+			continue
+		}
+		if _, ok := named.Underlying().(*types.Struct); ok {
+			if g.isNewer(name) {
+				g.newer = append(g.newer, name)
+				continue
+			}
+			g.types = append(g.types, boundType{name: name})
+		}
+	}
+
+	for _, name := range names {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !fn.Exported() {
+			continue
+		}
+		if g.isNewer(name) {
+			g.newer = append(g.newer, name)
+			continue
+		}
+		signature := fn.Type().(*types.Signature)
+		if !g.supported(signature) {
+			g.skipped = append(g.skipped, name)
+			continue
+		}
+		g.funcs = append(g.funcs, boundFunc{name, signature, paramNames(signature)})
+	}
+
+	for i := range g.types {
+		bound := &g.types[i]
+		named := scope.Lookup(bound.name).Type()
+		methods := types.NewMethodSet(types.NewPointer(named))
+		for j := 0; j < methods.Len(); j++ {
+			method := methods.At(j).Obj().(*types.Func)
+			if !method.Exported() {
+				continue
+			}
+			if g.isNewer(bound.name + "." + method.Name()) {
+				g.newer = append(g.newer, bound.name+"."+method.Name())
+				continue
+			}
+			signature := method.Type().(*types.Signature)
+			if !g.supported(signature) {
+				g.skipped = append(g.skipped, bound.name+"."+method.Name())
+				continue
+			}
+			receiver := "value"
+			if name := signature.Recv().Name(); name != "" && name != "_" {
+				receiver = name
+			}
+			params := append([]string{receiver}, paramNames(signature)...)
+			bound.methods = append(bound.methods, boundFunc{method.Name(), signature, params})
+		}
+	}
+}
+
+// Return whether a symbol was introduced after the required Go version
+func (g *generator) isNewer(name string) bool {
+	if g.options.GoVersion == "" {
+		return false
+	}
+	since, ok := g.options.API[name]
+	return ok && version.Compare(since, version.Lang(g.options.GoVersion)) > 0
+}
+
+func paramNames(signature *types.Signature) []string {
+	names := []string{}
+	for i := 0; i < signature.Params().Len(); i++ {
+		name := signature.Params().At(i).Name()
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Return the bound struct type of a type, if any
+func (g *generator) boundType(t types.Type) (string, bool) {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() != g.pkg {
+		return "", false
+	}
+	for _, bound := range g.types {
+		if bound.name == named.Obj().Name() {
+			return bound.name, true
+		}
+	}
+	return "", false
+}
+
+func (g *generator) supported(signature *types.Signature) bool {
+	if signature.TypeParams().Len() > 0 {
+		return false
+	}
+	for i := 0; i < signature.Params().Len(); i++ {
+		t := signature.Params().At(i).Type()
+		if signature.Variadic() && i == signature.Params().Len()-1 {
+			t = t.(*types.Slice).Elem()
+		}
+		if !g.convertible(t) {
+			return false
+		}
+	}
+	results := signature.Results()
+	for i := 0; i < results.Len(); i++ {
+		t := results.At(i).Type()
+		if i == results.Len()-1 && isError(t) {
+			continue
+		}
+		if !g.convertible(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// Return whether values of a type can be converted in both directions
+func (g *generator) convertible(t types.Type) bool {
+	if _, ok := g.boundType(t); ok {
+		return true
+	}
+	if pointer, ok := t.(*types.Pointer); ok {
+		_, ok := g.boundType(pointer.Elem())
+		return ok
+	}
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != g.pkg {
+		// Named types from other packages would need extra imports
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return basicKind(u) != ""
+	case *types.Slice:
+		if _, ok := u.Elem().(*types.Named); ok {
+			return false
+		}
+		return g.convertible(u.Elem())
+	}
+	return false
+}
+
+// Return the conversion kind of a basic type
+func basicKind(t *types.Basic) string {
+	info := t.Info()
+	switch {
+	case info&types.IsString != 0:
+		return "string"
+	case info&types.IsBoolean != 0:
+		return "bool"
+	case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+		return "uint"
+	case info&types.IsInteger != 0:
+		return "int"
+	case info&types.IsFloat != 0:
+		return "float"
+	}
+	return ""
+}
+
+//
+// Code generation
+//
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+func (g *generator) generate() {
+	g.printf("// Code generated by helena-bindgen; DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.options.Package)
+	g.printf("import (\n")
+	g.printf("\t\"helena/core\"\n")
+	g.printf("\t\"helena/helena_dialect\"\n")
+	g.printf("\t%q\n", g.pkg.Path())
+	g.printf(")\n\n")
+
+	g.printf("/**\n * Main static module entry point.\n */\n")
+	g.printf("func Initmodule() *helena_dialect.Module {\n")
+	g.printf("\tscope := helena_dialect.NewRootScope(nil)\n")
+	g.printf("\texports := &helena_dialect.Exports{}\n")
+	g.printf("\tmodule := helena_dialect.NewModule(scope, exports)\n")
+	g.printf("\tmodule.SetDoc(%q)\n", "Bindings for the Go "+g.pkg.Path()+" package")
+	g.printf("\texportCommand(module, %q, %s{})\n", g.options.Command, g.commandType())
+	for _, bound := range g.types {
+		g.printf("\texportCommand(module, %q, %sCmd{})\n", bound.name, bound.name)
+	}
+	g.printf("\treturn module\n")
+	g.printf("}\n\n")
+	g.printf("func exportCommand(module *helena_dialect.Module, name string, cmd core.Command) {\n")
+	g.printf("\tmodule.Scope.RegisterNamedCommand(name, cmd)\n")
+	g.printf("\t(*module.Exports)[name] = core.STR(name)\n")
+	g.printf("}\n\n")
+
+	g.printf("func listValue[T any](values []T, fn func(T) core.Value) core.Value {\n")
+	g.printf("\tif values == nil {\n\t\treturn core.NIL\n\t}\n")
+	g.printf("\tlist := make([]core.Value, len(values))\n")
+	g.printf("\tfor i, value := range values {\n\t\tlist[i] = fn(value)\n\t}\n")
+	g.printf("\treturn core.LIST(list)\n")
+	g.printf("}\n\n")
+
+	for _, bound := range g.types {
+		g.generateValue(bound)
+	}
+	g.generateCommand(g.commandType(), g.options.Command, "method", g.funcs, "")
+	for _, bound := range g.types {
+		g.generateCommand(bound.name+"Cmd", bound.name, "subcommand", bound.methods, bound.name)
+	}
+
+	if len(g.skipped) > 0 {
+		g.printf("// Unsupported signatures:\n")
+		for _, name := range g.skipped {
+			g.printf("//   - %s\n", name)
+		}
+	}
+	if len(g.newer) > 0 {
+		g.printf("// Requires a Go version later than %s:\n", version.Lang(g.options.GoVersion))
+		for _, name := range g.newer {
+			g.printf("//   - %s\n", name)
+		}
+	}
+}
+
+func (g *generator) commandType() string {
+	name := g.options.Command
+	return strings.ToUpper(name[:1]) + name[1:] + "Cmd"
+}
+
+func (g *generator) generateValue(bound boundType) {
+	qualified := g.pkg.Name() + "." + bound.name
+	g.printf("type %sValue struct {\n\tvalue *%s\n}\n\n", bound.name, qualified)
+	g.printf("var %sValueType core.CustomValueType = core.CustomValueType{Name: %q}\n\n",
+		bound.name, "go:"+qualified)
+	g.printf("func New%sValue(value *%s) %sValue {\n\treturn %sValue{value}\n}\n\n",
+		bound.name, qualified, bound.name, bound.name)
+	g.printf("func (%sValue) Type() core.ValueType {\n\treturn core.ValueType_CUSTOM\n}\n\n", bound.name)
+	g.printf("func (%sValue) CustomType() core.CustomValueType {\n\treturn %sValueType\n}\n\n",
+		bound.name, bound.name)
+	g.printf("func (value %sValue) Display(fn core.DisplayFunction) string {\n", bound.name)
+	g.printf("\tif fn != nil {\n\t\treturn fn(value)\n\t}\n")
+	g.printf("\treturn core.UndisplayableValueWithLabel(%q)\n}\n\n", qualified)
+	g.printf("// Return the wrapped Go value\n")
+	g.printf("func (value %sValue) Value() *%s {\n\treturn value.value\n}\n\n", bound.name, qualified)
+	g.printf("func %sValueOrNil(value *%s) core.Value {\n", lowerFirst(bound.name), qualified)
+	g.printf("\tif value == nil {\n\t\treturn core.NIL\n\t}\n")
+	g.printf("\treturn New%sValue(value)\n}\n\n", bound.name)
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// Return the usage of a bound function
+func (g *generator) usage(command string, fn boundFunc) string {
+	parts := []string{command, fn.name}
+	for i, param := range fn.params {
+		if fn.signature.Variadic() && i == len(fn.params)-1 {
+			parts = append(parts, "?"+param+" ...?")
+		} else {
+			parts = append(parts, param)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Generate a command dispatching to bound functions by name
+//
+// Type commands also get `subcommands` and `new`
+func (g *generator) generateCommand(
+	typeName string,
+	command string,
+	label string,
+	funcs []boundFunc,
+	structName string,
+) {
+	names := []string{}
+	if structName != "" {
+		names = append(names, "subcommands", "new")
+	}
+	for _, fn := range funcs {
+		names = append(names, fn.name)
+	}
+	subcommands := lowerFirst(typeName) + "Subcommands"
+	g.printf("var %s = helena_dialect.NewSubcommands([]string{\n", subcommands)
+	for _, name := range names {
+		g.printf("\t%q,\n", name)
+	}
+	g.printf("})\n\n")
+
+	g.printf("type %s struct{}\n\n", typeName)
+	g.printf("func (%s) Execute(args []core.Value, _ any) core.Result {\n", typeName)
+	g.printf("\tif len(args) < 2 {\n")
+	g.printf("\t\treturn helena_dialect.ARITY_ERROR(%q)\n", command+" "+label+" ?arg ...?")
+	g.printf("\t}\n")
+	g.printf("\tresult, %s := core.ValueToString(args[1])\n", label)
+	g.printf("\tif result.Code != core.ResultCode_OK {\n")
+	if structName != "" {
+		g.printf("\t\treturn helena_dialect.INVALID_SUBCOMMAND_ERROR()\n")
+	} else {
+		g.printf("\t\treturn core.ERROR(\"invalid method name\")\n")
+	}
+	g.printf("\t}\n")
+	g.printf("\tswitch %s {\n", label)
+	if structName != "" {
+		g.printf("\tcase \"subcommands\":\n")
+		g.printf("\t\tif len(args) != 2 {\n")
+		g.printf("\t\t\treturn helena_dialect.ARITY_ERROR(%q)\n", command+" subcommands")
+		g.printf("\t\t}\n")
+		g.printf("\t\treturn core.OK(%s.List)\n\n", subcommands)
+		g.printf("\tcase \"new\":\n")
+		g.printf("\t\tif len(args) != 2 {\n")
+		g.printf("\t\t\treturn helena_dialect.ARITY_ERROR(%q)\n", command+" new")
+		g.printf("\t\t}\n")
+		g.printf("\t\treturn core.OK(New%sValue(&%s.%s{}))\n\n", structName, g.pkg.Name(), structName)
+	}
+	for _, fn := range funcs {
+		g.generateCase(command, fn)
+	}
+	g.printf("\tdefault:\n")
+	if structName != "" {
+		g.printf("\t\treturn helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)\n")
+	} else {
+		g.printf("\t\treturn core.ERROR(`unknown method \"` + method + `\"`)\n")
+	}
+	g.printf("\t}\n")
+	g.printf("}\n\n")
+
+	g.printf("func (%s) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {\n", typeName)
+	g.printf("\tif len(args) < 2 {\n")
+	g.printf("\t\treturn core.OK(core.STR(%q))\n", command+" "+label+" ?arg ...?")
+	g.printf("\t}\n")
+	g.printf("\tresult, %s := core.ValueToString(args[1])\n", label)
+	g.printf("\tif result.Code != core.ResultCode_OK {\n")
+	g.printf("\t\treturn core.ERROR(\"invalid %s name\")\n", label)
+	g.printf("\t}\n")
+	g.printf("\tvar signature string\n")
+	g.printf("\tmaxArgs := -1\n")
+	g.printf("\tswitch %s {\n", label)
+	if structName != "" {
+		g.printf("\tcase \"subcommands\":\n")
+		g.printf("\t\tsignature, maxArgs = %q, 2\n", command+" subcommands")
+		g.printf("\tcase \"new\":\n")
+		g.printf("\t\tsignature, maxArgs = %q, 2\n", command+" new")
+	}
+	for _, fn := range funcs {
+		g.printf("\tcase %q:\n", fn.name)
+		if fn.signature.Variadic() {
+			g.printf("\t\tsignature = %q\n", g.usage(command, fn))
+		} else {
+			g.printf("\t\tsignature, maxArgs = %q, %d\n", g.usage(command, fn), len(fn.params)+2)
+		}
+	}
+	g.printf("\tdefault:\n")
+	if structName != "" {
+		g.printf("\t\treturn helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)\n")
+	} else {
+		g.printf("\t\treturn core.ERROR(`unknown method \"` + method + `\"`)\n")
+	}
+	g.printf("\t}\n")
+	g.printf("\tif maxArgs >= 0 && len(args) > maxArgs {\n")
+	g.printf("\t\treturn helena_dialect.ARITY_ERROR(signature)\n")
+	g.printf("\t}\n")
+	g.printf("\treturn core.OK(core.STR(signature))\n")
+	g.printf("}\n\n")
+}
+
+func (g *generator) generateCase(command string, fn boundFunc) {
+	usage := g.usage(command, fn)
+	nparams := len(fn.params)
+	g.printf("\tcase %q:\n", fn.name)
+	if fn.signature.Variadic() {
+		g.printf("\t\tif len(args) < %d {\n", nparams+1)
+	} else {
+		g.printf("\t\tif len(args) != %d {\n", nparams+2)
+	}
+	g.printf("\t\t\treturn helena_dialect.ARITY_ERROR(%q)\n", usage)
+	g.printf("\t\t}\n")
+
+	// Arguments, including the receiver
+	args := []string{}
+	offset := 0
+	if fn.signature.Recv() != nil {
+		g.convert(fn.signature.Recv().Type(), "args[2]", "a0", 2)
+		offset = 1
+	}
+	params := fn.signature.Params()
+	for i := 0; i < params.Len(); i++ {
+		name := fmt.Sprintf("a%d", i+offset)
+		t := params.At(i).Type()
+		if fn.signature.Variadic() && i == params.Len()-1 {
+			g.printf("\t\t%s := make(%s, len(args)-%d)\n", name, g.typeString(t), i+offset+2)
+			g.printf("\t\tfor i, arg := range args[%d:] {\n", i+offset+2)
+			g.convert(t.(*types.Slice).Elem(), "arg", name+"[i]", 3)
+			g.printf("\t\t}\n")
+			args = append(args, name+"...")
+		} else {
+			g.convert(t, fmt.Sprintf("args[%d]", i+offset+2), name, 2)
+			args = append(args, name)
+		}
+	}
+
+	// Call
+	results := fn.signature.Results()
+	names := []string{}
+	for i := 0; i < results.Len(); i++ {
+		names = append(names, fmt.Sprintf("r%d", i))
+	}
+	call := g.pkg.Name() + "." + fn.name + "(" + strings.Join(args, ", ") + ")"
+	if fn.signature.Recv() != nil {
+		call = "a0." + fn.name + "(" + strings.Join(args, ", ") + ")"
+	}
+	if len(names) == 0 {
+		g.printf("\t\t%s\n", call)
+		g.printf("\t\treturn core.OK(core.NIL)\n\n")
+		return
+	}
+	g.printf("\t\t%s := %s\n", strings.Join(names, ", "), call)
+
+	// Results
+	values := []string{}
+	for i := 0; i < results.Len(); i++ {
+		t := results.At(i).Type()
+		if i == results.Len()-1 && isError(t) {
+			g.printf("\t\tif %s != nil {\n\t\t\treturn core.ERROR(%s.Error())\n\t\t}\n", names[i], names[i])
+			continue
+		}
+		values = append(values, g.toValue(t, names[i]))
+	}
+	switch len(values) {
+	case 0:
+		g.printf("\t\treturn core.OK(core.NIL)\n\n")
+	case 1:
+		g.printf("\t\treturn core.OK(%s)\n\n", values[0])
+	default:
+		g.printf("\t\treturn core.OK(core.LIST([]core.Value{%s}))\n\n", strings.Join(values, ", "))
+	}
+}
+
+// Generate the conversion of a Helena value expression into a declared Go
+// variable or assignable expression
+//
+// Nested conversions use depth-suffixed names to avoid shadowing the target
+func (g *generator) convert(t types.Type, value string, target string, depth int) {
+	indent := strings.Repeat("\t", depth)
+	declare := !strings.ContainsAny(target, "[.")
+	if declare {
+		g.printf("%svar %s %s\n", indent, target, g.typeString(t))
+	}
+	g.printf("%s{\n", indent)
+	inner := indent + "\t"
+	if name, ok := g.boundType(t); ok {
+		g.printf("%sv, ok := %s.(%sValue)\n", inner, value, name)
+		g.printf("%sif !ok {\n%s\treturn core.ERROR(%q)\n%s}\n", inner, inner, "invalid "+name+" value", inner)
+		g.printf("%s%s = *v.value\n", inner, target)
+	} else if pointer, ok := t.(*types.Pointer); ok {
+		name, _ := g.boundType(pointer.Elem())
+		g.printf("%sv, ok := %s.(%sValue)\n", inner, value, name)
+		g.printf("%sif !ok {\n%s\treturn core.ERROR(%q)\n%s}\n", inner, inner, "invalid "+name+" value", inner)
+		g.printf("%s%s = v.value\n", inner, target)
+	} else if slice, ok := t.Underlying().(*types.Slice); ok {
+		if basic, ok := slice.Elem().Underlying().(*types.Basic); ok && basic.Kind() == types.Byte {
+			g.printf("%sresult, v := core.ValueToString(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+			g.printf("%s%s = %s(v)\n", inner, target, g.typeString(t))
+		} else {
+			values := fmt.Sprintf("values%d", depth)
+			index := fmt.Sprintf("i%d", depth)
+			element := fmt.Sprintf("value%d", depth)
+			g.printf("%sresult, %s := helena_dialect.ValueToArray(%s)\n", inner, values, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+			g.printf("%s%s = make(%s, len(%s))\n", inner, target, g.typeString(t), values)
+			g.printf("%sfor %s, %s := range %s {\n", inner, index, element, values)
+			g.convert(slice.Elem(), element, target+"["+index+"]", depth+2)
+			g.printf("%s}\n", inner)
+		}
+	} else {
+		basic := t.Underlying().(*types.Basic)
+		typeName := g.typeString(t)
+		switch basicKind(basic) {
+		case "string":
+			g.printf("%sresult, v := core.ValueToString(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+		case "bool":
+			g.printf("%sresult, v := core.ValueToBoolean(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+		case "int":
+			g.printf("%sresult, v := core.ValueToInteger(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+			if basic.Kind() != types.Int64 {
+				g.printf("%sif int64(%s(v)) != v {\n%s\treturn core.ERROR(\"integer out of range\")\n%s}\n",
+					inner, typeName, inner, inner)
+			}
+		case "uint":
+			g.printf("%sresult, v := core.ValueToInteger(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+			g.printf("%sif v < 0 || uint64(%s(v)) != uint64(v) {\n%s\treturn core.ERROR(\"integer out of range\")\n%s}\n",
+				inner, typeName, inner, inner)
+		case "float":
+			g.printf("%sresult, v := core.ValueToFloat(%s)\n", inner, value)
+			g.printf("%sif result.Code != core.ResultCode_OK {\n%s\treturn result\n%s}\n", inner, inner, inner)
+		}
+		g.printf("%s%s = %s(v)\n", inner, target, typeName)
+	}
+	g.printf("%s}\n", indent)
+}
+
+// Return the expression converting a Go variable to a Helena value
+func (g *generator) toValue(t types.Type, name string) string {
+	if bound, ok := g.boundType(t); ok {
+		return "New" + bound + "Value(&" + name + ")"
+	}
+	if pointer, ok := t.(*types.Pointer); ok {
+		bound, _ := g.boundType(pointer.Elem())
+		return lowerFirst(bound) + "ValueOrNil(" + name + ")"
+	}
+	if slice, ok := t.Underlying().(*types.Slice); ok {
+		if basic, ok := slice.Elem().Underlying().(*types.Basic); ok && basic.Kind() == types.Byte {
+			return "core.STR(string(" + name + "))"
+		}
+		element := g.toValue(slice.Elem(), "value")
+		return "listValue(" + name + ", func(value " + g.typeString(slice.Elem()) +
+			") core.Value { return " + element + " })"
+	}
+	switch basicKind(t.Underlying().(*types.Basic)) {
+	case "string":
+		return "core.STR(string(" + name + "))"
+	case "bool":
+		return "core.BOOL(bool(" + name + "))"
+	case "int", "uint":
+		return "core.INT(int64(" + name + "))"
+	default:
+		return "core.REAL(float64(" + name + "))"
+	}
+}
+
+//
+// Test scaffolding
+//
+
+func (g *generator) generateTests() {
+	suite := "Test"
+	for _, part := range strings.Split(g.options.Package, "_") {
+		if part != "" {
+			suite += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	g.printf("package %s_test\n\n", g.options.Package)
+	g.printf("import (\n")
+	g.printf("\t\"testing\"\n\n")
+	g.printf("\t. \"github.com/onsi/ginkgo/v2\"\n")
+	g.printf("\t. \"github.com/onsi/gomega\"\n\n")
+	g.printf("\t\"helena/core\"\n")
+	g.printf("\t\"helena/helena_dialect\"\n")
+	g.printf("\t\"helena/native/%s\"\n", g.options.Package)
+	g.printf(")\n\n")
+	g.printf("func %s(t *testing.T) {\n", suite)
+	g.printf("\tRegisterFailHandler(Fail)\n")
+	g.printf("\tRunSpecs(t, %q)\n", g.options.Module+" Suite")
+	g.printf("}\n\n")
+
+	g.printf("var _ = Describe(%q, func() {\n", g.options.Module)
+	g.printf("\tvar rootScope *helena_dialect.Scope\n\n")
+	g.printf("\tvar tokenizer core.Tokenizer\n")
+	g.printf("\tvar parser *core.Parser\n\n")
+	g.printf("\tparse := func(script string) *core.Script {\n")
+	g.printf("\t\treturn parser.ParseTokens(tokenizer.Tokenize(script), nil).Script\n")
+	g.printf("\t}\n")
+	g.printf("\texecute := func(script string) core.Result {\n")
+	g.printf("\t\treturn rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()\n")
+	g.printf("\t}\n")
+	g.printf("\tevaluate := func(script string) core.Value {\n")
+	g.printf("\t\treturn execute(script).Value\n")
+	g.printf("\t}\n\n")
+	g.printf("\tBeforeEach(func() {\n")
+	g.printf("\t\trootScope = helena_dialect.NewRootScope(nil)\n")
+	g.printf("\t\thelena_dialect.InitCommands(rootScope)\n")
+	g.printf("\t\tmodule := %s.Initmodule()\n", g.options.Package)
+	g.printf("\t\tfor name := range *module.Exports {\n")
+	g.printf("\t\t\trootScope.RegisterNamedCommand(name, module.Scope.ResolveNamedCommand(name))\n")
+	g.printf("\t\t}\n")
+	g.printf("\t\ttokenizer = core.Tokenizer{}\n")
+	g.printf("\t\tparser = core.NewParser(nil)\n")
+	g.printf("\t})\n\n")
+
+	g.generateCommandTests(g.options.Command, g.funcs, "method")
+	for _, bound := range g.types {
+		g.printf("\n")
+		g.generateCommandTests(bound.name, bound.methods, "subcommand")
+	}
+	g.printf("})\n")
+}
+
+func (g *generator) generateCommandTests(command string, funcs []boundFunc, label string) {
+	g.printf("\tDescribe(%q, func() {\n", command)
+	g.printf("\t\tSpecify(\"usage\", func() {\n")
+	g.printf("\t\t\tExpect(evaluate(%s)).To(Equal(core.STR(%s)))\n",
+		quote("help "+command), quote(command+" "+label+" ?arg ...?"))
+	for _, fn := range funcs {
+		g.printf("\t\t\tExpect(evaluate(%s)).To(Equal(core.STR(%s)))\n",
+			quote("help "+command+" "+fn.name), quote(g.usage(command, fn)))
+	}
+	g.printf("\t\t})\n\n")
+	g.printf("\t\tDescribe(\"Exceptions\", func() {\n")
+	g.printf("\t\t\tSpecify(\"wrong arity\", func() {\n")
+	g.printf("\t\t\t\tExpect(execute(%s)).To(Equal(core.ERROR(%s)))\n",
+		quote(command), quote(`wrong # args: should be "`+command+" "+label+` ?arg ...?"`))
+	for _, fn := range funcs {
+		if len(fn.params) == 0 || fn.signature.Variadic() && len(fn.params) == 1 {
+			continue
+		}
+		g.printf("\t\t\t\tExpect(execute(%s)).To(Equal(core.ERROR(%s)))\n",
+			quote(command+" "+fn.name), quote(`wrong # args: should be "`+g.usage(command, fn)+`"`))
+	}
+	g.printf("\t\t\t})\n")
+	g.printf("\t\t})\n\n")
+	g.printf("\t\t// TODO add behavior specs\n")
+	g.printf("\t})\n")
+}
+
+// Quote a string as a Go literal, preferring raw strings
+func quote(s string) string {
+	if strings.ContainsAny(s, "`\n") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
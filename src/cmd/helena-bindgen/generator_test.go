@@ -0,0 +1,253 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const shapesSource = `
+package shapes
+
+import "errors"
+
+type Point struct {
+	X, Y int
+}
+
+func (p Point) Norm1() int { return abs(p.X) + abs(p.Y) }
+func (p *Point) Move(dx, dy int) { p.X += dx; p.Y += dy }
+func (p *Point) Callback(fn func()) {}
+
+type Unit uint8
+
+func NewPoint(x, y int) *Point { return &Point{x, y} }
+func Origin() Point { return Point{} }
+func Scale(factor float64, values ...float64) []float64 { return nil }
+func Parse(s string) (Point, error) { return Point{}, errors.New("invalid point") }
+func Bytes(data []byte, unit Unit) (string, bool) { return "", false }
+func Filter(keep func(Point) bool) []Point { return nil }
+func Generic[T any](value T) T { return value }
+func unexported() {}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+`
+
+// Importer resolving a package from memory and others from source
+type memoryImporter struct {
+	pkg      *types.Package
+	fallback types.Importer
+}
+
+func (i memoryImporter) Import(path string) (*types.Package, error) {
+	if path == i.pkg.Path() {
+		return i.pkg, nil
+	}
+	return i.fallback.Import(path)
+}
+
+var _ = Describe("helena-bindgen", func() {
+	var fset *token.FileSet
+	var pkg *types.Package
+
+	typeCheck := func(path string, source string, imports types.Importer) (*types.Package, error) {
+		file, err := parser.ParseFile(fset, path+".go", source, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		config := types.Config{Importer: imports}
+		return config.Check(path, fset, []*ast.File{file}, nil)
+	}
+
+	BeforeEach(func() {
+		fset = token.NewFileSet()
+		var err error
+		pkg, err = typeCheck("example.com/shapes", shapesSource, importer.Default())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("API collection", func() {
+		It("should bind exported struct types", func() {
+			g := newGenerator(pkg, Options{})
+			Expect(g.types).To(HaveLen(1))
+			Expect(g.types[0].name).To(Equal("Point"))
+		})
+		It("should bind supported functions", func() {
+			g := newGenerator(pkg, Options{})
+			names := []string{}
+			for _, fn := range g.funcs {
+				names = append(names, fn.name)
+			}
+			Expect(names).To(Equal([]string{"Bytes", "NewPoint", "Origin", "Parse", "Scale"}))
+		})
+		It("should bind methods of the pointer method set", func() {
+			g := newGenerator(pkg, Options{})
+			names := []string{}
+			for _, fn := range g.types[0].methods {
+				names = append(names, fn.name)
+			}
+			Expect(names).To(Equal([]string{"Move", "Norm1"}))
+			Expect(g.types[0].methods[0].params).To(Equal([]string{"p", "dx", "dy"}))
+		})
+		It("should skip unsupported signatures", func() {
+			g := newGenerator(pkg, Options{})
+			Expect(g.skipped).To(Equal([]string{"Filter", "Generic", "Point.Callback"}))
+		})
+		It("should skip symbols newer than the required Go version", func() {
+			api := map[string]string{
+				"NewPoint":   "go1.1",
+				"Origin":     "go1.22",
+				"Scale":      "go1.25",
+				"Point.Move": "go1.27",
+			}
+			g := newGenerator(pkg, Options{GoVersion: "go1.24.3", API: api})
+			names := []string{}
+			for _, fn := range g.funcs {
+				names = append(names, fn.name)
+			}
+			Expect(names).To(Equal([]string{"Bytes", "NewPoint", "Origin", "Parse"}))
+			Expect(g.types[0].methods).To(HaveLen(1))
+			Expect(g.newer).To(Equal([]string{"Scale", "Point.Move"}))
+		})
+		It("should skip newer struct types", func() {
+			g := newGenerator(pkg, Options{GoVersion: "go1.24", API: map[string]string{"Point": "go1.25"}})
+			Expect(g.types).To(BeEmpty())
+			Expect(g.newer).To(Equal([]string{"Point"}))
+		})
+		It("should ignore API versions without a required Go version", func() {
+			g := newGenerator(pkg, Options{API: map[string]string{"Scale": "go1.25"}})
+			Expect(g.newer).To(BeEmpty())
+		})
+		It("should apply option defaults", func() {
+			g := newGenerator(pkg, Options{})
+			Expect(g.options).To(Equal(Options{
+				Package: "go_shapes",
+				Module:  "go:shapes",
+				Command: "shapes",
+			}))
+			Expect(g.commandType()).To(Equal("ShapesCmd"))
+		})
+	})
+
+	Describe("Generate", func() {
+		It("should generate valid Go code", func() {
+			source, err := Generate(pkg, Options{})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = typeCheck("helena/native/go_shapes", string(source), memoryImporter{
+				pkg, importer.ForCompiler(fset, "source", nil),
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("should generate usage and arity checks", func() {
+			source, _ := Generate(pkg, Options{})
+			Expect(string(source)).To(ContainSubstring(`ARITY_ERROR("shapes NewPoint x y")`))
+			Expect(string(source)).To(ContainSubstring(`signature = "shapes Scale factor ?values ...?"`))
+			Expect(string(source)).To(ContainSubstring(`ARITY_ERROR("Point Move p dx dy")`))
+		})
+		It("should generate custom value types", func() {
+			source, _ := Generate(pkg, Options{})
+			Expect(string(source)).To(ContainSubstring(
+				`var PointValueType core.CustomValueType = core.CustomValueType{Name: "go:shapes.Point"}`,
+			))
+		})
+		It("should list skipped signatures", func() {
+			source, _ := Generate(pkg, Options{})
+			Expect(string(source)).To(ContainSubstring(
+				"// Unsupported signatures:\n//   - Filter\n//   - Generic\n//   - Point.Callback\n",
+			))
+		})
+		It("should list symbols newer than the required Go version", func() {
+			source, _ := Generate(pkg, Options{GoVersion: "go1.24.3", API: map[string]string{"Scale": "go1.25"}})
+			Expect(string(source)).NotTo(ContainSubstring(`case "Scale":`))
+			Expect(string(source)).To(ContainSubstring(
+				"// Requires a Go version later than go1.24:\n//   - Scale\n",
+			))
+		})
+		It("should use the provided names", func() {
+			source, _ := Generate(pkg, Options{Package: "geometry", Command: "geo"})
+			Expect(string(source)).To(ContainSubstring("package geometry\n"))
+			Expect(string(source)).To(ContainSubstring(`exportCommand(module, "geo", GeoCmd{})`))
+		})
+	})
+
+	Describe("API versions", func() {
+		It("should parse symbol names", func() {
+			for _, test := range []struct{ line, pkg, name string }{
+				{"pkg strings, func CutLast(string, string) (string, string, bool) #71151", "strings", "CutLast"},
+				{"pkg strings, type Builder struct", "strings", "Builder"},
+				{"pkg strings, method (*Builder) Grow(int)", "strings", "Builder.Grow"},
+				{"pkg strings, method (Reader) Len() int", "strings", "Reader.Len"},
+				{"pkg slices, func Sorted[$0 cmp.Ordered](iter.Seq[$0]) []$0", "slices", "Sorted"},
+				{"pkg syscall (linux-386), func Setuid(int) error", "syscall", "Setuid"},
+			} {
+				name, ok := parseAPILine(test.line, test.pkg)
+				Expect(ok).To(BeTrue(), test.line)
+				Expect(name).To(Equal(test.name), test.line)
+			}
+		})
+		It("should ignore other packages and symbols", func() {
+			for _, line := range []string{
+				"pkg strings/v2, func Clone(string) string",
+				"pkg stringsx, func Clone(string) string",
+				"pkg strings, type Builder struct, Field int",
+				"pkg strings, const MaxLen = 10",
+			} {
+				_, ok := parseAPILine(line, "strings")
+				Expect(ok).To(BeFalse(), line)
+			}
+		})
+		It("should load the earliest version of each symbol", func() {
+			goroot := GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(goroot, "api"), 0o755)).To(Succeed())
+			files := map[string]string{
+				"go1.txt":    "pkg strings, func Index(string, string) int\n",
+				"go1.20.txt": "pkg strings, func CutPrefix(string, string) (string, bool)\n",
+				"go1.9.txt":  "pkg strings, method (*Builder) Grow(int)\npkg strings, func CutPrefix(string, string) (string, bool)\n",
+				"except.txt": "pkg strings, func Index(string, string) int\n",
+			}
+			for name, content := range files {
+				Expect(os.WriteFile(filepath.Join(goroot, "api", name), []byte(content), 0o644)).To(Succeed())
+			}
+			api, err := LoadAPI(goroot, "strings")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api).To(Equal(map[string]string{
+				"Index":        "go1",
+				"CutPrefix":    "go1.9",
+				"Builder.Grow": "go1.9",
+			}))
+		})
+		It("should read the module Go version", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n\ngo 1.23.4\n"), 0o644)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(dir, "sub"), 0o755)).To(Succeed())
+			Expect(ModuleGoVersion(filepath.Join(dir, "sub"))).To(Equal("go1.23.4"))
+		})
+	})
+
+	Describe("GenerateTests", func() {
+		It("should generate a Ginkgo suite", func() {
+			source, err := GenerateTests(pkg, Options{Module: "go:geometry"})
+			Expect(err).NotTo(HaveOccurred())
+			file, err := parser.ParseFile(fset, "go_shapes_test.go", source, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Name.Name).To(Equal("go_shapes_test"))
+			Expect(string(source)).To(ContainSubstring("func TestGoShapes(t *testing.T) {"))
+			Expect(string(source)).To(ContainSubstring(`RunSpecs(t, "go:geometry Suite")`))
+			Expect(string(source)).To(ContainSubstring(
+				"Expect(execute(`shapes NewPoint`)).To(Equal(core.ERROR(`wrong # args: should be \"shapes NewPoint x y\"`)))",
+			))
+		})
+	})
+})
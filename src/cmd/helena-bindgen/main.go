@@ -0,0 +1,104 @@
+// helena-bindgen generates Helena native modules from Go packages
+//
+// Usage:
+//
+//	helena-bindgen -package strings [-out file] [-pkg name] [-module name] [-tests file] [-go version]
+//
+// Symbols introduced by Go versions later than the one declared in the
+// enclosing go.mod file are skipped, according to the $GOROOT/api files.
+//
+// It is meant to be invoked from go:generate directives in native module
+// directories:
+//
+//	//go:generate go run helena/cmd/helena-bindgen -package strings -tests go_strings_test.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+func main() {
+	packagePath := flag.String("package", "", "import path of the Go package to bind")
+	out := flag.String("out", "", "output file (default <pkg>_gen.go)")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "name of the generated package (default $GOPACKAGE)")
+	module := flag.String("module", "", "name of the Helena module (default go:<package>)")
+	command := flag.String("command", "", "name of the command wrapping package functions (default <package>)")
+	tests := flag.String("tests", "", "test scaffolding file, only written if missing")
+	goVersion := flag.String("go", "", "required Go version (default from go.mod)")
+	flag.Parse()
+
+	if *packagePath == "" || flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: helena-bindgen -package path [-out file] [-pkg name] [-module name] [-command name] [-tests file] [-go version]")
+		os.Exit(2)
+	}
+
+	if err := run(*packagePath, *out, *tests, Options{
+		Package:   *pkg,
+		Module:    *module,
+		Command:   *command,
+		GoVersion: *goVersion,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "helena-bindgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(packagePath string, out string, tests string, options Options) error {
+	fset := token.NewFileSet()
+	pkg, err := importer.ForCompiler(fset, "source", nil).Import(packagePath)
+	if err != nil {
+		return err
+	}
+	if options.Package == "" {
+		options.Package = "go_" + pkg.Name()
+	}
+	if options.GoVersion == "" {
+		options.GoVersion, err = ModuleGoVersion(".")
+		if err != nil {
+			return err
+		}
+	}
+	if options.GoVersion != "" {
+		goroot := os.Getenv("GOROOT")
+		if goroot == "" {
+			goroot = runtime.GOROOT()
+		}
+		options.API, err = LoadAPI(goroot, packagePath)
+		if err != nil {
+			return err
+		}
+	}
+	if out == "" {
+		out = options.Package + "_gen.go"
+	}
+
+	source, err := Generate(pkg, options)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, source, 0o644); err != nil {
+		return err
+	}
+
+	if tests == "" {
+		return nil
+	}
+	if _, err := os.Stat(tests); err == nil {
+		// Scaffolding is meant to be edited by hand
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	source, err = GenerateTests(pkg, options)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tests, source, 0o644)
+}
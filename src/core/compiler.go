@@ -739,6 +739,10 @@ type Executor struct {
 
 	// Opaque context passed to commands
 	Context any
+
+	// Maximum size of strings and tuples built during execution, as measured
+	// by ValueSize, 0 for no limit
+	MaxValueSize uint
 }
 
 // Execute the given program and return last executed result
@@ -912,6 +916,9 @@ func (executor *Executor) ExecuteUntil(program *Program, state *ProgramState, st
 					if result.Code != ResultCode_OK {
 						return result
 					}
+					if executor.MaxValueSize > 0 && uint(b.Len()+len(s2)) > executor.MaxValueSize {
+						return ERROR("value size limit exceeded")
+					}
 					b.WriteString(s2)
 				}
 				state.Push(NewStringValue(b.String()))
@@ -920,6 +927,9 @@ func (executor *Executor) ExecuteUntil(program *Program, state *ProgramState, st
 		case OpCode_MAKE_TUPLE:
 			{
 				values := state.LastFrame
+				if executor.MaxValueSize > 0 && valuesSize(values) > executor.MaxValueSize {
+					return ERROR("value size limit exceeded")
+				}
 				state.Push(NewTupleValue(append([]Value{}, values...)))
 			}
 
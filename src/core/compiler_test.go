@@ -46,6 +46,7 @@ var _ = Describe("Compilation and execution", func() {
 			commandResolver,
 			selectorResolver,
 			nil,
+			0,
 		}
 	})
 
@@ -3451,10 +3452,26 @@ var _ = Describe("Compilation and execution", func() {
 				commandResolver,
 				selectorResolver,
 				context,
+				0,
 			}
 			execute(program)
 			Expect(cmd.context).To(BeIdenticalTo(context))
 		})
+		It("should limit the size of built values", func() {
+			variableResolver.register("var", STR("ab"))
+			executor.MaxValueSize = 4
+
+			Expect(evaluate(compileFirstWord(parse(`"$var$var"`)))).To(Equal(STR("abab")))
+			Expect(execute(compileFirstWord(parse(`"$var$var$var"`)))).To(Equal(
+				ERROR("value size limit exceeded"),
+			))
+			Expect(evaluate(compileFirstWord(parse("($var)")))).To(Equal(
+				TUPLE([]Value{STR("ab")}),
+			))
+			Expect(execute(compileFirstWord(parse("($var a)")))).To(Equal(
+				ERROR("value size limit exceeded"),
+			))
+		})
 
 		Describe("exceptions", func() {
 			Specify("invalid command name", func() {
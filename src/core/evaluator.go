@@ -45,6 +45,7 @@ func NewCompilingEvaluator(
 			commandResolver,
 			selectorResolver,
 			context,
+			0,
 		},
 	}
 }
@@ -456,6 +456,36 @@ func (parser *Parser) ParseTokens(tokens []Token, source *Source) ParseResult {
 	return parser.Parse(NewArrayTokenStream(tokens, source))
 }
 
+// Parse a token stream as free text
+//
+// Text is parsed like the content of a string, i.e. with variable, command
+// and selector substitutions, except that string delimiters are regular
+// characters. The resulting script is made of a single sentence with a single
+// word holding a string morpheme
+func (parser *Parser) ParseText(stream TokenStream) ParseResult {
+	parser.Begin(stream)
+	firstToken := parser.context.script.firstToken
+	parser.ensureWord(firstToken)
+	parser.openString(firstToken)
+	text := parser.context.node
+	for !parser.End() {
+		token := parser.stream.Next()
+		if parser.context.node == text && token.Type == TokenType_STRING_DELIMITER {
+			parser.endSubstitution()
+			parser.addLiteral(token, token.Literal)
+			continue
+		}
+		result := parser.parseToken(token)
+		if !result.Success {
+			return result
+		}
+	}
+	if parser.context.node == text {
+		parser.closeString()
+	}
+	return parser.CloseStream()
+}
+
 // Parse a token stream
 //
 // This method is useful when parsing incomplete scripts in interactive mode,
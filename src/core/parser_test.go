@@ -1727,6 +1727,62 @@ int main(void) {
 		})
 	})
 
+	Describe("text", func() {
+		parseText := func(text string) *Script {
+			return parser.ParseText(NewArrayTokenStream(tokenizer.Tokenize(text), nil)).Script
+		}
+
+		Specify("empty text", func() {
+			script := parseText("")
+			Expect(toTree(script)).To(Equal(M_SCRIPT(M_SENTENCE(M_WORD(M_STRING())))))
+		})
+		Specify("plain text", func() {
+			script := parseText("this {is (a #text;\n} ")
+			Expect(toTree(script)).To(Equal(M_SCRIPT(
+				M_SENTENCE(M_WORD(M_STRING(M_LITERAL("this {is (a #text;\n} ")))),
+			)))
+		})
+		Specify("string delimiters", func() {
+			script := parseText(`say "hello" """world"""`)
+			Expect(toTree(script)).To(Equal(M_SCRIPT(
+				M_SENTENCE(M_WORD(M_STRING(M_LITERAL(`say "hello" """world"""`)))),
+			)))
+		})
+		Specify("substitutions", func() {
+			script := parseText(`$a "$b(c)" [cmd "d"] $"`)
+			Expect(toTree(script)).To(Equal(M_SCRIPT(
+				M_SENTENCE(M_WORD(M_STRING(
+					M_SUBSTITUTE_NEXT("$"),
+					M_LITERAL("a"),
+					M_LITERAL(` "`),
+					M_SUBSTITUTE_NEXT("$"),
+					M_LITERAL("b"),
+					M_TUPLE(M_SENTENCE(M_WORD(M_LITERAL("c")))),
+					M_LITERAL(`" `),
+					M_EXPRESSION(M_SENTENCE(
+						M_WORD(M_LITERAL("cmd")),
+						M_WORD(M_STRING(M_LITERAL("d"))),
+					)),
+					M_LITERAL(` $"`),
+				))),
+			)))
+		})
+		Describe("exceptions", func() {
+			Specify("unterminated expression", func() {
+				tokens := tokenizer.Tokenize("[cmd")
+				Expect(parser.ParseText(NewArrayTokenStream(tokens, nil))).To(Equal(
+					PARSE_ERROR("unmatched left bracket"),
+				))
+			})
+			Specify("unterminated string in expression", func() {
+				tokens := tokenizer.Tokenize(`[cmd "]`)
+				Expect(parser.ParseText(NewArrayTokenStream(tokens, nil))).To(Equal(
+					PARSE_ERROR("unmatched string delimiter"),
+				))
+			})
+		})
+	})
+
 	Describe("capturePositions", func() {
 		BeforeEach(func() {
 			parser = NewParser(&ParserOptions{CapturePositions: true})
@@ -784,6 +784,40 @@ func IsCustomValue(
 	return value.Type() == ValueType_CUSTOM && value.(CustomValue).CustomType() == customType
 }
 
+//
+// Value size
+//
+
+// Measure the size of a value
+//
+// The size counts the bytes of strings and the elements of collections,
+// nested values included. Other values have a zero size
+func ValueSize(value Value) uint {
+	switch value := value.(type) {
+	case StringValue:
+		return uint(len(value.Value))
+	case ListValue:
+		return valuesSize(value.Values)
+	case TupleValue:
+		return valuesSize(value.Values)
+	case DictionaryValue:
+		size := uint(len(value.Map))
+		for key, v := range value.Map {
+			size += uint(len(key)) + ValueSize(v)
+		}
+		return size
+	default:
+		return 0
+	}
+}
+func valuesSize(values []Value) uint {
+	size := uint(len(values))
+	for _, value := range values {
+		size += ValueSize(value)
+	}
+	return size
+}
+
 //
 // Convenience functions for primitive value creation
 //
@@ -959,6 +959,20 @@ var _ = Describe("values", func() {
 			})
 		})
 	})
+
+	Describe("ValueSize", func() {
+		It("should count string bytes", func() {
+			Expect(ValueSize(STR("abc"))).To(Equal(uint(3)))
+		})
+		It("should count nested elements", func() {
+			Expect(ValueSize(LIST([]Value{STR("ab"), TUPLE([]Value{STR("c")})}))).To(Equal(uint(6)))
+			Expect(ValueSize(DICT(map[string]Value{"ab": STR("c")}))).To(Equal(uint(4)))
+		})
+		It("should ignore other values", func() {
+			Expect(ValueSize(INT(123))).To(Equal(uint(0)))
+			Expect(ValueSize(NIL)).To(Equal(uint(0)))
+		})
+	})
 })
 
 type undisplayableSelector struct{}
@@ -0,0 +1,71 @@
+//
+// Go errors for Helena results
+//
+
+package helena
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+)
+
+// Error raised by a script
+type Error struct {
+	// Error message, from the `message` key of structured error values
+	Message string
+
+	// Original error value
+	Value core.Value
+
+	// Error stack, nil unless the interpreter captures error stacks
+	Stack *core.ErrorStack
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Unexpected result code, e.g. a top-level break or yield
+type ResultError struct {
+	Result core.Result
+}
+
+func (err *ResultError) Error() string {
+	return "unexpected " + core.RESULT_CODE_NAME(err.Result)
+}
+
+// Script parsing error
+type ParseError struct {
+	Message string
+
+	// Source file name, empty for inline scripts
+	Filename string
+}
+
+func (err *ParseError) Error() string {
+	if err.Filename != "" {
+		return err.Filename + ": " + err.Message
+	}
+	return err.Message
+}
+
+// Convert a non-OK result to a Go error
+func newResultError(result core.Result) error {
+	if result.Code != core.ResultCode_ERROR {
+		return &ResultError{result}
+	}
+	message := helena_dialect.ErrorMessage(result.Value)
+	err := &Error{Message: message, Value: result.Value}
+	if stack, ok := result.Data.(*core.ErrorStack); ok {
+		err.Stack = stack
+	}
+	return err
+}
+
+// Convert a Go error to an ERROR result
+func errorResult(err error) core.Result {
+	if e, ok := err.(*Error); ok && e.Value != nil {
+		return core.Result{Code: core.ResultCode_ERROR, Value: e.Value}
+	}
+	return core.ERROR(err.Error())
+}
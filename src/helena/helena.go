@@ -0,0 +1,182 @@
+//
+// High-level embedding API
+//
+// Interpreters wrap the tokenizer, parser, compiler and process machinery and
+// convert results to Go values and errors
+//
+
+package helena
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+	"io/fs"
+)
+
+type Options struct {
+	// Record source positions in error stacks
+	CapturePositions bool
+
+	// Record error stacks in Error values
+	CaptureErrorStack bool
+
+	// Directory relative module paths resolve against, "." if empty
+	RootDir string
+
+	// Directories where modules and packages are looked up
+	SearchPath []string
+
+	// File system modules and files are read from, SystemFS if nil
+	FS fs.FS
+}
+
+// Helena interpreter with its own root scope and module registry
+//
+// Interpreters are not safe for concurrent use
+type Interpreter struct {
+	options  Options
+	scope    *helena_dialect.Scope
+	registry *helena_dialect.ModuleRegistry
+}
+
+func New(options *Options) *Interpreter {
+	interpreter := &Interpreter{}
+	if options != nil {
+		interpreter.options = *options
+	}
+	if interpreter.options.RootDir == "" {
+		interpreter.options.RootDir = "."
+	}
+	interpreter.scope = helena_dialect.NewRootScope(&helena_dialect.ScopeOptions{
+		CapturePositions:  interpreter.options.CapturePositions,
+		CaptureErrorStack: interpreter.options.CaptureErrorStack,
+	})
+	interpreter.registry = helena_dialect.NewModuleRegistry(&helena_dialect.ModuleOptions{
+		CapturePositions:  interpreter.options.CapturePositions,
+		CaptureErrorStack: interpreter.options.CaptureErrorStack,
+		SearchPath:        interpreter.options.SearchPath,
+		FS:                interpreter.options.FS,
+	})
+	helena_dialect.InitCommandsForModule(
+		interpreter.scope,
+		interpreter.registry,
+		interpreter.options.RootDir,
+	)
+	return interpreter
+}
+
+// Return the root scope for lower-level access
+func (interpreter *Interpreter) Scope() *helena_dialect.Scope {
+	return interpreter.scope
+}
+
+// Return the module registry, e.g. to register native modules
+func (interpreter *Interpreter) Registry() *helena_dialect.ModuleRegistry {
+	return interpreter.registry
+}
+
+// Evaluate a script in the root scope
+func (interpreter *Interpreter) Eval(source string) (core.Value, error) {
+	return interpreter.eval(source, nil)
+}
+
+// Evaluate a script file in the root scope
+//
+// The file is read from the interpreter file system
+func (interpreter *Interpreter) EvalFile(path string) (core.Value, error) {
+	fsys := interpreter.registry.FS()
+	result, path := helena_dialect.JoinModulePath(fsys, "", path)
+	if result.Code != core.ResultCode_OK {
+		return nil, newResultError(result)
+	}
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return interpreter.eval(string(data), &path)
+}
+
+func (interpreter *Interpreter) eval(source string, filename *string) (core.Value, error) {
+	tokens := core.Tokenizer{}.Tokenize(source)
+	parser := core.NewParser(&core.ParserOptions{
+		CapturePositions: interpreter.options.CapturePositions,
+	})
+	parseResult := parser.ParseTokens(tokens, &core.Source{Filename: filename})
+	if !parseResult.Success {
+		parseError := &ParseError{Message: parseResult.Message}
+		if filename != nil {
+			parseError.Filename = *filename
+		}
+		return nil, parseError
+	}
+	program := interpreter.scope.Compile(*parseResult.Script)
+	return interpreter.run(program)
+}
+
+// Call a command with the given arguments
+//
+// Arguments are passed as is, without substitution
+func (interpreter *Interpreter) Call(name string, args ...core.Value) (core.Value, error) {
+	cmdline := append([]core.Value{core.STR(name)}, args...)
+	return interpreter.run(interpreter.scope.CompileArgs(cmdline))
+}
+
+func (interpreter *Interpreter) run(program *core.Program) (core.Value, error) {
+	result := interpreter.scope.PrepareProcess(program).RunAndWait()
+	switch result.Code {
+	case core.ResultCode_OK,
+		core.ResultCode_RETURN:
+		return result.Value, nil
+	default:
+		return nil, newResultError(result)
+	}
+}
+
+// Set a root variable
+func (interpreter *Interpreter) SetVar(name string, value core.Value) error {
+	result := interpreter.scope.SetNamedVariable(name, value)
+	if result.Code != core.ResultCode_OK {
+		return newResultError(result)
+	}
+	return nil
+}
+
+// Get a root variable or constant
+func (interpreter *Interpreter) GetVar(name string) (core.Value, error) {
+	result := interpreter.scope.GetVariable(core.STR(name), nil)
+	if result.Code != core.ResultCode_OK {
+		return nil, newResultError(result)
+	}
+	return result.Value, nil
+}
+
+// Go function callable as a Helena command
+//
+// Arguments exclude the command name. Returned errors become ERROR results;
+// *Error values keep their original value
+type Func func(args []core.Value) (core.Value, error)
+
+type funcCommand struct {
+	fn Func
+}
+
+func (command funcCommand) Execute(args []core.Value, _ any) core.Result {
+	value, err := command.fn(args[1:])
+	if err != nil {
+		return errorResult(err)
+	}
+	if value == nil {
+		value = core.NIL
+	}
+	return core.OK(value)
+}
+
+// Register a Go function as a root command
+func (interpreter *Interpreter) RegisterFunc(name string, fn Func) {
+	interpreter.scope.RegisterNamedCommand(name, funcCommand{fn})
+}
+
+// Register a command as a root command
+func (interpreter *Interpreter) RegisterCommand(name string, command core.Command) {
+	interpreter.scope.RegisterNamedCommand(name, command)
+}
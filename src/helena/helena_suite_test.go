@@ -0,0 +1,23 @@
+package helena_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+)
+
+func TestHelena(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Helena Suite")
+}
+
+var NIL = core.NIL
+var INT = core.INT
+var STR = core.STR
+var LIST = core.LIST
+
+var OK = core.OK
+var ERROR = core.ERROR
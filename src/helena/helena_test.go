@@ -0,0 +1,154 @@
+package helena_test
+
+import (
+	"errors"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena"
+	"helena/native/go_os"
+)
+
+var _ = Describe("Interpreter", func() {
+	var interpreter *Interpreter
+
+	BeforeEach(func() {
+		interpreter = New(nil)
+	})
+
+	Describe("Eval", func() {
+		It("should return the script result", func() {
+			Expect(interpreter.Eval("set a 1; list ($a 2)")).To(Equal(
+				LIST([]core.Value{STR("1"), STR("2")}),
+			))
+		})
+		It("should accept top-level returns", func() {
+			Expect(interpreter.Eval("return val; unreachable")).To(Equal(STR("val")))
+		})
+		It("should keep state across evaluations", func() {
+			_, err := interpreter.Eval("macro cmd {} {idem val}")
+			Expect(err).To(BeNil())
+			Expect(interpreter.Eval("cmd")).To(Equal(STR("val")))
+		})
+		It("should return script errors as Error values", func() {
+			_, err := interpreter.Eval("error msg")
+			var scriptError *Error
+			Expect(errors.As(err, &scriptError)).To(BeTrue())
+			Expect(scriptError.Message).To(Equal("msg"))
+			Expect(scriptError.Value).To(Equal(STR("msg")))
+			Expect(scriptError.Stack).To(BeNil())
+		})
+		It("should extract messages from structured errors", func() {
+			interpreter.RegisterCommand("os", go_os.OsCmd{})
+			_, err := interpreter.Eval("os ReadFile /helena-missing-file")
+			var scriptError *Error
+			Expect(errors.As(err, &scriptError)).To(BeTrue())
+			Expect(scriptError.Message).To(Equal("open /helena-missing-file: no such file or directory"))
+			Expect(scriptError.Value.(core.DictionaryValue).Map["kind"]).To(Equal(STR("NotExist")))
+		})
+		It("should capture error stacks", func() {
+			interpreter = New(&Options{CaptureErrorStack: true, CapturePositions: true})
+			_, err := interpreter.Eval("macro cmd {} {error msg}\ncmd")
+			stack := err.(*Error).Stack
+			Expect(stack.Depth()).To(Equal(uint(2)))
+			Expect(stack.Level(1).Position.Line).To(Equal(uint(1)))
+		})
+		It("should return unexpected result codes as ResultError values", func() {
+			_, err := interpreter.Eval("break")
+			Expect(err).To(Equal(&ResultError{core.BREAK(NIL)}))
+			Expect(err.Error()).To(Equal("unexpected break"))
+		})
+		It("should return parsing errors as ParseError values", func() {
+			_, err := interpreter.Eval("{")
+			Expect(err).To(Equal(&ParseError{Message: "unmatched left brace"}))
+		})
+	})
+
+	Describe("EvalFile", func() {
+		BeforeEach(func() {
+			interpreter = New(&Options{FS: fstest.MapFS{
+				"main.lna":  {Data: []byte("import lib.lna (name); name")},
+				"lib.lna":   {Data: []byte("macro name {} {idem lib}; export name")},
+				"error.lna": {Data: []byte("{")},
+			}})
+		})
+
+		It("should evaluate files from the interpreter file system", func() {
+			Expect(interpreter.EvalFile("main.lna")).To(Equal(STR("lib")))
+		})
+		It("should report file names in parsing errors", func() {
+			_, err := interpreter.EvalFile("error.lna")
+			Expect(err).To(Equal(&ParseError{Message: "unmatched left brace", Filename: "error.lna"}))
+			Expect(err.Error()).To(Equal("error.lna: unmatched left brace"))
+		})
+		It("should return file system errors", func() {
+			_, err := interpreter.EvalFile("unknown.lna")
+			Expect(err).To(MatchError(ContainSubstring("file does not exist")))
+		})
+		It("should forbid paths outside of the file system", func() {
+			_, err := interpreter.EvalFile("../main.lna")
+			Expect(err).To(MatchError(`module path "../main.lna" escapes the module root`))
+		})
+	})
+
+	Describe("Call", func() {
+		It("should call commands with unsubstituted arguments", func() {
+			Expect(interpreter.Call("idem", STR("$a"))).To(Equal(STR("$a")))
+			Expect(interpreter.Call("string", STR("[b]"), STR("length"))).To(Equal(INT(3)))
+		})
+		It("should return errors", func() {
+			_, err := interpreter.Call("unknownCommand")
+			Expect(err).To(MatchError(`cannot resolve command "unknownCommand"`))
+		})
+	})
+
+	Describe("Variables", func() {
+		It("should be shared with scripts", func() {
+			Expect(interpreter.SetVar("a", STR("val"))).To(Succeed())
+			Expect(interpreter.Eval("get a")).To(Equal(STR("val")))
+			_, err := interpreter.Eval("set b val2")
+			Expect(err).To(BeNil())
+			Expect(interpreter.GetVar("b")).To(Equal(STR("val2")))
+		})
+		It("should return errors", func() {
+			_, err := interpreter.GetVar("unknown")
+			Expect(err).To(MatchError(`cannot get "unknown": no such variable`))
+			_, err = interpreter.Eval("let cst val")
+			Expect(err).To(BeNil())
+			Expect(interpreter.SetVar("cst", STR("val"))).To(MatchError(
+				`cannot redefine constant "cst"`,
+			))
+		})
+	})
+
+	Describe("RegisterFunc", func() {
+		It("should register Go functions as commands", func() {
+			interpreter.RegisterFunc("count", func(args []core.Value) (core.Value, error) {
+				return INT(int64(len(args))), nil
+			})
+			Expect(interpreter.Eval("count a b c")).To(Equal(INT(3)))
+		})
+		It("should return NIL for nil values", func() {
+			interpreter.RegisterFunc("noop", func([]core.Value) (core.Value, error) {
+				return nil, nil
+			})
+			Expect(interpreter.Eval("noop")).To(Equal(NIL))
+		})
+		It("should convert Go errors to script errors", func() {
+			interpreter.RegisterFunc("fail", func([]core.Value) (core.Value, error) {
+				return nil, errors.New("failure")
+			})
+			Expect(interpreter.Eval("catch {fail} error msg {idem $msg}")).To(Equal(STR("failure")))
+		})
+		It("should preserve the value of Error values", func() {
+			interpreter.RegisterFunc("fail", func([]core.Value) (core.Value, error) {
+				return nil, &Error{Message: "a b", Value: LIST([]core.Value{STR("a"), STR("b")})}
+			})
+			_, err := interpreter.Eval("fail")
+			Expect(err.(*Error).Value).To(Equal(LIST([]core.Value{STR("a"), STR("b")})))
+		})
+	})
+})
@@ -46,6 +46,7 @@ func (metacommand *aliasMetacommand) Resume(result core.Result, context any) cor
 }
 
 type aliasCommand struct {
+	commandOrigin
 	value       core.Value
 	cmd         core.Value
 	metacommand *aliasMetacommand
@@ -79,7 +80,7 @@ func (aliasCmd) Execute(args []core.Value, context any) core.Result {
 	name, cmd := args[1], args[2]
 
 	alias := newAliasCommand(cmd)
-	result := scope.RegisterCommand(name, alias)
+	result := scope.defineCommand(name, alias)
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -54,6 +54,13 @@ func (yieldCmd) Execute(args []core.Value, _ any) core.Result {
 		return core.YIELD(core.NIL)
 	}
 }
+func (yieldCmd) Resume(result core.Result, _ any) core.Result {
+	if result.Value == coroutineCloseSignal {
+		// Unwind closed coroutines
+		return core.CUSTOM_RESULT(closeResultCode, core.NIL)
+	}
+	return core.OK(result.Value)
+}
 func (yieldCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
 	if len(args) > 2 {
 		return ARITY_ERROR(YIELD_SIGNATURE)
@@ -85,6 +92,21 @@ func (errorCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.R
 	return core.OK(core.STR(ERROR_SIGNATURE))
 }
 
+// Return the message of an error value
+//
+// Structured errors such as those of go:os are dicts with a `message` key;
+// other values give their string representation, if any
+func ErrorMessage(value core.Value) string {
+	if dict, ok := value.(core.DictionaryValue); ok {
+		value = dict.Map["message"]
+		if value == nil {
+			return ""
+		}
+	}
+	_, s := core.ValueToStringOrDefault(value, "")
+	return s
+}
+
 const BREAK_SIGNATURE = "break"
 
 type breakCmd struct{}
@@ -119,6 +141,72 @@ func (continueCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) cor
 	return core.OK(core.STR(CONTINUE_SIGNATURE))
 }
 
+const RESULTCODE_SIGNATURE = "resultcode name"
+
+type resultcodeCmd struct{}
+
+func (resultcodeCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 2 {
+		return ARITY_ERROR(RESULTCODE_SIGNATURE)
+	}
+	result, name := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid result code name")
+	}
+	if isReservedResultCodeName(name) {
+		return core.ERROR(`cannot redefine result code "` + name + `"`)
+	}
+	command := newCustomResultCodeCommand(core.CustomResultCode{Name: name})
+	scope.RegisterNamedCommand(name, command)
+	return core.OK(command.value)
+}
+func (resultcodeCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(RESULTCODE_SIGNATURE)
+	}
+	return core.OK(core.STR(RESULTCODE_SIGNATURE))
+}
+
+type customResultCodeCommand struct {
+	value core.Value
+	code  core.CustomResultCode
+}
+
+func newCustomResultCodeCommand(code core.CustomResultCode) *customResultCodeCommand {
+	cmd := &customResultCodeCommand{}
+	cmd.value = core.NewCommandValue(cmd)
+	cmd.code = code
+	return cmd
+}
+
+func (cmd *customResultCodeCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(cmd.code.Name + " ?value?")
+	}
+	if len(args) == 2 {
+		return core.CUSTOM_RESULT(cmd.code, args[1])
+	} else {
+		return core.CUSTOM_RESULT(cmd.code, core.NIL)
+	}
+}
+func (cmd *customResultCodeCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(cmd.code.Name + " ?value?")
+	}
+	return core.OK(core.STR(cmd.code.Name + " ?value?"))
+}
+
+func isReservedResultCodeName(name string) bool {
+	switch name {
+	case "ok", "return", "yield", "error", "break", "continue",
+		passResultCode.Name, closeResultCode.Name:
+		return true
+	default:
+		return false
+	}
+}
+
 const EVAL_SIGNATURE = "eval body"
 
 type evalCmd struct{}
@@ -156,6 +244,16 @@ func (helpCmd) Execute(args []core.Value, context any) core.Result {
 	if len(args) < 2 {
 		return ARITY_ERROR(HELP_SIGNATURE)
 	}
+	if _, option := core.ValueToStringOrDefault(args[1], ""); option == "-search" {
+		if len(args) != 3 {
+			return ARITY_ERROR("help -search term")
+		}
+		result, term := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid search term")
+		}
+		return core.OK(sortedNameList(searchHelp(scope, term)))
+	}
 	command := scope.ResolveCommand(args[1])
 	if command == nil {
 		result, cmdname := core.ValueToString(args[1])
@@ -165,6 +263,9 @@ func (helpCmd) Execute(args []core.Value, context any) core.Result {
 			return core.ERROR("invalid command name")
 		}
 	}
+	if len(args) == 2 && hasDoc(command) {
+		return core.OK(core.STR(FullHelp(scope, args[1], command)))
+	}
 	if c, ok := command.(core.CommandWithHelp); ok {
 		return c.Help(args[1:], core.CommandHelpOptions{}, scope)
 	} else {
@@ -187,8 +288,10 @@ func registerBasicCommands(scope *Scope) {
 	scope.RegisterNamedCommand("error", errorCmd{})
 	scope.RegisterNamedCommand("break", breakCmd{})
 	scope.RegisterNamedCommand("continue", continueCmd{})
+	scope.RegisterNamedCommand("resultcode", resultcodeCmd{})
 	scope.RegisterNamedCommand("eval", evalCmd{})
 	scope.RegisterNamedCommand("help", helpCmd{})
+	scope.RegisterNamedCommand("doc", docCmd{})
 	scope.RegisterNamedCommand("^", core.LAST_RESULT)
 	scope.RegisterNamedCommand("|>", core.SHIFT_LAST_FRAME_RESULT)
 }
@@ -145,6 +145,16 @@ var _ = Describe("Helena basic commands", func() {
 			})
 		})
 
+		Describe("Go API", func() {
+			Specify("`ErrorMessage`", func() {
+				Expect(ErrorMessage(STR("msg"))).To(Equal("msg"))
+				Expect(ErrorMessage(INT(1))).To(Equal("1"))
+				Expect(ErrorMessage(evaluate("dict (message msg kind NotExist)"))).To(Equal("msg"))
+				Expect(ErrorMessage(evaluate("dict (kind NotExist)"))).To(Equal(""))
+				Expect(ErrorMessage(LIST([]core.Value{}))).To(Equal(""))
+			})
+		})
+
 		Describe("Exceptions", func() {
 			Specify("wrong arity", func() {
 				Expect(execute("error")).To(Equal(
@@ -209,6 +219,102 @@ var _ = Describe("Helena basic commands", func() {
 		})
 	})
 
+	Describe("resultcode", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help resultcode")).To(Equal(STR("resultcode name")))
+				Expect(evaluate("help resultcode name")).To(Equal(STR("resultcode name")))
+			})
+
+			It("should return a command object", func() {
+				Expect(evaluate("resultcode abort").Type()).To(Equal(core.ValueType_COMMAND))
+			})
+			It("should define a new command", func() {
+				evaluate("resultcode abort")
+				Expect(rootScope.ResolveNamedCommand("abort")).NotTo(BeNil())
+			})
+		})
+
+		Describe("Custom code command", func() {
+			Specify("usage", func() {
+				evaluate("resultcode abort")
+				Expect(evaluate("help abort")).To(Equal(STR("abort ?value?")))
+				Expect(evaluate("help abort val")).To(Equal(STR("abort ?value?")))
+			})
+
+			Specify("result code should be the declared custom code", func() {
+				evaluate("resultcode abort")
+				result := execute("abort")
+				Expect(result.Code).To(Equal(core.ResultCode_CUSTOM))
+				Expect(core.RESULT_CODE_NAME(result)).To(Equal("abort"))
+				Expect(
+					core.IsCustomResult(result, core.CustomResultCode{Name: "abort"}),
+				).To(BeTrue())
+			})
+			It("should return nil by default", func() {
+				evaluate("resultcode abort")
+				Expect(execute("abort").Value).To(Equal(NIL))
+			})
+			It("should return its optional `value` argument", func() {
+				evaluate("resultcode abort")
+				Expect(execute("abort val").Value).To(Equal(STR("val")))
+			})
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					evaluate("resultcode abort")
+					Expect(execute("abort a b")).To(Equal(
+						ERROR(`wrong # args: should be "abort ?value?"`),
+					))
+					Expect(execute("help abort a b")).To(Equal(
+						ERROR(`wrong # args: should be "abort ?value?"`),
+					))
+				})
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("resultcode")).To(Equal(
+					ERROR(`wrong # args: should be "resultcode name"`),
+				))
+				Expect(execute("resultcode a b")).To(Equal(
+					ERROR(`wrong # args: should be "resultcode name"`),
+				))
+				Expect(execute("help resultcode a b")).To(Equal(
+					ERROR(`wrong # args: should be "resultcode name"`),
+				))
+			})
+			Specify("invalid `name`", func() {
+				Expect(execute("resultcode []")).To(Equal(
+					ERROR("invalid result code name"),
+				))
+			})
+			Specify("standard result codes", func() {
+				Expect(execute("resultcode ok")).To(Equal(
+					ERROR(`cannot redefine result code "ok"`),
+				))
+				Expect(execute("resultcode return")).To(Equal(
+					ERROR(`cannot redefine result code "return"`),
+				))
+				Expect(execute("resultcode yield")).To(Equal(
+					ERROR(`cannot redefine result code "yield"`),
+				))
+				Expect(execute("resultcode error")).To(Equal(
+					ERROR(`cannot redefine result code "error"`),
+				))
+				Expect(execute("resultcode break")).To(Equal(
+					ERROR(`cannot redefine result code "break"`),
+				))
+				Expect(execute("resultcode continue")).To(Equal(
+					ERROR(`cannot redefine result code "continue"`),
+				))
+				Expect(execute("resultcode pass")).To(Equal(
+					ERROR(`cannot redefine result code "pass"`),
+				))
+			})
+		})
+	})
+
 	Describe("eval", func() {
 		Describe("Specifications", func() {
 			Specify("usage", func() {
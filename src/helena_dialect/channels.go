@@ -0,0 +1,473 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"reflect"
+)
+
+//
+// Channel values
+//
+// Channels wrap Go channels of Helena values. They are safe for concurrent
+// use and can be shared between interpreters; sent values are deep-copied.
+//
+
+var ChannelValueType = core.CustomValueType{Name: "channel"}
+
+type ChannelValue struct {
+	channel chan core.Value
+}
+
+// Create a new channel with the given buffer capacity
+func NewChannelValue(capacity int) ChannelValue {
+	return ChannelValue{make(chan core.Value, capacity)}
+}
+
+// Wrap an existing Go channel
+func ChannelValueFromChan(channel chan core.Value) ChannelValue {
+	return ChannelValue{channel}
+}
+
+func (ChannelValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (ChannelValue) CustomType() core.CustomValueType {
+	return ChannelValueType
+}
+func (ChannelValue) Shareable() {}
+func (value ChannelValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("channel")
+}
+
+// Return the underlying Go channel
+func (value ChannelValue) Chan() chan core.Value {
+	return value.channel
+}
+
+// Send a value, blocking until the channel is ready
+func (value ChannelValue) Send(v core.Value) core.Result {
+	wait := newChannelWaitValue([]channelOperation{{value, true, v}})
+	wait.Wait()
+	return wait.result
+}
+
+// Receive a value, blocking until the channel is ready
+//
+// Report false when the channel is closed
+func (value ChannelValue) Receive() (core.Value, bool) {
+	v, ok := <-value.channel
+	return v, ok
+}
+
+// Close the channel
+func (value ChannelValue) Close() (result core.Result) {
+	defer func() {
+		if recover() != nil {
+			result = CHANNEL_CLOSED_ERROR()
+		}
+	}()
+	close(value.channel)
+	return core.OK(core.NIL)
+}
+
+func CHANNEL_CLOSED_ERROR() core.Result { return core.ERROR("channel is closed") }
+
+func ValueToChannel(value core.Value) (core.Result, ChannelValue) {
+	if channel, ok := value.(ChannelValue); ok {
+		return core.OK(channel), channel
+	}
+	return core.ERROR("invalid channel"), ChannelValue{}
+}
+
+//
+// Channel wait values
+//
+// Channel wait values are yielded by channel operations that cannot complete
+// immediately. Resuming the yielding command retries the operation, unless a
+// driver has already completed it by calling Wait.
+//
+
+var ChannelWaitValueType = core.CustomValueType{Name: "channel wait"}
+
+type channelOperation struct {
+	channel ChannelValue
+	send    bool
+	value   core.Value
+}
+type ChannelWaitValue struct {
+	operations []channelOperation
+	done       bool
+	selected   int
+	result     core.Result
+}
+
+func newChannelWaitValue(operations []channelOperation) *ChannelWaitValue {
+	wait := &ChannelWaitValue{operations: operations, result: core.OK(core.NIL)}
+	for i, operation := range operations {
+		if !operation.send {
+			continue
+		}
+		result, value := DeepCopyValue(operation.value)
+		if result.Code != core.ResultCode_OK {
+			wait.done = true
+			wait.result = result
+			return wait
+		}
+		wait.operations[i].value = value
+	}
+	return wait
+}
+
+func (*ChannelWaitValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (*ChannelWaitValue) CustomType() core.CustomValueType {
+	return ChannelWaitValueType
+}
+func (wait *ChannelWaitValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(wait)
+	}
+	return core.UndisplayableValueWithLabel("channel wait")
+}
+
+// Report whether the operation has completed
+func (wait *ChannelWaitValue) Done() bool {
+	return wait.done
+}
+
+// Block until one of the operations completes and return its value
+func (wait *ChannelWaitValue) Wait() core.Value {
+	if !wait.done {
+		wait.perform(true)
+	}
+	return wait.result.Value
+}
+
+// Attempt to complete one of the operations without blocking
+func (wait *ChannelWaitValue) try() bool {
+	if !wait.done {
+		wait.perform(false)
+	}
+	return wait.done
+}
+
+func (wait *ChannelWaitValue) perform(block bool) {
+	cases := make([]reflect.SelectCase, 0, len(wait.operations)+1)
+	for _, operation := range wait.operations {
+		if operation.send {
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(operation.channel.channel),
+				Send: reflect.ValueOf(&operation.value).Elem(),
+			})
+		} else {
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(operation.channel.channel),
+			})
+		}
+	}
+	if !block {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+	defer func() {
+		if recover() != nil {
+			// Sending on a closed channel
+			wait.done = true
+			wait.result = CHANNEL_CLOSED_ERROR()
+		}
+	}()
+	chosen, received, ok := reflect.Select(cases)
+	if chosen == len(wait.operations) {
+		// Default case
+		return
+	}
+	wait.done = true
+	wait.selected = chosen
+	if wait.operations[chosen].send {
+		wait.result = core.OK(core.NIL)
+	} else if ok {
+		wait.result = core.OK(received.Interface().(core.Value))
+	} else {
+		wait.result = CHANNEL_CLOSED_ERROR()
+	}
+}
+
+// Perform the operation or yield the wait value until done
+func runChannelWait(wait *ChannelWaitValue) core.Result {
+	if !wait.try() {
+		return core.YIELD_STATE(wait, wait)
+	}
+	return wait.result
+}
+
+//
+// Channel commands
+//
+
+type chanCommand struct {
+	scope    *Scope
+	ensemble *EnsembleCommand
+}
+
+func newChanCommand(scope *Scope) *chanCommand {
+	chan_ := &chanCommand{}
+	chan_.scope = scope.NewChildScope()
+	_, argspec := ArgspecValueFromValue(core.LIST([]core.Value{core.STR("value")}))
+	chan_.ensemble = NewEnsembleCommand(chan_.scope, argspec)
+	return chan_
+}
+func (cmd *chanCommand) Execute(args []core.Value, context any) core.Result {
+	if len(args) == 2 {
+		if channel, ok := args[1].(ChannelValue); ok {
+			return core.OK(channel)
+		}
+		result, capacity := core.ValueToInteger(args[1])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if capacity < 0 {
+			return core.ERROR("invalid capacity")
+		}
+		return core.OK(NewChannelValue(int(capacity)))
+	}
+	return cmd.ensemble.Execute(args, context)
+}
+func (cmd *chanCommand) Resume(result core.Result, context any) core.Result {
+	return cmd.ensemble.Resume(result, context)
+}
+func (cmd *chanCommand) Help(args []core.Value, options core.CommandHelpOptions, context any) core.Result {
+	return cmd.ensemble.Help(args, options, context)
+}
+
+const CHAN_SEND_SIGNATURE = "chan value send message"
+
+type chanSendCmd struct{}
+
+func (chanSendCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 3 {
+		return ARITY_ERROR(CHAN_SEND_SIGNATURE)
+	}
+	result, channel := ValueToChannel(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return runChannelWait(
+		newChannelWaitValue([]channelOperation{{channel, true, args[2]}}),
+	)
+}
+func (chanSendCmd) Resume(result core.Result, _ any) core.Result {
+	return runChannelWait(result.Data.(*ChannelWaitValue))
+}
+func (chanSendCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(CHAN_SEND_SIGNATURE)
+	}
+	return core.OK(core.STR(CHAN_SEND_SIGNATURE))
+}
+
+const CHAN_RECEIVE_SIGNATURE = "chan value receive"
+
+type chanReceiveCmd struct{}
+
+func (chanReceiveCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 2 {
+		return ARITY_ERROR(CHAN_RECEIVE_SIGNATURE)
+	}
+	result, channel := ValueToChannel(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return runChannelWait(newChannelWaitValue([]channelOperation{{channel, false, nil}}))
+}
+func (chanReceiveCmd) Resume(result core.Result, _ any) core.Result {
+	return runChannelWait(result.Data.(*ChannelWaitValue))
+}
+func (chanReceiveCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(CHAN_RECEIVE_SIGNATURE)
+	}
+	return core.OK(core.STR(CHAN_RECEIVE_SIGNATURE))
+}
+
+const CHAN_CLOSE_SIGNATURE = "chan value close"
+
+type chanCloseCmd struct{}
+
+func (chanCloseCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 2 {
+		return ARITY_ERROR(CHAN_CLOSE_SIGNATURE)
+	}
+	result, channel := ValueToChannel(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return channel.Close()
+}
+func (chanCloseCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(CHAN_CLOSE_SIGNATURE)
+	}
+	return core.OK(core.STR(CHAN_CLOSE_SIGNATURE))
+}
+
+const CHAN_LENGTH_SIGNATURE = "chan value length"
+
+type chanLengthCmd struct{}
+
+func (chanLengthCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 2 {
+		return ARITY_ERROR(CHAN_LENGTH_SIGNATURE)
+	}
+	result, channel := ValueToChannel(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return core.OK(core.INT(int64(len(channel.channel))))
+}
+func (chanLengthCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(CHAN_LENGTH_SIGNATURE)
+	}
+	return core.OK(core.STR(CHAN_LENGTH_SIGNATURE))
+}
+
+const CHAN_CAPACITY_SIGNATURE = "chan value capacity"
+
+type chanCapacityCmd struct{}
+
+func (chanCapacityCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 2 {
+		return ARITY_ERROR(CHAN_CAPACITY_SIGNATURE)
+	}
+	result, channel := ValueToChannel(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return core.OK(core.INT(int64(cap(channel.channel))))
+}
+func (chanCapacityCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(CHAN_CAPACITY_SIGNATURE)
+	}
+	return core.OK(core.STR(CHAN_CAPACITY_SIGNATURE))
+}
+
+const SELECT_SIGNATURE = "select ?receive channel varname body ...? ?send channel message body ...? ?default body?"
+
+type selectCmd struct{}
+type selectCmdState struct {
+	scope   *Scope
+	wait    *ChannelWaitValue
+	bodies  []core.Value
+	varname []string
+}
+
+func (cmd selectCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	operations := []channelOperation{}
+	state := &selectCmdState{scope: scope}
+	var defaultBody core.Value
+	i := 1
+	for i < len(args) {
+		result, keyword := core.ValueToString(args[i])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid keyword")
+		}
+		switch keyword {
+		case "receive",
+			"send":
+			if len(args)-i < 4 {
+				return core.ERROR(`wrong #args: missing ` + keyword + ` case body`)
+			}
+			result, channel := ValueToChannel(args[i+1])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			body := args[i+3]
+			if body.Type() != core.ValueType_SCRIPT {
+				return core.ERROR("body must be a script")
+			}
+			if keyword == "receive" {
+				result, varname := core.ValueToString(args[i+2])
+				if result.Code != core.ResultCode_OK {
+					return core.ERROR("invalid variable name")
+				}
+				operations = append(operations, channelOperation{channel, false, nil})
+				state.varname = append(state.varname, varname)
+			} else {
+				operations = append(operations, channelOperation{channel, true, args[i+2]})
+				state.varname = append(state.varname, "")
+			}
+			state.bodies = append(state.bodies, body)
+			i += 4
+		case "default":
+			if len(args)-i < 2 {
+				return core.ERROR(`wrong #args: missing default body`)
+			}
+			if len(args)-i > 2 {
+				return core.ERROR("default must be the last case")
+			}
+			defaultBody = args[i+1]
+			if defaultBody.Type() != core.ValueType_SCRIPT {
+				return core.ERROR("body must be a script")
+			}
+			i += 2
+		default:
+			return core.ERROR(`invalid keyword "` + keyword + `"`)
+		}
+	}
+	if len(operations) == 0 && defaultBody == nil {
+		return ARITY_ERROR(SELECT_SIGNATURE)
+	}
+	state.wait = newChannelWaitValue(operations)
+	if state.wait.done {
+		return state.wait.result
+	}
+	if !state.wait.try() && defaultBody != nil {
+		program := scope.CompileScriptValue(defaultBody.(core.ScriptValue))
+		return CreateContinuationValue(scope, program)
+	}
+	return cmd.run(state)
+}
+func (cmd selectCmd) Resume(result core.Result, _ any) core.Result {
+	state := result.Data.(*selectCmdState)
+	state.wait.try()
+	return cmd.run(state)
+}
+func (selectCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	return core.OK(core.STR(SELECT_SIGNATURE))
+}
+func (selectCmd) run(state *selectCmdState) core.Result {
+	if !state.wait.done {
+		return core.YIELD_STATE(state.wait, state)
+	}
+	if state.wait.result.Code != core.ResultCode_OK {
+		return state.wait.result
+	}
+	body := state.bodies[state.wait.selected]
+	scope := state.scope
+	if varname := state.varname[state.wait.selected]; varname != "" {
+		scope = scope.NewLocalScope(
+			map[string]uint{varname: 0},
+			[]core.Value{state.wait.result.Value},
+		)
+	}
+	program := scope.CompileScriptValue(body.(core.ScriptValue))
+	return CreateContinuationValue(scope, program)
+}
+
+func registerChannelCommands(scope *Scope) {
+	command := newChanCommand(scope)
+	scope.RegisterNamedCommand("chan", command)
+	command.scope.RegisterNamedCommand("send", chanSendCmd{})
+	command.scope.RegisterNamedCommand("receive", chanReceiveCmd{})
+	command.scope.RegisterNamedCommand("close", chanCloseCmd{})
+	command.scope.RegisterNamedCommand("length", chanLengthCmd{})
+	command.scope.RegisterNamedCommand("capacity", chanCapacityCmd{})
+	scope.RegisterNamedCommand("select", selectCmd{})
+}
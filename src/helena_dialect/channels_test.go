@@ -0,0 +1,286 @@
+package helena_dialect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena channels", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("chan", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help chan")).To(Equal(
+					STR("chan value ?subcommand? ?arg ...?"),
+				))
+			})
+
+			It("should create channels with the given capacity", func() {
+				evaluate("set c [chan 2]")
+				Expect(evaluate("get c").(core.CustomValue).CustomType()).To(Equal(
+					ChannelValueType,
+				))
+				Expect(evaluate("chan $c capacity")).To(Equal(INT(2)))
+				Expect(evaluate("chan $c length")).To(Equal(INT(0)))
+			})
+			It("should return channels as is", func() {
+				evaluate("set c [chan 0]")
+				Expect(evaluate("chan $c")).To(Equal(evaluate("get c")))
+			})
+		})
+
+		Describe("Subcommands", func() {
+			Describe("send/receive", func() {
+				It("should transfer values in order", func() {
+					evaluate("set c [chan 2]")
+					Expect(execute("chan $c send a")).To(Equal(OK(NIL)))
+					Expect(execute("chan $c send (b c)")).To(Equal(OK(NIL)))
+					Expect(evaluate("chan $c length")).To(Equal(INT(2)))
+					Expect(evaluate("chan $c receive")).To(Equal(STR("a")))
+					Expect(evaluate("chan $c receive")).To(Equal(
+						TUPLE([]core.Value{STR("b"), STR("c")}),
+					))
+				})
+				It("should yield when the channel is not ready", func() {
+					evaluate("set c [chan 0]")
+					process := prepareScript("chan $c receive")
+					result := process.Run()
+					Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+					Expect(result.Value.(core.CustomValue).CustomType()).To(Equal(
+						ChannelWaitValueType,
+					))
+
+					channel := evaluate("get c").(ChannelValue)
+					go channel.Send(STR("value"))
+					Expect(process.RunAndWait()).To(Equal(OK(STR("value"))))
+				})
+				It("should retry pending operations on resumption", func() {
+					evaluate("set c [chan 1]")
+					evaluate("chan $c send a")
+					process := prepareScript("chan $c send b")
+					Expect(process.Run().Code).To(Equal(core.ResultCode_YIELD))
+					Expect(process.Run().Code).To(Equal(core.ResultCode_YIELD))
+					Expect(evaluate("chan $c receive")).To(Equal(STR("a")))
+					Expect(process.Run()).To(Equal(OK(NIL)))
+					Expect(evaluate("chan $c receive")).To(Equal(STR("b")))
+				})
+				It("should yield from coroutines instead of blocking", func() {
+					evaluate("set c [chan 0]")
+					evaluate("set cr [coroutine {set v [chan $c receive]; idem got-$v}]")
+					result := execute("$cr wait")
+					Expect(result.Code).To(Equal(core.ResultCode_OK))
+					Expect(evaluate("$cr done")).To(Equal(FALSE))
+
+					channel := evaluate("get c").(ChannelValue)
+					go channel.Send(STR("value"))
+					result.Value.(WaitableValue).Wait()
+					Expect(evaluate("$cr wait")).To(Equal(STR("got-value")))
+					Expect(evaluate("$cr done")).To(Equal(TRUE))
+				})
+				It("should deep-copy sent values", func() {
+					evaluate("set c [chan 1]")
+					evaluate("chan $c send {idem a}")
+					channel := evaluate("get c").(ChannelValue)
+					value, ok := channel.Receive()
+					Expect(ok).To(BeTrue())
+					Expect(value.(core.ScriptValue).Cache.Program).To(BeNil())
+				})
+			})
+
+			Describe("close", func() {
+				It("should close the channel", func() {
+					evaluate("set c [chan 1]")
+					evaluate("chan $c send a")
+					Expect(execute("chan $c close")).To(Equal(OK(NIL)))
+					Expect(evaluate("chan $c receive")).To(Equal(STR("a")))
+					Expect(execute("chan $c receive")).To(Equal(
+						ERROR("channel is closed"),
+					))
+				})
+				It("should wake up pending receives", func() {
+					evaluate("set c [chan 0]")
+					process := prepareScript("chan $c receive")
+					Expect(process.Run().Code).To(Equal(core.ResultCode_YIELD))
+					evaluate("chan $c close")
+					Expect(process.Run()).To(Equal(ERROR("channel is closed")))
+				})
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("invalid capacity", func() {
+				Expect(execute("chan a")).To(Equal(ERROR(`invalid integer "a"`)))
+				Expect(execute("chan -1")).To(Equal(ERROR("invalid capacity")))
+			})
+			Specify("invalid channel", func() {
+				Expect(execute("chan a receive")).To(Equal(ERROR("invalid channel")))
+			})
+			Specify("wrong arity", func() {
+				evaluate("set c [chan 0]")
+				Expect(execute("chan $c send")).To(Equal(
+					ERROR(`wrong # args: should be "chan value send message"`),
+				))
+				Expect(execute("chan $c receive a")).To(Equal(
+					ERROR(`wrong # args: should be "chan value receive"`),
+				))
+				Expect(execute("chan $c close a")).To(Equal(
+					ERROR(`wrong # args: should be "chan value close"`),
+				))
+			})
+			Specify("closed channel", func() {
+				evaluate("set c [chan 1]")
+				evaluate("chan $c close")
+				Expect(execute("chan $c send a")).To(Equal(ERROR("channel is closed")))
+				Expect(execute("chan $c close")).To(Equal(ERROR("channel is closed")))
+			})
+			Specify("non-shareable messages", func() {
+				evaluate("set c [chan 1]")
+				Expect(execute("chan $c send [macro {} {}]")).To(Equal(
+					ERROR("value cannot be shared across interpreters"),
+				))
+			})
+		})
+	})
+
+	Describe("select", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help select")).To(Equal(
+					STR(
+						"select ?receive channel varname body ...? ?send channel message body ...? ?default body?",
+					),
+				))
+			})
+
+			It("should run the body of the first ready case", func() {
+				evaluate("set c1 [chan 1]; set c2 [chan 1]")
+				evaluate("chan $c2 send val")
+				Expect(
+					evaluate(
+						"select receive $c1 v {idem c1-$v} receive $c2 v {idem c2-$v}",
+					),
+				).To(Equal(STR("c2-val")))
+				Expect(
+					evaluate("select send $c1 msg {idem sent}"),
+				).To(Equal(STR("sent")))
+				Expect(evaluate("chan $c1 receive")).To(Equal(STR("msg")))
+			})
+			It("should run the default body when no case is ready", func() {
+				evaluate("set c [chan 0]")
+				Expect(
+					evaluate("select receive $c v {idem received} default {idem none}"),
+				).To(Equal(STR("none")))
+				Expect(evaluate("select default {idem none}")).To(Equal(STR("none")))
+			})
+			It("should yield when no case is ready", func() {
+				evaluate("set c [chan 0]")
+				process := prepareScript("select receive $c v {idem received-$v}")
+				Expect(process.Run().Code).To(Equal(core.ResultCode_YIELD))
+				channel := evaluate("get c").(ChannelValue)
+				go channel.Send(STR("value"))
+				Expect(process.RunAndWait()).To(Equal(OK(STR("received-value"))))
+			})
+			It("should not leak the receive variable", func() {
+				evaluate("set c [chan 1]; chan $c send val")
+				evaluate("select receive $c v {idem $v}")
+				Expect(execute("get v").Code).To(Equal(core.ResultCode_ERROR))
+			})
+		})
+
+		Describe("Control flow", func() {
+			It("should propagate body results", func() {
+				evaluate("set c [chan 1]; chan $c send val")
+				Expect(execute("select receive $c v {error msg}")).To(Equal(ERROR("msg")))
+				Expect(execute("select default {break}")).To(Equal(BREAK(NIL)))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("select")).To(Equal(
+					ERROR(
+						`wrong # args: should be "select ?receive channel varname body ...? ?send channel message body ...? ?default body?"`,
+					),
+				))
+				Expect(execute("select receive a b")).To(Equal(
+					ERROR("wrong #args: missing receive case body"),
+				))
+				Expect(execute("select default")).To(Equal(
+					ERROR("wrong #args: missing default body"),
+				))
+			})
+			Specify("invalid keyword", func() {
+				Expect(execute("select foo a b c")).To(Equal(
+					ERROR(`invalid keyword "foo"`),
+				))
+			})
+			Specify("misplaced default", func() {
+				evaluate("set c [chan 0]")
+				Expect(execute("select default {} receive $c v {}")).To(Equal(
+					ERROR("default must be the last case"),
+				))
+			})
+			Specify("closed channel", func() {
+				evaluate("set c [chan 0]; chan $c close")
+				Expect(execute("select receive $c v {}")).To(Equal(
+					ERROR("channel is closed"),
+				))
+			})
+		})
+	})
+
+	Describe("parallel integration", func() {
+		It("should exchange values between interpreters", func() {
+			Expect(
+				evaluate(`
+					set c [chan 0]
+					parallel (c $c) (
+						{
+							set i 0
+							while {$i < 3} {chan $c send $i; set i [+ $i 1]}
+							chan $c close
+						}
+						{
+							set sum 0
+							set i 0
+							while {$i < 3} {
+								set sum [+ $sum [chan $c receive]]
+								set i [+ $i 1]
+							}
+							idem $sum
+						}
+					)
+				`),
+			).To(Equal(LIST([]core.Value{NIL, INT(3)})))
+		})
+	})
+})
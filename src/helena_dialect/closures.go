@@ -75,6 +75,8 @@ func CLOSURE_HELP_SIGNATURE(name core.Value, argspec ArgspecValue, options core.
 }
 
 type closureCommand struct {
+	commandOrigin
+	commandDoc
 	value       core.Value
 	metacommand *closureMetacommand
 	scope       *Scope
@@ -94,6 +96,7 @@ func newClosureCommand(
 	closure.scope = scope
 	closure.argspec = argspec
 	closure.body = body
+	closure.doc = ExtractDocString(body.Script)
 	closure.guard = guard
 	closure.metacommand = newClosureMetacommand(closure)
 	return closure
@@ -175,7 +178,7 @@ func (closureCmd) Execute(args []core.Value, context any) core.Result {
 		guard,
 	)
 	if name != nil {
-		result := scope.RegisterCommand(name, closure)
+		result := scope.defineCommand(name, closure)
 		if result.Code != core.ResultCode_OK {
 			return result
 		}
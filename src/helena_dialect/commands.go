@@ -0,0 +1,188 @@
+package helena_dialect
+
+import "helena/core"
+
+// Commands that track the deletion of the binding that defined them
+//
+// Deleted commands may still be referenced by command values, e.g. the
+// metacommand of a proc stored in a variable or the target of an alias.
+// Executing them through such stale references then fails with a clear error.
+// Other bindings of the same command, e.g. imported aliases, are unaffected.
+type DeletableCommand interface {
+	core.Command
+
+	// Return the name the defining binding was deleted under, if any
+	DeletedName() (string, bool)
+}
+
+type deletedNamer interface {
+	DeletedName() (string, bool)
+}
+
+// Deletion state of a scope binding
+type commandDeletion struct {
+	deleted bool
+	name    string
+}
+
+// Command bound to a name in a scope
+//
+// Each registration gets its own binding so that unregistering a name only
+// affects the references that went through it
+type commandBinding struct {
+	command  core.Command
+	deletion *commandDeletion
+}
+
+func newCommandBinding(command core.Command) commandBinding {
+	return commandBinding{command, &commandDeletion{}}
+}
+func (commandBinding) Type() core.ValueType {
+	return core.ValueType_COMMAND
+}
+func (binding commandBinding) Command() core.Command {
+	return binding.command
+}
+
+// Defining binding embedded in script-defined commands
+type commandOrigin struct {
+	binding *commandDeletion
+}
+
+type definableCommand interface {
+	core.Command
+	define(binding *commandDeletion)
+}
+
+func (origin *commandOrigin) define(binding *commandDeletion) {
+	origin.binding = binding
+}
+func (origin *commandOrigin) DeletedName() (string, bool) {
+	if origin.binding == nil {
+		return "", false
+	}
+	return origin.binding.name, origin.binding.deleted
+}
+
+func (metacommand *procMetacommand) DeletedName() (string, bool) {
+	return metacommand.proc.DeletedName()
+}
+func (metacommand *closureMetacommand) DeletedName() (string, bool) {
+	return metacommand.closure.DeletedName()
+}
+func (metacommand *macroMetacommand) DeletedName() (string, bool) {
+	return metacommand.macro.DeletedName()
+}
+func (metacommand *aliasMetacommand) DeletedName() (string, bool) {
+	return metacommand.alias.DeletedName()
+}
+func (metacommand *ensembleMetacommand) DeletedName() (string, bool) {
+	return metacommand.ensemble.DeletedName()
+}
+func (metacommand *namespaceMetacommand) DeletedName() (string, bool) {
+	return metacommand.namespace.DeletedName()
+}
+
+// Placeholder resolved from stale references to deleted commands
+type deletedCommand struct {
+	name string
+}
+
+func (command deletedCommand) Execute(_ []core.Value, _ any) core.Result {
+	return core.ERROR(`command "` + command.name + `" has been deleted`)
+}
+
+// Resolve the scope and local name of a possibly namespace-qualified command
+//
+// Qualified names are tuples whose leading elements designate nested
+// namespaces, e.g. `(ns sub cmd)`
+func resolveCommandTarget(scope *Scope, target core.Value) (core.Result, *Scope, string) {
+	path := []core.Value{target}
+	if target.Type() == core.ValueType_TUPLE {
+		path = target.(core.TupleValue).Values
+		if len(path) == 0 {
+			return core.ERROR("invalid command name"), nil, ""
+		}
+	}
+	for i, element := range path[:len(path)-1] {
+		var command core.Command
+		if i == 0 {
+			command = scope.ResolveCommand(element)
+		} else if element.Type() == core.ValueType_COMMAND {
+			command = element.(core.CommandValue).Command()
+		} else {
+			result, name := core.ValueToString(element)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid namespace"), nil, ""
+			}
+			if value := scope.ResolveLocalCommand(name); value != nil {
+				command = value.Command()
+			}
+		}
+		switch namespace := command.(type) {
+		case *namespaceCommand:
+			scope = namespace.scope
+		case *namespaceMetacommand:
+			scope = namespace.namespace.scope
+		default:
+			result, name := core.ValueToString(element)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid namespace"), nil, ""
+			}
+			return core.ERROR(`cannot resolve namespace "` + name + `"`), nil, ""
+		}
+	}
+	result, name := core.ValueToString(path[len(path)-1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid command name"), nil, ""
+	}
+	return core.OK(core.NIL), scope, name
+}
+
+const RENAME_SIGNATURE = "rename cmdname newname"
+
+type renameCmd struct{}
+
+func (renameCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 3 {
+		return ARITY_ERROR(RENAME_SIGNATURE)
+	}
+	result, target, name := resolveCommandTarget(scope, args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	result, newName := core.ValueToString(args[2])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid command name")
+	}
+	return target.RenameNamedCommand(name, newName)
+}
+func (renameCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(RENAME_SIGNATURE)
+	}
+	return core.OK(core.STR(RENAME_SIGNATURE))
+}
+
+const UNREGISTER_SIGNATURE = "unregister cmdname"
+
+type unregisterCmd struct{}
+
+func (unregisterCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 2 {
+		return ARITY_ERROR(UNREGISTER_SIGNATURE)
+	}
+	result, target, name := resolveCommandTarget(scope, args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return target.UnregisterNamedCommand(name)
+}
+func (unregisterCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(UNREGISTER_SIGNATURE)
+	}
+	return core.OK(core.STR(UNREGISTER_SIGNATURE))
+}
@@ -0,0 +1,242 @@
+package helena_dialect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena command lifecycle", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("rename", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help rename")).To(Equal(STR("rename cmdname newname")))
+			})
+
+			It("should rename the command", func() {
+				evaluate("macro cmd {} {idem val}")
+				Expect(execute("rename cmd cmd2")).To(Equal(OK(NIL)))
+				Expect(evaluate("cmd2")).To(Equal(STR("val")))
+				Expect(execute("cmd")).To(Equal(ERROR(`cannot resolve command "cmd"`)))
+			})
+			It("should keep existing references valid", func() {
+				evaluate("set m [macro cmd {} {idem val}]")
+				evaluate("alias a [$m]")
+				evaluate("rename cmd cmd2")
+				Expect(evaluate("a")).To(Equal(STR("val")))
+			})
+			It("should accept namespace-qualified names", func() {
+				evaluate("namespace ns1 {namespace ns2 {macro cmd {} {idem val}}}")
+				Expect(execute("rename (ns1 ns2 cmd) cmd2")).To(Equal(OK(NIL)))
+				Expect(evaluate("ns1 ns2 cmd2")).To(Equal(STR("val")))
+				Expect(execute("ns1 ns2 cmd")).To(Equal(
+					ERROR(`unknown subcommand "cmd"`),
+				))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("rename a")).To(Equal(
+					ERROR(`wrong # args: should be "rename cmdname newname"`),
+				))
+				Expect(execute("help rename a b c")).To(Equal(
+					ERROR(`wrong # args: should be "rename cmdname newname"`),
+				))
+			})
+			Specify("unknown command", func() {
+				Expect(execute("rename unknownCommand cmd")).To(Equal(
+					ERROR(`cannot resolve command "unknownCommand"`),
+				))
+			})
+			Specify("non-local command", func() {
+				Expect(execute("scope s {rename idem cmd}")).To(Equal(
+					ERROR(`cannot resolve command "idem"`),
+				))
+			})
+			Specify("existing command", func() {
+				evaluate("macro cmd {} {}")
+				Expect(execute("rename cmd idem")).To(Equal(
+					ERROR(`command "idem" already exists`),
+				))
+			})
+			Specify("invalid command name", func() {
+				evaluate("macro cmd {} {}")
+				Expect(execute("rename [] cmd2")).To(Equal(ERROR("invalid command name")))
+				Expect(execute("rename cmd []")).To(Equal(ERROR("invalid command name")))
+				Expect(execute("rename () cmd2")).To(Equal(ERROR("invalid command name")))
+			})
+			Specify("unknown namespace", func() {
+				Expect(execute("rename (unknownNamespace cmd) cmd2")).To(Equal(
+					ERROR(`cannot resolve namespace "unknownNamespace"`),
+				))
+				Expect(execute("rename (idem cmd) cmd2")).To(Equal(
+					ERROR(`cannot resolve namespace "idem"`),
+				))
+			})
+		})
+	})
+
+	Describe("unregister", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help unregister")).To(Equal(STR("unregister cmdname")))
+			})
+
+			It("should remove the command", func() {
+				evaluate("macro cmd {} {}")
+				Expect(execute("unregister cmd")).To(Equal(OK(NIL)))
+				Expect(execute("cmd")).To(Equal(ERROR(`cannot resolve command "cmd"`)))
+			})
+			It("should unshadow parent commands", func() {
+				evaluate("macro cmd {} {idem root}")
+				evaluate("scope s {macro cmd {} {idem local}}")
+				Expect(evaluate("s eval {cmd}")).To(Equal(STR("local")))
+				evaluate("s eval {unregister cmd}")
+				Expect(evaluate("s eval {cmd}")).To(Equal(STR("root")))
+			})
+			It("should accept namespace-qualified names", func() {
+				evaluate("namespace ns {macro cmd {} {}}")
+				Expect(execute("unregister (ns cmd)")).To(Equal(OK(NIL)))
+				Expect(execute("ns cmd")).To(Equal(ERROR(`unknown subcommand "cmd"`)))
+			})
+			It("should break aliases to the command", func() {
+				evaluate("macro cmd {} {}")
+				evaluate("alias a cmd")
+				evaluate("unregister cmd")
+				Expect(execute("a")).To(Equal(ERROR(`cannot resolve command "cmd"`)))
+			})
+
+			Describe("Stale references", func() {
+				Specify("metacommands", func() {
+					evaluate("set m [proc cmd {} {}]")
+					evaluate("unregister cmd")
+					Expect(execute("$m argspec")).To(Equal(
+						ERROR(`command "cmd" has been deleted`),
+					))
+				})
+				Specify("aliased command values", func() {
+					evaluate("set m [closure cmd {} {}]")
+					evaluate("alias a [$m]")
+					evaluate("unregister cmd")
+					Expect(execute("a")).To(Equal(
+						ERROR(`command "cmd" has been deleted`),
+					))
+				})
+				Specify("ensembles", func() {
+					evaluate("set e [ensemble cmd {} {macro sub {} {}}]")
+					evaluate("unregister cmd")
+					Expect(execute("$e eval {}")).To(Equal(
+						ERROR(`command "cmd" has been deleted`),
+					))
+				})
+				Specify("namespaces", func() {
+					evaluate("set ns [namespace cmd {}]")
+					evaluate("unregister cmd")
+					Expect(execute("$ns eval {}")).To(Equal(
+						ERROR(`command "cmd" has been deleted`),
+					))
+				})
+				Specify("other bindings", func() {
+					evaluate("set ns [namespace ns {}]")
+					evaluate("set m [$ns eval {macro cmd {} {idem v}}]")
+					evaluate("$ns import cmd a")
+					evaluate("unregister a")
+					Expect(execute("$m argspec").Code).To(Equal(core.ResultCode_OK))
+					Expect(evaluate("ns cmd")).To(Equal(STR("v")))
+
+					evaluate("$ns import cmd a")
+					evaluate("unregister (ns cmd)")
+					Expect(execute("$m argspec")).To(Equal(
+						ERROR(`command "cmd" has been deleted`),
+					))
+					Expect(evaluate("a")).To(Equal(STR("v")))
+				})
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("unregister")).To(Equal(
+					ERROR(`wrong # args: should be "unregister cmdname"`),
+				))
+				Expect(execute("help unregister a b")).To(Equal(
+					ERROR(`wrong # args: should be "unregister cmdname"`),
+				))
+			})
+			Specify("unknown command", func() {
+				Expect(execute("unregister unknownCommand")).To(Equal(
+					ERROR(`cannot resolve command "unknownCommand"`),
+				))
+			})
+			Specify("invalid command name", func() {
+				Expect(execute("unregister []")).To(Equal(ERROR("invalid command name")))
+			})
+		})
+	})
+
+	Describe("info exists", func() {
+		It("should check command existence", func() {
+			evaluate("macro cmd {} {}")
+			evaluate("namespace ns {macro sub {} {}}")
+			Expect(evaluate("info exists cmd")).To(Equal(TRUE))
+			Expect(evaluate("info exists idem")).To(Equal(TRUE))
+			Expect(evaluate("info exists unknownCommand")).To(Equal(FALSE))
+			Expect(evaluate("info exists (ns sub)")).To(Equal(TRUE))
+			Expect(evaluate("info exists (ns unknownCommand)")).To(Equal(FALSE))
+			Expect(evaluate("info exists (unknownNamespace sub)")).To(Equal(FALSE))
+		})
+		It("should report deleted commands", func() {
+			evaluate("set m [macro cmd {} {}]")
+			Expect(evaluate("info exists $m")).To(Equal(TRUE))
+			evaluate("unregister cmd")
+			Expect(evaluate("info exists cmd")).To(Equal(FALSE))
+			Expect(evaluate("info exists $m")).To(Equal(FALSE))
+		})
+	})
+
+	Describe("Go API", func() {
+		It("should report deleted commands", func() {
+			evaluate("macro cmd {} {}")
+			Expect(rootScope.HasLocalCommand("cmd")).To(BeTrue())
+			Expect(rootScope.RenameNamedCommand("cmd", "cmd2")).To(Equal(OK(NIL)))
+			command := rootScope.ResolveNamedCommand("cmd2").(DeletableCommand)
+			_, deleted := command.DeletedName()
+			Expect(deleted).To(BeFalse())
+			Expect(rootScope.UnregisterNamedCommand("cmd2")).To(Equal(OK(NIL)))
+			Expect(rootScope.HasLocalCommand("cmd2")).To(BeFalse())
+			name, deleted := command.DeletedName()
+			Expect(deleted).To(BeTrue())
+			Expect(name).To(Equal("cmd2"))
+		})
+	})
+})
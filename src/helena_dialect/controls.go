@@ -17,6 +17,7 @@ type loopCmdState struct {
 	index         string
 	varnames      []core.Value
 	sources       []LoopSourceFn
+	iterators     []core.Value
 	activeSources int
 	i             int
 	iSource       int
@@ -68,6 +69,7 @@ func (loopCmd) Execute(args []core.Value, context any) core.Result {
 	}
 	subscope := scope.NewLocalScope(slots, nil)
 	sources := make([]LoopSourceFn, nbSources)
+	iterators := make([]core.Value, nbSources)
 	for i, iSource := firstSource, 0; i < len(args)-1; i, iSource = i+2, iSource+1 {
 		source := args[i+1]
 		switch source.Type() {
@@ -108,6 +110,16 @@ func (loopCmd) Execute(args []core.Value, context any) core.Result {
 			}
 		default:
 			{
+				if iterator := resolveIterator(scope, source); iterator != nil {
+					if iterator.IsClosable() {
+						iterators[iSource] = source
+					}
+					program := subscope.CompileArgs([]core.Value{source, core.STR("next")})
+					sources[iSource] = func(i int, data any, callback ContinuationCallback) core.Result {
+						return CreateContinuationValueWithCallback(subscope, program, data, callback)
+					}
+					continue
+				}
 				if scope.ResolveCommand(source) == nil {
 					return core.ERROR("invalid source")
 				}
@@ -127,6 +139,7 @@ func (loopCmd) Execute(args []core.Value, context any) core.Result {
 	state.index = index
 	state.varnames = varnames
 	state.sources = sources
+	state.iterators = iterators
 	state.activeSources = len(sources)
 	state.i = 0
 	state.iSource = -1
@@ -161,14 +174,14 @@ func loopCmdNextSource(state *loopCmdState) core.Result {
 		switch result.Code {
 		case core.ResultCode_BREAK:
 			state.sources[state.iSource] = nil
+			state.iterators[state.iSource] = nil
 			state.activeSources--
 			return loopCmdNextSource(state)
 		case core.ResultCode_CONTINUE:
 			return loopCmdNextSource(state)
 		case core.ResultCode_OK:
 		default:
-			loopCmdStatePool.Put(state)
-			return result
+			return loopCmdFinish(state, result)
 		}
 		value := result.Value
 		result2 := DestructureValue(
@@ -179,8 +192,7 @@ func loopCmdNextSource(state *loopCmdState) core.Result {
 			value,
 		)
 		if result2.Code != core.ResultCode_OK {
-			loopCmdStatePool.Put(state)
-			return result2
+			return loopCmdFinish(state, result2)
 		}
 		return loopCmdNextSource(state)
 	})
@@ -191,18 +203,34 @@ func loopCmdBody(state *loopCmdState) core.Result {
 		state := data.(*loopCmdState)
 		switch result.Code {
 		case core.ResultCode_BREAK:
-			loopCmdStatePool.Put(state)
-			return state.lastResult
+			return loopCmdFinish(state, state.lastResult)
 		case core.ResultCode_CONTINUE:
 		case core.ResultCode_OK:
 			state.lastResult = result
 		default:
-			loopCmdStatePool.Put(state)
-			return result
+			return loopCmdFinish(state, result)
 		}
 		return loopCmdNextIteration(state)
 	})
 }
+func loopCmdFinish(state *loopCmdState, result core.Result) core.Result {
+	// Close iterators on early termination
+	for i, iterator := range state.iterators {
+		if iterator == nil {
+			continue
+		}
+		state.iterators[i] = nil
+		program := state.scope.CompileArgs([]core.Value{iterator, core.STR("close")})
+		return CreateContinuationValueWithCallback(state.scope, program, state, func(closeResult core.Result, data any) core.Result {
+			if closeResult.Code == core.ResultCode_ERROR && result.Code != core.ResultCode_ERROR {
+				result = closeResult
+			}
+			return loopCmdFinish(data.(*loopCmdState), result)
+		})
+	}
+	loopCmdStatePool.Put(state)
+	return result
+}
 
 const WHILE_SIGNATURE = "while test body"
 
@@ -570,7 +598,7 @@ func (whenCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Re
 	return core.OK(core.STR(WHEN_SIGNATURE))
 }
 
-const CATCH_SIGNATURE = "catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?finally handler?"
+const CATCH_SIGNATURE = "catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?custom code value handler? ?finally handler?"
 
 type catchStateStep uint8
 
@@ -614,6 +642,11 @@ func (cmd catchCmd) Execute(args []core.Value, context any) core.Result {
 			core.ResultCode_YIELD,
 			core.ResultCode_ERROR:
 			return core.OK(core.TUPLE([]core.Value{codeName, result.Value}))
+		case core.ResultCode_CUSTOM:
+			if core.IsCustomResult(result, passResultCode) {
+				return core.OK(core.TUPLE([]core.Value{codeName}))
+			}
+			return core.OK(core.TUPLE([]core.Value{codeName, result.Value}))
 		default:
 			return core.OK(core.TUPLE([]core.Value{core.STR(core.RESULT_CODE_NAME(result))}))
 		}
@@ -656,7 +689,7 @@ func (cmd catchCmd) run(state *catchState, scope *Scope) core.Result {
 					state.result = state.bodyResult
 					state.step = catchStateStep_beforeFinally
 				}
-				i := cmd.findHandlerIndex(state.bodyResult.Code, state.args)
+				i := cmd.findHandlerIndex(state.bodyResult, state.args)
 				if i >= len(state.args)-1 {
 					state.result = state.bodyResult
 					state.step = catchStateStep_beforeFinally
@@ -685,6 +718,19 @@ func (cmd catchCmd) run(state *catchState, scope *Scope) core.Result {
 						program := scope.CompileScriptValue(handler.(core.ScriptValue)) // TODO check type
 						state.process = scope.PrepareProcess(program)
 					}
+				case core.ResultCode_CUSTOM:
+					{
+						_, varname := core.ValueToString(state.args[i+2])
+						handler := state.args[i+3]
+						subscope := scope.NewLocalScope(
+							map[string]uint{varname: 0},
+							[]core.Value{state.bodyResult.Value},
+						)
+						program := subscope.CompileScriptValue(
+							handler.(core.ScriptValue),
+						) // TODO check type
+						state.process = subscope.PrepareProcess(program)
+					}
 				default:
 					panic("CANTHAPPEN")
 				}
@@ -736,9 +782,10 @@ func (cmd catchCmd) run(state *catchState, scope *Scope) core.Result {
 	}
 }
 func (catchCmd) findHandlerIndex(
-	code core.ResultCode,
+	result core.Result,
 	args []core.Value,
 ) int {
+	code := result.Code
 	i := 2
 	for i < len(args) {
 		_, keyword := core.ValueToString(args[i])
@@ -768,6 +815,14 @@ func (catchCmd) findHandlerIndex(
 				return i
 			}
 			i += 2
+		case "custom":
+			if code == core.ResultCode_CUSTOM {
+				_, name := core.ValueToString(args[i+1])
+				if core.IsCustomResult(result, core.CustomResultCode{Name: name}) {
+					return i
+				}
+			}
+			i += 4
 		case "finally":
 			i += 2
 		}
@@ -786,6 +841,8 @@ func (catchCmd) findFinallyIndex(args []core.Value) int {
 		case "break",
 			"continue":
 			i += 2
+		case "custom":
+			i += 4
 		case "finally":
 			return i
 		}
@@ -825,6 +882,29 @@ func (catchCmd) checkArgs(args []core.Value) core.Result {
 			default:
 				i += 2
 			}
+		case "custom":
+			switch len(args) - i {
+			case 1:
+				return core.ERROR(`wrong #args: missing custom handler code`)
+			case 2:
+				return core.ERROR(`wrong #args: missing custom handler parameter`)
+			case 3:
+				return core.ERROR(`wrong #args: missing custom handler body`)
+			default:
+				{
+					result, name := core.ValueToString(args[i+1])
+					if result.Code != core.ResultCode_OK {
+						return core.ERROR(`invalid custom handler code name`)
+					}
+					if isReservedResultCodeName(name) {
+						return core.ERROR(`invalid custom handler code "` + name + `"`)
+					}
+					if result, _ := core.ValueToString(args[i+2]); result.Code != core.ResultCode_OK {
+						return core.ERROR(`invalid custom handler parameter name`)
+					}
+					i += 4
+				}
+			}
 		default:
 			return core.ERROR(`invalid keyword "` + keyword + `"`)
 		}
@@ -856,6 +936,7 @@ func (passCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Re
 
 func registerControlCommands(scope *Scope) {
 	scope.RegisterNamedCommand("loop", loopCmd{})
+	scope.RegisterNamedCommand("range", rangeCmd{})
 	scope.RegisterNamedCommand("while", whileCmd{})
 	scope.RegisterNamedCommand("if", ifCmd{})
 	scope.RegisterNamedCommand("when", whenCmd{})
@@ -412,6 +412,129 @@ var _ = Describe("Helena control flow commands", func() {
 					})
 				})
 			})
+
+			Describe("Iterator sources", func() {
+				It("should iterate over coroutine yields", func() {
+					evaluate(`
+						set values [list ()]
+						loop value [coroutine {yield a; yield b; idem c}] {
+							set values [list $values append ($value)]
+						}
+					`)
+					Expect(evaluate("get values")).To(Equal(evaluate("list (a b)")))
+				})
+				It("should iterate over ranges", func() {
+					Expect(evaluate("list [range 3]")).To(Equal(
+						LIST([]core.Value{INT(0), INT(1), INT(2)}),
+					))
+					evaluate(`
+						set values [list ()]
+						loop i v [range 1 7 2] {
+							set values [list $values append (($i $v))]
+						}
+					`)
+					Expect(evaluate("get values")).To(Equal(
+						LIST([]core.Value{
+							TUPLE([]core.Value{INT(0), INT(1)}),
+							TUPLE([]core.Value{INT(1), INT(3)}),
+							TUPLE([]core.Value{INT(2), INT(5)}),
+						}),
+					))
+				})
+				It("should iterate over iterator ensembles", func() {
+					evaluate(`
+						ensemble it {} {
+							set i 0
+							closure next {} {
+								if [$i == 3] {break}
+								set i [+ $i 1]
+							}
+						}
+					`)
+					evaluate(`
+						set values [list ()]
+						loop value it {set values [list $values append ($value)]}
+					`)
+					Expect(evaluate("get values")).To(Equal(
+						LIST([]core.Value{INT(1), INT(2), INT(3)}),
+					))
+				})
+				It("should iterate over lazy infinite sources", func() {
+					evaluate(`
+						set values [list ()]
+						loop v [coroutine {set i 0; while true {yield $i; set i [+ $i 1]}}] {
+							if [$v == 3] {break}
+							set values [list $values append ($v)]
+						}
+					`)
+					Expect(evaluate("get values")).To(Equal(
+						LIST([]core.Value{STR("0"), INT(1), INT(2)}),
+					))
+				})
+				It("should interleave with other sources", func() {
+					evaluate(`
+						set values [list ()]
+						loop a [list (x y z)] b [range 3] {
+							set values [list $values append (($a $b))]
+						}
+					`)
+					Expect(evaluate("get values")).To(Equal(
+						LIST([]core.Value{
+							TUPLE([]core.Value{STR("x"), INT(0)}),
+							TUPLE([]core.Value{STR("y"), INT(1)}),
+							TUPLE([]core.Value{STR("z"), INT(2)}),
+						}),
+					))
+				})
+
+				Describe("early termination", func() {
+					It("should close coroutines on break", func() {
+						evaluate("set cr [coroutine {yield a; yield b; yield c}]")
+						evaluate("loop v $cr {break}")
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+					})
+					It("should close coroutines on return and errors", func() {
+						evaluate("set cr [coroutine {yield a; yield b}]")
+						Expect(execute("loop v $cr {return val}")).To(Equal(RETURN(STR("val"))))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+						evaluate("set cr [coroutine {yield a; yield b}]")
+						Expect(execute("loop v $cr {error msg}")).To(Equal(ERROR("msg")))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+					})
+					It("should call iterator ensembles' close", func() {
+						evaluate(`
+							ensemble it {} {
+								set closed false
+								closure next {} {idem val}
+								closure close {} {set closed true}
+							}
+						`)
+						Expect(execute("loop v it {break}")).To(Equal(OK(NIL)))
+						Expect(evaluate("[it] eval {get closed}")).To(Equal(STR("true")))
+					})
+					It("should propagate close errors", func() {
+						evaluate(`
+							ensemble it {} {
+								closure next {} {idem val}
+								closure close {} {error closing}
+							}
+						`)
+						Expect(execute("loop v it {break}")).To(Equal(ERROR("closing")))
+						Expect(execute("loop v it {error msg}")).To(Equal(ERROR("msg")))
+					})
+					It("should not close exhausted iterators", func() {
+						evaluate(`
+							ensemble it {} {
+								set closed false
+								closure next {} {break}
+								closure close {} {set closed true}
+							}
+						`)
+						evaluate("loop v it {}")
+						Expect(evaluate("[it] eval {get closed}")).To(Equal(STR("false")))
+					})
+				})
+			})
 		})
 
 		Describe("Control flow", func() {
@@ -558,6 +681,36 @@ var _ = Describe("Helena control flow commands", func() {
 					Expect(evaluate("get i")).To(Equal(INT(10)))
 				})
 			})
+			Describe("custom code", func() {
+				It("should interrupt sources with custom code", func() {
+					evaluate("resultcode abort")
+					result := execute("loop v {abort val; unreachable} {unreachable}")
+					Expect(core.RESULT_CODE_NAME(result)).To(Equal("abort"))
+					Expect(result.Value).To(Equal(STR("val")))
+				})
+				It("should interrupt the loop with custom code", func() {
+					evaluate("resultcode abort")
+					result := execute("set i 0; loop {set i [+ $i 1]; abort val; unreachable}")
+					Expect(core.RESULT_CODE_NAME(result)).To(Equal("abort"))
+					Expect(result.Value).To(Equal(STR("val")))
+					Expect(evaluate("get i")).To(Equal(INT(1)))
+				})
+				It("should be catchable within the loop body", func() {
+					evaluate("resultcode skip")
+					Expect(
+						evaluate(`
+							set l [list ()]
+							loop v [list (a b c)] {
+								catch {
+									if {string $v == b} {skip}
+									set l [list $l append ($v)]
+								} custom skip value {}
+							}
+							get l
+						`),
+					).To(Equal(evaluate("list (a c)")))
+				})
+			})
 		})
 
 		Describe("Examples", func() {
@@ -1637,7 +1790,7 @@ var _ = Describe("Helena control flow commands", func() {
 			Specify("usage", func() {
 				Expect(evaluate("help catch")).To(Equal(
 					STR(
-						"catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?finally handler?",
+						"catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?custom code value handler? ?finally handler?",
 					),
 				))
 			})
@@ -1677,6 +1830,13 @@ var _ = Describe("Helena control flow commands", func() {
 			Specify("`CONTINUE` code should return `(continue)` tuple", func() {
 				Expect(execute("catch {continue}")).To(Equal(execute("tuple (continue)")))
 			})
+			Specify("custom code should return `(code value)` tuple", func() {
+				evaluate("resultcode abort")
+				Expect(execute("catch {abort}")).To(Equal(execute("tuple (abort [])")))
+				Expect(execute("catch {abort value}")).To(Equal(
+					execute("tuple (abort value)"),
+				))
+			})
 			Specify("arbitrary errors", func() {
 				Expect(execute("catch {idem}")).To(Equal(
 					execute(`tuple (error "wrong # args: should be \"idem value\"")`),
@@ -2372,6 +2532,125 @@ var _ = Describe("Helena control flow commands", func() {
 			})
 		})
 
+		Describe("`custom` handler", func() {
+			BeforeEach(func() {
+				evaluate("resultcode abort")
+				evaluate("resultcode skip")
+			})
+			It("should catch matching custom code", func() {
+				evaluate("catch {abort} custom abort value {set var handler}")
+				Expect(evaluate("get var")).To(Equal(STR("handler")))
+			})
+			It("should pass custom code value to handler", func() {
+				Expect(
+					evaluate("catch {abort val} custom abort value {idem _$value}"),
+				).To(Equal(STR("_val")))
+			})
+			It("should select handler by code name", func() {
+				Expect(
+					evaluate(
+						"catch {skip val} custom abort value {unreachable} custom skip value {idem skip_$value}",
+					),
+				).To(Equal(STR("skip_val")))
+			})
+			It("should let other codes pass through", func() {
+				Expect(execute("catch {idem value} custom abort value {unreachable}")).To(Equal(
+					OK(STR("value")),
+				))
+				Expect(execute("catch {return value} custom abort value {unreachable}")).To(Equal(
+					RETURN(STR("value")),
+				))
+				Expect(execute("catch {yield value} custom abort value {unreachable}")).To(Equal(
+					YIELD(STR("value")),
+				))
+				Expect(execute("catch {error message} custom abort value {unreachable}")).To(Equal(
+					ERROR("message"),
+				))
+				Expect(execute("catch {break} custom abort value {unreachable}")).To(Equal(BREAK(NIL)))
+				Expect(execute("catch {continue} custom abort value {unreachable}")).To(Equal(
+					CONTINUE(NIL),
+				))
+				Expect(
+					core.RESULT_CODE_NAME(execute("catch {skip} custom abort value {unreachable}")),
+				).To(Equal("skip"))
+			})
+			It("should let `pass` pass through", func() {
+				Expect(
+					core.RESULT_CODE_NAME(execute("catch {pass} custom abort value {unreachable}")),
+				).To(Equal("pass"))
+			})
+			It("should let original result pass through on `pass`", func() {
+				result := execute("catch {abort val} custom abort value {pass; unreachable}")
+				Expect(core.RESULT_CODE_NAME(result)).To(Equal("abort"))
+				Expect(result.Value).To(Equal(STR("val")))
+			})
+			It("should execute `finally` handler", func() {
+				Expect(
+					execute(
+						"catch {abort} custom abort value {idem handler} finally {set var finally}",
+					),
+				).To(Equal(OK(STR("handler"))))
+				Expect(evaluate("get var")).To(Equal(STR("finally")))
+			})
+			Describe("Control flow", func() {
+				Describe("`yield`", func() {
+					It("should provide a resumable state", func() {
+						process := prepareScript(
+							"catch {abort} custom abort value {idem _$[yield handler]}",
+						)
+
+						result := process.Run()
+						Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+						Expect(result.Value).To(Equal(STR("handler")))
+						Expect(result.Data).NotTo(BeNil())
+
+						process.YieldBack(STR("value"))
+						result = process.Run()
+						Expect(result).To(Equal(OK(STR("_value"))))
+					})
+				})
+				Describe("`error`", func() {
+					It("should interrupt handler with `ERROR` code", func() {
+						Expect(
+							execute("catch {abort} custom abort value {error message; unreachable}"),
+						).To(Equal(ERROR("message")))
+					})
+				})
+			})
+
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					Expect(execute("catch {} custom")).To(Equal(
+						ERROR("wrong #args: missing custom handler code"),
+					))
+					Expect(execute("catch {} custom abort")).To(Equal(
+						ERROR("wrong #args: missing custom handler parameter"),
+					))
+					Expect(execute("catch {} custom abort value")).To(Equal(
+						ERROR("wrong #args: missing custom handler body"),
+					))
+				})
+				Specify("invalid code name", func() {
+					Expect(execute("catch {} custom [] value {}")).To(Equal(
+						ERROR("invalid custom handler code name"),
+					))
+				})
+				Specify("reserved code name", func() {
+					Expect(execute("catch {} custom error value {}")).To(Equal(
+						ERROR(`invalid custom handler code "error"`),
+					))
+					Expect(execute("catch {} custom pass value {}")).To(Equal(
+						ERROR(`invalid custom handler code "pass"`),
+					))
+				})
+				Specify("invalid parameter name", func() {
+					Expect(execute("catch {} custom abort [] {}")).To(Equal(
+						ERROR("invalid custom handler parameter name"),
+					))
+				})
+			})
+		})
+
 		Describe("`finally` handler", func() {
 			It("should execute for `OK` code", func() {
 				evaluate("catch {idem value} finally {set var handler}")
@@ -2484,7 +2763,7 @@ var _ = Describe("Helena control flow commands", func() {
 			Specify("wrong arity", func() {
 				Expect(execute("catch")).To(Equal(
 					ERROR(
-						`wrong # args: should be "catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?finally handler?"`,
+						`wrong # args: should be "catch body ?return value handler? ?yield value handler? ?error message handler? ?break handler? ?continue handler? ?custom code value handler? ?finally handler?"`,
 					),
 				))
 			})
@@ -124,7 +124,7 @@ func (process *Process) Run() core.Result {
 		return process.lastResult
 	}
 	context := process.stack.CurrentContext()
-	result := context.scope.Execute(context.program, context.state)
+	result := process.execute(context)
 	for process.stack.Depth() > 0 {
 		if continuation, ok := result.Value.(*ContinuationValue); ok {
 			if result.Code != core.ResultCode_YIELD && context.callback == nil {
@@ -134,7 +134,7 @@ func (process *Process) Run() core.Result {
 
 			// Push and execute result continuation context
 			context = process.stack.PushContinuation(continuation)
-			result = context.scope.Execute(context.program, context.state)
+			result = process.execute(context)
 
 			// Continuation is no longer used so put it back in the pool
 			continuationValuePool.Put(continuation)
@@ -142,6 +142,13 @@ func (process *Process) Run() core.Result {
 		}
 
 		if result.Code == core.ResultCode_YIELD {
+			if query, ok := result.Value.(*processQuery); ok {
+				// Answer query and resume current context
+				process.YieldBack(query.answer(process))
+				result = process.execute(context)
+				continue
+			}
+
 			// Yield result to caller
 			break
 		}
@@ -203,10 +210,37 @@ func (process *Process) Run() core.Result {
 
 		// Yield back and resume current context
 		context.state.SetResult(result)
-		result = context.scope.Execute(context.program, context.state)
+		result = process.execute(context)
 	}
 	return result
 }
+
+// Execute the context program after checking the interpreter limits, if any
+func (process *Process) execute(context ProcessContext) core.Result {
+	scope := context.scope
+	if scope.limits != nil {
+		if result := scope.limits.check(process); result.Code != core.ResultCode_OK {
+			return result
+		}
+	}
+	return scope.Execute(context.program, context.state)
+}
+
+// Query about the process running a command
+//
+// Commands yield queries to get information about their process, e.g. its
+// stack depth; the process answers and resumes the command at once
+type processQuery struct {
+	answer func(process *Process) core.Value
+}
+
+func (*processQuery) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (*processQuery) CustomType() core.CustomValueType {
+	return core.CustomValueType{Name: "processQuery"}
+}
+
 func (process *Process) SetResult(result core.Result) {
 	context := process.stack.CurrentContext()
 	context.state.SetResult(result)
@@ -223,6 +257,9 @@ type scopeContext struct {
 	Constants map[string]core.Value
 	Variables map[string]core.Value
 	Commands  map[string]core.CommandValue
+
+	// Modules the scope imported commands from
+	imports []*Module
 }
 
 func newScopeContext(parent *scopeContext) *scopeContext {
@@ -245,6 +282,7 @@ type Scope struct {
 	localValues []core.Value
 	compiler    core.Compiler
 	executor    core.Executor
+	limits      *interpLimits
 }
 
 type variableResolver struct{ scope *Scope }
@@ -256,7 +294,11 @@ func (resolver variableResolver) Resolve(name string) core.Value {
 type commandResolver struct{ scope *Scope }
 
 func (resolver commandResolver) Resolve(name core.Value) core.Command {
-	return resolver.scope.ResolveCommand(name)
+	command := resolver.scope.ResolveCommand(name)
+	if command != nil && resolver.scope.limits != nil {
+		return resolver.scope.limits.wrap(command)
+	}
+	return command
 }
 
 func newScope(
@@ -288,10 +330,13 @@ func NewRootScope(options *ScopeOptions) *Scope {
 	return newScope(newScopeContext(nil), options)
 }
 func (scope *Scope) NewChildScope() *Scope {
-	return newScope(newScopeContext(scope.Context), &scope.options)
+	child := newScope(newScopeContext(scope.Context), &scope.options)
+	child.setLimits(scope.limits)
+	return child
 }
 func (scope *Scope) NewLocalScope(slots map[string]uint, values []core.Value) *Scope {
 	child := newScope(scope.Context, &scope.options)
+	child.setLimits(scope.limits)
 	if slots != nil {
 		child.localSlots = slots
 		if values != nil {
@@ -303,6 +348,11 @@ func (scope *Scope) NewLocalScope(slots map[string]uint, values []core.Value) *S
 	return child
 }
 
+func (scope *Scope) setLimits(limits *interpLimits) {
+	scope.limits = limits
+	scope.executor.MaxValueSize = limits.maxValueSize()
+}
+
 func (scope *Scope) Compile(script core.Script) *core.Program {
 	return scope.compiler.CompileScript(script)
 }
@@ -374,15 +424,26 @@ func (scope *Scope) ResolveVariable(name string) core.Value {
 	}
 	return nil
 }
+
+// Resolve the command designated by a command name value
+//
+// Besides command names, tuples, command values and numbers, custom values
+// implementing core.CommandValue resolve to their command. This lets native
+// modules return objects that scripts call directly, e.g. go:slog loggers or
+// go:regexp values
 func (scope *Scope) ResolveCommand(value core.Value) core.Command {
 	switch value.Type() {
 	case core.ValueType_TUPLE:
 		return expandPrefixCmd
 	case core.ValueType_COMMAND:
-		return value.(core.CommandValue).Command()
+		return resolveValueCommand(value.(core.CommandValue))
 	case core.ValueType_INTEGER,
 		core.ValueType_REAL:
 		return numberCmd
+	case core.ValueType_CUSTOM:
+		if commandValue, ok := value.(core.CommandValue); ok {
+			return resolveValueCommand(commandValue)
+		}
 	}
 	result, cmdname := core.ValueToString(value)
 	if result.Code != core.ResultCode_OK {
@@ -397,6 +458,17 @@ func (scope *Scope) ResolveCommand(value core.Value) core.Command {
 	}
 	return nil
 }
+
+// Resolve the command held by a command value, honoring deletions
+func resolveValueCommand(value core.CommandValue) core.Command {
+	command := value.Command()
+	if deletable, ok := command.(deletedNamer); ok {
+		if name, deleted := deletable.DeletedName(); deleted {
+			return deletedCommand{name}
+		}
+	}
+	return command
+}
 func (scope *Scope) ResolveNamedCommand(name string) core.Command {
 	context := scope.Context
 	for context != nil {
@@ -575,7 +647,59 @@ func (scope *Scope) RegisterCommand(name core.Value, command core.Command) core.
 	return core.OK(core.NIL)
 }
 func (scope *Scope) RegisterNamedCommand(name string, command core.Command) {
-	scope.Context.Commands[name] = core.NewCommandValue(command)
+	scope.Context.Commands[name] = newCommandBinding(command)
+}
+
+// Unregister the scope from the modules it imported commands from
+//
+// Module reloads no longer rebind the imported commands of released scopes.
+// Procs release their call scopes on return; hosts should release the
+// long-lived scopes they discard
+func (scope *Scope) ReleaseImports() {
+	for _, module := range scope.Context.imports {
+		module.removeDependents(scope.Context)
+	}
+	scope.Context.imports = nil
+}
+
+// Register a command and make it track the deletion of its new binding
+func (scope *Scope) defineCommand(name core.Value, command definableCommand) core.Result {
+	result, cmdname := core.ValueToString(name)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid command name")
+	}
+	binding := newCommandBinding(command)
+	scope.Context.Commands[cmdname] = binding
+	command.define(binding.deletion)
+	return core.OK(core.NIL)
+}
+func (scope *Scope) HasLocalCommand(name string) bool {
+	_, ok := scope.Context.Commands[name]
+	return ok
+}
+func (scope *Scope) UnregisterNamedCommand(name string) core.Result {
+	command, ok := scope.Context.Commands[name]
+	if !ok {
+		return core.ERROR(`cannot resolve command "` + name + `"`)
+	}
+	delete(scope.Context.Commands, name)
+	if binding, ok := command.(commandBinding); ok {
+		binding.deletion.deleted = true
+		binding.deletion.name = name
+	}
+	return core.OK(core.NIL)
+}
+func (scope *Scope) RenameNamedCommand(name string, newName string) core.Result {
+	command, ok := scope.Context.Commands[name]
+	if !ok {
+		return core.ERROR(`cannot resolve command "` + name + `"`)
+	}
+	if _, ok := scope.Context.Commands[newName]; ok {
+		return core.ERROR(`command "` + newName + `" already exists`)
+	}
+	delete(scope.Context.Commands, name)
+	scope.Context.Commands[newName] = command
+	return core.OK(core.NIL)
 }
 
 type expandPrefixState struct {
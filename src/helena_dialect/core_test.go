@@ -30,6 +30,20 @@ var _ = Describe("Helena core internals", func() {
 	})
 
 	Describe("Process", func() {
+		It("should pass the scope itself to commands", func() {
+			var scopes []*Scope
+			rootScope.RegisterNamedCommand("cmd", simpleCommand{
+				execute: func(_ []core.Value, context any) core.Result {
+					scopes = append(scopes, context.(*Scope))
+					return OK(NIL)
+				},
+			})
+			prepareScript("cmd").Run()
+			prepareScript("cmd").Run()
+			Expect(scopes).To(HaveLen(2))
+			Expect(scopes[0]).To(BeIdenticalTo(rootScope))
+			Expect(scopes[1]).To(BeIdenticalTo(rootScope))
+		})
 		Specify("captureErrorStack", func() {
 			source := `
 macro cmd1 {} {cmd2}
@@ -192,5 +206,57 @@ mac
 			})
 		})
 
+		Describe("command resolution", func() {
+			It("should resolve custom values implementing core.CommandValue", func() {
+				rootScope.SetNamedVariable("obj", commandObject{simpleCommand{
+					execute: func(args []core.Value, _ any) core.Result {
+						return OK(LIST(args[1:]))
+					},
+				}})
+				Expect(prepareScript("$obj a b").Run()).To(Equal(OK(LIST([]core.Value{STR("a"), STR("b")}))))
+			})
+			It("should report deleted commands of custom values", func() {
+				rootScope.SetNamedVariable("obj", commandObject{deletedObjectCommand{"cmd"}})
+				Expect(prepareScript("$obj a b").Run()).To(Equal(ERROR(`command "cmd" has been deleted`)))
+			})
+			It("should not resolve other custom values", func() {
+				rootScope.SetNamedVariable("obj", customObject{})
+				Expect(prepareScript("$obj a b").Run()).To(Equal(ERROR("invalid command name")))
+			})
+		})
 	})
 })
+
+type customObject struct{}
+
+func (customObject) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (customObject) CustomType() core.CustomValueType {
+	return core.CustomValueType{Name: "object"}
+}
+
+type commandObject struct {
+	command core.Command
+}
+
+func (commandObject) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (commandObject) CustomType() core.CustomValueType {
+	return core.CustomValueType{Name: "commandObject"}
+}
+func (object commandObject) Command() core.Command {
+	return object.command
+}
+
+type deletedObjectCommand struct {
+	name string
+}
+
+func (deletedObjectCommand) Execute(_ []core.Value, _ any) core.Result {
+	return OK(STR("executed"))
+}
+func (command deletedObjectCommand) DeletedName() (string, bool) {
+	return command.name, true
+}
@@ -11,11 +11,12 @@ const (
 )
 
 type coroutineCommand struct {
-	value   core.Value
-	scope   *Scope
-	body    core.ScriptValue
-	state   coroutineState
-	process *Process
+	value     core.Value
+	scope     *Scope
+	body      core.ScriptValue
+	state     coroutineState
+	process   *Process
+	suspended bool
 }
 
 func newCoroutineCommand(scope *Scope, body core.ScriptValue) *coroutineCommand {
@@ -33,6 +34,8 @@ var coroutineSubcommands = NewSubcommands([]string{
 	"active",
 	"done",
 	"yield",
+	"next",
+	"close",
 })
 
 func (cmd *coroutineCommand) Execute(args []core.Value, _ any) core.Result {
@@ -88,13 +91,42 @@ func (cmd *coroutineCommand) Execute(args []core.Value, _ any) core.Result {
 		}
 		return cmd.run()
 
+	case "next":
+		if len(args) != 2 {
+			return ARITY_ERROR("<coroutine> next")
+		}
+		if cmd.state == coroutineState_done {
+			return core.BREAK(core.NIL)
+		}
+		if cmd.state == coroutineState_inactive {
+			cmd.state = coroutineState_active
+			program := cmd.scope.CompileScriptValue(cmd.body)
+			cmd.process = cmd.scope.PrepareProcess(program)
+		}
+		result := cmd.run()
+		if result.Code == core.ResultCode_OK && cmd.state == coroutineState_done {
+			// Completion ends iteration
+			return core.BREAK(core.NIL)
+		}
+		return result
+
+	case "close":
+		if len(args) != 2 {
+			return ARITY_ERROR("<coroutine> close")
+		}
+		return cmd.close()
+
 	default:
 		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
 	}
 }
 
+func (*coroutineCommand) IsIterator() bool { return true }
+func (*coroutineCommand) IsClosable() bool { return true }
+
 func (cmd *coroutineCommand) run() core.Result {
 	result := cmd.process.Run()
+	cmd.suspended = result.Code == core.ResultCode_YIELD
 	switch result.Code {
 	case core.ResultCode_OK,
 		core.ResultCode_RETURN:
@@ -109,6 +141,44 @@ func (cmd *coroutineCommand) run() core.Result {
 	}
 }
 
+// Resume a suspended body with the close signal so that it unwinds through
+// its finally handlers
+func (cmd *coroutineCommand) close() core.Result {
+	process, suspended := cmd.process, cmd.suspended
+	cmd.state = coroutineState_done
+	cmd.process = nil
+	cmd.suspended = false
+	if !suspended {
+		return core.OK(core.NIL)
+	}
+	process.YieldBack(coroutineCloseSignal)
+	result := process.Run()
+	switch result.Code {
+	case core.ResultCode_ERROR:
+		return result
+	case core.ResultCode_YIELD:
+		return core.ERROR("coroutine yielded while closing")
+	default:
+		return core.OK(core.NIL)
+	}
+}
+
+// Result code of yield commands resumed with the close signal
+//
+// The code is reserved so that no handler can catch it
+var closeResultCode = core.CustomResultCode{Name: "close"}
+
+type closeSignal struct{}
+
+func (*closeSignal) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (*closeSignal) CustomType() core.CustomValueType {
+	return core.CustomValueType{Name: "close"}
+}
+
+var coroutineCloseSignal = &closeSignal{}
+
 const COROUTINE_SIGNATURE = "coroutine body"
 
 type coroutineCmd struct{}
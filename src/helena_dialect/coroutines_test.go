@@ -166,7 +166,7 @@ var _ = Describe("Helena coroutines", func() {
 				Describe("`subcommands`", func() {
 					It("should return list of subcommands", func() {
 						Expect(evaluate("[coroutine {}] subcommands")).To(Equal(
-							evaluate("list (subcommands wait active done yield)"),
+							evaluate("list (subcommands wait active done yield next close)"),
 						))
 					})
 
@@ -293,6 +293,86 @@ var _ = Describe("Helena coroutines", func() {
 						})
 					})
 				})
+				Describe("`next`", func() {
+					It("should return yielded values", func() {
+						evaluate("set cr [coroutine {yield val1; yield val2; idem val3}]")
+						Expect(execute("$cr next")).To(Equal(OK(STR("val1"))))
+						Expect(evaluate("$cr active")).To(Equal(TRUE))
+						Expect(execute("$cr next")).To(Equal(OK(STR("val2"))))
+					})
+					It("should break once the coroutine completes", func() {
+						evaluate("set cr [coroutine {yield val1; idem val2}]")
+						evaluate("$cr next")
+						Expect(execute("$cr next")).To(Equal(BREAK(NIL)))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+						Expect(execute("$cr next")).To(Equal(BREAK(NIL)))
+					})
+					It("should propagate errors", func() {
+						evaluate("set cr [coroutine {error msg}]")
+						Expect(execute("$cr next")).To(Equal(ERROR("msg")))
+					})
+
+					Describe("Exceptions", func() {
+						Specify("wrong arity", func() {
+							Expect(execute("[coroutine {}] next a")).To(Equal(
+								ERROR(`wrong # args: should be "<coroutine> next"`),
+							))
+						})
+					})
+				})
+				Describe("`close`", func() {
+					It("should finalize the coroutine", func() {
+						evaluate("set cr [coroutine {yield val1; yield val2}]")
+						evaluate("$cr wait")
+						Expect(execute("$cr close")).To(Equal(OK(NIL)))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+						Expect(execute("$cr yield")).To(Equal(ERROR("coroutine is done")))
+						Expect(execute("$cr next")).To(Equal(BREAK(NIL)))
+					})
+					It("should run finalizers of suspended coroutines", func() {
+						evaluate("set closed false")
+						evaluate(
+							"set cr [coroutine {catch {yield val1; yield val2; set closed body} yield value {pass} finally {set closed true}}]",
+						)
+						Expect(execute("$cr wait")).To(Equal(OK(STR("val1"))))
+						Expect(execute("$cr close")).To(Equal(OK(NIL)))
+						Expect(evaluate("get closed")).To(Equal(STR("true")))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+					})
+					It("should not run finalizers of unstarted coroutines", func() {
+						evaluate("set closed false")
+						evaluate(
+							"set cr [coroutine {catch {yield val1} yield value {pass} finally {set closed true}}]",
+						)
+						Expect(execute("$cr close")).To(Equal(OK(NIL)))
+						Expect(evaluate("get closed")).To(Equal(STR("false")))
+					})
+					It("should propagate finalizer errors", func() {
+						evaluate(
+							"set cr [coroutine {catch {yield val1} yield value {pass} finally {error msg}}]",
+						)
+						evaluate("$cr wait")
+						Expect(execute("$cr close")).To(Equal(ERROR("msg")))
+						Expect(evaluate("$cr done")).To(Equal(TRUE))
+					})
+
+					Describe("Exceptions", func() {
+						Specify("yielding finalizers", func() {
+							evaluate(
+								"set cr [coroutine {catch {yield val1} yield value {pass} finally {yield val2}}]",
+							)
+							evaluate("$cr wait")
+							Expect(execute("$cr close")).To(Equal(
+								ERROR("coroutine yielded while closing"),
+							))
+						})
+						Specify("wrong arity", func() {
+							Expect(execute("[coroutine {}] close a")).To(Equal(
+								ERROR(`wrong # args: should be "<coroutine> close"`),
+							))
+						})
+					})
+				})
 
 				Describe("Exceptions", func() {
 					Specify("unknown subcommand", func() {
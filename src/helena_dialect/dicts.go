@@ -20,6 +20,12 @@ func newDictCommand(scope *Scope) *dictCommand {
 }
 func (cmd *dictCommand) Execute(args []core.Value, context any) core.Result {
 	if len(args) == 2 {
+		if args[1].Type() == core.ValueType_COMMAND {
+			scope := context.(*Scope)
+			if resolveIterator(scope, args[1]) != nil {
+				return collectIteratorValues(scope, args[1], entriesToDictionaryValue)
+			}
+		}
 		return valueToDictionaryValue(args[1])
 	}
 	return cmd.ensemble.Execute(args, context)
@@ -39,7 +45,7 @@ func (dictSizeCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 2 {
 		return ARITY_ERROR(DICT_SIZE_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -60,7 +66,7 @@ func (dictHasCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 3 {
 		return ARITY_ERROR(DICT_HAS_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -85,7 +91,7 @@ func (dictGetCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 3 && len(args) != 4 {
 		return ARITY_ERROR(DICT_GET_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -141,7 +147,7 @@ func (dictAddCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 4 {
 		return ARITY_ERROR(DICT_ADD_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -168,7 +174,7 @@ func (dictRemoveCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) == 2 {
 		return valueToDictionaryValue(args[1])
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -194,13 +200,13 @@ func (dictMergeCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) == 2 {
 		return valueToDictionaryValue(args[1])
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
 	clone := maps.Clone(map_)
 	for i := 2; i < len(args); i++ {
-		result2, map2 := valueToMap(args[i])
+		result2, map2 := ValueToMap(args[i])
 		if result2.Code != core.ResultCode_OK {
 			return result2
 		}
@@ -220,7 +226,7 @@ func (dictKeysCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 2 {
 		return ARITY_ERROR(DICT_KEYS_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -245,7 +251,7 @@ func (dictValuesCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 2 {
 		return ARITY_ERROR(DICT_VALUES_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -270,7 +276,7 @@ func (dictEntriesCmd) Execute(args []core.Value, _ any) core.Result {
 	if len(args) != 2 {
 		return ARITY_ERROR(DICT_ENTRIES_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -314,7 +320,7 @@ func (dictForeachCmd) Execute(args []core.Value, context any) core.Result {
 	default:
 		return ARITY_ERROR(DICT_FOREACH_SIGNATURE)
 	}
-	result, map_ := valueToMap(args[1])
+	result, map_ := ValueToMap(args[1])
 	if result.Code != core.ResultCode_OK {
 		return result
 	}
@@ -389,7 +395,7 @@ func valueToDictionaryValue(value core.Value) core.Result {
 		core.ValueType_LIST,
 		core.ValueType_TUPLE:
 		{
-			result, map_ := valueToMap(value)
+			result, map_ := ValueToMap(value)
 			if result.Code != core.ResultCode_OK {
 				return result
 			}
@@ -399,7 +405,22 @@ func valueToDictionaryValue(value core.Value) core.Result {
 		return core.ERROR("invalid dictionary")
 	}
 }
-func valueToMap(value core.Value) (core.Result, map[string]core.Value) {
+func entriesToDictionaryValue(entries []core.Value) core.Result {
+	map_ := map[string]core.Value{}
+	for _, entry := range entries {
+		result, values := ValueToArray(entry)
+		if result.Code != core.ResultCode_OK || len(values) != 2 {
+			return core.ERROR("invalid key-value pair")
+		}
+		result, key := core.ValueToString(values[0])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid key")
+		}
+		map_[key] = values[1]
+	}
+	return core.OK(core.DICT(map_))
+}
+func ValueToMap(value core.Value) (core.Result, map[string]core.Value) {
 	if value.Type() == core.ValueType_DICTIONARY {
 		return core.OK(core.NIL), value.(core.DictionaryValue).Map
 	}
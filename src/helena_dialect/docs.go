@@ -0,0 +1,249 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"slices"
+	"strings"
+)
+
+// Commands carrying a doc string
+//
+// Doc strings are free text followed by optional tagged lines:
+//
+//	@arg name description
+//	@example command line
+type DocumentedCommand interface {
+	core.Command
+
+	// Return the command doc string
+	Doc() string
+
+	// Replace the command doc string
+	SetDoc(doc string)
+}
+
+// Doc string embedded in documented commands
+type commandDoc struct {
+	doc string
+}
+
+func (doc *commandDoc) Doc() string {
+	return doc.doc
+}
+func (doc *commandDoc) SetDoc(value string) {
+	doc.doc = value
+}
+
+// Extract the doc string from the leading comments of a script
+func ExtractDocString(script core.Script) string {
+	lines := []string{}
+	for _, sentence := range script.Sentences {
+		if len(sentence.Words) != 1 || len(sentence.Words[0].Word.Morphemes) != 1 {
+			break
+		}
+		switch morpheme := sentence.Words[0].Word.Morphemes[0].(type) {
+		case core.LineCommentMorpheme:
+			lines = append(lines, strings.TrimPrefix(morpheme.Value, " "))
+			continue
+		case core.BlockCommentMorpheme:
+			for _, line := range strings.Split(strings.TrimSpace(morpheme.Value), "\n") {
+				lines = append(lines, strings.TrimSpace(line))
+			}
+			continue
+		}
+		break
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Parsed doc string
+type DocString struct {
+	Description string
+	Arguments   map[string]string
+	Examples    []string
+}
+
+func ParseDocString(doc string) DocString {
+	result := DocString{Arguments: map[string]string{}, Examples: []string{}}
+	description := []string{}
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if text, ok := strings.CutPrefix(trimmed, "@arg "); ok {
+			name, text, _ := strings.Cut(strings.TrimSpace(text), " ")
+			result.Arguments[name] = strings.TrimSpace(text)
+		} else if text, ok := strings.CutPrefix(trimmed, "@example "); ok {
+			result.Examples = append(result.Examples, strings.TrimSpace(text))
+		} else {
+			description = append(description, line)
+		}
+	}
+	result.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	return result
+}
+
+// Help sections of a command
+type commandHelp struct {
+	signature string
+	doc       DocString
+	arguments []argumentHelp
+}
+type argumentHelp struct {
+	usage string
+	doc   string
+}
+
+func getCommandHelp(scope *Scope, name core.Value, command core.Command) commandHelp {
+	help := commandHelp{}
+	if c, ok := command.(core.CommandWithHelp); ok {
+		result := c.Help([]core.Value{name}, core.CommandHelpOptions{}, scope)
+		if result.Code == core.ResultCode_OK {
+			_, help.signature = core.ValueToStringOrDefault(result.Value, "")
+		}
+	}
+	if help.signature == "" {
+		_, help.signature = core.ValueToStringOrDefault(name, "")
+	}
+	if c, ok := command.(DocumentedCommand); ok {
+		help.doc = ParseDocString(c.Doc())
+	} else {
+		help.doc = ParseDocString("")
+	}
+	var argspec ArgspecValue
+	switch c := command.(type) {
+	case *EnsembleCommand:
+		argspec = c.argspec
+	default:
+		argspec, _, _ = commandDefinition(command)
+	}
+	for _, arg := range argspec.Argspec.Args {
+		help.arguments = append(help.arguments, argumentHelp{
+			usage: BuildUsage([]Argument{arg}, 0),
+			doc:   help.doc.Arguments[arg.Name],
+		})
+	}
+	return help
+}
+
+// Return whether a command has documentation beyond its signature
+func hasDoc(command core.Command) bool {
+	c, ok := command.(DocumentedCommand)
+	return ok && c.Doc() != ""
+}
+
+// Render the full help text of a command
+func FullHelp(scope *Scope, name core.Value, command core.Command) string {
+	help := getCommandHelp(scope, name, command)
+	sections := []string{help.signature}
+	if help.doc.Description != "" {
+		sections = append(sections, help.doc.Description)
+	}
+	if len(help.arguments) > 0 {
+		lines := []string{"Arguments:"}
+		for _, argument := range help.arguments {
+			line := "  " + argument.usage
+			if argument.doc != "" {
+				line += " - " + argument.doc
+			}
+			lines = append(lines, line)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if len(help.doc.Examples) > 0 {
+		sections = append(sections, "Examples:\n  "+strings.Join(help.doc.Examples, "\n  "))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// Render the help of named commands as a Markdown section
+func MarkdownHelp(title string, doc string, scope *Scope, names []string) string {
+	var builder strings.Builder
+	builder.WriteString("# " + title + "\n\n")
+	if doc != "" {
+		builder.WriteString(doc + "\n\n")
+	}
+	names = slices.Clone(names)
+	slices.Sort(names)
+	for _, name := range names {
+		command := scope.ResolveNamedCommand(name)
+		if command == nil {
+			continue
+		}
+		help := getCommandHelp(scope, core.STR(name), command)
+		builder.WriteString("## `" + name + "`\n\n")
+		builder.WriteString("```\n" + help.signature + "\n```\n\n")
+		if help.doc.Description != "" {
+			builder.WriteString(help.doc.Description + "\n\n")
+		}
+		if len(help.arguments) > 0 {
+			builder.WriteString("**Arguments**\n\n")
+			for _, argument := range help.arguments {
+				builder.WriteString("- `" + argument.usage + "`")
+				if argument.doc != "" {
+					builder.WriteString(": " + argument.doc)
+				}
+				builder.WriteString("\n")
+			}
+			builder.WriteString("\n")
+		}
+		if len(help.doc.Examples) > 0 {
+			builder.WriteString("**Examples**\n\n```\n")
+			builder.WriteString(strings.Join(help.doc.Examples, "\n"))
+			builder.WriteString("\n```\n\n")
+		}
+	}
+	return builder.String()
+}
+
+// Search visible commands whose name, signature or doc contains a term
+func searchHelp(scope *Scope, term string) []string {
+	term = strings.ToLower(term)
+	names := []string{}
+	for context := scope.Context; context != nil; context = context.parent {
+		for name, value := range context.Commands {
+			if slices.Contains(names, name) {
+				continue
+			}
+			help := getCommandHelp(scope, core.STR(name), value.Command())
+			if strings.Contains(strings.ToLower(name), term) ||
+				strings.Contains(strings.ToLower(help.signature), term) ||
+				strings.Contains(strings.ToLower(help.doc.Description), term) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+const DOC_SIGNATURE = "doc command ?docstring?"
+
+type docCmd struct{}
+
+func (docCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 2 && len(args) != 3 {
+		return ARITY_ERROR(DOC_SIGNATURE)
+	}
+	command := scope.ResolveCommand(args[1])
+	if command == nil {
+		return infoUnresolvedCommandError(args[1])
+	}
+	documented, ok := command.(DocumentedCommand)
+	if !ok {
+		return core.ERROR("command does not accept a doc string")
+	}
+	if len(args) == 2 {
+		return core.OK(core.STR(documented.Doc()))
+	}
+	result, doc := core.ValueToString(args[2])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid doc string")
+	}
+	documented.SetDoc(doc)
+	return core.OK(core.NIL)
+}
+func (docCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(DOC_SIGNATURE)
+	}
+	return core.OK(core.STR(DOC_SIGNATURE))
+}
@@ -0,0 +1,207 @@
+package helena_dialect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena documentation", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommandsForModule(rootScope, NewModuleRegistry(nil), "")
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("Doc strings", func() {
+		It("should be extracted from leading line comments", func() {
+			Expect(ExtractDocString(*parse("# line 1\n#  line 2\ncmd\n# not doc"))).To(Equal(
+				"line 1\n line 2",
+			))
+		})
+		It("should be extracted from leading block comments", func() {
+			Expect(ExtractDocString(*parse("#{ line 1\n  line 2 }#\ncmd"))).To(Equal(
+				"line 1\nline 2",
+			))
+		})
+		It("should be empty without leading comments", func() {
+			Expect(ExtractDocString(*parse("cmd\n# comment"))).To(Equal(""))
+			Expect(ExtractDocString(*parse(""))).To(Equal(""))
+		})
+		It("should be parsed into sections", func() {
+			doc := ParseDocString("Summary\n\nDetails\n@arg a first\n@arg b\n@example cmd 1")
+			Expect(doc.Description).To(Equal("Summary\n\nDetails"))
+			Expect(doc.Arguments).To(Equal(map[string]string{"a": "first", "b": ""}))
+			Expect(doc.Examples).To(Equal([]string{"cmd 1"}))
+		})
+
+		Specify("procs", func() {
+			evaluate("proc cmd {} {\n# proc doc\nidem val\n}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("proc doc")))
+			Expect(evaluate("cmd")).To(Equal(STR("val")))
+		})
+		Specify("closures", func() {
+			evaluate("closure cmd {} {\n# closure doc\n}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("closure doc")))
+		})
+		Specify("macros", func() {
+			evaluate("macro cmd {} {\n# macro doc\n}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("macro doc")))
+		})
+		Specify("ensembles", func() {
+			evaluate("ensemble cmd {} {\n# ensemble doc\n}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("ensemble doc")))
+		})
+		Specify("modules", func() {
+			evaluate("module cmd {\n# module doc\n}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("module doc")))
+		})
+	})
+
+	Describe("doc", func() {
+		Specify("usage", func() {
+			Expect(evaluate("help doc")).To(Equal(STR("doc command ?docstring?")))
+		})
+
+		It("should replace the doc string", func() {
+			evaluate("macro cmd {} {}")
+			Expect(evaluate("doc cmd")).To(Equal(STR("")))
+			Expect(execute("doc cmd {new doc}")).To(Equal(OK(NIL)))
+			Expect(evaluate("doc cmd")).To(Equal(STR("new doc")))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("doc")).To(Equal(
+					ERROR(`wrong # args: should be "doc command ?docstring?"`),
+				))
+				Expect(execute("doc a b c")).To(Equal(
+					ERROR(`wrong # args: should be "doc command ?docstring?"`),
+				))
+			})
+			Specify("unknown command", func() {
+				Expect(execute("doc unknownCommand")).To(Equal(
+					ERROR(`cannot resolve command "unknownCommand"`),
+				))
+			})
+			Specify("undocumented command", func() {
+				Expect(execute("doc set")).To(Equal(
+					ERROR("command does not accept a doc string"),
+				))
+			})
+			Specify("invalid doc string", func() {
+				evaluate("macro cmd {} {}")
+				Expect(execute("doc cmd []")).To(Equal(ERROR("invalid doc string")))
+			})
+		})
+	})
+
+	Describe("help", func() {
+		It("should return the full help of documented commands", func() {
+			evaluate(`proc add {a ?b} {
+				# Add numbers
+				#
+				# Return the sum.
+				# @arg a first operand
+				# @example add 1 2
+			}`)
+			Expect(evaluate("help add")).To(Equal(STR(
+				"add a ?b?\n\nAdd numbers\n\nReturn the sum.\n\n" +
+					"Arguments:\n  a - first operand\n  ?b?\n\n" +
+					"Examples:\n  add 1 2",
+			)))
+		})
+		It("should return the signature when called with arguments", func() {
+			evaluate("proc cmd {a} {\n# doc\n}")
+			Expect(evaluate("help cmd 1")).To(Equal(STR("cmd a")))
+		})
+		It("should return the signature of undocumented commands", func() {
+			evaluate("proc cmd {a} {}")
+			Expect(evaluate("help cmd")).To(Equal(STR("cmd a")))
+		})
+		It("should describe ensemble arguments", func() {
+			evaluate("ensemble cmd {value} {\n# Ensemble\n# @arg value the value\n}")
+			Expect(evaluate("help cmd")).To(Equal(STR(
+				"cmd value ?subcommand? ?arg ...?\n\nEnsemble\n\n" +
+					"Arguments:\n  value - the value",
+			)))
+		})
+
+		Describe("-search", func() {
+			It("should find commands by name", func() {
+				Expect(evaluate("help -search corout")).To(Equal(
+					evaluate("list (coroutine)"),
+				))
+			})
+			It("should find commands by doc string", func() {
+				evaluate("macro cmd1 {} {\n# Find the Needle\n}")
+				evaluate("macro cmd2 {} {\n# another needle\n}")
+				evaluate("macro cmd3 {} {}")
+				Expect(evaluate("help -search needle")).To(Equal(
+					evaluate("list (cmd1 cmd2)"),
+				))
+			})
+			It("should find commands in parent scopes", func() {
+				evaluate("macro needle1 {} {}")
+				Expect(evaluate("scope s {macro needle2 {} {}}; s eval {help -search needle}")).To(Equal(
+					evaluate("list (needle1 needle2)"),
+				))
+			})
+			It("should return an empty list when nothing matches", func() {
+				Expect(evaluate("help -search unknownTerm")).To(Equal(LIST([]core.Value{})))
+			})
+
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					Expect(execute("help -search")).To(Equal(
+						ERROR(`wrong # args: should be "help -search term"`),
+					))
+					Expect(execute("help -search a b")).To(Equal(
+						ERROR(`wrong # args: should be "help -search term"`),
+					))
+				})
+				Specify("invalid term", func() {
+					Expect(execute("help -search []")).To(Equal(
+						ERROR("invalid search term"),
+					))
+				})
+			})
+		})
+	})
+
+	Describe("MarkdownHelp", func() {
+		It("should render commands as Markdown", func() {
+			evaluate("macro cmd {a} {\n# Command\n# @arg a arg\n# @example cmd 1\n}")
+			Expect(MarkdownHelp("Title", "Doc", rootScope, []string{"unknown", "cmd", "idem"})).To(Equal(
+				"# Title\n\nDoc\n\n" +
+					"## `cmd`\n\n```\ncmd a\n```\n\nCommand\n\n" +
+					"**Arguments**\n\n- `a`: arg\n\n" +
+					"**Examples**\n\n```\ncmd 1\n```\n\n" +
+					"## `idem`\n\n```\nidem value\n```\n\n",
+			))
+		})
+	})
+})
@@ -128,6 +128,8 @@ func ENSEMBLE_HELP_PREFIX(name core.Value, argspec ArgspecValue, options core.Co
 }
 
 type EnsembleCommand struct {
+	commandOrigin
+	commandDoc
 	metacommand *ensembleMetacommand
 	scope       *Scope
 	argspec     ArgspecValue
@@ -218,6 +220,16 @@ func (ensemble *EnsembleCommand) Execute(args []core.Value, context any) core.Re
 	}
 	return result
 }
+
+// Ensembles without required arguments are iterators when they define a
+// `next` subcommand, and are closable when they define a `close` subcommand
+func (ensemble *EnsembleCommand) IsIterator() bool {
+	return ensemble.argspec.Argspec.NbRequired == 0 &&
+		ensemble.scope.ResolveLocalCommand("next") != nil
+}
+func (ensemble *EnsembleCommand) IsClosable() bool {
+	return ensemble.scope.ResolveLocalCommand("close") != nil
+}
 func (ensemble *EnsembleCommand) Resume(result core.Result, context any) core.Result {
 	scope := context.(*Scope)
 	state := result.Data.(ensembleSubcommandState)
@@ -311,8 +323,9 @@ func (ensembleCmd) Execute(args []core.Value, context any) core.Result {
 			core.ResultCode_RETURN:
 			{
 				ensemble := NewEnsembleCommand(subscope, argspec)
+				ensemble.SetDoc(ExtractDocString(body.(core.ScriptValue).Script))
 				if name != nil {
-					result := scope.RegisterCommand(name, ensemble)
+					result := scope.defineCommand(name, ensemble)
 					if result.Code != core.ResultCode_OK {
 						return result
 					}
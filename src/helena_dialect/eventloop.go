@@ -0,0 +1,530 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"sort"
+	"sync"
+	"time"
+)
+
+//
+// Clocks
+//
+
+// Source of time for event loops
+type Clock interface {
+	// Current time
+	Now() time.Time
+
+	// Return a channel that receives the time once the duration has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Clock based on the system time
+var SystemClock Clock = systemClock{}
+
+// Manually advanced clock for deterministic tests
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+type fakeClockWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+func (clock *FakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- clock.now
+		return channel
+	}
+	clock.waiters = append(clock.waiters, fakeClockWaiter{clock.now.Add(d), channel})
+	return channel
+}
+
+// Move the clock forward and notify expired waiters
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+	waiters := clock.waiters[:0]
+	for _, waiter := range clock.waiters {
+		if waiter.deadline.After(clock.now) {
+			waiters = append(waiters, waiter)
+		} else {
+			waiter.channel <- clock.now
+		}
+	}
+	clock.waiters = waiters
+}
+
+//
+// Pending values
+//
+// Pending values are yielded by native commands that run background work in
+// a goroutine. Drivers block on them with Wait then yield the resulting value
+// back to the process; the command then resumes with ResumePendingResult.
+//
+
+var PendingValueType = core.CustomValueType{Name: "pending"}
+
+type PendingValue struct {
+	done   chan struct{}
+	result core.Result
+}
+
+// Run fn in a new goroutine and return its pending result
+func StartPending(fn func() core.Result) *PendingValue {
+	pending := &PendingValue{done: make(chan struct{})}
+	go func() {
+		defer close(pending.done)
+		pending.result = fn()
+	}()
+	return pending
+}
+
+func (*PendingValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (*PendingValue) CustomType() core.CustomValueType {
+	return PendingValueType
+}
+func (pending *PendingValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(pending)
+	}
+	return core.UndisplayableValueWithLabel("pending")
+}
+
+// Block until the background work completes and return its result value
+func (pending *PendingValue) Wait() core.Value {
+	<-pending.done
+	return pending.result.Value
+}
+
+// Yield the pending result of fn run in the background
+//
+// Commands returning this must implement core.ResumableCommand and call
+// ResumePendingResult from Resume
+func CreatePendingResult(fn func() core.Result) core.Result {
+	pending := StartPending(fn)
+	return core.YIELD_STATE(pending, pending)
+}
+
+// Complete a command that yielded a pending result
+//
+// The result code comes from the background work and the value is the one
+// yielded back to the process
+func ResumePendingResult(result core.Result) core.Result {
+	pending := result.Data.(*PendingValue)
+	if pending.result.Code == core.ResultCode_ERROR {
+		return pending.result
+	}
+	return core.Result{Code: pending.result.Code, Value: result.Value}
+}
+
+//
+// Event loop
+//
+// Event loops schedule processes as tasks that run in turn on the loop
+// goroutine:
+//
+// - tasks that yield plain values are rescheduled after other ready tasks
+// - tasks that yield waitable values are suspended until a helper goroutine
+//   has waited on them, then resumed with the waited value
+// - timers spawn new tasks when due
+//
+
+type Task struct {
+	process *Process
+	timer   *eventTimer
+	waiting WaitableValue
+	done    bool
+	result  core.Result
+}
+
+// Report whether the task has completed
+func (task *Task) Done() bool {
+	return task.done
+}
+
+// Return the task result once completed
+func (task *Task) Result() core.Result {
+	return task.result
+}
+
+type eventTimer struct {
+	id       int64
+	deadline time.Time
+	interval time.Duration
+	scope    *Scope
+	body     core.ScriptValue
+}
+
+type EventLoop struct {
+	clock       Clock
+	ready       []*Task
+	timers      []*eventTimer
+	waiting     int
+	lastTimerId int64
+
+	// Tasks whose waited operation has completed, filled by helper goroutines
+	// so that they never block on a stopped loop
+	mutex     sync.Mutex
+	completed []*Task
+	notify    chan struct{}
+}
+
+func NewEventLoop(clock Clock) *EventLoop {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &EventLoop{
+		clock:  clock,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Schedule a new task running the program in the given scope
+func (loop *EventLoop) Spawn(scope *Scope, program *core.Program) *Task {
+	return loop.SpawnProcess(scope.PrepareProcess(program))
+}
+
+// Schedule a new task running a prepared process
+func (loop *EventLoop) SpawnProcess(process *Process) *Task {
+	task := &Task{process: process}
+	loop.ready = append(loop.ready, task)
+	return task
+}
+
+// Drop the tasks ready to run, e.g. once the failure of another task has
+// been reported
+//
+// Timers and tasks waiting on operations are kept
+func (loop *EventLoop) DiscardReady() {
+	loop.ready = nil
+}
+
+// Schedule the body to run in the given scope after a delay, then every
+// interval if non-zero
+//
+// Return the timer ID
+func (loop *EventLoop) AddTimer(
+	scope *Scope,
+	body core.ScriptValue,
+	delay time.Duration,
+	interval time.Duration,
+) int64 {
+	loop.lastTimerId++
+	loop.insertTimer(&eventTimer{
+		id:       loop.lastTimerId,
+		deadline: loop.clock.Now().Add(delay),
+		interval: interval,
+		scope:    scope,
+		body:     body,
+	})
+	return loop.lastTimerId
+}
+
+// Cancel a timer
+//
+// Report false if no such timer is pending
+func (loop *EventLoop) CancelTimer(id int64) bool {
+	for i, timer := range loop.timers {
+		if timer.id == id {
+			loop.timers = append(loop.timers[:i], loop.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (loop *EventLoop) insertTimer(timer *eventTimer) {
+	i := sort.Search(len(loop.timers), func(i int) bool {
+		return loop.timers[i].deadline.After(timer.deadline)
+	})
+	loop.timers = append(loop.timers, nil)
+	copy(loop.timers[i+1:], loop.timers[i:])
+	loop.timers[i] = timer
+}
+
+// Report whether the loop has no more tasks, timers or waited operations
+func (loop *EventLoop) Idle() bool {
+	return len(loop.ready) == 0 && len(loop.timers) == 0 && loop.waiting == 0
+}
+
+// Run the loop until idle, blocking on timers and waited operations
+//
+// Stop at the first failing task and return its error
+func (loop *EventLoop) Run() core.Result {
+	result, _ := loop.run(loop.Idle, nil)
+	return result
+}
+
+// Run the loop until the task completes and return its result
+//
+// Other tasks and timers keep running meanwhile; stop at the first failing
+// task and return its error
+func (loop *EventLoop) RunTask(task *Task) core.Result {
+	result, _ := loop.run(func() bool { return task.done || loop.Idle() }, nil)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return task.result
+}
+
+// Run the loop until idle or until the interrupt channel is received from,
+// e.g. when a host has new input to process
+//
+// Report whether the loop was interrupted; stop at the first failing task and
+// return its error
+func (loop *EventLoop) RunUntil(interrupt <-chan struct{}) (core.Result, bool) {
+	return loop.run(loop.Idle, interrupt)
+}
+
+func (loop *EventLoop) run(done func() bool, interrupt <-chan struct{}) (core.Result, bool) {
+	for {
+		result := loop.RunPending()
+		if result.Code != core.ResultCode_OK {
+			return result, false
+		}
+		if done() {
+			return core.OK(core.NIL), false
+		}
+		if len(loop.ready) > 0 {
+			continue
+		}
+		var timeout <-chan time.Time
+		if len(loop.timers) > 0 {
+			timeout = loop.clock.After(loop.timers[0].deadline.Sub(loop.clock.Now()))
+		}
+		select {
+		case <-loop.notify:
+			loop.resumeCompleted()
+		case <-timeout:
+		case <-interrupt:
+			return core.OK(core.NIL), true
+		}
+	}
+}
+
+// Process completed operations, due timers and ready tasks without blocking
+//
+// Tasks rescheduled during the call are run on the next call; stop at the
+// first failing task and return its error
+func (loop *EventLoop) RunPending() core.Result {
+	loop.resumeCompleted()
+
+	now := loop.clock.Now()
+	for len(loop.timers) > 0 && !loop.timers[0].deadline.After(now) {
+		timer := loop.timers[0]
+		loop.timers = loop.timers[1:]
+		if timer.interval > 0 {
+			timer.deadline = timer.deadline.Add(timer.interval)
+			loop.insertTimer(timer)
+		}
+		program := timer.scope.CompileScriptValue(timer.body)
+		task := loop.Spawn(timer.scope, program)
+		task.timer = timer
+	}
+
+	ready := loop.ready
+	loop.ready = nil
+	for i, task := range ready {
+		result := loop.step(task)
+		if result.Code != core.ResultCode_OK {
+			loop.ready = append(ready[i+1:], loop.ready...)
+			return result
+		}
+	}
+	return core.OK(core.NIL)
+}
+
+// Record the completion of a waited operation and wake up the loop
+func (loop *EventLoop) complete(task *Task) {
+	loop.mutex.Lock()
+	loop.completed = append(loop.completed, task)
+	loop.mutex.Unlock()
+	select {
+	case loop.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (loop *EventLoop) resumeCompleted() {
+	loop.mutex.Lock()
+	completed := loop.completed
+	loop.completed = nil
+	loop.mutex.Unlock()
+	for _, task := range completed {
+		loop.waiting--
+		task.process.YieldBack(task.waiting.Wait())
+		task.waiting = nil
+		loop.ready = append(loop.ready, task)
+	}
+}
+
+func (loop *EventLoop) step(task *Task) core.Result {
+	result := task.process.Run()
+	if result.Code == core.ResultCode_YIELD {
+		if waitable, ok := result.Value.(WaitableValue); ok {
+			task.waiting = waitable
+			loop.waiting++
+			go func() {
+				waitable.Wait()
+				loop.complete(task)
+			}()
+		} else {
+			loop.ready = append(loop.ready, task)
+		}
+		return core.OK(core.NIL)
+	}
+
+	task.done = true
+	task.result = result
+	switch result.Code {
+	case core.ResultCode_OK,
+		core.ResultCode_RETURN:
+		return core.OK(core.NIL)
+	case core.ResultCode_ERROR:
+		return result
+	case core.ResultCode_BREAK:
+		if task.timer != nil && task.timer.interval > 0 {
+			// Stop repeating timer
+			loop.CancelTimer(task.timer.id)
+			return core.OK(core.NIL)
+		}
+	}
+	return core.ERROR("unexpected " + core.RESULT_CODE_NAME(result))
+}
+
+// Register the event loop commands in the scope
+func (loop *EventLoop) RegisterCommands(scope *Scope) {
+	scope.RegisterNamedCommand("after", afterCmd{loop})
+	scope.RegisterNamedCommand("every", everyCmd{loop})
+	scope.RegisterNamedCommand("spawn", spawnCmd{loop})
+}
+
+const AFTER_SIGNATURE = "after delay body"
+const AFTER_CANCEL_SIGNATURE = "after cancel id"
+
+type afterCmd struct{ loop *EventLoop }
+
+func (cmd afterCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 3 {
+		return ARITY_ERROR(AFTER_SIGNATURE)
+	}
+	if _, keyword := core.ValueToString(args[1]); keyword == "cancel" {
+		result, id := core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.BOOL(cmd.loop.CancelTimer(id)))
+	}
+	result, delay := valueToDuration(args[1], "invalid delay")
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	body := args[2]
+	if body.Type() != core.ValueType_SCRIPT {
+		return core.ERROR("body must be a script")
+	}
+	id := cmd.loop.AddTimer(scope, body.(core.ScriptValue), delay, 0)
+	return core.OK(core.INT(id))
+}
+func (afterCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(AFTER_SIGNATURE)
+	}
+	if len(args) > 1 {
+		if _, keyword := core.ValueToString(args[1]); keyword == "cancel" {
+			return core.OK(core.STR(AFTER_CANCEL_SIGNATURE))
+		}
+	}
+	return core.OK(core.STR(AFTER_SIGNATURE))
+}
+
+const EVERY_SIGNATURE = "every interval body"
+
+type everyCmd struct{ loop *EventLoop }
+
+func (cmd everyCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 3 {
+		return ARITY_ERROR(EVERY_SIGNATURE)
+	}
+	result, interval := valueToDuration(args[1], "invalid interval")
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	if interval == 0 {
+		return core.ERROR("invalid interval")
+	}
+	body := args[2]
+	if body.Type() != core.ValueType_SCRIPT {
+		return core.ERROR("body must be a script")
+	}
+	id := cmd.loop.AddTimer(scope, body.(core.ScriptValue), interval, interval)
+	return core.OK(core.INT(id))
+}
+func (everyCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(EVERY_SIGNATURE)
+	}
+	return core.OK(core.STR(EVERY_SIGNATURE))
+}
+
+const SPAWN_SIGNATURE = "spawn body"
+
+type spawnCmd struct{ loop *EventLoop }
+
+func (cmd spawnCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 2 {
+		return ARITY_ERROR(SPAWN_SIGNATURE)
+	}
+	body := args[1]
+	if body.Type() != core.ValueType_SCRIPT {
+		return core.ERROR("body must be a script")
+	}
+	subscope := scope.NewLocalScope(nil, nil)
+	program := subscope.CompileScriptValue(body.(core.ScriptValue))
+	cmd.loop.Spawn(subscope, program)
+	return core.OK(core.NIL)
+}
+func (spawnCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(SPAWN_SIGNATURE)
+	}
+	return core.OK(core.STR(SPAWN_SIGNATURE))
+}
+
+// Convert a non-negative number of milliseconds to a duration
+func valueToDuration(value core.Value, message string) (core.Result, time.Duration) {
+	result, ms := core.ValueToInteger(value)
+	if result.Code != core.ResultCode_OK {
+		return result, 0
+	}
+	if ms < 0 {
+		return core.ERROR(message), 0
+	}
+	return core.OK(value), time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,363 @@
+package helena_dialect_test
+
+import (
+	"runtime"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+type asyncCommand struct {
+	fn func(args []core.Value) core.Result
+}
+
+func (command asyncCommand) Execute(args []core.Value, _ any) core.Result {
+	return CreatePendingResult(func() core.Result { return command.fn(args) })
+}
+func (asyncCommand) Resume(result core.Result, _ any) core.Result {
+	return ResumePendingResult(result)
+}
+
+func goroutineStacks() string {
+	buf := make([]byte, 1<<20)
+	return string(buf[:runtime.Stack(buf, true)])
+}
+
+var _ = Describe("Helena event loop", func() {
+	var rootScope *Scope
+	var clock *FakeClock
+	var loop *EventLoop
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	spawn := func(script string) *Task {
+		return loop.Spawn(rootScope, rootScope.Compile(*parse(script)))
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+		clock = NewFakeClock(time.Unix(0, 0))
+		loop = NewEventLoop(clock)
+		loop.RegisterCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("after", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help after")).To(Equal(STR("after delay body")))
+				Expect(evaluate("help after cancel")).To(Equal(STR("after cancel id")))
+			})
+
+			It("should run body once the delay has elapsed", func() {
+				evaluate("set v 0")
+				Expect(evaluate("after 10 {set v 1}")).To(Equal(INT(1)))
+				Expect(loop.RunPending()).To(Equal(OK(NIL)))
+				Expect(evaluate("get v")).To(Equal(STR("0")))
+				clock.Advance(9 * time.Millisecond)
+				loop.RunPending()
+				Expect(evaluate("get v")).To(Equal(STR("0")))
+				clock.Advance(1 * time.Millisecond)
+				loop.RunPending()
+				Expect(evaluate("get v")).To(Equal(STR("1")))
+				Expect(loop.Idle()).To(BeTrue())
+			})
+			It("should fire timers in deadline order", func() {
+				evaluate("set l ()")
+				evaluate("after 20 {set l [list $l append (b)]}")
+				evaluate("after 10 {set l [list $l append (a)]}")
+				evaluate("after 20 {set l [list $l append (c)]}")
+				clock.Advance(30 * time.Millisecond)
+				loop.RunPending()
+				Expect(evaluate("get l")).To(Equal(
+					LIST([]core.Value{STR("a"), STR("b"), STR("c")}),
+				))
+			})
+			It("should cancel timers", func() {
+				evaluate("set v 0")
+				evaluate("set id [after 10 {set v 1}]")
+				Expect(evaluate("after cancel $id")).To(Equal(TRUE))
+				Expect(evaluate("after cancel $id")).To(Equal(FALSE))
+				clock.Advance(10 * time.Millisecond)
+				loop.RunPending()
+				Expect(evaluate("get v")).To(Equal(STR("0")))
+				Expect(loop.Idle()).To(BeTrue())
+			})
+		})
+
+		Describe("Control flow", func() {
+			It("should report body errors", func() {
+				evaluate("after 0 {error msg}")
+				Expect(loop.RunPending()).To(Equal(ERROR("msg")))
+			})
+			It("should reject unexpected result codes", func() {
+				evaluate("after 0 {break}")
+				Expect(loop.RunPending()).To(Equal(ERROR("unexpected break")))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("after 1")).To(Equal(
+					ERROR(`wrong # args: should be "after delay body"`),
+				))
+				Expect(execute("help after 1 {} a")).To(Equal(
+					ERROR(`wrong # args: should be "after delay body"`),
+				))
+			})
+			Specify("invalid delay", func() {
+				Expect(execute("after a {}")).To(Equal(ERROR(`invalid integer "a"`)))
+				Expect(execute("after -1 {}")).To(Equal(ERROR("invalid delay")))
+			})
+			Specify("invalid timer ID", func() {
+				Expect(execute("after cancel a")).To(Equal(ERROR(`invalid integer "a"`)))
+			})
+			Specify("non-script body", func() {
+				Expect(execute("after 1 a")).To(Equal(ERROR("body must be a script")))
+			})
+		})
+	})
+
+	Describe("every", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help every")).To(Equal(STR("every interval body")))
+			})
+
+			It("should run body repeatedly", func() {
+				evaluate("set v 0")
+				evaluate("set id [every 10 {set v [+ $v 1]}]")
+				for i := 1; i <= 3; i++ {
+					clock.Advance(10 * time.Millisecond)
+					loop.RunPending()
+					Expect(evaluate("get v")).To(Equal(INT(int64(i))))
+				}
+				evaluate("after cancel $id")
+				Expect(loop.Idle()).To(BeTrue())
+			})
+		})
+
+		Describe("Control flow", func() {
+			It("should stop on break", func() {
+				evaluate("set v 0")
+				evaluate("every 10 {set v [+ $v 1]; if [$v == 2] {break}}")
+				for i := 0; i < 3; i++ {
+					clock.Advance(10 * time.Millisecond)
+					Expect(loop.RunPending()).To(Equal(OK(NIL)))
+				}
+				Expect(evaluate("get v")).To(Equal(INT(2)))
+				Expect(loop.Idle()).To(BeTrue())
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("every 1")).To(Equal(
+					ERROR(`wrong # args: should be "every interval body"`),
+				))
+			})
+			Specify("invalid interval", func() {
+				Expect(execute("every 0 {}")).To(Equal(ERROR("invalid interval")))
+				Expect(execute("every -1 {}")).To(Equal(ERROR("invalid interval")))
+			})
+		})
+	})
+
+	Describe("spawn", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help spawn")).To(Equal(STR("spawn body")))
+			})
+
+			It("should interleave yielding tasks", func() {
+				evaluate("set l ()")
+				evaluate("spawn {set l [list $l append (a1)]; yield; set l [list $l append (a2)]}")
+				evaluate("spawn {set l [list $l append (b1)]; yield; set l [list $l append (b2)]}")
+				Expect(loop.Run()).To(Equal(OK(NIL)))
+				Expect(evaluate("get l")).To(Equal(
+					LIST([]core.Value{STR("a1"), STR("b1"), STR("a2"), STR("b2")}),
+				))
+			})
+			It("should access caller variables", func() {
+				evaluate("spawn {set v val}")
+				loop.Run()
+				Expect(evaluate("get v")).To(Equal(STR("val")))
+			})
+			It("should keep locals in the task", func() {
+				evaluate("spawn {local l val}")
+				loop.Run()
+				Expect(execute("get l").Code).To(Equal(core.ResultCode_ERROR))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("spawn")).To(Equal(
+					ERROR(`wrong # args: should be "spawn body"`),
+				))
+			})
+			Specify("non-script body", func() {
+				Expect(execute("spawn a")).To(Equal(ERROR("body must be a script")))
+			})
+		})
+	})
+
+	Describe("EventLoop", func() {
+		It("should return task results", func() {
+			task := spawn("idem val")
+			Expect(task.Done()).To(BeFalse())
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(task.Done()).To(BeTrue())
+			Expect(task.Result()).To(Equal(OK(STR("val"))))
+		})
+		It("should stop on the first failing task", func() {
+			spawn("error msg")
+			task := spawn("idem val")
+			Expect(loop.Run()).To(Equal(ERROR("msg")))
+			Expect(task.Done()).To(BeFalse())
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(task.Result()).To(Equal(OK(STR("val"))))
+		})
+		It("should drop ready tasks on demand", func() {
+			spawn("error msg")
+			task := spawn("idem val")
+			Expect(loop.Run()).To(Equal(ERROR("msg")))
+			loop.DiscardReady()
+			Expect(loop.Idle()).To(BeTrue())
+			Expect(task.Done()).To(BeFalse())
+		})
+		It("should run until a given task completes", func() {
+			evaluate("after 10 {set v 1}")
+			task := spawn("yield; idem val")
+			Expect(loop.RunTask(task)).To(Equal(OK(STR("val"))))
+			Expect(loop.Idle()).To(BeFalse())
+			Expect(loop.RunTask(spawn("error msg"))).To(Equal(ERROR("msg")))
+		})
+		It("should run spawned processes", func() {
+			process := prepareScript("")
+			process.SetResult(OK(STR("last")))
+			Expect(loop.RunTask(loop.SpawnProcess(process))).To(Equal(OK(STR("last"))))
+		})
+		It("should stop running when interrupted", func() {
+			evaluate("after 10 {set v 1}")
+			interrupt := make(chan struct{})
+			go func() { interrupt <- struct{}{} }()
+			result, interrupted := loop.RunUntil(interrupt)
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(interrupted).To(BeTrue())
+			clock.Advance(10 * time.Millisecond)
+			result, interrupted = loop.RunUntil(interrupt)
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(interrupted).To(BeFalse())
+			Expect(evaluate("get v")).To(Equal(STR("1")))
+		})
+		It("should block on timers until the clock advances", func() {
+			evaluate("after 10 {set v 1}")
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				clock.Advance(10 * time.Millisecond)
+			}()
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(evaluate("get v")).To(Equal(STR("1")))
+		})
+		It("should resume tasks waiting on channels", func() {
+			task := spawn("set c [chan 0]; after 10 {chan $c send val}; chan $c receive")
+			loop.RunPending()
+			Expect(task.Done()).To(BeFalse())
+			clock.Advance(10 * time.Millisecond)
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(task.Result()).To(Equal(OK(STR("val"))))
+		})
+
+		Describe("pending results", func() {
+			BeforeEach(func() {
+				rootScope.RegisterNamedCommand("async", asyncCommand{
+					func(args []core.Value) core.Result {
+						if len(args) == 2 {
+							return core.ERROR(asString(args[1]))
+						}
+						return core.OK(STR("done"))
+					},
+				})
+			})
+
+			It("should resume tasks with the background result", func() {
+				task := spawn("set v [async]; idem got-$v")
+				Expect(loop.Run()).To(Equal(OK(NIL)))
+				Expect(task.Result()).To(Equal(OK(STR("got-done"))))
+			})
+			It("should propagate background errors", func() {
+				spawn("async msg")
+				Expect(loop.Run()).To(Equal(ERROR("msg")))
+			})
+			It("should not block other tasks", func() {
+				blocker := make(chan struct{})
+				rootScope.RegisterNamedCommand("block", asyncCommand{
+					func(_ []core.Value) core.Result {
+						<-blocker
+						return core.OK(NIL)
+					},
+				})
+				task1 := spawn("block")
+				task2 := spawn("idem val")
+				loop.RunPending()
+				Expect(task1.Done()).To(BeFalse())
+				Expect(task2.Done()).To(BeTrue())
+				close(blocker)
+				Expect(loop.Run()).To(Equal(OK(NIL)))
+				Expect(task1.Done()).To(BeTrue())
+			})
+			It("should not leak waiters when the loop stops early", func() {
+				blocker := make(chan struct{})
+				rootScope.RegisterNamedCommand("block", asyncCommand{
+					func(_ []core.Value) core.Result {
+						<-blocker
+						return core.OK(STR("unblocked"))
+					},
+				})
+				task := spawn("block")
+				spawn("error msg")
+				Expect(loop.Run()).To(Equal(ERROR("msg")))
+				Expect(task.Done()).To(BeFalse())
+				close(blocker)
+				Eventually(goroutineStacks).ShouldNot(ContainSubstring("(*EventLoop).step"))
+				Expect(loop.Run()).To(Equal(OK(NIL)))
+				Expect(task.Result()).To(Equal(OK(STR("unblocked"))))
+			})
+			It("should work with processes run outside event loops", func() {
+				Expect(prepareScript("async").RunAndWait()).To(Equal(OK(STR("done"))))
+			})
+		})
+	})
+
+	Describe("FakeClock", func() {
+		It("should notify waiters when advanced", func() {
+			channel := clock.After(10 * time.Millisecond)
+			clock.Advance(5 * time.Millisecond)
+			Consistently(channel).ShouldNot(Receive())
+			clock.Advance(5 * time.Millisecond)
+			Eventually(channel).Should(Receive(Equal(time.Unix(0, 0).Add(10 * time.Millisecond))))
+		})
+	})
+})
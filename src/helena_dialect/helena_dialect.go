@@ -16,6 +16,17 @@ func InitCommandsForModule(
 	scope *Scope,
 	moduleRegistry *ModuleRegistry,
 	rootDir string,
+) {
+	initCommandsForModule(scope, moduleRegistry, rootDir, nil)
+}
+
+// Commands of module bodies track the file-based modules being loaded, so
+// that their imports can tell circular imports from concurrent ones
+func initCommandsForModule(
+	scope *Scope,
+	moduleRegistry *ModuleRegistry,
+	rootDir string,
+	loading []string,
 ) {
 	registerBasicCommands(scope)
 	registerVariableCommands(scope)
@@ -31,6 +42,7 @@ func InitCommandsForModule(
 	registerTupleCommands(scope)
 	registerScriptCommands(scope)
 	registerArgspecCommands(scope)
+	registerChannelCommands(scope)
 
 	scope.RegisterNamedCommand("scope", scopeCmd{})
 	scope.RegisterNamedCommand("namespace", namespaceCmd{})
@@ -40,11 +52,21 @@ func InitCommandsForModule(
 		scope,
 		moduleRegistry,
 		rootDir,
+		loading,
+	)
+	scope.RegisterNamedCommand(
+		"parallel",
+		newParallelCommand(moduleRegistry, rootDir, loading),
 	)
+	registerTemplateCommands(scope, moduleRegistry, rootDir)
 
 	scope.RegisterNamedCommand("macro", macroCmd{})
 	scope.RegisterNamedCommand("closure", closureCmd{})
 	scope.RegisterNamedCommand("proc", procCmd{})
 	scope.RegisterNamedCommand("coroutine", coroutineCmd{})
 	scope.RegisterNamedCommand("alias", aliasCmd{})
+	scope.RegisterNamedCommand("info", infoCmd{})
+	scope.RegisterNamedCommand("rename", renameCmd{})
+	scope.RegisterNamedCommand("unregister", unregisterCmd{})
+	scope.RegisterNamedCommand("interp", interpCmd{})
 }
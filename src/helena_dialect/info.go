@@ -0,0 +1,303 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"slices"
+)
+
+var infoSubcommands = NewSubcommands([]string{
+	"subcommands",
+	"variables",
+	"constants",
+	"commands",
+	"exists",
+	"kind",
+	"body",
+	"argspec",
+	"members",
+	"source",
+	"line",
+	"depth",
+})
+
+// Queries about the current process, answered by the process itself so
+// that processes sharing a scope never see each other
+var infoSourceQuery = &processQuery{func(process *Process) core.Value {
+	program := process.stack.CurrentContext().program
+	if program.Source == nil || program.Source.Filename == nil {
+		return core.NIL
+	}
+	return core.STR(*program.Source.Filename)
+}}
+var infoLineQuery = &processQuery{func(process *Process) core.Value {
+	context := process.stack.CurrentContext()
+	positions := context.program.OpCodePositions
+	pc := int(context.state.PC) - 1
+	if pc < 0 || pc >= len(positions) || positions[pc] == nil {
+		return core.NIL
+	}
+	return core.INT(int64(positions[pc].Line + 1))
+}}
+var infoDepthQuery = &processQuery{func(process *Process) core.Value {
+	return core.INT(int64(process.stack.Depth()))
+}}
+
+type infoCmd struct{}
+
+func (infoCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) == 1 {
+		return ARITY_ERROR("info ?subcommand? ?arg ...?")
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return ARITY_ERROR("info subcommands")
+		}
+		return core.OK(infoSubcommands.List)
+
+	case "variables":
+		if len(args) != 2 {
+			return ARITY_ERROR("info variables")
+		}
+		names := []string{}
+		for name, slot := range scope.localSlots {
+			if scope.localValues[slot] != nil {
+				names = append(names, name)
+			}
+		}
+		for name := range scope.Context.Variables {
+			names = append(names, name)
+		}
+		return core.OK(sortedNameList(names))
+
+	case "constants":
+		if len(args) != 2 {
+			return ARITY_ERROR("info constants")
+		}
+		names := []string{}
+		for name := range scope.Context.Constants {
+			names = append(names, name)
+		}
+		return core.OK(sortedNameList(names))
+
+	case "commands":
+		if len(args) != 2 {
+			return ARITY_ERROR("info commands")
+		}
+		names := []string{}
+		for context := scope.Context; context != nil; context = context.parent {
+			for name := range context.Commands {
+				if !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		return core.OK(sortedNameList(names))
+
+	case "exists":
+		if len(args) != 3 {
+			return ARITY_ERROR("info exists command")
+		}
+		switch args[2].Type() {
+		case core.ValueType_COMMAND:
+			_, deleted := scope.ResolveCommand(args[2]).(deletedCommand)
+			return core.OK(core.BOOL(!deleted))
+		case core.ValueType_TUPLE:
+			result, target, name := resolveCommandTarget(scope, args[2])
+			if result.Code != core.ResultCode_OK {
+				return core.OK(core.FALSE)
+			}
+			return core.OK(core.BOOL(target.HasLocalCommand(name)))
+		default:
+			result, name := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return core.OK(core.FALSE)
+			}
+			return core.OK(core.BOOL(scope.ResolveNamedCommand(name) != nil))
+		}
+
+	case "kind":
+		if len(args) != 3 {
+			return ARITY_ERROR("info kind command")
+		}
+		command := scope.ResolveCommand(args[2])
+		if command == nil {
+			return infoUnresolvedCommandError(args[2])
+		}
+		return core.OK(core.STR(commandKind(command)))
+
+	case "body":
+		if len(args) != 3 {
+			return ARITY_ERROR("info body command")
+		}
+		command := scope.ResolveCommand(args[2])
+		if command == nil {
+			return infoUnresolvedCommandError(args[2])
+		}
+		_, body, ok := commandDefinition(command)
+		if !ok {
+			return core.ERROR("command is not a proc, closure or macro")
+		}
+		return core.OK(body)
+
+	case "argspec":
+		if len(args) != 3 {
+			return ARITY_ERROR("info argspec command")
+		}
+		command := scope.ResolveCommand(args[2])
+		if command == nil {
+			return infoUnresolvedCommandError(args[2])
+		}
+		argspec, _, ok := commandDefinition(command)
+		if !ok {
+			return core.ERROR("command is not a proc, closure or macro")
+		}
+		return core.OK(argspec)
+
+	case "members":
+		if len(args) != 3 {
+			return ARITY_ERROR("info members namespace")
+		}
+		command := scope.ResolveCommand(args[2])
+		if command == nil {
+			return infoUnresolvedCommandError(args[2])
+		}
+		var namespace *namespaceCommand
+		switch command := command.(type) {
+		case *namespaceCommand:
+			namespace = command
+		case *namespaceMetacommand:
+			namespace = command.namespace
+		default:
+			return core.ERROR("command is not a namespace")
+		}
+		return core.OK(sortedNameList(namespace.scope.GetLocalCommandNames()))
+
+	case "source":
+		if len(args) != 2 {
+			return ARITY_ERROR("info source")
+		}
+		return core.YIELD(infoSourceQuery)
+
+	case "line":
+		if len(args) != 2 {
+			return ARITY_ERROR("info line")
+		}
+		return core.YIELD(infoLineQuery)
+
+	case "depth":
+		if len(args) != 2 {
+			return ARITY_ERROR("info depth")
+		}
+		return core.YIELD(infoDepthQuery)
+
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+func (infoCmd) Resume(result core.Result, _ any) core.Result {
+	return core.OK(result.Value)
+}
+func (infoCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(core.STR("info ?subcommand? ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	var signature string
+	var maxArgs int
+	switch subcommand {
+	case "subcommands",
+		"variables",
+		"constants",
+		"commands",
+		"source",
+		"line",
+		"depth":
+		signature, maxArgs = "info "+subcommand, 2
+	case "exists",
+		"kind",
+		"body",
+		"argspec":
+		signature, maxArgs = "info "+subcommand+" command", 3
+	case "members":
+		signature, maxArgs = "info members namespace", 3
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if len(args) > maxArgs {
+		return ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+func infoUnresolvedCommandError(value core.Value) core.Result {
+	result, name := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("cannot resolve command")
+	}
+	return core.ERROR(`cannot resolve command "` + name + `"`)
+}
+
+func sortedNameList(names []string) core.ListValue {
+	slices.Sort(names)
+	values := make([]core.Value, len(names))
+	for i, name := range names {
+		values[i] = core.STR(name)
+	}
+	return core.LIST(values)
+}
+
+// Return the kind of a command: proc, closure, macro, alias, ensemble,
+// namespace, scope, coroutine, module, or builtin for native commands
+func commandKind(command core.Command) string {
+	switch command.(type) {
+	case *procCommand, *procMetacommand:
+		return "proc"
+	case *closureCommand, *closureMetacommand:
+		return "closure"
+	case *macroCommand, *macroMetacommand:
+		return "macro"
+	case *aliasCommand, *aliasMetacommand:
+		return "alias"
+	case *EnsembleCommand, *ensembleMetacommand:
+		return "ensemble"
+	case *namespaceCommand, *namespaceMetacommand:
+		return "namespace"
+	case *scopeCommand:
+		return "scope"
+	case *coroutineCommand:
+		return "coroutine"
+	case *Module:
+		return "module"
+	default:
+		return "builtin"
+	}
+}
+
+// Return the argspec and body of procs, closures and macros
+func commandDefinition(command core.Command) (ArgspecValue, core.ScriptValue, bool) {
+	switch command := command.(type) {
+	case *procCommand:
+		return command.argspec, command.body, true
+	case *procMetacommand:
+		return command.proc.argspec, command.proc.body, true
+	case *closureCommand:
+		return command.argspec, command.body, true
+	case *closureMetacommand:
+		return command.closure.argspec, command.closure.body, true
+	case *macroCommand:
+		return command.argspec, command.body, true
+	case *macroMetacommand:
+		return command.macro.argspec, command.macro.body, true
+	default:
+		return ArgspecValue{}, core.ScriptValue{}, false
+	}
+}
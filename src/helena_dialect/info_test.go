@@ -0,0 +1,222 @@
+package helena_dialect_test
+
+import (
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena introspection", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("info", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help info")).To(Equal(STR("info ?subcommand? ?arg ...?")))
+			})
+			Specify("subcommands", func() {
+				Expect(evaluate("info subcommands")).To(Equal(
+					evaluate(
+						"list (subcommands variables constants commands exists kind body argspec members source line depth)",
+					),
+				))
+			})
+		})
+
+		Describe("Scope introspection", func() {
+			Specify("variables", func() {
+				evaluate("set b 1; set a 2; let c 3")
+				Expect(evaluate("info variables")).To(Equal(
+					evaluate("list (a b)"),
+				))
+				Expect(evaluate("proc cmd {x} {set y 1; info variables}; cmd 0")).To(Equal(
+					evaluate("list (x y)"),
+				))
+			})
+			Specify("constants", func() {
+				evaluate("let b 1; let a 2; set c 3")
+				Expect(evaluate("info constants")).To(Equal(
+					evaluate("list (a b)"),
+				))
+			})
+			Specify("commands", func() {
+				commands := evaluate("info commands").(core.ListValue)
+				Expect(commands.Values).To(ContainElements(STR("info"), STR("proc"), STR("set")))
+				Expect(commands.Values).NotTo(ContainElement(STR("foo")))
+				scoped := evaluate("scope s {macro foo {} {}}; s eval {info commands}").(core.ListValue)
+				Expect(scoped.Values).To(ContainElements(STR("foo"), STR("info")))
+			})
+			Specify("depth", func() {
+				Expect(evaluate("info depth")).To(Equal(INT(1)))
+				Expect(evaluate("proc cmd {} {info depth}; cmd")).To(Equal(INT(2)))
+				Expect(evaluate("proc cmd2 {} {cmd}; cmd2")).To(Equal(INT(3)))
+			})
+			Specify("depth of concurrent processes sharing a scope", func() {
+				results := make([]core.Result, 200)
+				var wg sync.WaitGroup
+				for i := range results {
+					program := rootScope.Compile(*parse("info depth"))
+					if i%2 == 1 {
+						program = rootScope.Compile(*parse("eval {info depth}"))
+					}
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						results[i] = rootScope.PrepareProcess(program).Run()
+					}(i)
+				}
+				wg.Wait()
+				for i, result := range results {
+					Expect(result).To(Equal(OK(INT(int64(1 + i%2)))))
+				}
+			})
+			Specify("source and line", func() {
+				parser = core.NewParser(&core.ParserOptions{CapturePositions: true})
+				rootScope = NewRootScope(&ScopeOptions{CapturePositions: true})
+				InitCommands(rootScope)
+
+				filename := "file.hl"
+				source := `
+set a 1
+list ([info source] [info line])
+`
+				script := parser.ParseTokens(
+					tokenizer.Tokenize(source),
+					&core.Source{Filename: &filename},
+				).Script
+				Expect(rootScope.PrepareProcess(rootScope.Compile(*script)).Run()).To(Equal(
+					OK(LIST([]core.Value{STR("file.hl"), INT(3)})),
+				))
+			})
+			Specify("source and line without positions", func() {
+				Expect(evaluate("info source")).To(Equal(NIL))
+				Expect(evaluate("info line")).To(Equal(NIL))
+			})
+		})
+
+		Describe("Command introspection", func() {
+			Specify("kind", func() {
+				evaluate("proc p {} {}")
+				evaluate("closure c {} {}")
+				evaluate("macro m {} {}")
+				evaluate("alias a p")
+				evaluate("ensemble e {} {}")
+				evaluate("namespace n {}")
+				evaluate("scope s {}")
+				evaluate("set co [coroutine {}]")
+				Expect(evaluate("info kind p")).To(Equal(STR("proc")))
+				Expect(evaluate("info kind c")).To(Equal(STR("closure")))
+				Expect(evaluate("info kind m")).To(Equal(STR("macro")))
+				Expect(evaluate("info kind a")).To(Equal(STR("alias")))
+				Expect(evaluate("info kind e")).To(Equal(STR("ensemble")))
+				Expect(evaluate("info kind n")).To(Equal(STR("namespace")))
+				Expect(evaluate("info kind s")).To(Equal(STR("scope")))
+				Expect(evaluate("info kind $co")).To(Equal(STR("coroutine")))
+				Expect(evaluate("info kind set")).To(Equal(STR("builtin")))
+				Expect(evaluate("info kind [proc {} {}]")).To(Equal(STR("proc")))
+			})
+			Specify("body", func() {
+				Expect(evaluate("info body [proc {} {idem a}]").(core.ScriptValue).Source).To(HaveValue(Equal(
+					"idem a",
+				)))
+				Expect(evaluate("closure cmd {} {idem b}; info body cmd").(core.ScriptValue).Source).To(HaveValue(Equal(
+					"idem b",
+				)))
+				Expect(evaluate("macro cmd {} {idem c}; info body cmd").(core.ScriptValue).Source).To(HaveValue(Equal(
+					"idem c",
+				)))
+			})
+			Specify("argspec", func() {
+				Expect(evaluate("proc cmd {a ?b} {}; info argspec cmd")).To(Equal(
+					evaluate("argspec {a ?b}"),
+				))
+				Expect(evaluate("argspec [info argspec cmd] usage")).To(Equal(
+					STR("a ?b?"),
+				))
+			})
+			Specify("members", func() {
+				evaluate("namespace ns {macro b {} {}; macro a {} {}}")
+				Expect(evaluate("info members ns")).To(Equal(evaluate("list (a b)")))
+				Expect(evaluate("info members [ns]")).To(Equal(evaluate("list (a b)")))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("info")).To(Equal(
+					ERROR(`wrong # args: should be "info ?subcommand? ?arg ...?"`),
+				))
+				Expect(execute("info variables a")).To(Equal(
+					ERROR(`wrong # args: should be "info variables"`),
+				))
+				Expect(execute("info kind")).To(Equal(
+					ERROR(`wrong # args: should be "info kind command"`),
+				))
+				Expect(execute("help info kind a b")).To(Equal(
+					ERROR(`wrong # args: should be "info kind command"`),
+				))
+				Expect(execute("info members")).To(Equal(
+					ERROR(`wrong # args: should be "info members namespace"`),
+				))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute("info unknownSubcommand")).To(Equal(
+					ERROR(`unknown subcommand "unknownSubcommand"`),
+				))
+			})
+			Specify("invalid subcommand name", func() {
+				Expect(execute("info []")).To(Equal(ERROR("invalid subcommand name")))
+			})
+			Specify("unresolved commands", func() {
+				Expect(execute("info kind unknownCommand")).To(Equal(
+					ERROR(`cannot resolve command "unknownCommand"`),
+				))
+				Expect(execute("info body unknownCommand")).To(Equal(
+					ERROR(`cannot resolve command "unknownCommand"`),
+				))
+			})
+			Specify("non-proc commands", func() {
+				Expect(execute("info body set")).To(Equal(
+					ERROR("command is not a proc, closure or macro"),
+				))
+				Expect(execute("info argspec set")).To(Equal(
+					ERROR("command is not a proc, closure or macro"),
+				))
+			})
+			Specify("non-namespace commands", func() {
+				Expect(execute("info members set")).To(Equal(
+					ERROR("command is not a namespace"),
+				))
+			})
+		})
+	})
+})
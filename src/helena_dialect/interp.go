@@ -0,0 +1,365 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"slices"
+)
+
+// Commands never available in safe interpreters
+//
+// These commands either load modules, create unrestricted root scopes, or
+// block on other goroutines. Nested interpreters remain available as they
+// inherit the limits of their parent. Other file-reading commands such as
+// `template file` remain available but read from an empty file system
+var unsafeInterpCommands = []string{
+	"import",
+	"module",
+	"parallel",
+	"chan",
+	"select",
+}
+
+type InterpOptions struct {
+	// Whitelisted commands, nil for all safe commands
+	Commands []string
+
+	// Maximum number of commands executed per evaluation, 0 for no limit
+	MaxSteps uint
+
+	// Maximum process stack depth, 0 for no limit
+	MaxDepth uint
+
+	// Maximum size of values built during execution or returned by commands,
+	// counting string bytes and collection elements including nested values,
+	// 0 for no limit
+	MaxValueSize uint
+}
+
+type interpLimits struct {
+	options InterpOptions
+	steps   uint
+	parent  *interpLimits
+}
+
+// Wrap a command resolved within a safe interpreter to enforce its limits
+func (limits *interpLimits) wrap(command core.Command) core.Command {
+	if command == core.LAST_RESULT || command == core.SHIFT_LAST_FRAME_RESULT {
+		return command
+	}
+	return limitedCommand{command, limits}
+}
+
+// Check the limits of the interpreter and its ancestors before each step
+//
+// Steps count against the budget of every enclosing interpreter
+func (limits *interpLimits) check(process *Process) core.Result {
+	for ; limits != nil; limits = limits.parent {
+		limits.steps++
+		if limits.options.MaxSteps > 0 && limits.steps > limits.options.MaxSteps {
+			return core.ERROR("step limit exceeded")
+		}
+		if limits.options.MaxDepth > 0 &&
+			process != nil &&
+			process.stack.Depth() > limits.options.MaxDepth {
+			return core.ERROR("recursion limit exceeded")
+		}
+	}
+	return core.OK(core.NIL)
+}
+
+// Get the strictest value size limit of the interpreter and its ancestors
+func (limits *interpLimits) maxValueSize() uint {
+	var size uint
+	for ; limits != nil; limits = limits.parent {
+		if limits.options.MaxValueSize > 0 &&
+			(size == 0 || limits.options.MaxValueSize < size) {
+			size = limits.options.MaxValueSize
+		}
+	}
+	return size
+}
+func (limits *interpLimits) checkResult(result core.Result) core.Result {
+	maxSize := limits.maxValueSize()
+	if maxSize == 0 || result.Value == nil {
+		return result
+	}
+	if core.ValueSize(result.Value) > maxSize {
+		return core.ERROR("value size limit exceeded")
+	}
+	return result
+}
+
+type limitedCommand struct {
+	command core.Command
+	limits  *interpLimits
+}
+
+func (command limitedCommand) Execute(args []core.Value, context any) core.Result {
+	// Depth is checked by processes when executing their contexts
+	result := command.limits.check(nil)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return command.limits.checkResult(command.command.Execute(args, context))
+}
+func (command limitedCommand) Resume(result core.Result, context any) core.Result {
+	resumable, ok := command.command.(core.ResumableCommand)
+	if !ok {
+		return core.OK(result.Value)
+	}
+	return command.limits.checkResult(resumable.Resume(result, context))
+}
+func (command limitedCommand) Help(args []core.Value, options core.CommandHelpOptions, context any) core.Result {
+	if c, ok := command.command.(core.CommandWithHelp); ok {
+		return c.Help(args, options, context)
+	}
+	return core.ERROR("no help for command")
+}
+
+// Safe child interpreter
+//
+// Child interpreters run in their own root scope with a restricted set of
+// commands and resource limits. Values cross the interpreter boundary as deep
+// copies
+type Interp struct {
+	Scope  *Scope
+	limits *interpLimits
+}
+
+func NewInterp(options *InterpOptions) (core.Result, *Interp) {
+	return newInterp(options, nil)
+}
+
+// Create a child interpreter that never exceeds the limits of its parent
+func newInterp(options *InterpOptions, parent *interpLimits) (core.Result, *Interp) {
+	if options == nil {
+		options = &InterpOptions{}
+	}
+	scope := NewRootScope(nil)
+	scope.setLimits(&interpLimits{options: *options, parent: parent})
+	InitCommandsForModule(scope, NewModuleRegistry(&ModuleOptions{FS: EmptyFS}), "")
+	for _, name := range unsafeInterpCommands {
+		delete(scope.Context.Commands, name)
+	}
+	if options.Commands != nil {
+		for _, name := range options.Commands {
+			if !scope.HasLocalCommand(name) {
+				return core.ERROR(
+					`command "` + name + `" is not available in safe interpreters`,
+				), nil
+			}
+		}
+		for _, name := range scope.GetLocalCommandNames() {
+			if !slices.Contains(options.Commands, name) {
+				delete(scope.Context.Commands, name)
+			}
+		}
+	}
+	return core.OK(core.NIL), &Interp{scope, scope.limits}
+}
+
+// Alias a parent command into the child interpreter
+//
+// The command is executed in the parent scope with deep copies of its
+// arguments; its result is copied back into the child
+func (interp *Interp) Alias(name string, parent *Scope, command core.Value) {
+	interp.Scope.RegisterNamedCommand(name, interpAliasCommand{parent, command})
+}
+
+// Evaluate a script in the child interpreter and return a copy of its result
+func (interp *Interp) Eval(script core.ScriptValue) core.Result {
+	result, clone := DeepCopyValue(script)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	interp.limits.steps = 0
+	program := interp.Scope.CompileScriptValue(clone.(core.ScriptValue))
+	return copyInterpResult(interp.Scope.PrepareProcess(program).Run())
+}
+
+// Copy a result across interpreter boundaries
+func copyInterpResult(result core.Result) core.Result {
+	switch result.Code {
+	case core.ResultCode_OK,
+		core.ResultCode_RETURN:
+		result, value := DeepCopyValue(result.Value)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(value)
+	case core.ResultCode_ERROR:
+		result, value := DeepCopyValue(result.Value)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.Result{Code: core.ResultCode_ERROR, Value: value}
+	default:
+		return core.ERROR("unexpected " + core.RESULT_CODE_NAME(result))
+	}
+}
+
+type interpAliasCommand struct {
+	parent  *Scope
+	command core.Value
+}
+
+func (alias interpAliasCommand) Execute(args []core.Value, _ any) core.Result {
+	result, values := deepCopyValues(args[1:])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	cmdline := append([]core.Value{alias.command}, values...)
+	program := alias.parent.CompileArgs(cmdline)
+	return copyInterpResult(alias.parent.PrepareProcess(program).RunAndWait())
+}
+
+//
+// Interp commands
+//
+
+type interpCommand struct {
+	value  core.Value
+	scope  *Scope
+	interp *Interp
+}
+
+func newInterpCommand(scope *Scope, interp *Interp) *interpCommand {
+	cmd := &interpCommand{}
+	cmd.value = core.NewCommandValue(cmd)
+	cmd.scope = scope
+	cmd.interp = interp
+	return cmd
+}
+
+var interpSubcommands = NewSubcommands([]string{
+	"subcommands",
+	"eval",
+	"alias",
+})
+
+func (cmd *interpCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return ARITY_ERROR("<interp> subcommands")
+		}
+		return core.OK(interpSubcommands.List)
+
+	case "eval":
+		if len(args) != 3 {
+			return ARITY_ERROR("<interp> eval body")
+		}
+		if args[2].Type() != core.ValueType_SCRIPT {
+			return core.ERROR("body must be a script")
+		}
+		return cmd.interp.Eval(args[2].(core.ScriptValue))
+
+	case "alias":
+		if len(args) != 4 {
+			return ARITY_ERROR("<interp> alias name command")
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid command name")
+		}
+		cmd.interp.Alias(name, cmd.scope, args[3])
+		return core.OK(core.NIL)
+
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+func (*interpCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(core.STR("<interp> ?subcommand? ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	var signature string
+	var maxArgs int
+	switch subcommand {
+	case "subcommands":
+		signature, maxArgs = "<interp> subcommands", 2
+	case "eval":
+		signature, maxArgs = "<interp> eval body", 3
+	case "alias":
+		signature, maxArgs = "<interp> alias name command", 4
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if len(args) > maxArgs {
+		return ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+const INTERP_SIGNATURE = "interp ?options?"
+
+type interpCmd struct{}
+
+func (interpCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	options := InterpOptions{}
+	switch len(args) {
+	case 1:
+	case 2:
+		result, entries := ValueToMap(args[1])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		for key, value := range entries {
+			switch key {
+			case "commands":
+				result, values := ValueToArray(value)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				options.Commands = make([]string, len(values))
+				for i, value := range values {
+					result, name := core.ValueToString(value)
+					if result.Code != core.ResultCode_OK {
+						return core.ERROR("invalid command name")
+					}
+					options.Commands[i] = name
+				}
+			case "steps", "depth", "size":
+				result, limit := core.ValueToInteger(value)
+				if result.Code != core.ResultCode_OK || limit < 0 {
+					return core.ERROR(`invalid limit "` + key + `"`)
+				}
+				switch key {
+				case "steps":
+					options.MaxSteps = uint(limit)
+				case "depth":
+					options.MaxDepth = uint(limit)
+				case "size":
+					options.MaxValueSize = uint(limit)
+				}
+			default:
+				return core.ERROR(`unknown option "` + key + `"`)
+			}
+		}
+	default:
+		return ARITY_ERROR(INTERP_SIGNATURE)
+	}
+	result, interp := newInterp(&options, scope.limits)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return core.OK(newInterpCommand(scope, interp).value)
+}
+func (interpCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(INTERP_SIGNATURE)
+	}
+	return core.OK(core.STR(INTERP_SIGNATURE))
+}
@@ -0,0 +1,269 @@
+package helena_dialect_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena safe interpreters", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("Go API", func() {
+		script := func(source string) core.ScriptValue {
+			return core.NewScriptValue(*parse(source), source)
+		}
+
+		It("should evaluate scripts in a separate root scope", func() {
+			_, interp := NewInterp(nil)
+			rootScope.SetNamedVariable("var", STR("parent"))
+			Expect(interp.Eval(script("set var child; get var"))).To(Equal(OK(STR("child"))))
+			Expect(evaluate("get var")).To(Equal(STR("parent")))
+			Expect(interp.Eval(script("get var"))).To(Equal(OK(STR("child"))))
+		})
+		It("should exclude unsafe commands", func() {
+			_, interp := NewInterp(nil)
+			for _, name := range []string{"import", "module", "parallel", "chan", "select"} {
+				Expect(interp.Scope.HasLocalCommand(name)).To(BeFalse())
+			}
+			Expect(interp.Scope.HasLocalCommand("set")).To(BeTrue())
+		})
+		It("should not read files", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "secret.tpl")
+			Expect(os.WriteFile(path, []byte("secret"), 0o644)).To(Succeed())
+			_, interp := NewInterp(nil)
+			Expect(interp.Eval(script("template file secret.tpl"))).To(Equal(
+				ERROR("error reading template: open secret.tpl: file does not exist"),
+			))
+			Expect(interp.Eval(script("template render {a$[idem b]c}"))).To(Equal(OK(STR("abc"))))
+			Expect(interp.Eval(script("template file " + path))).To(Equal(
+				ERROR(`module path "` + path + `" escapes the module root`),
+			))
+		})
+		It("should restrict commands to the whitelist", func() {
+			result, interp := NewInterp(&InterpOptions{Commands: []string{"idem", "list"}})
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(interp.Eval(script("idem val"))).To(Equal(OK(STR("val"))))
+			Expect(interp.Eval(script("set var val"))).To(Equal(
+				ERROR(`cannot resolve command "set"`),
+			))
+		})
+		It("should reject unsafe whitelisted commands", func() {
+			result, interp := NewInterp(&InterpOptions{Commands: []string{"idem", "import"}})
+			Expect(result).To(Equal(ERROR(`command "import" is not available in safe interpreters`)))
+			Expect(interp).To(BeNil())
+		})
+		It("should alias parent commands", func() {
+			rootScope.RegisterNamedCommand("cmd", simpleCommand{
+				execute: func(args []core.Value, _ any) core.Result {
+					return OK(LIST(args[1:]))
+				},
+			})
+			_, interp := NewInterp(nil)
+			interp.Alias("parentCmd", rootScope, STR("cmd"))
+			Expect(interp.Eval(script("parentCmd a b"))).To(Equal(
+				OK(LIST([]core.Value{STR("a"), STR("b")})),
+			))
+		})
+		It("should copy results across the boundary", func() {
+			_, interp := NewInterp(nil)
+			Expect(interp.Eval(script("list (a b)"))).To(Equal(
+				OK(LIST([]core.Value{STR("a"), STR("b")})),
+			))
+			Expect(interp.Eval(script("macro cmd {} {}"))).To(Equal(
+				ERROR("value cannot be shared across interpreters"),
+			))
+		})
+		It("should reject unexpected result codes", func() {
+			_, interp := NewInterp(nil)
+			Expect(interp.Eval(script("break"))).To(Equal(ERROR("unexpected break")))
+			Expect(interp.Eval(script("return val"))).To(Equal(OK(STR("val"))))
+			Expect(interp.Eval(script("error msg"))).To(Equal(ERROR("msg")))
+		})
+
+		Describe("Limits", func() {
+			Specify("steps", func() {
+				_, interp := NewInterp(&InterpOptions{MaxSteps: 10})
+				Expect(interp.Eval(script("idem a; idem b"))).To(Equal(OK(STR("b"))))
+				Expect(interp.Eval(script("while true {}"))).To(Equal(
+					ERROR("step limit exceeded"),
+				))
+				Expect(interp.Eval(script("idem c"))).To(Equal(OK(STR("c"))))
+			})
+			Specify("depth", func() {
+				_, interp := NewInterp(&InterpOptions{MaxDepth: 20})
+				Expect(interp.Eval(script("proc f {n} {if [$n == 0] {idem 0} else {f [- $n 1]}}; f 3"))).To(Equal(
+					OK(STR("0")),
+				))
+				Expect(interp.Eval(script("proc g {} {g}; g"))).To(Equal(
+					ERROR("recursion limit exceeded"),
+				))
+			})
+			Specify("value size", func() {
+				_, interp := NewInterp(&InterpOptions{MaxValueSize: 3})
+				Expect(interp.Eval(script("idem abc"))).To(Equal(OK(STR("abc"))))
+				Expect(interp.Eval(script("idem abcd"))).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+				Expect(interp.Eval(script("list (a b c d)"))).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+			})
+			Specify("nested value size", func() {
+				_, interp := NewInterp(&InterpOptions{MaxValueSize: 8})
+				Expect(interp.Eval(script("list (ab cd)"))).To(Equal(
+					OK(LIST([]core.Value{STR("ab"), STR("cd")})),
+				))
+				Expect(interp.Eval(script("list (abcd efgh)"))).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+				Expect(interp.Eval(script("list ((a b) (c d))"))).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+			})
+			Specify("built value size", func() {
+				_, interp := NewInterp(&InterpOptions{MaxValueSize: 8})
+				Expect(interp.Eval(script(`set s abcd; idem "$s$s"`))).To(Equal(
+					OK(STR("abcdabcd")),
+				))
+				Expect(interp.Eval(script(`set s abcd; set t "$s$s$s"`))).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+			})
+		})
+	})
+
+	Describe("interp", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help interp")).To(Equal(STR("interp ?options?")))
+			})
+			Specify("subcommands", func() {
+				Expect(evaluate("[interp] subcommands")).To(Equal(
+					evaluate("list (subcommands eval alias)"),
+				))
+			})
+
+			It("should return an interp object", func() {
+				value := evaluate("set i [interp]")
+				Expect(evaluate("$i")).To(Equal(value))
+			})
+			It("should evaluate bodies in the child", func() {
+				evaluate("set var parent")
+				evaluate("set i [interp]")
+				Expect(evaluate("$i eval {set var child}")).To(Equal(STR("child")))
+				Expect(evaluate("get var")).To(Equal(STR("parent")))
+				Expect(execute("$i eval {get var}")).To(Equal(OK(STR("child"))))
+			})
+			It("should alias parent commands", func() {
+				evaluate("set i [interp]")
+				evaluate("set log ()")
+				evaluate("macro record {value} {set log ([get log] $value)}")
+				Expect(execute("$i alias rec record")).To(Equal(OK(NIL)))
+				evaluate("$i eval {rec a; rec b}")
+				Expect(evaluate("get log")).To(Equal(evaluate("idem ((() a) b)")))
+			})
+			It("should accept command whitelists", func() {
+				evaluate("set i [interp [dict (commands (idem))]]")
+				Expect(evaluate("$i eval {idem val}")).To(Equal(STR("val")))
+				Expect(execute("$i eval {set a b}")).To(Equal(
+					ERROR(`cannot resolve command "set"`),
+				))
+			})
+			It("should accept limits", func() {
+				evaluate("set i [interp (steps 100 depth 10 size 3)]")
+				Expect(execute("$i eval {while true {}}")).To(Equal(
+					ERROR("step limit exceeded"),
+				))
+				Expect(execute("$i eval {proc f {} {f}; f}")).To(Equal(
+					ERROR("recursion limit exceeded"),
+				))
+				Expect(execute("$i eval {idem abcd}")).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+			})
+			It("should enforce parent limits in nested interps", func() {
+				evaluate("set i [interp (steps 50)]")
+				Expect(execute("$i eval {[interp] eval {loop k [range 100000] {idem $k}}}")).To(Equal(
+					ERROR("step limit exceeded"),
+				))
+				Expect(execute("$i eval {[interp (steps 1000)] eval {while true {}}}")).To(Equal(
+					ERROR("step limit exceeded"),
+				))
+				evaluate("set i [interp (depth 10)]")
+				Expect(execute("$i eval {[interp] eval {proc f {} {f}; f}}")).To(Equal(
+					ERROR("recursion limit exceeded"),
+				))
+				evaluate("set i [interp (size 3)]")
+				Expect(execute("$i eval {[interp (size 10)] eval {idem abcd}}")).To(Equal(
+					ERROR("value size limit exceeded"),
+				))
+				Expect(evaluate("$i eval {[interp] eval {idem abc}}")).To(Equal(STR("abc")))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("interp a b")).To(Equal(
+					ERROR(`wrong # args: should be "interp ?options?"`),
+				))
+				Expect(execute("[interp] eval")).To(Equal(
+					ERROR(`wrong # args: should be "<interp> eval body"`),
+				))
+				Expect(execute("[interp] alias a")).To(Equal(
+					ERROR(`wrong # args: should be "<interp> alias name command"`),
+				))
+			})
+			Specify("unknown option", func() {
+				Expect(execute("interp (foo 1)")).To(Equal(ERROR(`unknown option "foo"`)))
+			})
+			Specify("invalid limit", func() {
+				Expect(execute("interp (steps a)")).To(Equal(ERROR(`invalid limit "steps"`)))
+				Expect(execute("interp (depth -1)")).To(Equal(ERROR(`invalid limit "depth"`)))
+			})
+			Specify("unavailable command", func() {
+				Expect(execute("interp (commands (import))")).To(Equal(
+					ERROR(`command "import" is not available in safe interpreters`),
+				))
+			})
+			Specify("non-script body", func() {
+				Expect(execute("[interp] eval a")).To(Equal(ERROR("body must be a script")))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute("[interp] unknownSubcommand")).To(Equal(
+					ERROR(`unknown subcommand "unknownSubcommand"`),
+				))
+			})
+		})
+	})
+})
@@ -0,0 +1,183 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"math"
+)
+
+// Commands implementing the iterator protocol
+//
+// Iterators respond to the `next` subcommand by returning the next value, or
+// BREAK when exhausted. Closable iterators also respond to the `close`
+// subcommand, which consumers call when they stop iterating early.
+type IteratorCommand interface {
+	core.Command
+
+	// Report whether the command implements the iterator protocol
+	IsIterator() bool
+
+	// Report whether the iterator must be closed after early termination
+	IsClosable() bool
+}
+
+// Return the iterator command for a value, if any
+func resolveIterator(scope *Scope, value core.Value) IteratorCommand {
+	command := scope.ResolveCommand(value)
+	if command == nil {
+		return nil
+	}
+	iterator, ok := command.(IteratorCommand)
+	if !ok || !iterator.IsIterator() {
+		return nil
+	}
+	return iterator
+}
+
+type iteratorCollectState struct {
+	scope       *Scope
+	nextProgram *core.Program
+	values      []core.Value
+	done        func(values []core.Value) core.Result
+}
+
+// Collect all values of an iterator and pass them to done
+func collectIteratorValues(
+	scope *Scope,
+	iterator core.Value,
+	done func(values []core.Value) core.Result,
+) core.Result {
+	state := &iteratorCollectState{
+		scope:       scope,
+		nextProgram: scope.CompileArgs([]core.Value{iterator, core.STR("next")}),
+		values:      []core.Value{},
+		done:        done,
+	}
+	return collectIteratorNext(state)
+}
+func collectIteratorNext(state *iteratorCollectState) core.Result {
+	return CreateContinuationValueWithCallback(
+		state.scope,
+		state.nextProgram,
+		state,
+		func(result core.Result, data any) core.Result {
+			state := data.(*iteratorCollectState)
+			switch result.Code {
+			case core.ResultCode_OK:
+				state.values = append(state.values, result.Value)
+				return collectIteratorNext(state)
+			case core.ResultCode_BREAK:
+				return state.done(state.values)
+			default:
+				return result
+			}
+		},
+	)
+}
+
+//
+// Lazy integer ranges
+//
+
+type rangeCommand struct {
+	value   core.Value
+	current int64
+	end     int64
+	step    int64
+
+	// Whether the next value would overflow
+	done bool
+}
+
+func newRangeCommand(start int64, end int64, step int64) *rangeCommand {
+	cmd := &rangeCommand{current: start, end: end, step: step}
+	cmd.value = core.NewCommandValue(cmd)
+	return cmd
+}
+
+var rangeSubcommands = NewSubcommands([]string{
+	"subcommands",
+	"next",
+})
+
+func (cmd *rangeCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return ARITY_ERROR("<range> subcommands")
+		}
+		return core.OK(rangeSubcommands.List)
+
+	case "next":
+		if len(args) != 2 {
+			return ARITY_ERROR("<range> next")
+		}
+		if cmd.done ||
+			(cmd.step > 0 && cmd.current >= cmd.end) ||
+			(cmd.step < 0 && cmd.current <= cmd.end) {
+			return core.BREAK(core.NIL)
+		}
+		value := core.INT(cmd.current)
+		if (cmd.step > 0 && cmd.current > math.MaxInt64-cmd.step) ||
+			(cmd.step < 0 && cmd.current < math.MinInt64-cmd.step) {
+			cmd.done = true
+		} else {
+			cmd.current += cmd.step
+		}
+		return core.OK(value)
+
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+func (*rangeCommand) IsIterator() bool { return true }
+func (*rangeCommand) IsClosable() bool { return false }
+
+const RANGE_SIGNATURE = "range ?start? end ?step?"
+
+type rangeCmd struct{}
+
+func (rangeCmd) Execute(args []core.Value, _ any) core.Result {
+	var start, end, step int64 = 0, 0, 1
+	var result core.Result
+	switch len(args) {
+	case 2:
+		result, end = core.ValueToInteger(args[1])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+	case 3, 4:
+		result, start = core.ValueToInteger(args[1])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, end = core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if len(args) == 4 {
+			result, step = core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if step == 0 {
+				return core.ERROR("invalid step")
+			}
+		}
+	default:
+		return ARITY_ERROR(RANGE_SIGNATURE)
+	}
+	return core.OK(newRangeCommand(start, end, step).value)
+}
+func (rangeCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 4 {
+		return ARITY_ERROR(RANGE_SIGNATURE)
+	}
+	return core.OK(core.STR(RANGE_SIGNATURE))
+}
@@ -0,0 +1,140 @@
+package helena_dialect_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena iterators", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("range", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help range")).To(Equal(STR("range ?start? end ?step?")))
+			})
+
+			It("should generate values lazily", func() {
+				evaluate("set r [range 2]")
+				Expect(execute("$r next")).To(Equal(OK(INT(0))))
+				Expect(execute("$r next")).To(Equal(OK(INT(1))))
+				Expect(execute("$r next")).To(Equal(BREAK(NIL)))
+				Expect(execute("$r next")).To(Equal(BREAK(NIL)))
+			})
+			It("should accept start and step", func() {
+				Expect(evaluate("list [range 2 5]")).To(Equal(
+					LIST([]core.Value{INT(2), INT(3), INT(4)}),
+				))
+				Expect(evaluate("list [range 0 10 3]")).To(Equal(
+					LIST([]core.Value{INT(0), INT(3), INT(6), INT(9)}),
+				))
+				Expect(evaluate("list [range 3 0 -1]")).To(Equal(
+					LIST([]core.Value{INT(3), INT(2), INT(1)}),
+				))
+				Expect(evaluate("list [range 3 0]")).To(Equal(LIST([]core.Value{})))
+			})
+			It("should stop before overflowing", func() {
+				Expect(evaluate("list [range 9223372036854775800 9223372036854775807 5]")).To(Equal(
+					LIST([]core.Value{INT(9223372036854775800), INT(9223372036854775805)}),
+				))
+				Expect(evaluate("list [range -9223372036854775800 -9223372036854775808 -5]")).To(Equal(
+					LIST([]core.Value{INT(-9223372036854775800), INT(-9223372036854775805)}),
+				))
+				evaluate("set r [range 9223372036854775806 9223372036854775807 2]")
+				Expect(execute("$r next")).To(Equal(OK(INT(9223372036854775806))))
+				Expect(execute("$r next")).To(Equal(BREAK(NIL)))
+				Expect(execute("$r next")).To(Equal(BREAK(NIL)))
+			})
+			Specify("the range object should return itself", func() {
+				value := evaluate("set r [range 1]")
+				Expect(evaluate("$r")).To(Equal(value))
+			})
+			Specify("subcommands", func() {
+				Expect(evaluate("[range 1] subcommands")).To(Equal(
+					evaluate("list (subcommands next)"),
+				))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("range")).To(Equal(
+					ERROR(`wrong # args: should be "range ?start? end ?step?"`),
+				))
+				Expect(execute("range 1 2 3 4")).To(Equal(
+					ERROR(`wrong # args: should be "range ?start? end ?step?"`),
+				))
+				Expect(execute("[range 1] next a")).To(Equal(
+					ERROR(`wrong # args: should be "<range> next"`),
+				))
+			})
+			Specify("invalid bounds", func() {
+				Expect(execute("range a")).To(Equal(ERROR(`invalid integer "a"`)))
+				Expect(execute("range 1 a")).To(Equal(ERROR(`invalid integer "a"`)))
+				Expect(execute("range 1 2 a")).To(Equal(ERROR(`invalid integer "a"`)))
+			})
+			Specify("invalid step", func() {
+				Expect(execute("range 1 2 0")).To(Equal(ERROR("invalid step")))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute("[range 1] unknownSubcommand")).To(Equal(
+					ERROR(`unknown subcommand "unknownSubcommand"`),
+				))
+			})
+		})
+	})
+
+	Describe("combinators", func() {
+		It("should convert iterators to lists", func() {
+			Expect(
+				evaluate("list [coroutine {yield a; yield b}]"),
+			).To(Equal(LIST([]core.Value{STR("a"), STR("b")})))
+		})
+		It("should convert iterators of key-value pairs to dictionaries", func() {
+			Expect(
+				evaluate("dict [coroutine {yield (a 1); yield [list (b 2)]}]"),
+			).To(Equal(DICT(map[string]core.Value{"a": STR("1"), "b": STR("2")})))
+		})
+		It("should propagate iterator errors", func() {
+			Expect(execute("list [coroutine {yield a; error msg}]")).To(Equal(
+				ERROR("msg"),
+			))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("invalid key-value pairs", func() {
+				Expect(execute("dict [coroutine {yield a}]")).To(Equal(
+					ERROR("invalid key-value pair"),
+				))
+			})
+		})
+	})
+})
@@ -19,6 +19,14 @@ func newListCommand(scope *Scope) *listCommand {
 }
 func (cmd *listCommand) Execute(args []core.Value, context any) core.Result {
 	if len(args) == 2 {
+		if args[1].Type() == core.ValueType_COMMAND {
+			scope := context.(*Scope)
+			if resolveIterator(scope, args[1]) != nil {
+				return collectIteratorValues(scope, args[1], func(values []core.Value) core.Result {
+					return core.OK(core.LIST(values))
+				})
+			}
+		}
 		result, _ := ValueToList(args[1])
 		return result
 	}
@@ -75,6 +75,8 @@ func MACRO_HELP_SIGNATURE(name core.Value, argspec ArgspecValue, options core.Co
 }
 
 type macroCommand struct {
+	commandOrigin
+	commandDoc
 	value       core.Value
 	metacommand *macroMetacommand
 	argspec     ArgspecValue
@@ -87,6 +89,7 @@ func newMacroCommand(argspec ArgspecValue, body core.ScriptValue, guard core.Val
 	macro.value = core.NewCommandValue(macro)
 	macro.argspec = argspec
 	macro.body = body
+	macro.doc = ExtractDocString(body.Script)
 	macro.guard = guard
 	macro.metacommand = newMacroMetacommand(macro)
 	return macro
@@ -161,7 +164,7 @@ func (macroCmd) Execute(args []core.Value, context any) core.Result {
 	}
 	macro := newMacroCommand(argspec, body.(core.ScriptValue), guard)
 	if name != nil {
-		result := scope.RegisterCommand(name, macro)
+		result := scope.defineCommand(name, macro)
 		if result.Code != core.ResultCode_OK {
 			return result
 		}
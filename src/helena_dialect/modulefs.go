@@ -0,0 +1,72 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type systemFS struct{}
+
+func (systemFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (systemFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (systemFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (systemFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+// File system based on the host OS
+//
+// Unlike regular fs.FS implementations, paths are native OS paths and may be
+// absolute. Access is unrestricted: module paths may leave the module root
+// directory, e.g. `import ../lib.lna`; use another file system such as
+// os.DirFS to confine scripts to a directory
+var SystemFS fs.FS = systemFS{}
+
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// File system without any file, used where scripts must not read files
+var EmptyFS fs.FS = emptyFS{}
+
+// Return whether a file system uses native OS paths
+func isSystemFS(fsys fs.FS) bool {
+	_, ok := fsys.(systemFS)
+	return ok
+}
+
+// Join a module path to a directory
+//
+// Paths in the system file system follow OS rules and may escape the
+// directory, e.g. with `..` or absolute paths. Paths in other file systems
+// are slash-separated and cannot escape the file system root
+func JoinModulePath(fsys fs.FS, dir string, name string) (core.Result, string) {
+	if isSystemFS(fsys) {
+		return core.OK(core.NIL), filepath.Join(dir, name)
+	}
+	joined := path.Join(dir, name)
+	if joined == ".." || strings.HasPrefix(joined, "../") || !fs.ValidPath(joined) {
+		return core.ERROR(`module path "` + name + `" escapes the module root`), ""
+	}
+	return core.OK(core.NIL), joined
+}
+
+// Return the directory part of a module path
+func moduleDir(fsys fs.FS, modulePath string) string {
+	if isSystemFS(fsys) {
+		return filepath.Dir(modulePath)
+	}
+	return path.Dir(modulePath)
+}
+
+// Return the normalized form of a module directory
+func cleanModuleDir(fsys fs.FS, dir string) string {
+	if isSystemFS(fsys) {
+		return filepath.Clean(dir)
+	}
+	return path.Clean(dir)
+}
@@ -3,8 +3,10 @@ package helena_dialect
 import (
 	"fmt"
 	"helena/core"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"reflect"
+	"slices"
+	"sync"
 )
 
 type Exports = map[string]core.Value
@@ -45,9 +47,33 @@ func MODULE_HELP_PREFIX(name core.Value, options core.CommandHelpOptions) string
 }
 
 type Module struct {
-	value   core.Value
-	Scope   *Scope
-	Exports *Exports
+	commandDoc
+	value      core.Value
+	mutex      sync.RWMutex
+	Scope      *Scope
+	Exports    *Exports
+	dependents []moduleDependent
+}
+
+// Command imported from a module into another scope
+type ModuleDependent struct {
+	// Importing scope
+	Scope *Scope
+
+	// Export name
+	Name string
+
+	// Command name in the importing scope
+	Alias string
+}
+
+// Dependents are unregistered when their importing scope is released, see
+// Scope.ReleaseImports, or when they redefine the imported command
+type moduleDependent struct {
+	context *scopeContext
+	name    string
+	alias   string
+	command core.Command
 }
 
 func NewModule(scope *Scope, exports *Exports) *Module {
@@ -84,9 +110,10 @@ func (module *Module) Execute(args []core.Value, context any) core.Result {
 		if len(args) != 2 {
 			return ARITY_ERROR(MODULE_COMMAND_PREFIX(args[0]) + " exports")
 		}
-		values := make([]core.Value, len(*module.Exports))
+		_, exports := module.state()
+		values := make([]core.Value, len(*exports))
 		i := 0
-		for _, value := range *module.Exports {
+		for _, value := range *exports {
 			values[i] = value
 			i++
 		}
@@ -102,13 +129,7 @@ func (module *Module) Execute(args []core.Value, context any) core.Result {
 		} else {
 			aliasName = args[2]
 		}
-		return importCommand(
-			args[2],
-			aliasName,
-			module.Exports,
-			module.Scope,
-			scope,
-		)
+		return importCommand(args[2], aliasName, module, scope)
 
 	default:
 		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
@@ -149,11 +170,102 @@ func (*Module) Help(args []core.Value, options core.CommandHelpOptions, _ any) c
 	}
 }
 
+// Return the current scope and exports of the module
+func (module *Module) state() (*Scope, *Exports) {
+	module.mutex.RLock()
+	defer module.mutex.RUnlock()
+	return module.Scope, module.Exports
+}
+
+// Return the commands imported from the module that are still bound in their
+// importing scope
+func (module *Module) Dependents() []ModuleDependent {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	module.pruneDependents()
+	dependents := make([]ModuleDependent, len(module.dependents))
+	for i, dependent := range module.dependents {
+		dependents[i] = ModuleDependent{
+			Scope: newScope(dependent.context, nil),
+			Name:  dependent.name,
+			Alias: dependent.alias,
+		}
+	}
+	return dependents
+}
+
+// Drop dependents that redefined the imported command
+func (module *Module) pruneDependents() {
+	module.dependents = slices.DeleteFunc(module.dependents, func(dependent moduleDependent) bool {
+		return !dependent.isBound()
+	})
+}
+
+// Drop the dependents of a released scope context
+func (module *Module) removeDependents(context *scopeContext) {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	module.dependents = slices.DeleteFunc(module.dependents, func(dependent moduleDependent) bool {
+		return dependent.context == context
+	})
+}
+
+func (dependent moduleDependent) isBound() bool {
+	command := dependent.context.Commands[dependent.alias]
+	return command != nil && sameCommand(command.Command(), dependent.command)
+}
+
+// Compare commands by identity without panicking on uncomparable types
+func sameCommand(a core.Command, b core.Command) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	typeA := reflect.TypeOf(a)
+	if typeA != reflect.TypeOf(b) || !typeA.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// Replace the module state with that of a freshly loaded module
+//
+// Bound dependents are rebound to the new exports. The replacement is atomic:
+// it fails without changes when a dependent imports a name that is no longer
+// exported
+//
+// Rebinding writes to the command maps of the importing scopes, which aren't
+// safe for concurrent use: the replacement must happen on the goroutine
+// running the dependents, see ModuleRegistry.Reload
+func (module *Module) replace(other *Module) core.Result {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	module.pruneDependents()
+	dependents := make([]moduleDependent, len(module.dependents))
+	for i, dependent := range module.dependents {
+		if (*other.Exports)[dependent.name] == nil {
+			return core.ERROR(`export "` + dependent.name + `" is still imported`)
+		}
+		command := other.Scope.ResolveNamedCommand(dependent.name)
+		if command == nil {
+			return core.ERROR(`cannot resolve export "` + dependent.name + `"`)
+		}
+		dependent.command = command
+		dependents[i] = dependent
+	}
+	for _, dependent := range dependents {
+		dependent.context.Commands[dependent.alias] = newCommandBinding(dependent.command)
+	}
+	module.Scope = other.Scope
+	module.Exports = other.Exports
+	module.dependents = dependents
+	module.SetDoc(other.Doc())
+	return core.OK(core.NIL)
+}
+
 func importCommand(
 	importName core.Value,
 	aliasName core.Value,
-	exports *Exports,
-	source *Scope,
+	module *Module,
 	destination *Scope,
 ) core.Result {
 	result, name := core.ValueToString(importName)
@@ -164,25 +276,53 @@ func importCommand(
 	if result2.Code != core.ResultCode_OK {
 		return core.ERROR("invalid alias name")
 	}
-	if (*exports)[name] == nil {
+	module.mutex.Lock()
+	defer module.mutex.Unlock()
+	if (*module.Exports)[name] == nil {
 		return core.ERROR(`unknown export "` + name + `"`)
 	}
-	command := source.ResolveNamedCommand(name)
+	command := module.Scope.ResolveNamedCommand(name)
 	if command == nil {
 		return core.ERROR(`cannot resolve export "` + name + `"`)
 	}
 	destination.RegisterNamedCommand(alias, command)
+	module.pruneDependents()
+	context := destination.Context
+	if !slices.Contains(context.imports, module) {
+		context.imports = append(context.imports, module)
+	}
+	module.dependents = slices.DeleteFunc(module.dependents, func(dependent moduleDependent) bool {
+		return dependent.context == context && dependent.alias == alias
+	})
+	module.dependents = append(module.dependents, moduleDependent{
+		context: context,
+		name:    name,
+		alias:   alias,
+		command: command,
+	})
 	return core.OK(core.NIL)
 }
 
 type ModuleOptions struct {
 	CapturePositions  bool
 	CaptureErrorStack bool
+
+	// Directories where module and package names are looked up after the
+	// importing module directory
+	SearchPath []string
+
+	// File system modules are read from, SystemFS if nil
+	FS fs.FS
 }
 type ModuleRegistry struct {
 	options       ModuleOptions
+	fsys          fs.FS
+	mutex         sync.Mutex
 	modules       map[string]*Module
 	reservedNames map[string]struct{}
+	loads         map[string]*moduleLoad
+	versions      map[string]moduleVersion
+	templates     map[string]*cachedTemplate
 }
 
 func NewModuleRegistry(
@@ -197,45 +337,92 @@ func NewModuleRegistry(
 	} else {
 		moduleRegistry.options = *options
 	}
+	moduleRegistry.fsys = moduleRegistry.options.FS
+	if moduleRegistry.fsys == nil {
+		moduleRegistry.fsys = SystemFS
+	}
 	moduleRegistry.modules = map[string]*Module{}
 	moduleRegistry.reservedNames = map[string]struct{}{}
+	moduleRegistry.loads = map[string]*moduleLoad{}
+	moduleRegistry.versions = map[string]moduleVersion{}
+	moduleRegistry.templates = map[string]*cachedTemplate{}
 	return moduleRegistry
 }
 
+// Return the file system modules are read from
+func (registry *ModuleRegistry) FS() fs.FS {
+	return registry.fsys
+}
+
 func (registry *ModuleRegistry) IsReserved(name string) bool {
-	_, ok := registry.reservedNames[name]
-	return ok
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	_, reserved := registry.reservedNames[name]
+	_, loading := registry.loads[name]
+	return reserved || loading
 }
 func (registry *ModuleRegistry) Reserve(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 	registry.reservedNames[name] = struct{}{}
 }
 func (registry *ModuleRegistry) Release(name string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 	delete(registry.reservedNames, name)
 }
 func (registry *ModuleRegistry) IsRegistered(name string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 	_, ok := registry.modules[name]
 	return ok
 }
 func (registry *ModuleRegistry) Register(name string, module *Module) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 	registry.modules[name] = module
 }
 func (registry *ModuleRegistry) Get(name string) *Module {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
 	return registry.modules[name]
 }
+func (registry *ModuleRegistry) Names() []string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	names := make([]string, 0, len(registry.modules))
+	for name := range registry.modules {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
 
 const MODULE_SIGNATURE = "module ?name? body"
+const MODULE_RELOAD_SIGNATURE = "module reload path"
 
 type moduleCommand struct {
 	moduleRegistry *ModuleRegistry
 	rootDir        string
+	loading        []string
 }
 
-func newModuleCommand(moduleRegistry *ModuleRegistry, rootDir string) *moduleCommand {
-	return &moduleCommand{moduleRegistry, rootDir}
+func newModuleCommand(
+	moduleRegistry *ModuleRegistry,
+	rootDir string,
+	loading []string,
+) *moduleCommand {
+	return &moduleCommand{moduleRegistry, rootDir, loading}
 }
 
 func (cmd *moduleCommand) Execute(args []core.Value, context any) core.Result {
 	scope := context.(*Scope)
+	if isModuleReload(args) {
+		if len(args) != 3 {
+			return ARITY_ERROR(MODULE_RELOAD_SIGNATURE)
+		}
+		return cmd.reload(args[2])
+	}
 	var name, body core.Value
 	switch len(args) {
 	case 2:
@@ -253,6 +440,7 @@ func (cmd *moduleCommand) Execute(args []core.Value, context any) core.Result {
 		cmd.moduleRegistry,
 		cmd.rootDir,
 		body.(core.ScriptValue).Script,
+		cmd.loading,
 	)
 	if result.Code != core.ResultCode_OK {
 		return result
@@ -265,7 +453,41 @@ func (cmd *moduleCommand) Execute(args []core.Value, context any) core.Result {
 	}
 	return core.OK(module.value)
 }
+
+// Return whether module arguments designate the reload subcommand
+//
+// The subcommand always takes precedence, so "reload" is not a valid module
+// name
+func isModuleReload(args []core.Value) bool {
+	if len(args) < 2 || args[1].Type() == core.ValueType_SCRIPT {
+		return false
+	}
+	_, subcommand := core.ValueToStringOrDefault(args[1], "")
+	return subcommand == "reload"
+}
+
+func (cmd *moduleCommand) reload(pathValue core.Value) core.Result {
+	if pathValue.Type() == core.ValueType_SCRIPT {
+		return core.ERROR(`invalid module name "reload"`)
+	}
+	result, path := core.ValueToString(pathValue)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid path")
+	}
+	result, modulePath := findModule(cmd.moduleRegistry, cmd.rootDir, path)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	result, _ = cmd.moduleRegistry.Reload(modulePath)
+	return result
+}
 func (*moduleCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if isModuleReload(args) {
+		if len(args) > 3 {
+			return ARITY_ERROR(MODULE_RELOAD_SIGNATURE)
+		}
+		return core.OK(core.STR(MODULE_RELOAD_SIGNATURE))
+	}
 	if len(args) > 3 {
 		return ARITY_ERROR(MODULE_SIGNATURE)
 	}
@@ -276,45 +498,137 @@ func resolveModule(
 	moduleRegistry *ModuleRegistry,
 	rootDir string,
 	nameOrPath string,
+	loading []string,
 ) (core.Result, *Module) {
 	if moduleRegistry.IsRegistered(nameOrPath) {
 		module := moduleRegistry.Get(nameOrPath)
 		return core.OK(module.value), module
 	}
-	return resolveFileBasedModule(moduleRegistry, rootDir, nameOrPath)
+	return resolveFileBasedModule(moduleRegistry, rootDir, nameOrPath, loading)
 }
 
 func resolveFileBasedModule(
 	moduleRegistry *ModuleRegistry,
 	rootDir string,
 	filePath string,
+	loading []string,
 ) (core.Result, *Module) {
-	modulePath := filepath.Join(rootDir, filePath)
-	if moduleRegistry.IsRegistered(modulePath) {
-		module := moduleRegistry.Get(modulePath)
-		return core.OK(module.value), module
-	}
-
-	result, module := loadFileBasedModule(moduleRegistry, modulePath)
+	result, modulePath := findModule(moduleRegistry, rootDir, filePath)
 	if result.Code != core.ResultCode_OK {
 		return result, nil
 	}
-	moduleRegistry.Register(modulePath, module)
-	return core.OK(module.value), module
+	return moduleRegistry.load(modulePath, loading)
 }
 
-func loadFileBasedModule(
-	moduleRegistry *ModuleRegistry,
+// In-flight load of a file-based module, shared by concurrent importers
+type moduleLoad struct {
+	done   chan struct{}
+	result core.Result
+	module *Module
+
+	// Loads this module body is waiting for, either by importing them or by
+	// waiting for another importer to complete them
+	waitingFor []*moduleLoad
+}
+
+// Return whether the load waits for the target load, directly or not
+func (load *moduleLoad) waitsFor(target *moduleLoad) bool {
+	if load == target {
+		return true
+	}
+	for _, other := range load.waitingFor {
+		if other.waitsFor(target) {
+			return true
+		}
+	}
+	return false
+}
+func (load *moduleLoad) addWait(other *moduleLoad) {
+	load.waitingFor = append(load.waitingFor, other)
+}
+func (load *moduleLoad) removeWait(other *moduleLoad) {
+	if i := slices.Index(load.waitingFor, other); i >= 0 {
+		load.waitingFor = slices.Delete(load.waitingFor, i, i+1)
+	}
+}
+
+// Load and register a file-based module, or return the registered one
+//
+// The loading argument lists the file-based modules whose bodies are being
+// evaluated by the caller, innermost last. Importing one of them is circular,
+// and so is waiting for a concurrent load that waits for one of them; other
+// concurrent imports of the same module wait for the in-flight load and share
+// its outcome
+func (registry *ModuleRegistry) load(
 	modulePath string,
+	loading []string,
 ) (core.Result, *Module) {
-	if moduleRegistry.IsReserved(modulePath) {
+	registry.mutex.Lock()
+	if module, ok := registry.modules[modulePath]; ok {
+		registry.mutex.Unlock()
+		return core.OK(module.value), module
+	}
+	var parent *moduleLoad
+	if len(loading) > 0 {
+		parent = registry.loads[loading[len(loading)-1]]
+	}
+	_, reserved := registry.reservedNames[modulePath]
+	load, inFlight := registry.loads[modulePath]
+	if reserved ||
+		slices.Contains(loading, modulePath) ||
+		(inFlight && parent != nil && load.waitsFor(parent)) {
+		registry.mutex.Unlock()
 		return core.ERROR("circular imports are forbidden"), nil
 	}
-	moduleRegistry.Reserve(modulePath)
+	if inFlight {
+		if parent != nil {
+			parent.addWait(load)
+		}
+		registry.mutex.Unlock()
+		<-load.done
+		if parent != nil {
+			registry.mutex.Lock()
+			parent.removeWait(load)
+			registry.mutex.Unlock()
+		}
+		return load.result, load.module
+	}
+	load = &moduleLoad{done: make(chan struct{})}
+	registry.loads[modulePath] = load
+	if parent != nil {
+		parent.addWait(load)
+	}
+	registry.mutex.Unlock()
 
-	data, err := os.ReadFile(modulePath)
+	result, module := loadFileBasedModule(
+		registry,
+		modulePath,
+		append(slices.Clip(loading), modulePath),
+	)
+	if result.Code == core.ResultCode_OK {
+		registry.recordVersion(modulePath)
+	}
+	registry.mutex.Lock()
+	if result.Code == core.ResultCode_OK {
+		registry.modules[modulePath] = module
+	}
+	delete(registry.loads, modulePath)
+	if parent != nil {
+		parent.removeWait(load)
+	}
+	registry.mutex.Unlock()
+	load.result, load.module = result, module
+	close(load.done)
+	return result, module
+}
+
+func loadFileBasedModule(
+	moduleRegistry *ModuleRegistry,
+	modulePath string,
+	loading []string,
+) (core.Result, *Module) {
+	data, err := fs.ReadFile(moduleRegistry.fsys, modulePath)
 	if err != nil {
-		moduleRegistry.Release(modulePath)
 		return core.ERROR("error reading module: " + fmt.Sprint(err)), nil
 	}
 	tokens := core.Tokenizer{}.Tokenize(string(data))
@@ -323,25 +637,28 @@ func loadFileBasedModule(
 	})
 	parseResult := parser.ParseTokens(tokens, &core.Source{Filename: &modulePath})
 	if !parseResult.Success {
-		moduleRegistry.Release(modulePath)
 		return core.ERROR(parseResult.Message), nil
 	}
 
-	result, module := createModule(moduleRegistry, filepath.Dir(modulePath), *parseResult.Script)
-	moduleRegistry.Release(modulePath)
-	return result, module
+	return createModule(
+		moduleRegistry,
+		moduleDir(moduleRegistry.fsys, modulePath),
+		*parseResult.Script,
+		loading,
+	)
 }
 
 func createModule(
 	moduleRegistry *ModuleRegistry,
 	rootDir string,
 	script core.Script,
+	loading []string,
 ) (core.Result, *Module) {
 	rootScope := NewRootScope(&ScopeOptions{
 		CaptureErrorStack: moduleRegistry.options.CaptureErrorStack,
 		CapturePositions:  moduleRegistry.options.CapturePositions,
 	})
-	InitCommandsForModule(rootScope, moduleRegistry, rootDir)
+	initCommandsForModule(rootScope, moduleRegistry, rootDir, loading)
 
 	exports := &Exports{}
 	rootScope.RegisterNamedCommand("export", newExportCommand(exports))
@@ -357,6 +674,7 @@ func createModule(
 	}
 
 	module := NewModule(rootScope, exports)
+	module.SetDoc(ExtractDocString(script))
 	return core.OK(module.value), module
 }
 
@@ -365,10 +683,15 @@ const IMPORT_SIGNATURE = "import path ?name|imports?"
 type importCmd struct {
 	moduleRegistry *ModuleRegistry
 	rootDir        string
+	loading        []string
 }
 
-func newImportCommand(moduleRegistry *ModuleRegistry, rootDir string) *importCmd {
-	return &importCmd{moduleRegistry, rootDir}
+func newImportCommand(
+	moduleRegistry *ModuleRegistry,
+	rootDir string,
+	loading []string,
+) *importCmd {
+	return &importCmd{moduleRegistry, rootDir, loading}
 }
 
 func (cmd *importCmd) Execute(args []core.Value, context any) core.Result {
@@ -386,6 +709,7 @@ func (cmd *importCmd) Execute(args []core.Value, context any) core.Result {
 		cmd.moduleRegistry,
 		cmd.rootDir,
 		path,
+		cmd.loading,
 	)
 	if result2.Code != core.ResultCode_OK {
 		return result2
@@ -411,8 +735,7 @@ func (cmd *importCmd) Execute(args []core.Value, context any) core.Result {
 						result := importCommand(
 							values[0],
 							values[1],
-							module.Exports,
-							module.Scope,
+							module,
 							scope,
 						)
 						if result.Code != core.ResultCode_OK {
@@ -422,8 +745,7 @@ func (cmd *importCmd) Execute(args []core.Value, context any) core.Result {
 						result := importCommand(
 							name,
 							name,
-							module.Exports,
-							module.Scope,
+							module,
 							scope,
 						)
 						if result.Code != core.ResultCode_OK {
@@ -455,13 +777,14 @@ func registerModuleCommands(
 	scope *Scope,
 	moduleRegistry *ModuleRegistry,
 	rootDir string,
+	loading []string,
 ) {
 	scope.RegisterNamedCommand(
 		"module",
-		newModuleCommand(moduleRegistry, rootDir),
+		newModuleCommand(moduleRegistry, rootDir, loading),
 	)
 	scope.RegisterNamedCommand(
 		"import",
-		newImportCommand(moduleRegistry, rootDir),
+		newImportCommand(moduleRegistry, rootDir, loading),
 	)
 }
@@ -4,6 +4,8 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"testing/fstest"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -627,6 +629,307 @@ var _ = Describe("Helena modules", func() {
 		})
 	})
 
+	Describe("Search path", func() {
+		BeforeEach(func() {
+			rootScope = NewRootScope(nil)
+			moduleRegistry = NewModuleRegistry(&ModuleOptions{
+				SearchPath: []string{filepath.Join(dirname, "tests/lib")},
+			})
+			InitCommandsForModule(rootScope, moduleRegistry, dirname)
+		})
+
+		It("should resolve files relatively to the module directory first", func() {
+			evaluate(`import tests/module-a.lna (name)`)
+			Expect(evaluate("name")).To(Equal(STR("module-a")))
+		})
+		It("should resolve package names to their entry file", func() {
+			evaluate(`import greet (hello)`)
+			Expect(evaluate("hello")).To(Equal(STR("hello")))
+		})
+		It("should resolve package subpaths", func() {
+			evaluate(`import greet/extra.lna (extra)`)
+			Expect(evaluate("extra")).To(Equal(STR("extra")))
+		})
+		It("should append the module extension when needed", func() {
+			Expect(evaluate(`import greet/extra`)).To(Equal(
+				evaluate(`import greet/extra.lna`),
+			))
+		})
+		It("should resolve package dependencies from the search path", func() {
+			evaluate(`import app (run)`)
+			Expect(evaluate("run")).To(Equal(STR("hello")))
+		})
+		It("should share modules across import names", func() {
+			Expect(evaluate(`import greet`)).To(Equal(evaluate(`import greet/greet.lna`)))
+		})
+		It("should read package manifests", func() {
+			manifest, err := ReadPackageManifest(SystemFS, filepath.Join(dirname, "tests/lib/app"))
+			Expect(err).To(BeNil())
+			Expect(manifest).To(Equal(PackageManifest{
+				Name:         "app",
+				Version:      "0.1.0",
+				Entry:        "main.lna",
+				Dependencies: map[string]string{"greet": "1.2.0"},
+			}))
+		})
+		It("should read the search path from the environment", func() {
+			GinkgoT().Setenv("HELENA_PATH", "/a"+string(filepath.ListSeparator)+"/b")
+			Expect(SearchPathFromEnv()).To(Equal([]string{"/a", "/b"}))
+			GinkgoT().Setenv("HELENA_PATH", "")
+			Expect(SearchPathFromEnv()).To(Equal([]string{}))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("unknown module", func() {
+				Expect(execute(`import unknown/module`)).To(Equal(ERROR(
+					`module "unknown/module" not found, searched: ` +
+						dirname + ", " + filepath.Join(dirname, "tests/lib"),
+				)))
+			})
+			Specify("missing dependency", func() {
+				Expect(execute(`import orphan`)).To(Equal(ERROR(
+					`package "orphan" dependency "missing" not found, searched: ` +
+						filepath.Join(dirname, "tests/lib/orphan") + ", " +
+						filepath.Join(dirname, "tests/lib"),
+				)))
+			})
+			Specify("dependency version mismatch", func() {
+				Expect(execute(`import outdated`)).To(Equal(ERROR(
+					`package "outdated" requires "greet" version "2.0.0", found "1.2.0"`,
+				)))
+			})
+		})
+	})
+
+	Describe("System file system", func() {
+		It("should not restrict module paths to the root directory", func() {
+			InitCommandsForModule(rootScope, moduleRegistry, filepath.Join(dirname, "tests/lib"))
+			evaluate(`import ../module-a.lna (name)`)
+			Expect(evaluate("name")).To(Equal(STR("module-a")))
+		})
+		Specify("JoinModulePath", func() {
+			result, modulePath := JoinModulePath(SystemFS, "/a/b", "../../c.lna")
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(modulePath).To(Equal(filepath.FromSlash("/c.lna")))
+		})
+	})
+
+	Describe("File systems", func() {
+		BeforeEach(func() {
+			rootScope = NewRootScope(nil)
+			moduleRegistry = NewModuleRegistry(&ModuleOptions{
+				SearchPath: []string{"lib"},
+				FS: fstest.MapFS{
+					"main.lna":            {Data: []byte(`import sub/module-a.lna (name); export name`)},
+					"sub/module-a.lna":    {Data: []byte(`macro name {} {idem module-a}; export name`)},
+					"sub/module-b.lna":    {Data: []byte(`import ../main.lna (name); export name`)},
+					"sub/escape.lna":      {Data: []byte(`import ../../outside.lna`)},
+					"lib/pkg/helena.json": {Data: []byte(`{"name": "pkg", "version": "1.0"}`)},
+					"lib/pkg/main.lna":    {Data: []byte(`macro pkg {} {idem pkg}; export pkg`)},
+					"lib/bad/helena.json": {Data: []byte(`{"entry": "../../../outside.lna"}`)},
+				},
+			})
+			InitCommandsForModule(rootScope, moduleRegistry, ".")
+		})
+
+		It("should read modules from the file system", func() {
+			evaluate(`import main.lna (name)`)
+			Expect(evaluate("name")).To(Equal(STR("module-a")))
+		})
+		It("should resolve relative paths within the file system", func() {
+			evaluate(`import sub/module-b.lna (name)`)
+			Expect(evaluate("name")).To(Equal(STR("module-a")))
+		})
+		It("should normalize module paths", func() {
+			Expect(evaluate(`import ./sub/../main.lna`)).To(Equal(evaluate(`import main.lna`)))
+			Expect(evaluate(`import /main.lna`)).To(Equal(evaluate(`import main.lna`)))
+		})
+		It("should resolve packages from the search path", func() {
+			evaluate(`import pkg (pkg)`)
+			Expect(evaluate("pkg")).To(Equal(STR("pkg")))
+		})
+		It("should read package manifests", func() {
+			manifest, err := ReadPackageManifest(moduleRegistry.FS(), "lib/pkg")
+			Expect(err).To(BeNil())
+			Expect(manifest.Name).To(Equal("pkg"))
+			Expect(manifest.Entry).To(Equal("main.lna"))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("path escapes", func() {
+				Expect(execute(`import ../outside.lna`)).To(Equal(
+					ERROR(`module path "../outside.lna" escapes the module root`),
+				))
+				Expect(execute(`import sub/escape.lna`)).To(Equal(
+					ERROR(`module path "../../outside.lna" escapes the module root`),
+				))
+				Expect(execute(`import bad`)).To(Equal(
+					ERROR(`module path "../../../outside.lna" escapes the module root`),
+				))
+			})
+			Specify("unknown file", func() {
+				Expect(execute(`import unknown.lna`)).To(Equal(
+					ERROR(`module "unknown.lna" not found, searched: ., lib`),
+				))
+				result := execute(`import ./unknown.lna`)
+				Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+				Expect(asString(result.Value)).To(ContainSubstring("error reading module"))
+			})
+		})
+	})
+
+	Describe("Reloading", func() {
+		var files fstest.MapFS
+		BeforeEach(func() {
+			files = fstest.MapFS{
+				"lib.lna": {
+					Data:    []byte(`macro name {} {idem v1}; export name`),
+					ModTime: time.Unix(1, 0),
+				},
+			}
+			rootScope = NewRootScope(nil)
+			moduleRegistry = NewModuleRegistry(&ModuleOptions{FS: files})
+			InitCommandsForModule(rootScope, moduleRegistry, ".")
+		})
+		update := func(source string, seconds int64) {
+			files["lib.lna"] = &fstest.MapFile{Data: []byte(source), ModTime: time.Unix(seconds, 0)}
+		}
+
+		Specify("usage", func() {
+			Expect(evaluate("help module reload lib.lna")).To(Equal(STR("module reload path")))
+		})
+
+		It("should re-evaluate the module file", func() {
+			module := evaluate(`import lib.lna`)
+			update(`macro name {} {idem v2}; macro other {} {}; export name; export other`, 2)
+			Expect(execute(`module reload lib.lna`)).To(Equal(OK(module)))
+			Expect(evaluate(`import lib.lna`)).To(Equal(module))
+			Expect(evaluate(`[import lib.lna] import name; name`)).To(Equal(STR("v2")))
+			Expect(evaluate(`[import lib.lna] exports`).(core.ListValue).Values).To(HaveLen(2))
+		})
+		It("should rebind commands imported by dependents", func() {
+			evaluate(`import lib.lna (name (name alias))`)
+			update(`macro name {} {idem v2}; export name`, 2)
+			evaluate(`module reload lib.lna`)
+			Expect(evaluate("name")).To(Equal(STR("v2")))
+			Expect(evaluate("alias")).To(Equal(STR("v2")))
+			module := moduleRegistry.Get("lib.lna")
+			aliases := []string{}
+			for _, dependent := range module.Dependents() {
+				Expect(dependent.Scope.Context).To(BeIdenticalTo(rootScope.Context))
+				Expect(dependent.Name).To(Equal("name"))
+				aliases = append(aliases, dependent.Alias)
+			}
+			Expect(aliases).To(ConsistOf("name", "alias"))
+		})
+		It("should ignore dependents that redefined imported commands", func() {
+			evaluate(`import lib.lna (name)`)
+			evaluate(`macro name {} {idem local}`)
+			update(`macro other {} {}; export other`, 2)
+			Expect(execute(`module reload lib.lna`).Code).To(Equal(core.ResultCode_OK))
+			Expect(evaluate("name")).To(Equal(STR("local")))
+			Expect(moduleRegistry.Get("lib.lna").Dependents()).To(BeEmpty())
+		})
+		It("should keep the previous version on error", func() {
+			evaluate(`import lib.lna (name)`)
+			update(`error broken`, 2)
+			Expect(execute(`module reload lib.lna`)).To(Equal(ERROR("broken")))
+			Expect(evaluate("name")).To(Equal(STR("v1")))
+		})
+		It("should not remove exports still imported by dependents", func() {
+			evaluate(`import lib.lna (name)`)
+			update(`macro other {} {}; export other`, 2)
+			Expect(execute(`module reload lib.lna`)).To(Equal(
+				ERROR(`export "name" is still imported`),
+			))
+			Expect(evaluate("name")).To(Equal(STR("v1")))
+			Expect(evaluate(`[import lib.lna] exports`)).To(Equal(LIST([]core.Value{STR("name")})))
+		})
+		It("should track each importing scope once", func() {
+			evaluate(`closure c {} {import lib.lna (name)}`)
+			for range 10 {
+				evaluate("c")
+			}
+			Expect(moduleRegistry.Get("lib.lna").Dependents()).To(HaveLen(1))
+		})
+		It("should release dependents of proc calls on return", func() {
+			evaluate(`proc p {} {import lib.lna (name); name}`)
+			for range 100 {
+				Expect(evaluate("p")).To(Equal(STR("v1")))
+			}
+			Expect(moduleRegistry.Get("lib.lna").Dependents()).To(BeEmpty())
+			update(`macro name {} {idem v2}; export name`, 2)
+			Expect(execute(`module reload lib.lna`).Code).To(Equal(core.ResultCode_OK))
+			Expect(evaluate("p")).To(Equal(STR("v2")))
+		})
+
+		It("should release dependents of released scopes", func() {
+			child := rootScope.NewChildScope()
+			child.PrepareProcess(child.Compile(*parse(`import lib.lna (name)`))).Run()
+			evaluate(`import lib.lna (name)`)
+			Expect(moduleRegistry.Get("lib.lna").Dependents()).To(HaveLen(2))
+			child.ReleaseImports()
+			dependents := moduleRegistry.Get("lib.lna").Dependents()
+			Expect(dependents).To(HaveLen(1))
+			Expect(dependents[0].Scope.Context).To(BeIdenticalTo(rootScope.Context))
+		})
+
+		Describe("Change detection", func() {
+			It("should report modified modules once", func() {
+				evaluate(`import lib.lna`)
+				Expect(moduleRegistry.PollChanges()).To(BeEmpty())
+				update(`macro name {} {idem v2}; export name`, 2)
+				Expect(moduleRegistry.PollChanges()).To(Equal([]string{"lib.lna"}))
+				Expect(moduleRegistry.PollChanges()).To(BeEmpty())
+			})
+			It("should notify watchers", func() {
+				evaluate(`import lib.lna`)
+				update(`macro name {} {idem v2}; export name`, 2)
+				changes := make(chan string, 1)
+				watcher := moduleRegistry.Watch(time.Millisecond, func(modulePath string) {
+					changes <- modulePath
+				})
+				Eventually(changes).Should(Receive(Equal("lib.lna")))
+				watcher.Stop()
+				result, _ := moduleRegistry.Reload("lib.lna")
+				Expect(result.Code).To(Equal(core.ResultCode_OK))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("unloaded module", func() {
+				Expect(execute(`module reload lib.lna`)).To(Equal(
+					ERROR(`module "lib.lna" is not loaded from a file`),
+				))
+			})
+			Specify("unknown module", func() {
+				Expect(execute(`module reload unknown.lna`)).To(Equal(
+					ERROR(`module "unknown.lna" not found, searched: .`),
+				))
+			})
+			Specify("wrong arity", func() {
+				Expect(execute(`module reload`)).To(Equal(
+					ERROR(`wrong # args: should be "module reload path"`),
+				))
+				Expect(execute(`module reload a b`)).To(Equal(
+					ERROR(`wrong # args: should be "module reload path"`),
+				))
+				Expect(execute(`help module reload a b`)).To(Equal(
+					ERROR(`wrong # args: should be "module reload path"`),
+				))
+			})
+			Specify("invalid path", func() {
+				Expect(execute(`module reload []`)).To(Equal(ERROR("invalid path")))
+			})
+			Specify("reserved module name", func() {
+				Expect(execute(`module reload {}`)).To(Equal(
+					ERROR(`invalid module name "reload"`),
+				))
+				Expect(rootScope.Context.Commands["reload"]).To(BeNil())
+			})
+		})
+	})
+
 	Describe("Error stack", func() {
 		BeforeEach(func() {
 			parser = core.NewParser(&core.ParserOptions{CapturePositions: true})
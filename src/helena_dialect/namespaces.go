@@ -162,6 +162,7 @@ func NAMESPACE_HELP_PREFIX(name core.Value, options core.CommandHelpOptions) str
 }
 
 type namespaceCommand struct {
+	commandOrigin
 	metacommand *namespaceMetacommand
 	scope       *Scope
 }
@@ -259,7 +260,7 @@ func (namespaceCmd) Execute(args []core.Value, context any) core.Result {
 			{
 				namespace := newNamespaceCommand(subscope)
 				if name != nil {
-					result := scope.RegisterCommand(name, namespace)
+					result := scope.defineCommand(name, namespace)
 					if result.Code != core.ResultCode_OK {
 						return result
 					}
@@ -0,0 +1,210 @@
+package helena_dialect
+
+import (
+	"encoding/json"
+	"fmt"
+	"helena/core"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Name of the manifest file identifying package directories
+const PACKAGE_MANIFEST = "helena.json"
+
+// Default package entry file
+const PACKAGE_DEFAULT_ENTRY = "main.lna"
+
+// Extension tried when a module name doesn't match a file
+const MODULE_EXTENSION = ".lna"
+
+// Environment variable listing module search directories
+const SEARCH_PATH_VARIABLE = "HELENA_PATH"
+
+// Package manifest
+//
+// Manifests are JSON files at the root of package directories:
+//
+//	{
+//	  "name": "mylib",
+//	  "version": "1.0.0",
+//	  "entry": "main.lna",
+//	  "dependencies": {"otherlib": "2.1.0"}
+//	}
+//
+// Dependencies map package names to their required version, "*" or "" for
+// any version
+type PackageManifest struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Entry        string            `json:"entry"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// Read the manifest of a package directory
+func ReadPackageManifest(fsys fs.FS, dir string) (PackageManifest, error) {
+	manifest := PackageManifest{}
+	_, manifestPath := JoinModulePath(fsys, dir, PACKAGE_MANIFEST)
+	data, err := fs.ReadFile(fsys, manifestPath)
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, err
+	}
+	if manifest.Name == "" {
+		manifest.Name = path.Base(filepath.ToSlash(dir))
+	}
+	if manifest.Entry == "" {
+		manifest.Entry = PACKAGE_DEFAULT_ENTRY
+	}
+	return manifest, nil
+}
+
+// Return the module search path from the HELENA_PATH environment variable
+func SearchPathFromEnv() []string {
+	searchPath := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv(SEARCH_PATH_VARIABLE)) {
+		if dir != "" {
+			searchPath = append(searchPath, dir)
+		}
+	}
+	return searchPath
+}
+
+// Return the directories where modules are looked up from a root directory
+func (registry *ModuleRegistry) searchDirs(rootDir string) []string {
+	dirs := []string{cleanModuleDir(registry.fsys, rootDir)}
+	for _, dir := range registry.options.SearchPath {
+		dir = cleanModuleDir(registry.fsys, dir)
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Return whether a module path must be resolved against its root directory
+// only
+func isExplicitModulePath(path string) bool {
+	return filepath.IsAbs(path) ||
+		strings.HasPrefix(path, "/") ||
+		path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") ||
+		strings.HasPrefix(path, "../")
+}
+
+// Locate the file of a module
+//
+// Absolute and explicitly relative paths are resolved against the root
+// directory. Other names such as `pkg` or `pkg/sub` are looked up in the root
+// directory then in each search path entry; package directories resolve to
+// their manifest entry file, and a missing file is retried with the module
+// extension
+func findModule(
+	moduleRegistry *ModuleRegistry,
+	rootDir string,
+	name string,
+) (core.Result, string) {
+	fsys := moduleRegistry.fsys
+	if isExplicitModulePath(name) {
+		result, modulePath := JoinModulePath(fsys, rootDir, name)
+		if result.Code != core.ResultCode_OK {
+			return result, ""
+		}
+		if isPackageDir(fsys, modulePath) {
+			return packageEntry(moduleRegistry, modulePath)
+		}
+		return core.OK(core.NIL), modulePath
+	}
+
+	searched := []string{}
+	for _, dir := range moduleRegistry.searchDirs(rootDir) {
+		result, modulePath := JoinModulePath(fsys, dir, name)
+		if result.Code != core.ResultCode_OK {
+			return result, ""
+		}
+		for _, candidate := range []string{modulePath, modulePath + MODULE_EXTENSION} {
+			info, err := fs.Stat(fsys, candidate)
+			if err != nil {
+				continue
+			}
+			if !info.IsDir() {
+				return core.OK(core.NIL), candidate
+			}
+			if isPackageDir(fsys, candidate) {
+				return packageEntry(moduleRegistry, candidate)
+			}
+		}
+		searched = append(searched, dir)
+	}
+	return core.ERROR(
+		`module "` + name + `" not found, searched: ` + strings.Join(searched, ", "),
+	), ""
+}
+
+func isPackageDir(fsys fs.FS, dir string) bool {
+	_, manifestPath := JoinModulePath(fsys, dir, PACKAGE_MANIFEST)
+	info, err := fs.Stat(fsys, manifestPath)
+	return err == nil && !info.IsDir()
+}
+
+// Return the entry file of a package after checking its dependencies
+func packageEntry(moduleRegistry *ModuleRegistry, dir string) (core.Result, string) {
+	manifest, err := ReadPackageManifest(moduleRegistry.fsys, dir)
+	if err != nil {
+		return core.ERROR("invalid package manifest: " + fmt.Sprint(err)), ""
+	}
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		version := manifest.Dependencies[name]
+		result := checkPackageDependency(moduleRegistry, dir, manifest, name, version)
+		if result.Code != core.ResultCode_OK {
+			return result, ""
+		}
+	}
+	return JoinModulePath(moduleRegistry.fsys, dir, manifest.Entry)
+}
+
+func checkPackageDependency(
+	moduleRegistry *ModuleRegistry,
+	dir string,
+	manifest PackageManifest,
+	name string,
+	version string,
+) core.Result {
+	fsys := moduleRegistry.fsys
+	searched := []string{}
+	for _, searchDir := range moduleRegistry.searchDirs(dir) {
+		result, dependencyDir := JoinModulePath(fsys, searchDir, name)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if !isPackageDir(fsys, dependencyDir) {
+			searched = append(searched, searchDir)
+			continue
+		}
+		dependency, err := ReadPackageManifest(fsys, dependencyDir)
+		if err != nil {
+			return core.ERROR("invalid package manifest: " + fmt.Sprint(err))
+		}
+		if version != "" && version != "*" && version != dependency.Version {
+			return core.ERROR(
+				`package "` + manifest.Name + `" requires "` + name +
+					`" version "` + version + `", found "` + dependency.Version + `"`,
+			)
+		}
+		return core.OK(core.NIL)
+	}
+	return core.ERROR(
+		`package "` + manifest.Name + `" dependency "` + name +
+			`" not found, searched: ` + strings.Join(searched, ", "),
+	)
+}
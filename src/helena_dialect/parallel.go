@@ -0,0 +1,242 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"sync"
+)
+
+// Custom values that are safe for concurrent use and can be shared as-is
+// across interpreter boundaries
+type ShareableValue interface {
+	core.CustomValue
+
+	// Marker method
+	Shareable()
+}
+
+// Yielded values that can block the current goroutine until the operation
+// they stand for is ready to complete
+type WaitableValue interface {
+	core.Value
+
+	// Block until ready and return the value to yield back to the process
+	Wait() core.Value
+}
+
+// Run the process, blocking on yielded waitable values until completion
+//
+// Other yielded values are returned as is
+func (process *Process) RunAndWait() core.Result {
+	result := process.Run()
+	for result.Code == core.ResultCode_YIELD {
+		waitable, ok := result.Value.(WaitableValue)
+		if !ok {
+			break
+		}
+		process.YieldBack(waitable.Wait())
+		result = process.Run()
+	}
+	return result
+}
+
+// Return a deep copy of a value that can safely cross interpreter boundaries
+//
+// Commands and custom values are bound to their originating interpreter and
+// cannot be copied, except for shareable values that are passed by reference
+func DeepCopyValue(value core.Value) (core.Result, core.Value) {
+	if _, ok := value.(ShareableValue); ok {
+		return core.OK(value), value
+	}
+	switch value.Type() {
+	case core.ValueType_NIL,
+		core.ValueType_BOOLEAN,
+		core.ValueType_INTEGER,
+		core.ValueType_REAL,
+		core.ValueType_STRING:
+		return core.OK(value), value
+
+	case core.ValueType_LIST:
+		result, values := deepCopyValues(value.(core.ListValue).Values)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		list := core.LIST(values)
+		return core.OK(list), list
+
+	case core.ValueType_TUPLE:
+		result, values := deepCopyValues(value.(core.TupleValue).Values)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		tuple := core.TUPLE(values)
+		return core.OK(tuple), tuple
+
+	case core.ValueType_DICTIONARY:
+		entries := make(map[string]core.Value, len(value.(core.DictionaryValue).Map))
+		for key, v := range value.(core.DictionaryValue).Map {
+			result, clone := DeepCopyValue(v)
+			if result.Code != core.ResultCode_OK {
+				return result, nil
+			}
+			entries[key] = clone
+		}
+		dictionary := core.DICT(entries)
+		return core.OK(dictionary), dictionary
+
+	case core.ValueType_SCRIPT:
+		// Scripts are immutable but carry a run-time cache, so give the clone
+		// its own
+		script := value.(core.ScriptValue)
+		clone := core.ScriptValue{
+			Script: script.Script,
+			Source: script.Source,
+			Cache:  &core.ScriptValueCache{},
+		}
+		return core.OK(clone), clone
+
+	case core.ValueType_QUALIFIED:
+		qualified := value.(core.QualifiedValue)
+		result, source := DeepCopyValue(qualified.Source)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		clone := core.NewQualifiedValue(source, qualified.Selectors)
+		return core.OK(clone), clone
+
+	default:
+		return core.ERROR("value cannot be shared across interpreters"), nil
+	}
+}
+func deepCopyValues(values []core.Value) (core.Result, []core.Value) {
+	copies := make([]core.Value, len(values))
+	for i, value := range values {
+		result, clone := DeepCopyValue(value)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		copies[i] = clone
+	}
+	return core.OK(core.NIL), copies
+}
+
+// Run script bodies concurrently, each in its own root scope
+//
+// Root scopes are created with the given options, initialized with init, and
+// populated with deep copies of inputs as variables. Results are joined in
+// order into a list; the error of the first failing body is returned instead
+func RunParallel(
+	init func(scope *Scope),
+	options *ScopeOptions,
+	inputs map[string]core.Value,
+	bodies []core.ScriptValue,
+) core.Result {
+	// Copy all values upfront so that goroutines never touch caller values
+	taskInputs := make([]map[string]core.Value, len(bodies))
+	taskBodies := make([]core.ScriptValue, len(bodies))
+	for i, body := range bodies {
+		taskInputs[i] = make(map[string]core.Value, len(inputs))
+		for name, value := range inputs {
+			result, clone := DeepCopyValue(value)
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			taskInputs[i][name] = clone
+		}
+		_, clone := DeepCopyValue(body)
+		taskBodies[i] = clone.(core.ScriptValue)
+	}
+
+	results := make([]core.Result, len(bodies))
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			scope := NewRootScope(options)
+			init(scope)
+			for name, value := range taskInputs[i] {
+				result := scope.SetNamedVariable(name, value)
+				if result.Code != core.ResultCode_OK {
+					results[i] = result
+					return
+				}
+			}
+			program := scope.CompileScriptValue(taskBodies[i])
+			results[i] = scope.PrepareProcess(program).RunAndWait()
+		}(i)
+	}
+	wg.Wait()
+
+	values := make([]core.Value, len(results))
+	for i, result := range results {
+		switch result.Code {
+		case core.ResultCode_OK,
+			core.ResultCode_RETURN:
+			values[i] = result.Value
+		case core.ResultCode_ERROR:
+			return result
+		default:
+			return core.ERROR("unexpected " + core.RESULT_CODE_NAME(result))
+		}
+	}
+	return core.OK(core.LIST(values))
+}
+
+const PARALLEL_SIGNATURE = "parallel ?inputs? bodies"
+
+type parallelCmd struct {
+	moduleRegistry *ModuleRegistry
+	rootDir        string
+	loading        []string
+}
+
+func newParallelCommand(
+	moduleRegistry *ModuleRegistry,
+	rootDir string,
+	loading []string,
+) *parallelCmd {
+	return &parallelCmd{moduleRegistry, rootDir, loading}
+}
+
+func (cmd *parallelCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	var inputs map[string]core.Value
+	var bodies core.Value
+	switch len(args) {
+	case 2:
+		bodies = args[1]
+	case 3:
+		result, entries := ValueToMap(args[1])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		inputs, bodies = entries, args[2]
+	default:
+		return ARITY_ERROR(PARALLEL_SIGNATURE)
+	}
+	result, values := ValueToArray(bodies)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	scripts := make([]core.ScriptValue, len(values))
+	for i, value := range values {
+		if value.Type() != core.ValueType_SCRIPT {
+			return core.ERROR("body must be a script")
+		}
+		scripts[i] = value.(core.ScriptValue)
+	}
+	return RunParallel(
+		func(child *Scope) {
+			initCommandsForModule(child, cmd.moduleRegistry, cmd.rootDir, cmd.loading)
+		},
+		&scope.options,
+		inputs,
+		scripts,
+	)
+}
+func (*parallelCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 3 {
+		return ARITY_ERROR(PARALLEL_SIGNATURE)
+	}
+	return core.OK(core.STR(PARALLEL_SIGNATURE))
+}
@@ -0,0 +1,269 @@
+package helena_dialect_test
+
+import (
+	"io/fs"
+	"sync/atomic"
+	"testing/fstest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena parallel execution", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("parallel", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help parallel")).To(Equal(STR("parallel ?inputs? bodies")))
+				Expect(evaluate("help parallel bodies")).To(Equal(
+					STR("parallel ?inputs? bodies"),
+				))
+				Expect(evaluate("help parallel inputs bodies")).To(Equal(
+					STR("parallel ?inputs? bodies"),
+				))
+			})
+
+			It("should return body results in order", func() {
+				Expect(
+					evaluate(`
+						parallel (
+							{set i 0; while {$i < 1000} {set i [+ $i 1]}; idem a}
+							{idem b}
+							{return c}
+						)
+					`),
+				).To(Equal(LIST([]core.Value{STR("a"), STR("b"), STR("c")})))
+			})
+			It("should return an empty list with no bodies", func() {
+				Expect(evaluate("parallel ()")).To(Equal(LIST([]core.Value{})))
+			})
+			It("should run bodies in isolated root scopes", func() {
+				evaluate("set var val")
+				Expect(execute("parallel ({get var})")).To(Equal(
+					ERROR(`cannot get "var": no such variable`),
+				))
+				evaluate("parallel ({set var2 val2})")
+				Expect(execute("get var2").Code).To(Equal(core.ResultCode_ERROR))
+			})
+			It("should give access to standard commands", func() {
+				Expect(
+					evaluate("parallel ({list (a b c) length} {string abc length})"),
+				).To(Equal(LIST([]core.Value{INT(3), INT(3)})))
+			})
+			It("should pass inputs as variables", func() {
+				Expect(
+					evaluate(
+						"parallel (a 1 b 2) ({+ $a $b} {* $a $b} {list ($a $b)})",
+					),
+				).To(Equal(
+					LIST([]core.Value{
+						INT(3),
+						INT(2),
+						LIST([]core.Value{STR("1"), STR("2")}),
+					}),
+				))
+				evaluate("set d [dict (k v)]")
+				Expect(evaluate("parallel [dict (d $d)] ({dict $d get k})")).To(Equal(
+					LIST([]core.Value{STR("v")}),
+				))
+			})
+			It("should support nested parallel bodies", func() {
+				Expect(
+					evaluate("parallel ({parallel ({idem a} {idem b})})"),
+				).To(Equal(
+					LIST([]core.Value{LIST([]core.Value{STR("a"), STR("b")})}),
+				))
+			})
+		})
+
+		Describe("Module imports", func() {
+			var files *slowFS
+			BeforeEach(func() {
+				files = &slowFS{files: fstest.MapFS{
+					"m.lna": {Data: []byte("macro name {} {idem m}; export name")},
+					"a.lna": {Data: []byte("import b.lna")},
+					"b.lna": {Data: []byte("import a.lna")},
+				}}
+				rootScope = NewRootScope(nil)
+				moduleRegistry := NewModuleRegistry(&ModuleOptions{FS: files})
+				InitCommandsForModule(rootScope, moduleRegistry, ".")
+			})
+
+			It("should load modules imported concurrently once", func() {
+				Expect(execute(
+					"parallel ({[import m.lna] exports} {[import m.lna] exports})",
+				)).To(Equal(OK(LIST([]core.Value{
+					LIST([]core.Value{STR("name")}),
+					LIST([]core.Value{STR("name")}),
+				}))))
+				Expect(evaluate("import m.lna (name); name")).To(Equal(STR("m")))
+				Expect(files.reads.Load()).To(Equal(int32(1)))
+			})
+			It("should detect circular imports across bodies", func() {
+				Expect(execute("parallel ({import a.lna} {import b.lna})")).To(Equal(
+					ERROR("circular imports are forbidden"),
+				))
+			})
+			It("should detect circular imports from nested bodies", func() {
+				files.files["a.lna"] = &fstest.MapFile{Data: []byte("parallel ({import a.lna})")}
+				Expect(execute("import a.lna")).To(Equal(
+					ERROR("circular imports are forbidden"),
+				))
+			})
+		})
+
+		Describe("Control flow", func() {
+			It("should propagate the first error in body order", func() {
+				Expect(
+					execute(
+						"parallel ({idem a} {error msg1} {error msg2})",
+					),
+				).To(Equal(ERROR("msg1")))
+			})
+			It("should reject unexpected result codes", func() {
+				Expect(execute("parallel ({break})")).To(Equal(
+					ERROR("unexpected break"),
+				))
+				Expect(execute("parallel ({continue})")).To(Equal(
+					ERROR("unexpected continue"),
+				))
+				Expect(execute("parallel ({yield})")).To(Equal(
+					ERROR("unexpected yield"),
+				))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("parallel")).To(Equal(
+					ERROR(`wrong # args: should be "parallel ?inputs? bodies"`),
+				))
+				Expect(execute("parallel a b c")).To(Equal(
+					ERROR(`wrong # args: should be "parallel ?inputs? bodies"`),
+				))
+				Expect(execute("help parallel a b c")).To(Equal(
+					ERROR(`wrong # args: should be "parallel ?inputs? bodies"`),
+				))
+			})
+			Specify("invalid bodies", func() {
+				Expect(execute("parallel a")).To(Equal(ERROR("invalid list")))
+				Expect(execute("parallel (a)")).To(Equal(ERROR("body must be a script")))
+			})
+			Specify("invalid inputs", func() {
+				Expect(execute("parallel (a) ()")).To(Equal(
+					ERROR("invalid key-value list"),
+				))
+			})
+			Specify("non-shareable inputs", func() {
+				Expect(execute("parallel (cmd [macro {} {}]) ({})")).To(Equal(
+					ERROR("value cannot be shared across interpreters"),
+				))
+			})
+		})
+	})
+
+	Describe("RunParallel", func() {
+		It("should initialize each scope", func() {
+			bodies := []core.ScriptValue{
+				core.NewScriptValue(*parse("cmd"), "cmd"),
+				core.NewScriptValue(*parse("cmd"), "cmd"),
+			}
+			result := RunParallel(
+				func(scope *Scope) {
+					scope.RegisterNamedCommand("cmd", simpleCommand{
+						func(_ []core.Value, _ any) core.Result {
+							return OK(STR("val"))
+						},
+					})
+				},
+				nil,
+				nil,
+				bodies,
+			)
+			Expect(result).To(Equal(OK(LIST([]core.Value{STR("val"), STR("val")}))))
+		})
+		It("should isolate input copies", func() {
+			list := LIST([]core.Value{STR("a")})
+			result := RunParallel(
+				func(scope *Scope) { InitCommands(scope) },
+				nil,
+				map[string]core.Value{"l": list},
+				[]core.ScriptValue{
+					core.NewScriptValue(*parse("set l [list $l append (b)]"), ""),
+					core.NewScriptValue(*parse("get l"), ""),
+				},
+			)
+			Expect(result.Code).To(Equal(core.ResultCode_OK))
+			Expect(result.Value.(core.ListValue).Values[1]).To(Equal(list))
+			Expect(list).To(Equal(LIST([]core.Value{STR("a")})))
+		})
+	})
+
+	Describe("DeepCopyValue", func() {
+		It("should copy nested values", func() {
+			value := LIST([]core.Value{
+				TUPLE([]core.Value{INT(1), REAL(2)}),
+				DICT(map[string]core.Value{"k": STR("v")}),
+			})
+			result, clone := DeepCopyValue(value)
+			Expect(result.Code).To(Equal(core.ResultCode_OK))
+			Expect(clone).To(Equal(value))
+		})
+		It("should give scripts their own cache", func() {
+			script := core.NewScriptValue(*parse("idem a"), "idem a")
+			rootScope.CompileScriptValue(script)
+			_, clone := DeepCopyValue(script)
+			Expect(clone.(core.ScriptValue).Cache.Program).To(BeNil())
+			Expect(script.Cache.Program).NotTo(BeNil())
+		})
+		It("should reject commands", func() {
+			result, _ := DeepCopyValue(core.NewCommandValue(simpleCommand{}))
+			Expect(result).To(Equal(ERROR("value cannot be shared across interpreters")))
+		})
+	})
+})
+
+// File system with slow reads, so that concurrent imports overlap
+type slowFS struct {
+	files fstest.MapFS
+	reads atomic.Int32
+}
+
+func (fsys *slowFS) Open(name string) (fs.File, error) {
+	return fsys.files.Open(name)
+}
+func (fsys *slowFS) ReadFile(name string) ([]byte, error) {
+	fsys.reads.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return fsys.files.ReadFile(name)
+}
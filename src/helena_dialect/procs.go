@@ -75,6 +75,8 @@ func PROC_HELP_SIGNATURE(name core.Value, argspec ArgspecValue, options core.Com
 }
 
 type procCommand struct {
+	commandOrigin
+	commandDoc
 	value       core.Value
 	metacommand *procMetacommand
 	scope       *Scope
@@ -96,6 +98,7 @@ func newProcCommand(
 	proc.scope = scope
 	proc.argspec = argspec
 	proc.body = body
+	proc.doc = ExtractDocString(body.Script)
 	proc.guard = guard
 	proc.program = program
 	proc.metacommand = newProcMetacommand(proc)
@@ -120,6 +123,7 @@ func (proc *procCommand) Execute(args []core.Value, _ any) core.Result {
 	}
 	if proc.guard != nil {
 		return CreateContinuationValueWithCallback(subscope, proc.program, nil, func(result core.Result, data any) core.Result {
+			subscope.ReleaseImports()
 			switch result.Code {
 			case core.ResultCode_OK,
 				core.ResultCode_RETURN:
@@ -127,7 +131,8 @@ func (proc *procCommand) Execute(args []core.Value, _ any) core.Result {
 					program := proc.scope.CompilePair(proc.guard, result.Value)
 					return CreateContinuationValue(proc.scope, program)
 				}
-			case core.ResultCode_ERROR:
+			case core.ResultCode_ERROR,
+				core.ResultCode_CUSTOM:
 				return result
 			default:
 				return core.ERROR("unexpected " + core.RESULT_CODE_NAME(result))
@@ -135,11 +140,13 @@ func (proc *procCommand) Execute(args []core.Value, _ any) core.Result {
 		})
 	} else {
 		return CreateContinuationValueWithCallback(subscope, proc.program, nil, func(result core.Result, data any) core.Result {
+			subscope.ReleaseImports()
 			switch result.Code {
 			case core.ResultCode_OK,
 				core.ResultCode_RETURN:
 				return core.OK(result.Value)
-			case core.ResultCode_ERROR:
+			case core.ResultCode_ERROR,
+				core.ResultCode_CUSTOM:
 				return result
 			default:
 				return core.ERROR("unexpected " + core.RESULT_CODE_NAME(result))
@@ -200,7 +207,7 @@ func (procCmd) Execute(args []core.Value, context any) core.Result {
 		program,
 	)
 	if name != nil {
-		result := scope.RegisterCommand(name, proc)
+		result := scope.defineCommand(name, proc)
 		if result.Code != core.ResultCode_OK {
 			return result
 		}
@@ -446,6 +446,22 @@ var _ = Describe("Helena procedures", func() {
 					Expect(execute("cmd")).To(Equal(ERROR("unexpected continue")))
 				})
 			})
+			Describe("custom code", func() {
+				It("should interrupt a proc and pass through", func() {
+					evaluate("resultcode abort")
+					evaluate("proc cmd {} {abort val; idem val2}")
+					result := execute("cmd")
+					Expect(core.RESULT_CODE_NAME(result)).To(Equal("abort"))
+					Expect(result.Value).To(Equal(STR("val")))
+				})
+				It("should be catchable by caller", func() {
+					evaluate("resultcode abort")
+					evaluate("proc cmd {} {abort val; idem val2}")
+					Expect(
+						evaluate("catch {cmd} custom abort value {idem caught_$value}"),
+					).To(Equal(STR("caught_val")))
+				})
+			})
 		})
 	})
 })
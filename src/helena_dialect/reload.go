@@ -0,0 +1,139 @@
+package helena_dialect
+
+import (
+	"helena/core"
+	"io/fs"
+	"slices"
+	"sync"
+	"time"
+)
+
+// File state of a loaded module, used to detect changes
+type moduleVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func (registry *ModuleRegistry) statVersion(modulePath string) (moduleVersion, bool) {
+	info, err := fs.Stat(registry.fsys, modulePath)
+	if err != nil {
+		return moduleVersion{}, false
+	}
+	return moduleVersion{info.ModTime(), info.Size()}, true
+}
+func (registry *ModuleRegistry) recordVersion(modulePath string) {
+	version, ok := registry.statVersion(modulePath)
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if ok {
+		registry.versions[modulePath] = version
+	} else {
+		delete(registry.versions, modulePath)
+	}
+}
+
+// Reload a file-based module from its registered path
+//
+// The file is evaluated again in a fresh root scope, then the registered
+// module object takes the new scope and exports so that existing references
+// to the module see the new version. Commands imported by dependents are
+// rebound to the new exports. On error the previous version is kept
+//
+// Rebinding updates the scopes of dependents, which aren't safe for
+// concurrent use: Reload must be called from the goroutine running them
+func (registry *ModuleRegistry) Reload(modulePath string) (core.Result, *Module) {
+	registry.mutex.Lock()
+	_, ok := registry.versions[modulePath]
+	registry.mutex.Unlock()
+	module := registry.Get(modulePath)
+	if module == nil || !ok {
+		return core.ERROR(`module "` + modulePath + `" is not loaded from a file`), nil
+	}
+
+	result, fresh := loadFileBasedModule(registry, modulePath, []string{modulePath})
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	result = module.replace(fresh)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	registry.recordVersion(modulePath)
+	return core.OK(module.value), module
+}
+
+// Return the paths of file-based modules modified since they were last
+// loaded or polled, in sorted order
+//
+// Each change is reported once
+func (registry *ModuleRegistry) PollChanges() []string {
+	registry.mutex.Lock()
+	paths := make([]string, 0, len(registry.versions))
+	for modulePath := range registry.versions {
+		paths = append(paths, modulePath)
+	}
+	registry.mutex.Unlock()
+	slices.Sort(paths)
+
+	changed := []string{}
+	for _, modulePath := range paths {
+		version, ok := registry.statVersion(modulePath)
+		if !ok {
+			continue
+		}
+		registry.mutex.Lock()
+		if registry.versions[modulePath] != version {
+			registry.versions[modulePath] = version
+			changed = append(changed, modulePath)
+		}
+		registry.mutex.Unlock()
+	}
+	return changed
+}
+
+// Polling watcher for module file changes
+//
+// The watcher only relies on file modification times and sizes, so it works
+// with any fs.FS and doesn't need platform-specific notification APIs
+type ModuleWatcher struct {
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Watch file-based modules for changes
+//
+// The callback is called from the watcher goroutine with each changed module
+// path. Scopes aren't safe for concurrent use, so hosts typically forward the
+// path to the goroutine running the interpreter, which then calls Reload
+func (registry *ModuleRegistry) Watch(
+	interval time.Duration,
+	onChange func(modulePath string),
+) *ModuleWatcher {
+	watcher := &ModuleWatcher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(watcher.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-watcher.stop:
+				return
+			case <-ticker.C:
+				for _, modulePath := range registry.PollChanges() {
+					onChange(modulePath)
+				}
+			}
+		}
+	}()
+	return watcher
+}
+
+// Stop the watcher and wait for its goroutine to exit
+func (watcher *ModuleWatcher) Stop() {
+	watcher.once.Do(func() { close(watcher.stop) })
+	<-watcher.done
+}
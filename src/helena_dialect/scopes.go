@@ -10,6 +10,7 @@ func SCOPE_HELP_PREFIX(name core.Value, options core.CommandHelpOptions) string
 }
 
 type scopeCommand struct {
+	commandOrigin
 	value core.Value
 	scope *Scope
 }
@@ -140,7 +141,7 @@ func (scopeCmd) Execute(args []core.Value, context any) core.Result {
 			{
 				command := newScopeCommand(subscope)
 				if name != nil {
-					result := scope.RegisterCommand(name, command)
+					result := scope.defineCommand(name, command)
 					if result.Code != core.ResultCode_OK {
 						return result
 					}
@@ -0,0 +1,606 @@
+package helena_dialect
+
+import (
+	"fmt"
+	"helena/core"
+	"io/fs"
+	"strings"
+)
+
+//
+// Text substitution
+//
+// Text is substituted with the same rules as double-quoted strings, except
+// that string delimiters are regular characters
+//
+
+// Parse text into a word made of a single string morpheme
+func parseText(text string) (core.Result, core.Word) {
+	tokens := core.Tokenizer{}.Tokenize(text)
+	parseResult := core.NewParser(nil).ParseText(core.NewArrayTokenStream(tokens, nil))
+	if !parseResult.Success {
+		return core.ERROR(parseResult.Message), core.Word{}
+	}
+	return core.OK(core.NIL), parseResult.Script.Sentences[0].Words[0].Word
+}
+
+// Return the value of parsed text that needs no substitution
+func constantText(word core.Word) (string, bool) {
+	var value strings.Builder
+	for _, morpheme := range word.Morphemes[0].(core.StringMorpheme).Morphemes {
+		literal, ok := morpheme.(core.LiteralMorpheme)
+		if !ok {
+			return "", false
+		}
+		value.WriteString(literal.Value)
+	}
+	return value.String(), true
+}
+
+const SUBST_SIGNATURE = "subst text"
+
+type substCmd struct{}
+
+func (substCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) != 2 {
+		return ARITY_ERROR(SUBST_SIGNATURE)
+	}
+	result, text := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid text")
+	}
+	result, word := parseText(text)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return CreateContinuationValue(scope, scope.compiler.CompileWord(word))
+}
+func (substCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) > 2 {
+		return ARITY_ERROR(SUBST_SIGNATURE)
+	}
+	return core.OK(core.STR(SUBST_SIGNATURE))
+}
+
+//
+// Templates
+//
+// Templates are substituted text with control directives between `{%` and
+// `%}` delimiters:
+//
+//	{% if test %} ... {% elseif test %} ... {% else %} ... {% end %}
+//	{% foreach varname list %} ... {% end %}
+//
+// Directive arguments follow the regular word syntax, and tests are
+// either boolean values or scripts, like for the `if` command. Directives
+// standing alone on their line are removed along with the line, so that they
+// leave no blank lines in the output
+//
+
+const (
+	TEMPLATE_DIRECTIVE_OPEN  = "{%"
+	TEMPLATE_DIRECTIVE_CLOSE = "%}"
+)
+
+type templateNode interface{}
+
+// Text with substitutions
+type templateText struct {
+	// Constant value when program is nil
+	value   string
+	program *core.Program
+}
+
+// Conditional blocks
+type templateIf struct {
+	// Programs returning a tuple with the test value
+	tests  []*core.Program
+	bodies [][]templateNode
+
+	// Body of the else branch (if any)
+	orElse []templateNode
+}
+
+// Loop blocks
+type templateForeach struct {
+	// Program returning a tuple with the variable name and list value
+	args *core.Program
+	body []templateNode
+}
+
+// Compiled template
+//
+// Templates are immutable once compiled and can be rendered any number of
+// times, including concurrently
+type Template struct {
+	nodes []templateNode
+}
+
+// A template segment is either text or a directive
+type templateSegment struct {
+	text      string
+	directive bool
+}
+
+func isBlankLine(s string) bool {
+	return strings.Trim(s, " \t\r") == ""
+}
+
+// Split template source into text and directive segments
+func splitTemplate(source string) (core.Result, []templateSegment) {
+	segments := []templateSegment{}
+	atLineStart := true
+	for {
+		start := strings.Index(source, TEMPLATE_DIRECTIVE_OPEN)
+		if start < 0 {
+			segments = append(segments, templateSegment{source, false})
+			return core.OK(core.NIL), segments
+		}
+		end := strings.Index(source[start+len(TEMPLATE_DIRECTIVE_OPEN):], TEMPLATE_DIRECTIVE_CLOSE)
+		if end < 0 {
+			return core.ERROR("unmatched template directive delimiter"), nil
+		}
+		end += start + len(TEMPLATE_DIRECTIVE_OPEN)
+		text := source[:start]
+		directive := source[start+len(TEMPLATE_DIRECTIVE_OPEN) : end]
+		rest := source[end+len(TEMPLATE_DIRECTIVE_CLOSE):]
+
+		lineStart := strings.LastIndexByte(text, '\n') + 1
+		lineEnd := strings.IndexByte(rest, '\n')
+		if lineEnd < 0 {
+			lineEnd = len(rest)
+		}
+		standalone := (lineStart > 0 || atLineStart) &&
+			isBlankLine(text[lineStart:]) &&
+			isBlankLine(rest[:lineEnd])
+		if standalone {
+			text = text[:lineStart]
+			rest = rest[min(lineEnd+1, len(rest)):]
+		}
+		atLineStart = standalone
+
+		segments = append(segments,
+			templateSegment{text, false},
+			templateSegment{directive, true},
+		)
+		source = rest
+	}
+}
+
+// Template block being compiled
+type templateBlock struct {
+	// Directive keyword that opened the block, empty for the top-level block
+	keyword string
+
+	// Node owning the block
+	node templateNode
+
+	// Current list of nodes
+	nodes *[]templateNode
+}
+
+// Compile a template
+func CompileTemplate(source string) (core.Result, *Template) {
+	result, segments := splitTemplate(source)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	compiler := core.NewCompiler(nil)
+	template := &Template{}
+	stack := []*templateBlock{{nodes: &template.nodes}}
+	for _, segment := range segments {
+		block := stack[len(stack)-1]
+		if !segment.directive {
+			if segment.text == "" {
+				continue
+			}
+			result, word := parseText(segment.text)
+			if result.Code != core.ResultCode_OK {
+				return result, nil
+			}
+			if value, ok := constantText(word); ok {
+				*block.nodes = append(*block.nodes, &templateText{value: value})
+			} else {
+				*block.nodes = append(*block.nodes, &templateText{program: compiler.CompileWord(word)})
+			}
+			continue
+		}
+
+		result, keyword, args := parseTemplateDirective(segment.text)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		argsProgram := compiler.CompileSentences([]core.Sentence{{Words: args}})
+		switch keyword {
+		case "if":
+			if len(args) != 1 {
+				return ARITY_ERROR("if test"), nil
+			}
+			node := &templateIf{
+				tests:  []*core.Program{argsProgram},
+				bodies: [][]templateNode{{}},
+			}
+			*block.nodes = append(*block.nodes, node)
+			stack = append(stack, &templateBlock{keyword, node, &node.bodies[0]})
+
+		case "elseif":
+			if len(args) != 1 {
+				return ARITY_ERROR("elseif test"), nil
+			}
+			if block.keyword != "if" {
+				return core.ERROR(`unexpected "elseif" directive`), nil
+			}
+			node := block.node.(*templateIf)
+			node.tests = append(node.tests, argsProgram)
+			node.bodies = append(node.bodies, []templateNode{})
+			block.nodes = &node.bodies[len(node.bodies)-1]
+
+		case "else":
+			if len(args) != 0 {
+				return ARITY_ERROR("else"), nil
+			}
+			if block.keyword != "if" {
+				return core.ERROR(`unexpected "else" directive`), nil
+			}
+			node := block.node.(*templateIf)
+			node.orElse = []templateNode{}
+			block.keyword = "else"
+			block.nodes = &node.orElse
+
+		case "foreach":
+			if len(args) != 2 {
+				return ARITY_ERROR("foreach varname list"), nil
+			}
+			node := &templateForeach{args: argsProgram}
+			*block.nodes = append(*block.nodes, node)
+			stack = append(stack, &templateBlock{keyword, node, &node.body})
+
+		case "end":
+			if len(args) != 0 {
+				return ARITY_ERROR("end"), nil
+			}
+			if len(stack) == 1 {
+				return core.ERROR(`unexpected "end" directive`), nil
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			return core.ERROR(`unknown directive "` + keyword + `"`), nil
+		}
+	}
+	if len(stack) > 1 {
+		return core.ERROR(`missing "end" directive`), nil
+	}
+	return core.OK(core.NIL), template
+}
+
+// Parse a directive into its keyword and argument words
+func parseTemplateDirective(directive string) (core.Result, string, []core.WordOrValue) {
+	tokens := core.Tokenizer{}.Tokenize(directive)
+	parseResult := core.NewParser(nil).ParseTokens(tokens, nil)
+	if !parseResult.Success {
+		return core.ERROR(parseResult.Message), "", nil
+	}
+	sentences := parseResult.Script.Sentences
+	if len(sentences) != 1 {
+		return core.ERROR("invalid directive"), "", nil
+	}
+	words := sentences[0].Words
+	morphemes := words[0].Word.Morphemes
+	if len(morphemes) != 1 || morphemes[0].Type() != core.MorphemeType_LITERAL {
+		return core.ERROR("invalid directive keyword"), "", nil
+	}
+	return core.OK(core.NIL), morphemes[0].(core.LiteralMorpheme).Value, words[1:]
+}
+
+//
+// Template rendering
+//
+// Rendering evaluates the template nodes in sequence with continuations, so
+// that substituted commands can yield in turn, e.g. from coroutines
+//
+
+// Sequence of template nodes being rendered
+type templateFrame struct {
+	nodes []templateNode
+	i     int
+
+	// Loop state (if any)
+	varname core.Value
+	values  []core.Value
+	j       int
+}
+
+type templateRenderer struct {
+	scope  *Scope
+	output strings.Builder
+	frames []*templateFrame
+}
+
+// Render a template in the given scope
+func (template *Template) Render(scope *Scope) core.Result {
+	renderer := &templateRenderer{scope: scope}
+	renderer.frames = []*templateFrame{{nodes: template.nodes}}
+	return renderer.next()
+}
+
+func (renderer *templateRenderer) next() core.Result {
+	for len(renderer.frames) > 0 {
+		frame := renderer.frames[len(renderer.frames)-1]
+		if frame.i >= len(frame.nodes) {
+			if frame.j < len(frame.values) {
+				result := renderer.iterate(frame)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				continue
+			}
+			renderer.frames = renderer.frames[:len(renderer.frames)-1]
+			continue
+		}
+		node := frame.nodes[frame.i]
+		frame.i++
+		switch node := node.(type) {
+		case *templateText:
+			if node.program == nil {
+				renderer.output.WriteString(node.value)
+				continue
+			}
+			return renderer.evaluate(node.program, func(value core.Value) core.Result {
+				result, s := core.ValueToString(value)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				renderer.output.WriteString(s)
+				return renderer.next()
+			})
+
+		case *templateIf:
+			return renderer.test(node, 0)
+
+		case *templateForeach:
+			return renderer.evaluate(node.args, func(value core.Value) core.Result {
+				args := value.(core.TupleValue).Values
+				if len(args) != 2 {
+					return ARITY_ERROR("foreach varname list")
+				}
+				result, list := ValueToList(args[1])
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				frame := &templateFrame{nodes: node.body, varname: args[0], values: list.Values}
+				renderer.frames = append(renderer.frames, frame)
+				frame.i = len(frame.nodes)
+				return renderer.next()
+			})
+		}
+	}
+	return core.OK(core.STR(renderer.output.String()))
+}
+
+// Run a program and pass its value to the given function
+func (renderer *templateRenderer) evaluate(
+	program *core.Program,
+	then func(value core.Value) core.Result,
+) core.Result {
+	return CreateContinuationValueWithCallback(
+		renderer.scope,
+		program,
+		nil,
+		func(result core.Result, _ any) core.Result {
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			return then(result.Value)
+		},
+	)
+}
+
+// Evaluate the test of the given branch of a conditional block
+func (renderer *templateRenderer) test(node *templateIf, branch int) core.Result {
+	if branch >= len(node.tests) {
+		return renderer.enter(node.orElse)
+	}
+	return renderer.evaluate(node.tests[branch], func(value core.Value) core.Result {
+		args := value.(core.TupleValue).Values
+		if len(args) != 1 {
+			return ARITY_ERROR("if test")
+		}
+		test := args[0]
+		if test.Type() != core.ValueType_SCRIPT {
+			return renderer.branch(node, branch, test)
+		}
+		program := renderer.scope.CompileScriptValue(test.(core.ScriptValue))
+		return renderer.evaluate(program, func(value core.Value) core.Result {
+			return renderer.branch(node, branch, value)
+		})
+	})
+}
+func (renderer *templateRenderer) branch(node *templateIf, branch int, test core.Value) core.Result {
+	result, b := core.ValueToBoolean(test)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	if b {
+		return renderer.enter(node.bodies[branch])
+	}
+	return renderer.test(node, branch+1)
+}
+
+// Render a block of nodes then resume with the current frame
+func (renderer *templateRenderer) enter(nodes []templateNode) core.Result {
+	if len(nodes) > 0 {
+		renderer.frames = append(renderer.frames, &templateFrame{nodes: nodes})
+	}
+	return renderer.next()
+}
+
+// Start the next loop iteration
+func (renderer *templateRenderer) iterate(frame *templateFrame) core.Result {
+	value := frame.values[frame.j]
+	frame.j++
+	frame.i = 0
+	return DestructureValue(
+		func(name core.Value, value core.Value, check bool) core.Result {
+			return renderer.scope.DestructureVariable(name, value, check)
+		},
+		frame.varname,
+		value,
+	)
+}
+
+//
+// Template file cache
+//
+// File-based templates are compiled once and cached in the module registry
+// until their file changes
+//
+
+type cachedTemplate struct {
+	version  moduleVersion
+	template *Template
+}
+
+// Return the compiled template for the given file
+func (registry *ModuleRegistry) LoadTemplate(templatePath string) (core.Result, *Template) {
+	version, ok := registry.statVersion(templatePath)
+	if ok {
+		registry.mutex.Lock()
+		cached, found := registry.templates[templatePath]
+		registry.mutex.Unlock()
+		if found && cached.version == version {
+			return core.OK(core.NIL), cached.template
+		}
+	}
+
+	data, err := fs.ReadFile(registry.fsys, templatePath)
+	if err != nil {
+		return core.ERROR("error reading template: " + fmt.Sprint(err)), nil
+	}
+	result, template := CompileTemplate(string(data))
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	if ok {
+		registry.mutex.Lock()
+		registry.templates[templatePath] = &cachedTemplate{version, template}
+		registry.mutex.Unlock()
+	}
+	return core.OK(core.NIL), template
+}
+
+var templateSubcommands = NewSubcommands([]string{
+	"subcommands",
+	"render",
+	"file",
+})
+
+const TEMPLATE_SIGNATURE = "template ?subcommand? ?arg ...?"
+
+type templateCmd struct {
+	moduleRegistry *ModuleRegistry
+	rootDir        string
+}
+
+func newTemplateCommand(moduleRegistry *ModuleRegistry, rootDir string) *templateCmd {
+	return &templateCmd{moduleRegistry, rootDir}
+}
+
+func (cmd *templateCmd) Execute(args []core.Value, context any) core.Result {
+	scope := context.(*Scope)
+	if len(args) == 1 {
+		return ARITY_ERROR(TEMPLATE_SIGNATURE)
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return ARITY_ERROR("template subcommands")
+		}
+		return core.OK(templateSubcommands.List)
+
+	case "render":
+		if len(args) != 3 && len(args) != 4 {
+			return ARITY_ERROR("template render source ?data?")
+		}
+		result, source := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid source")
+		}
+		result, template := CompileTemplate(source)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return renderTemplate(scope, template, args[3:])
+
+	case "file":
+		if len(args) != 3 && len(args) != 4 {
+			return ARITY_ERROR("template file path ?data?")
+		}
+		result, path := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid path")
+		}
+		result, templatePath := JoinModulePath(cmd.moduleRegistry.fsys, cmd.rootDir, path)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, template := cmd.moduleRegistry.LoadTemplate(templatePath)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return renderTemplate(scope, template, args[3:])
+
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+func (*templateCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(core.STR(TEMPLATE_SIGNATURE))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return INVALID_SUBCOMMAND_ERROR()
+	}
+	var signature string
+	var maxArgs int
+	switch subcommand {
+	case "subcommands":
+		signature, maxArgs = "template subcommands", 2
+	case "render":
+		signature, maxArgs = "template render source ?data?", 4
+	case "file":
+		signature, maxArgs = "template file path ?data?", 4
+	default:
+		return UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if len(args) > maxArgs {
+		return ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+// Render a template in a child scope where data entries are variables
+func renderTemplate(scope *Scope, template *Template, data []core.Value) core.Result {
+	child := scope.NewChildScope()
+	if len(data) > 0 {
+		result, entries := ValueToMap(data[0])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		for name, value := range entries {
+			child.SetNamedVariable(name, value)
+		}
+	}
+	return template.Render(child)
+}
+
+func registerTemplateCommands(scope *Scope, moduleRegistry *ModuleRegistry, rootDir string) {
+	scope.RegisterNamedCommand("subst", substCmd{})
+	scope.RegisterNamedCommand("template", newTemplateCommand(moduleRegistry, rootDir))
+}
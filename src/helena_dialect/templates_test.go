@@ -0,0 +1,307 @@
+package helena_dialect_test
+
+import (
+	"testing/fstest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	. "helena/helena_dialect"
+)
+
+var _ = Describe("Helena templates", func() {
+	var rootScope *Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+	init := func() {
+		rootScope = NewRootScope(nil)
+		InitCommands(rootScope)
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	}
+
+	BeforeEach(init)
+
+	Describe("subst", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help subst")).To(Equal(STR("subst text")))
+				Expect(evaluate("help subst text")).To(Equal(STR("subst text")))
+			})
+
+			It("should return plain text as is", func() {
+				rootScope.SetNamedVariable("text", STR("this {is (a #text;\n"))
+				Expect(evaluate(`subst $text`)).To(Equal(STR("this {is (a #text;\n")))
+				Expect(evaluate(`subst ""`)).To(Equal(STR("")))
+			})
+			It("should substitute variables", func() {
+				evaluate("set name world")
+				Expect(evaluate(`subst {hello ${name}!}`)).To(Equal(STR("hello world!")))
+			})
+			It("should substitute commands", func() {
+				Expect(evaluate(`subst {1 + 2 = [+ 1 2]}`)).To(Equal(STR("1 + 2 = 3")))
+			})
+			It("should apply selectors", func() {
+				evaluate("set l [list (a b c)]; set d [dict (key value)]")
+				Expect(evaluate(`subst {$l[1] $d(key)}`)).To(Equal(STR("b value")))
+			})
+			It("should keep string delimiters", func() {
+				evaluate("set name world")
+				rootScope.SetNamedVariable("text", STR(`key = "$name" """raw""" [string "a b" length]"`))
+				Expect(evaluate(`subst $text`)).To(Equal(
+					STR(`key = "world" """raw""" 3"`),
+				))
+			})
+			It("should evaluate in the caller scope", func() {
+				Expect(evaluate(`proc cmd {x} {subst {x=$x}}; cmd 1`)).To(Equal(STR("x=1")))
+			})
+			It("should accept yielding commands", func() {
+				process := prepareScript("subst {a [yield x] b}")
+				result := process.Run()
+				Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+				Expect(result.Value).To(Equal(STR("x")))
+				process.YieldBack(STR("y"))
+				Expect(process.Run()).To(Equal(OK(STR("a y b"))))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("subst")).To(Equal(ERROR(`wrong # args: should be "subst text"`)))
+				Expect(execute("subst a b")).To(Equal(ERROR(`wrong # args: should be "subst text"`)))
+			})
+			Specify("invalid text", func() {
+				Expect(execute("subst []")).To(Equal(ERROR("invalid text")))
+			})
+			Specify("parse errors", func() {
+				Expect(execute(`subst "a \[b"`)).To(Equal(ERROR("unmatched left bracket")))
+			})
+			Specify("substitution errors", func() {
+				Expect(execute(`subst {a $unknown}`)).To(Equal(
+					ERROR(`cannot resolve variable "unknown"`),
+				))
+				Expect(execute(`subst {a [error msg]}`)).To(Equal(ERROR("msg")))
+			})
+		})
+	})
+
+	Describe("template", func() {
+		Describe("Specifications", func() {
+			Specify("usage", func() {
+				Expect(evaluate("help template")).To(Equal(STR("template ?subcommand? ?arg ...?")))
+				Expect(evaluate("help template render")).To(Equal(
+					STR("template render source ?data?"),
+				))
+				Expect(evaluate("help template file")).To(Equal(
+					STR("template file path ?data?"),
+				))
+			})
+			Specify("subcommands", func() {
+				Expect(evaluate("template subcommands")).To(Equal(
+					evaluate("list (subcommands render file)"),
+				))
+			})
+		})
+
+		Describe("render", func() {
+			It("should substitute data entries", func() {
+				Expect(evaluate(`template render {host = ${host}:[+ $port 1]} [dict (host localhost port 8000)]`)).To(Equal(
+					STR("host = localhost:8001"),
+				))
+				Expect(evaluate(`template render {plain text}`)).To(Equal(STR("plain text")))
+			})
+			It("should not see caller variables", func() {
+				evaluate("set name value")
+				Expect(execute(`template render {$name}`)).To(Equal(
+					ERROR(`cannot resolve variable "name"`),
+				))
+			})
+			It("should render conditional blocks", func() {
+				source := `{a{% if $a %}1{% elseif {$b} %}2{% else %}3{% end %}}`
+				Expect(evaluate(`template render ` + source + ` [dict (a true b false)]`)).To(Equal(STR("a1")))
+				Expect(evaluate(`template render ` + source + ` [dict (a false b true)]`)).To(Equal(STR("a2")))
+				Expect(evaluate(`template render ` + source + ` [dict (a false b false)]`)).To(Equal(STR("a3")))
+				Expect(evaluate(`template render {{% if [string a == b] %}x{% end %}}`)).To(Equal(STR("")))
+			})
+			It("should render loop blocks", func() {
+				Expect(evaluate(`template render {{% foreach i $l %}<${i}>{% end %}} [dict (l (a b c))]`)).To(Equal(
+					STR("<a><b><c>"),
+				))
+				Expect(evaluate(`template render {{% foreach (k v) $l %}${k}=${v};{% end %}} [dict (l ((a 1) (b 2)))]`)).To(Equal(
+					STR("a=1;b=2;"),
+				))
+				Expect(evaluate(`template render {{% foreach i () %}x{% end %}}`)).To(Equal(STR("")))
+			})
+			It("should nest blocks", func() {
+				rootScope.SetNamedVariable("source", STR(`
+{% foreach server $servers %}
+server $server {
+  {% foreach port (80 443) %}
+  {% if {string $port == 443} %}
+  listen $port ssl
+  {% else %}
+  listen $port
+  {% end %}
+  {% end %}
+}
+{% end %}
+`))
+				Expect(evaluate(`template render $source [dict (servers (a b))]`)).To(Equal(STR(`
+server a {
+  listen 80
+  listen 443 ssl
+}
+server b {
+  listen 80
+  listen 443 ssl
+}
+`)))
+			})
+			It("should keep directives inline with text", func() {
+				Expect(evaluate(`template render {a {% if true %}b{% end %}
+c} ()`)).To(Equal(STR("a b\nc")))
+			})
+			It("should accept yielding commands", func() {
+				process := prepareScript(`template render {{% foreach i (1 2) %}[yield $i]{% end %}}`)
+				result := process.Run()
+				Expect(result).To(Equal(core.YIELD(STR("1"))))
+				process.YieldBack(STR("a"))
+				result = process.Run()
+				Expect(result).To(Equal(core.YIELD(STR("2"))))
+				process.YieldBack(STR("b"))
+				Expect(process.Run()).To(Equal(OK(STR("ab"))))
+			})
+		})
+
+		Describe("file", func() {
+			var files fstest.MapFS
+			BeforeEach(func() {
+				files = fstest.MapFS{
+					"config.tpl": {
+						Data:    []byte("{% foreach name $names %}\nname = $name\n{% end %}\n"),
+						ModTime: time.Unix(1, 0),
+					},
+				}
+				rootScope = NewRootScope(nil)
+				moduleRegistry := NewModuleRegistry(&ModuleOptions{FS: files})
+				InitCommandsForModule(rootScope, moduleRegistry, ".")
+			})
+			update := func(source string, seconds int64) {
+				files["config.tpl"] = &fstest.MapFile{Data: []byte(source), ModTime: time.Unix(seconds, 0)}
+			}
+
+			It("should render template files", func() {
+				Expect(evaluate(`template file config.tpl [dict (names (a b))]`)).To(Equal(
+					STR("name = a\nname = b\n"),
+				))
+			})
+			It("should compile files once", func() {
+				evaluate(`template file config.tpl [dict (names ())]`)
+				update("{% foreach name $names %}\nNAME = $name\n{% end %}\n", 1)
+				Expect(evaluate(`template file config.tpl [dict (names (a))]`)).To(Equal(
+					STR("name = a\n"),
+				))
+			})
+			It("should recompile modified files", func() {
+				evaluate(`template file config.tpl [dict (names ())]`)
+				update("{% foreach name $names %}\nNAME = $name\n{% end %}\n", 2)
+				Expect(evaluate(`template file config.tpl [dict (names (a))]`)).To(Equal(
+					STR("NAME = a\n"),
+				))
+			})
+			Specify("unknown files", func() {
+				result := execute(`template file unknown.tpl`)
+				Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+				Expect(result.Value.(core.StringValue).Value).To(ContainSubstring("error reading template"))
+			})
+			Specify("path escapes", func() {
+				Expect(execute(`template file ../outside.tpl`)).To(Equal(
+					ERROR(`module path "../outside.tpl" escapes the module root`),
+				))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("template")).To(Equal(
+					ERROR(`wrong # args: should be "template ?subcommand? ?arg ...?"`),
+				))
+				Expect(execute("template render")).To(Equal(
+					ERROR(`wrong # args: should be "template render source ?data?"`),
+				))
+				Expect(execute("template file a b c")).To(Equal(
+					ERROR(`wrong # args: should be "template file path ?data?"`),
+				))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute("template unknownSubcommand")).To(Equal(
+					ERROR(`unknown subcommand "unknownSubcommand"`),
+				))
+			})
+			Specify("invalid subcommand name", func() {
+				Expect(execute("template []")).To(Equal(ERROR("invalid subcommand name")))
+			})
+			Specify("unmatched delimiters", func() {
+				Expect(execute(`template render "a {% if true"`)).To(Equal(
+					ERROR("unmatched template directive delimiter"),
+				))
+			})
+			Specify("unknown directives", func() {
+				Expect(execute(`template render {{% while true %}}`)).To(Equal(
+					ERROR(`unknown directive "while"`),
+				))
+				Expect(execute(`template render {{% $x %}}`)).To(Equal(
+					ERROR("invalid directive keyword"),
+				))
+				Expect(execute(`template render {{% %}}`)).To(Equal(ERROR("invalid directive")))
+			})
+			Specify("unbalanced blocks", func() {
+				Expect(execute(`template render {{% if true %}}`)).To(Equal(
+					ERROR(`missing "end" directive`),
+				))
+				Expect(execute(`template render {{% end %}}`)).To(Equal(
+					ERROR(`unexpected "end" directive`),
+				))
+				Expect(execute(`template render {{% else %}}`)).To(Equal(
+					ERROR(`unexpected "else" directive`),
+				))
+				Expect(execute(`template render {{% if true %}{% else %}{% elseif true %}{% end %}}`)).To(Equal(
+					ERROR(`unexpected "elseif" directive`),
+				))
+			})
+			Specify("wrong directive arity", func() {
+				Expect(execute(`template render {{% if %}{% end %}}`)).To(Equal(
+					ERROR(`wrong # args: should be "if test"`),
+				))
+				Expect(execute(`template render {{% foreach i %}{% end %}}`)).To(Equal(
+					ERROR(`wrong # args: should be "foreach varname list"`),
+				))
+			})
+			Specify("invalid test values", func() {
+				Expect(execute(`template render {{% if maybe %}{% end %}}`)).To(Equal(
+					ERROR(`invalid boolean "maybe"`),
+				))
+			})
+			Specify("invalid data", func() {
+				Expect(execute(`template render {} (a)`)).To(Equal(ERROR("invalid key-value list")))
+			})
+		})
+	})
+})
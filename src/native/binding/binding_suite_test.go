@@ -0,0 +1,30 @@
+package binding_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+)
+
+func TestBinding(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Binding Suite")
+}
+
+//
+// Helpers
+//
+
+var NIL = core.NIL
+var TRUE = core.TRUE
+var INT = core.INT
+var REAL = core.REAL
+var STR = core.STR
+var LIST = core.LIST
+var DICT = core.DICT
+
+var OK = core.OK
+var ERROR = core.ERROR
@@ -0,0 +1,389 @@
+package binding_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/binding"
+)
+
+type point struct {
+	X      int
+	Y      int
+	Label  string `helena:"label"`
+	hidden bool
+}
+
+func (p *point) Move(dx int, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+func (p point) Norm1() int {
+	return abs(p.X) + abs(p.Y)
+}
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+var _ = Describe("Binding", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("Conversions", func() {
+		convert := func(value core.Value, sample any) any {
+			result, v := binding.FromValue(value, reflect.TypeOf(sample))
+			Expect(result.Code).To(Equal(core.ResultCode_OK))
+			return v.Interface()
+		}
+
+		Specify("scalars", func() {
+			Expect(convert(STR("abc"), "")).To(Equal("abc"))
+			Expect(convert(STR("true"), false)).To(Equal(true))
+			Expect(convert(STR("12"), int8(0))).To(Equal(int8(12)))
+			Expect(convert(INT(12), uint(0))).To(Equal(uint(12)))
+			Expect(convert(STR("1.5"), float32(0))).To(Equal(float32(1.5)))
+			Expect(convert(STR("abc"), []byte{})).To(Equal([]byte("abc")))
+		})
+		Specify("collections", func() {
+			Expect(convert(evaluate("list (1 2)"), []int{})).To(Equal([]int{1, 2}))
+			Expect(convert(evaluate("idem (a b)"), [2]string{})).To(Equal([2]string{"a", "b"}))
+			Expect(convert(evaluate("dict (a 1 b 2)"), map[string]int{})).To(Equal(
+				map[string]int{"a": 1, "b": 2},
+			))
+		})
+		Specify("structs", func() {
+			Expect(convert(evaluate("dict (X 1 label a)"), point{})).To(Equal(
+				point{X: 1, Label: "a"},
+			))
+			Expect(convert(evaluate("dict (Y 2)"), &point{})).To(Equal(&point{Y: 2}))
+		})
+		Specify("natural Go values", func() {
+			Expect(convert(evaluate("list (a [dict (b 1)])"), []any{})).To(Equal(
+				[]any{"a", map[string]any{"b": "1"}},
+			))
+			result, v := binding.FromValue(NIL, reflect.TypeFor[any]())
+			Expect(result.Code).To(Equal(core.ResultCode_OK))
+			Expect(v.IsNil()).To(BeTrue())
+		})
+		Specify("Helena values", func() {
+			Expect(convert(LIST([]core.Value{STR("a")}), core.ListValue{})).To(Equal(
+				LIST([]core.Value{STR("a")}),
+			))
+		})
+		Specify("errors", func() {
+			result, _ := binding.FromValue(STR("a"), reflect.TypeOf(0))
+			Expect(result).To(Equal(ERROR(`invalid integer "a"`)))
+			result, _ = binding.FromValue(INT(256), reflect.TypeOf(uint8(0)))
+			Expect(result).To(Equal(ERROR("integer out of range")))
+			result, _ = binding.FromValue(INT(-1), reflect.TypeOf(uint(0)))
+			Expect(result).To(Equal(ERROR("integer out of range")))
+			result, _ = binding.FromValue(evaluate("dict (Z 1)"), reflect.TypeOf(point{}))
+			Expect(result).To(Equal(ERROR(`unknown field "Z"`)))
+			result, _ = binding.FromValue(STR("a"), reflect.TypeOf(make(chan int)))
+			Expect(result).To(Equal(ERROR("unsupported type chan int")))
+			result, _ = binding.FromValue(NIL, reflect.TypeOf(point{}))
+			Expect(result).To(Equal(ERROR("invalid binding_test.point value")))
+			result, _ = binding.FromValue(binding.NewObjectValue((*point)(nil)), reflect.TypeOf(point{}))
+			Expect(result).To(Equal(ERROR("invalid binding_test.point value")))
+		})
+		Specify("unsigned integers beyond the integer range", func() {
+			for _, u := range []uint64{math.MaxInt64 + 1, math.MaxUint64} {
+				value := binding.ToValue(reflect.ValueOf(u))
+				Expect(convert(value, uint64(0))).To(Equal(u))
+			}
+			result, _ := binding.FromValue(STR("18446744073709551616"), reflect.TypeOf(uint64(0)))
+			Expect(result).To(Equal(ERROR(`invalid integer "18446744073709551616"`)))
+			result, _ = binding.FromValue(STR("9223372036854775808"), reflect.TypeOf(uint32(0)))
+			Expect(result).To(Equal(ERROR("integer out of range")))
+		})
+		Specify("nil values", func() {
+			result, v := binding.FromValue(NIL, reflect.TypeOf(&point{}))
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(v.IsNil()).To(BeTrue())
+			result, v = binding.FromValue(NIL, reflect.TypeOf([]int{}))
+			Expect(result).To(Equal(OK(NIL)))
+			Expect(v.IsNil()).To(BeTrue())
+		})
+
+		Specify("Go values", func() {
+			Expect(binding.ToValue(reflect.ValueOf("a"))).To(Equal(STR("a")))
+			Expect(binding.ToValue(reflect.ValueOf(uint16(3)))).To(Equal(INT(3)))
+			Expect(binding.ToValue(reflect.ValueOf(uint64(1 << 63)))).To(Equal(STR("9223372036854775808")))
+			Expect(binding.ToValue(reflect.ValueOf(2.5))).To(Equal(REAL(2.5)))
+			Expect(binding.ToValue(reflect.ValueOf(true))).To(Equal(TRUE))
+			Expect(binding.ToValue(reflect.ValueOf([]byte("b")))).To(Equal(STR("b")))
+			Expect(binding.ToValue(reflect.ValueOf([]string{"a"}))).To(Equal(
+				LIST([]core.Value{STR("a")}),
+			))
+			Expect(binding.ToValue(reflect.ValueOf([]string(nil)))).To(Equal(NIL))
+			Expect(binding.ToValue(reflect.ValueOf(map[string]int{"a": 1}))).To(Equal(
+				DICT(map[string]core.Value{"a": INT(1)}),
+			))
+			Expect(binding.ToValue(reflect.ValueOf(point{X: 1, Label: "a"}))).To(Equal(
+				DICT(map[string]core.Value{"X": INT(1), "Y": INT(0), "label": STR("a")}),
+			))
+			Expect(binding.ToValue(reflect.ValueOf(STR("v")))).To(Equal(STR("v")))
+		})
+		Specify("opaque Go values", func() {
+			re := regexp.MustCompile("a")
+			value := binding.ToValue(reflect.ValueOf(re))
+			Expect(value).To(Equal(binding.NewObjectValue(re)))
+			Expect(core.IsCustomValue(value, binding.ObjectValueType(reflect.TypeOf(re)))).To(BeTrue())
+			Expect(value.(core.CustomValue).CustomType().Name).To(Equal("go:*regexp.Regexp"))
+			Expect(value.(binding.ObjectValue).Display(nil)).To(Equal("{#{go:*regexp.Regexp}#}"))
+			unwrapped, ok := binding.Unwrap[*regexp.Regexp](value)
+			Expect(ok).To(BeTrue())
+			Expect(unwrapped).To(BeIdenticalTo(re))
+			_, ok = binding.Unwrap[*strings.Builder](value)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Func", func() {
+		It("should call Go functions with converted arguments", func() {
+			rootScope.RegisterNamedCommand("repeat", binding.Func("repeat", strings.Repeat))
+			Expect(execute("repeat ab 3")).To(Equal(OK(STR("ababab"))))
+		})
+		It("should support variadic functions", func() {
+			rootScope.RegisterNamedCommand("join", binding.Func("join", func(sep string, parts ...string) string {
+				return strings.Join(parts, sep)
+			}))
+			Expect(execute("join -")).To(Equal(OK(STR(""))))
+			Expect(execute("join - a b c")).To(Equal(OK(STR("a-b-c"))))
+		})
+		It("should convert results", func() {
+			rootScope.RegisterNamedCommand("noop", binding.Func("noop", func() {}))
+			rootScope.RegisterNamedCommand("pair", binding.Func("pair", func() (int, string) {
+				return 1, "a"
+			}))
+			Expect(execute("noop")).To(Equal(OK(NIL)))
+			Expect(execute("pair")).To(Equal(OK(LIST([]core.Value{INT(1), STR("a")}))))
+		})
+		It("should return errors as ERROR results", func() {
+			rootScope.RegisterNamedCommand("compile", binding.Func("compile", regexp.Compile))
+			rootScope.RegisterNamedCommand("fail", binding.Func("fail", func() error {
+				return errors.New("failure")
+			}))
+			Expect(execute(`compile "("`)).To(Equal(
+				ERROR("error parsing regexp: missing closing ): `(`"),
+			))
+			Expect(execute("fail")).To(Equal(ERROR("failure")))
+			Expect(evaluate("compile a").Type()).To(Equal(core.ValueType_CUSTOM))
+		})
+		It("should support method expressions", func() {
+			rootScope.RegisterNamedCommand("compile", binding.Func("compile", regexp.Compile))
+			rootScope.RegisterNamedCommand("match", binding.Func(
+				"match",
+				(*regexp.Regexp).MatchString,
+			))
+			Expect(execute("set re [compile a+]; match $re baa")).To(Equal(OK(TRUE)))
+		})
+		It("should return panics as ERROR results", func() {
+			rootScope.RegisterNamedCommand("match", binding.Func(
+				"match",
+				(*regexp.Regexp).MatchString,
+			))
+			rootScope.RegisterNamedCommand("fail", binding.Func("fail", func() {
+				panic("failure")
+			}))
+			Expect(execute("match [] a")).To(Equal(
+				ERROR("match: runtime error: invalid memory address or nil pointer dereference"),
+			))
+			Expect(execute("fail")).To(Equal(ERROR("fail: failure")))
+		})
+		It("should generate help signatures", func() {
+			rootScope.RegisterNamedCommand("repeat", binding.Func("repeat", strings.Repeat))
+			rootScope.RegisterNamedCommand("join", binding.Func("join", func(string, ...string) {}))
+			Expect(evaluate("help repeat")).To(Equal(STR("repeat string int")))
+			Expect(evaluate("help join")).To(Equal(STR("join string ?string ...?")))
+			Expect(execute("help repeat a b c")).To(Equal(
+				ERROR(`wrong # args: should be "repeat string int"`),
+			))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				rootScope.RegisterNamedCommand("repeat", binding.Func("repeat", strings.Repeat))
+				Expect(execute("repeat a")).To(Equal(
+					ERROR(`wrong # args: should be "repeat string int"`),
+				))
+			})
+			Specify("invalid arguments", func() {
+				rootScope.RegisterNamedCommand("repeat", binding.Func("repeat", strings.Repeat))
+				Expect(execute("repeat a b")).To(Equal(ERROR(`invalid integer "b"`)))
+			})
+			Specify("non-function values", func() {
+				Expect(func() { binding.Func("a", 1) }).To(PanicWith("binding: a is not a function"))
+			})
+		})
+	})
+
+	Describe("Method", func() {
+		BeforeEach(func() {
+			rootScope.RegisterNamedCommand("compile", binding.Func("compile", regexp.Compile))
+			rootScope.RegisterNamedCommand("match", binding.Method(
+				"match",
+				(*regexp.Regexp).MatchString,
+			))
+		})
+
+		It("should call method expressions", func() {
+			Expect(execute("set re [compile a+]; match $re baa")).To(Equal(OK(TRUE)))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("nil receivers", func() {
+				Expect(execute("match [] a")).To(Equal(ERROR("invalid *regexp.Regexp value")))
+			})
+			Specify("functions without receiver", func() {
+				Expect(func() { binding.Method("a", func() {}) }).To(PanicWith("binding: a has no receiver"))
+			})
+		})
+	})
+
+	Describe("Group", func() {
+		BeforeEach(func() {
+			rootScope.RegisterNamedCommand("strings", binding.Group("strings", map[string]any{
+				"ToUpper": strings.ToUpper,
+				"Split":   strings.Split,
+			}))
+		})
+
+		It("should dispatch to functions by name", func() {
+			Expect(execute("strings ToUpper abc")).To(Equal(OK(STR("ABC"))))
+			Expect(execute("strings Split a,b ,")).To(Equal(
+				OK(LIST([]core.Value{STR("a"), STR("b")})),
+			))
+		})
+		It("should generate help signatures", func() {
+			Expect(evaluate("help strings")).To(Equal(STR("strings method ?arg ...?")))
+			Expect(evaluate("help strings Split")).To(Equal(STR("strings Split string string")))
+		})
+		It("should list methods", func() {
+			Expect(binding.Group("g", map[string]any{"b": abs, "a": abs}).Methods()).To(Equal(
+				[]string{"a", "b"},
+			))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("strings")).To(Equal(
+					ERROR(`wrong # args: should be "strings method ?arg ...?"`),
+				))
+				Expect(execute("strings ToUpper")).To(Equal(
+					ERROR(`wrong # args: should be "strings ToUpper string"`),
+				))
+			})
+			Specify("unknown method", func() {
+				Expect(execute("strings Unknown")).To(Equal(ERROR(`unknown method "Unknown"`)))
+			})
+			Specify("invalid method name", func() {
+				Expect(execute("strings []")).To(Equal(ERROR("invalid method name")))
+			})
+		})
+	})
+
+	Describe("Type", func() {
+		BeforeEach(func() {
+			rootScope.RegisterNamedCommand("point", binding.Type("point", point{}))
+		})
+
+		It("should create object values", func() {
+			value := evaluate("point new (X 1 Y 2)")
+			object, ok := binding.Unwrap[*point](value)
+			Expect(ok).To(BeTrue())
+			Expect(object).To(Equal(&point{X: 1, Y: 2}))
+			Expect(evaluate("point new")).To(Equal(binding.NewObjectValue(&point{})))
+		})
+		It("should list fields and methods", func() {
+			Expect(evaluate("point fields")).To(Equal(
+				LIST([]core.Value{STR("X"), STR("Y"), STR("label")}),
+			))
+			Expect(evaluate("point methods")).To(Equal(
+				LIST([]core.Value{STR("Move"), STR("Norm1")}),
+			))
+		})
+		It("should get and set fields", func() {
+			evaluate("set p [point new (label a)]")
+			Expect(evaluate("point get $p label")).To(Equal(STR("a")))
+			Expect(execute("point set $p X 3")).To(Equal(OK(NIL)))
+			Expect(evaluate("point get $p X")).To(Equal(INT(3)))
+		})
+		It("should call methods", func() {
+			evaluate("set p [point new (X 1 Y 2)]")
+			Expect(execute("point Move $p -3 1")).To(Equal(OK(NIL)))
+			Expect(evaluate("point Norm1 $p")).To(Equal(INT(5)))
+		})
+		It("should accept dictionaries as receivers", func() {
+			Expect(evaluate("point Norm1 (X -1 Y 2)")).To(Equal(INT(3)))
+		})
+		It("should generate help signatures", func() {
+			Expect(evaluate("help point")).To(Equal(STR("point subcommand ?arg ...?")))
+			Expect(evaluate("help point new")).To(Equal(STR("point new ?fields?")))
+			Expect(evaluate("help point Move")).To(Equal(
+				STR("point Move *binding_test.point int int"),
+			))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("point")).To(Equal(
+					ERROR(`wrong # args: should be "point subcommand ?arg ...?"`),
+				))
+				Expect(execute("point get a")).To(Equal(
+					ERROR(`wrong # args: should be "point get object field"`),
+				))
+				Expect(execute("point Move a")).To(Equal(
+					ERROR(`wrong # args: should be "point Move *binding_test.point int int"`),
+				))
+			})
+			Specify("invalid object", func() {
+				Expect(execute("point get a X")).To(Equal(ERROR("invalid point value")))
+			})
+			Specify("nil receivers", func() {
+				Expect(execute("point Move [] 1 2")).To(Equal(
+					ERROR("invalid *binding_test.point value"),
+				))
+			})
+			Specify("unknown field", func() {
+				Expect(execute("point get [point new] Z")).To(Equal(ERROR(`unknown field "Z"`)))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute("point unknown")).To(Equal(ERROR(`unknown subcommand "unknown"`)))
+			})
+			Specify("non-struct types", func() {
+				Expect(func() { binding.Type("a", 1) }).To(PanicWith("binding: a is not a struct type"))
+			})
+		})
+	})
+})
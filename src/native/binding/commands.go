@@ -0,0 +1,372 @@
+//
+// Commands wrapping Go functions, methods and types
+//
+
+package binding
+
+import (
+	"fmt"
+	"helena/core"
+	"helena/helena_dialect"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Command calling a Go function
+//
+// Arguments are converted with FromValue and results with ToValue. A trailing
+// error result becomes an ERROR result when non-nil; remaining results give
+// NIL when there are none, the converted value when there is one, and a list
+// otherwise. Panics in the function also become ERROR results
+type FuncCommand struct {
+	name     string
+	fn       reflect.Value
+	receiver bool
+}
+
+// Wrap a Go function as a command
+//
+// Methods can be wrapped using method expressions such as
+// (*regexp.Regexp).MatchString; the receiver is then the first argument. Use
+// Method to reject nil receivers
+func Func(name string, fn any) *FuncCommand {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		panic("binding: " + name + " is not a function")
+	}
+	return &FuncCommand{name, v, false}
+}
+
+// Wrap a Go method expression as a command
+//
+// The receiver is the first argument and cannot be nil
+func Method(name string, fn any) *FuncCommand {
+	command := Func(name, fn)
+	if command.fn.Type().NumIn() == 0 {
+		panic("binding: " + name + " has no receiver")
+	}
+	command.receiver = true
+	return command
+}
+
+// Return the command signature, using argument type names
+func (command *FuncCommand) Signature() string {
+	t := command.fn.Type()
+	parts := []string{command.name}
+	for i := 0; i < t.NumIn(); i++ {
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			parts = append(parts, "?"+typeName(t.In(i).Elem())+" ...?")
+		} else {
+			parts = append(parts, typeName(t.In(i)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (command *FuncCommand) Execute(args []core.Value, _ any) core.Result {
+	return command.call(args[1:], command.Signature())
+}
+func (command *FuncCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	return command.help(len(args)-1, command.Signature())
+}
+
+func (command *FuncCommand) help(nargs int, signature string) core.Result {
+	t := command.fn.Type()
+	if !t.IsVariadic() && nargs > t.NumIn() {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+func (command *FuncCommand) call(args []core.Value, signature string) core.Result {
+	t := command.fn.Type()
+	nin := t.NumIn()
+	if t.IsVariadic() {
+		if len(args) < nin-1 {
+			return helena_dialect.ARITY_ERROR(signature)
+		}
+	} else if len(args) != nin {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var argType reflect.Type
+		if t.IsVariadic() && i >= nin-1 {
+			argType = t.In(nin - 1).Elem()
+		} else {
+			argType = t.In(i)
+		}
+		result, v := FromValue(arg, argType)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if i == 0 && command.receiver && isNil(v) {
+			return core.ERROR("invalid " + typeName(argType) + " value")
+		}
+		in[i] = v
+	}
+	return command.invoke(in)
+}
+
+// Call the function, turning panics into ERROR results
+func (command *FuncCommand) invoke(in []reflect.Value) (result core.Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = core.ERROR(fmt.Sprintf("%s: %v", command.name, r))
+		}
+	}()
+	return callResult(command.fn.Call(in))
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// Convert function results to a Helena result
+func callResult(out []reflect.Value) core.Result {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return core.ERROR(err.Interface().(error).Error())
+		}
+		out = out[:len(out)-1]
+	}
+	switch len(out) {
+	case 0:
+		return core.OK(core.NIL)
+	case 1:
+		return core.OK(ToValue(out[0]))
+	default:
+		values := make([]core.Value, len(out))
+		for i, v := range out {
+			values[i] = ToValue(v)
+		}
+		return core.OK(core.LIST(values))
+	}
+}
+
+// Command dispatching to Go functions by name: `name method ?arg ...?`
+type GroupCommand struct {
+	name  string
+	funcs map[string]*FuncCommand
+}
+
+// Wrap a set of named Go functions as a single command
+func Group(name string, funcs map[string]any) *GroupCommand {
+	command := &GroupCommand{name, map[string]*FuncCommand{}}
+	for method, fn := range funcs {
+		command.funcs[method] = Func(method, fn)
+	}
+	return command
+}
+
+// Return the sorted method names
+func (command *GroupCommand) Methods() []string {
+	names := make([]string, 0, len(command.funcs))
+	for name := range command.funcs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (command *GroupCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR(command.name + " method ?arg ...?")
+	}
+	result, method := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid method name")
+	}
+	fn, ok := command.funcs[method]
+	if !ok {
+		return core.ERROR(`unknown method "` + method + `"`)
+	}
+	return fn.call(args[2:], command.name+" "+fn.Signature())
+}
+func (command *GroupCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR(command.name + " method ?arg ...?"))
+	}
+	result, method := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid method name")
+	}
+	fn, ok := command.funcs[method]
+	if !ok {
+		return core.ERROR(`unknown method "` + method + `"`)
+	}
+	return fn.help(len(args)-2, command.name+" "+fn.Signature())
+}
+
+// Command binding a Go struct type
+//
+// Instances are object values wrapping pointers to the struct:
+//
+//	name new ?fields?
+//	name fields
+//	name methods
+//	name get object field
+//	name set object field value
+//	name <method> object ?arg ...?
+type TypeCommand struct {
+	name    string
+	t       reflect.Type
+	methods map[string]*FuncCommand
+}
+
+// Bind the struct type of a sample value, e.g. Type("point", Point{})
+func Type(name string, sample any) *TypeCommand {
+	t := reflect.TypeOf(sample)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic("binding: " + name + " is not a struct type")
+	}
+	command := &TypeCommand{name, t, map[string]*FuncCommand{}}
+	ptr := reflect.PointerTo(t)
+	for i := 0; i < ptr.NumMethod(); i++ {
+		method := ptr.Method(i)
+		command.methods[method.Name] = &FuncCommand{method.Name, method.Func, true}
+	}
+	return command
+}
+
+var typeSubcommands = []string{"new", "fields", "methods", "get", "set"}
+
+func (command *TypeCommand) usage(subcommand string) string {
+	switch subcommand {
+	case "new":
+		return command.name + " new ?fields?"
+	case "fields":
+		return command.name + " fields"
+	case "methods":
+		return command.name + " methods"
+	case "get":
+		return command.name + " get object field"
+	case "set":
+		return command.name + " set object field value"
+	}
+	return command.name + " " + command.methods[subcommand].Signature()
+}
+
+func (command *TypeCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR(command.name + " subcommand ?arg ...?")
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "new":
+		if len(args) > 3 {
+			return helena_dialect.ARITY_ERROR(command.usage(subcommand))
+		}
+		ptr := reflect.New(command.t)
+		if len(args) == 3 {
+			result, v := FromValue(args[2], command.t)
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			ptr.Elem().Set(v)
+		}
+		return core.OK(NewObjectValue(ptr.Interface()))
+
+	case "fields":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR(command.usage(subcommand))
+		}
+		fields := exportedFields(command.t)
+		values := make([]core.Value, len(fields))
+		for i, field := range fields {
+			values[i] = core.STR(fieldName(field))
+		}
+		return core.OK(core.LIST(values))
+
+	case "methods":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR(command.usage(subcommand))
+		}
+		names := make([]string, 0, len(command.methods))
+		for name := range command.methods {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		values := make([]core.Value, len(names))
+		for i, name := range names {
+			values[i] = core.STR(name)
+		}
+		return core.OK(core.LIST(values))
+
+	case "get":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR(command.usage(subcommand))
+		}
+		result, field := command.field(args[2], args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(ToValue(field))
+
+	case "set":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR(command.usage(subcommand))
+		}
+		result, field := command.field(args[2], args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, v := FromValue(args[4], field.Type())
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		field.Set(v)
+		return core.OK(core.NIL)
+	}
+
+	method, ok := command.methods[subcommand]
+	if !ok {
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	return method.call(args[2:], command.usage(subcommand))
+}
+func (command *TypeCommand) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR(command.name + " subcommand ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	if method, ok := command.methods[subcommand]; ok && !slices.Contains(typeSubcommands, subcommand) {
+		return method.help(len(args)-2, command.usage(subcommand))
+	}
+	if !slices.Contains(typeSubcommands, subcommand) {
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	return core.OK(core.STR(command.usage(subcommand)))
+}
+
+// Return the addressable field of an object
+func (command *TypeCommand) field(object core.Value, name core.Value) (core.Result, reflect.Value) {
+	ptr, ok := object.(ObjectValue)
+	if !ok || reflect.TypeOf(ptr.object) != reflect.PointerTo(command.t) {
+		return core.ERROR("invalid " + command.name + " value"), reflect.Value{}
+	}
+	result, fieldName := core.ValueToString(name)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid field name"), reflect.Value{}
+	}
+	field, found := structField(command.t, fieldName)
+	if !found {
+		return core.ERROR(`unknown field "` + fieldName + `"`), reflect.Value{}
+	}
+	return core.OK(core.NIL), reflect.ValueOf(ptr.object).Elem().FieldByIndex(field.Index)
+}
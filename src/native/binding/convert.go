@@ -0,0 +1,403 @@
+//
+// Conversion between Helena values and Go values
+//
+
+package binding
+
+import (
+	"fmt"
+	"helena/core"
+	"helena/helena_dialect"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var valueType = reflect.TypeFor[core.Value]()
+var errorType = reflect.TypeFor[error]()
+
+// Convert a Helena value to a Go value of the given type
+//
+// Supported types are strings, booleans, integers, floats, byte slices (from
+// strings), slices and arrays (from lists), maps with string keys and structs
+// (from dictionaries), pointers, and Helena values. Empty interfaces get the
+// natural Go value given by ToGo. Object values convert to their wrapped Go
+// value when assignable. NIL only converts to nil pointers, slices, maps and
+// interfaces
+func FromValue(value core.Value, t reflect.Type) (core.Result, reflect.Value) {
+	if value == core.NIL && !isNillable(t) {
+		return core.ERROR("invalid " + typeName(t) + " value"), reflect.Value{}
+	}
+	if reflect.TypeOf(value).AssignableTo(t) && t.NumMethod() > 0 || reflect.TypeOf(value) == t {
+		return ok(reflect.ValueOf(value))
+	}
+	if object, isObject := value.(ObjectValue); isObject {
+		v := reflect.ValueOf(object.object)
+		if v.Type().AssignableTo(t) {
+			return ok(v)
+		}
+		if v.Kind() == reflect.Pointer && v.Type().Elem().AssignableTo(t) {
+			if v.IsNil() {
+				return core.ERROR("invalid " + typeName(t) + " value"), reflect.Value{}
+			}
+			return ok(v.Elem())
+		}
+		if t.Kind() == reflect.Pointer && v.Type().AssignableTo(t.Elem()) {
+			ptr := reflect.New(t.Elem())
+			ptr.Elem().Set(v)
+			return ok(ptr)
+		}
+		return core.ERROR("invalid " + typeName(t) + " value"), reflect.Value{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		result, s := core.ValueToString(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		return ok(reflect.ValueOf(s).Convert(t))
+
+	case reflect.Bool:
+		result, b := core.ValueToBoolean(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		return ok(reflect.ValueOf(b).Convert(t))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result, i := core.ValueToInteger(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(i) {
+			return core.ERROR("integer out of range"), reflect.Value{}
+		}
+		v.SetInt(i)
+		return ok(v)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result, u := valueToUnsigned(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowUint(u) {
+			return core.ERROR("integer out of range"), reflect.Value{}
+		}
+		v.SetUint(u)
+		return ok(v)
+
+	case reflect.Float32, reflect.Float64:
+		result, f := core.ValueToFloat(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		return ok(reflect.ValueOf(f).Convert(t))
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			result, s := core.ValueToString(value)
+			if result.Code != core.ResultCode_OK {
+				return result, reflect.Value{}
+			}
+			return ok(reflect.ValueOf([]byte(s)).Convert(t))
+		}
+		if value == core.NIL {
+			return ok(reflect.Zero(t))
+		}
+		result, values := helena_dialect.ValueToArray(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		v := reflect.MakeSlice(t, len(values), len(values))
+		for i, element := range values {
+			result, e := FromValue(element, t.Elem())
+			if result.Code != core.ResultCode_OK {
+				return result, reflect.Value{}
+			}
+			v.Index(i).Set(e)
+		}
+		return ok(v)
+
+	case reflect.Array:
+		result, values := helena_dialect.ValueToArray(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		if len(values) != t.Len() {
+			return core.ERROR(fmt.Sprintf("expected %d elements", t.Len())), reflect.Value{}
+		}
+		v := reflect.New(t).Elem()
+		for i, element := range values {
+			result, e := FromValue(element, t.Elem())
+			if result.Code != core.ResultCode_OK {
+				return result, reflect.Value{}
+			}
+			v.Index(i).Set(e)
+		}
+		return ok(v)
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		if value == core.NIL {
+			return ok(reflect.Zero(t))
+		}
+		result, entries := helena_dialect.ValueToMap(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		v := reflect.MakeMapWithSize(t, len(entries))
+		for key, element := range entries {
+			result, e := FromValue(element, t.Elem())
+			if result.Code != core.ResultCode_OK {
+				return result, reflect.Value{}
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), e)
+		}
+		return ok(v)
+
+	case reflect.Struct:
+		result, entries := helena_dialect.ValueToMap(value)
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		v := reflect.New(t).Elem()
+		for key, element := range entries {
+			field, found := structField(t, key)
+			if !found {
+				return core.ERROR(`unknown field "` + key + `"`), reflect.Value{}
+			}
+			result, e := FromValue(element, field.Type)
+			if result.Code != core.ResultCode_OK {
+				return result, reflect.Value{}
+			}
+			v.FieldByIndex(field.Index).Set(e)
+		}
+		return ok(v)
+
+	case reflect.Pointer:
+		if value == core.NIL {
+			return ok(reflect.Zero(t))
+		}
+		result, e := FromValue(value, t.Elem())
+		if result.Code != core.ResultCode_OK {
+			return result, reflect.Value{}
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(e)
+		return ok(ptr)
+
+	case reflect.Interface:
+		v := reflect.ValueOf(ToGo(value))
+		if !v.IsValid() {
+			return ok(reflect.Zero(t))
+		}
+		if v.Type().AssignableTo(t) {
+			return ok(v)
+		}
+		return core.ERROR("invalid " + typeName(t) + " value"), reflect.Value{}
+	}
+	return core.ERROR("unsupported type " + typeName(t)), reflect.Value{}
+}
+
+func isNillable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return true
+	default:
+		return false
+	}
+}
+
+// Convert a Helena value to an unsigned integer, accepting decimal strings
+// beyond the integer range as produced by ToValue
+func valueToUnsigned(value core.Value) (core.Result, uint64) {
+	if value.Type() != core.ValueType_INTEGER {
+		if result, s := core.ValueToString(value); result.Code == core.ResultCode_OK {
+			if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return core.OK(core.NIL), u
+			}
+		}
+	}
+	result, i := core.ValueToInteger(value)
+	if result.Code != core.ResultCode_OK {
+		return result, 0
+	}
+	if i < 0 {
+		return core.ERROR("integer out of range"), 0
+	}
+	return core.OK(core.NIL), uint64(i)
+}
+
+func ok(v reflect.Value) (core.Result, reflect.Value) {
+	return core.OK(core.NIL), v
+}
+
+// Convert a Helena value to a natural Go value
+//
+// Strings, integers, reals and booleans convert to their Go counterparts,
+// lists and tuples to []any, dictionaries to map[string]any, NIL to nil and
+// objects to their wrapped value. Other values are kept as is
+func ToGo(value core.Value) any {
+	switch v := value.(type) {
+	case ObjectValue:
+		return v.object
+	case core.StringValue:
+		return v.Value
+	case core.IntegerValue:
+		return v.Value
+	case core.RealValue:
+		return v.Value
+	case core.BooleanValue:
+		return v.Value
+	case core.ListValue:
+		return valuesToGo(v.Values)
+	case core.TupleValue:
+		return valuesToGo(v.Values)
+	case core.DictionaryValue:
+		m := make(map[string]any, len(v.Map))
+		for key, element := range v.Map {
+			m[key] = ToGo(element)
+		}
+		return m
+	}
+	if value == core.NIL {
+		return nil
+	}
+	return value
+}
+func valuesToGo(values []core.Value) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = ToGo(value)
+	}
+	return result
+}
+
+// Convert a Go value to a Helena value
+//
+// Strings and byte slices convert to strings, booleans, integers and floats
+// to their Helena counterparts, slices and arrays to lists, maps and structs
+// with exported fields to dictionaries, and nil values to NIL. Unsigned
+// integers beyond the integer range convert to decimal strings, which
+// FromValue accepts for unsigned types. Pointers, functions, channels and
+// structs without exported fields are wrapped as opaque object values
+func ToValue(v reflect.Value) core.Value {
+	if !v.IsValid() {
+		return core.NIL
+	}
+	if v.Type().Implements(valueType) {
+		if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil() {
+			return core.NIL
+		}
+		return v.Interface().(core.Value)
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return core.NIL
+		}
+		return ToValue(v.Elem())
+	case reflect.String:
+		return core.STR(v.String())
+	case reflect.Bool:
+		return core.BOOL(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return core.INT(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return core.STR(strconv.FormatUint(v.Uint(), 10))
+		}
+		return core.INT(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return core.REAL(v.Float())
+	case reflect.Slice:
+		if v.IsNil() {
+			return core.NIL
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return core.STR(string(v.Bytes()))
+		}
+		return listValue(v)
+	case reflect.Array:
+		return listValue(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return core.NIL
+		}
+		m := make(map[string]core.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = ToValue(iter.Value())
+		}
+		return core.DICT(m)
+	case reflect.Struct:
+		fields := exportedFields(v.Type())
+		if len(fields) == 0 {
+			return NewObjectValue(v.Interface())
+		}
+		m := make(map[string]core.Value, len(fields))
+		for _, field := range fields {
+			m[fieldName(field)] = ToValue(v.FieldByIndex(field.Index))
+		}
+		return core.DICT(m)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return core.NIL
+		}
+	}
+	return NewObjectValue(v.Interface())
+}
+
+func listValue(v reflect.Value) core.ListValue {
+	values := make([]core.Value, v.Len())
+	for i := range values {
+		values[i] = ToValue(v.Index(i))
+	}
+	return core.LIST(values)
+}
+
+// Return the exported fields of a struct type
+func exportedFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous && fieldName(field) != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Return the Helena name of a struct field, from its `helena` tag if any
+func fieldName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("helena"); ok && tag != "" {
+		return tag
+	}
+	return field.Name
+}
+
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for _, field := range exportedFields(t) {
+		if fieldName(field) == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Return the name of a Go type as used in signatures and error messages
+func typeName(t reflect.Type) string {
+	if t == valueType {
+		return "value"
+	}
+	name := t.String()
+	if strings.HasPrefix(name, "interface {") {
+		return "any"
+	}
+	return name
+}
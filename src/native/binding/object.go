@@ -0,0 +1,53 @@
+//
+// Opaque Go object values
+//
+
+package binding
+
+import (
+	"helena/core"
+	"reflect"
+)
+
+// Custom value wrapping an opaque Go object
+type ObjectValue struct {
+	object any
+}
+
+func NewObjectValue(object any) ObjectValue {
+	return ObjectValue{object}
+}
+
+// Return the custom value type of objects wrapping values of the given Go type
+func ObjectValueType(t reflect.Type) core.CustomValueType {
+	return core.CustomValueType{Name: "go:" + t.String()}
+}
+
+func (ObjectValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value ObjectValue) CustomType() core.CustomValueType {
+	return ObjectValueType(reflect.TypeOf(value.object))
+}
+func (value ObjectValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel(value.CustomType().Name)
+}
+
+// Return the wrapped Go object
+func (value ObjectValue) Object() any {
+	return value.object
+}
+
+// Extract a Go value of the given type from an object value
+func Unwrap[T any](value core.Value) (T, bool) {
+	object, ok := value.(ObjectValue)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := object.object.(T)
+	return t, ok
+}
@@ -0,0 +1,340 @@
+package go_crypto
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"helena/core"
+	"helena/helena_dialect"
+	"net/url"
+	"strings"
+)
+
+// Strip the package prefix from Go error messages
+func encodingError(err error, pkg string) core.Result {
+	return core.ERROR(strings.TrimPrefix(err.Error(), pkg+": "))
+}
+
+type HexCmd struct{}
+
+func (HexCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "hex method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/encoding/hex
+	switch method {
+	case "EncodeToString":
+		// https://pkg.go.dev/encoding/hex#EncodeToString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "hex EncodeToString src"`)
+		}
+		result, src := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(hex.EncodeToString(src)))
+
+	case "DecodeString":
+		// https://pkg.go.dev/encoding/hex#DecodeString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "hex DecodeString s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		bytes, err := hex.DecodeString(s)
+		if err != nil {
+			return encodingError(err, "encoding/hex")
+		}
+		return core.OK(NewBytesValue(bytes))
+
+	case "Dump":
+		// https://pkg.go.dev/encoding/hex#Dump
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "hex Dump data"`)
+		}
+		result, data := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(hex.Dump(data)))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// Base64 and base32 encodings
+//
+// The encoding is given by name before the method, e.g.
+// `base64 URLEncoding EncodeToString src`, and defaults to StdEncoding
+//
+
+var base64Encodings = map[string]*base64.Encoding{
+	"StdEncoding":    base64.StdEncoding,
+	"URLEncoding":    base64.URLEncoding,
+	"RawStdEncoding": base64.RawStdEncoding,
+	"RawURLEncoding": base64.RawURLEncoding,
+}
+
+type Base64Cmd struct{}
+
+func (Base64Cmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "base64 ?encoding? method ?arg ...?"`)
+	}
+	encoding := base64.StdEncoding
+	if name, ok := asString(args[1]); ok && base64Encodings[name] != nil {
+		encoding = base64Encodings[name]
+		args = args[1:]
+		if len(args) < 2 {
+			return core.ERROR(`wrong # args: should be "base64 ?encoding? method ?arg ...?"`)
+		}
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/encoding/base64
+	switch method {
+	case "EncodeToString":
+		// https://pkg.go.dev/encoding/base64#Encoding.EncodeToString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "base64 ?encoding? EncodeToString src"`)
+		}
+		result, src := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(encoding.EncodeToString(src)))
+
+	case "DecodeString":
+		// https://pkg.go.dev/encoding/base64#Encoding.DecodeString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "base64 ?encoding? DecodeString s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		bytes, err := encoding.DecodeString(s)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(NewBytesValue(bytes))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+var base32Encodings = map[string]*base32.Encoding{
+	"StdEncoding": base32.StdEncoding,
+	"HexEncoding": base32.HexEncoding,
+}
+
+type Base32Cmd struct{}
+
+func (Base32Cmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "base32 ?encoding? method ?arg ...?"`)
+	}
+	encoding := base32.StdEncoding
+	if name, ok := asString(args[1]); ok && base32Encodings[name] != nil {
+		encoding = base32Encodings[name]
+		args = args[1:]
+		if len(args) < 2 {
+			return core.ERROR(`wrong # args: should be "base32 ?encoding? method ?arg ...?"`)
+		}
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/encoding/base32
+	switch method {
+	case "EncodeToString":
+		// https://pkg.go.dev/encoding/base32#Encoding.EncodeToString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "base32 ?encoding? EncodeToString src"`)
+		}
+		result, src := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(encoding.EncodeToString(src)))
+
+	case "DecodeString":
+		// https://pkg.go.dev/encoding/base32#Encoding.DecodeString
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "base32 ?encoding? DecodeString s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		bytes, err := encoding.DecodeString(s)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(NewBytesValue(bytes))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// URL encoding
+//
+
+// Convert query values to a dict of lists
+func valuesToDict(values url.Values) core.Value {
+	entries := make(map[string]core.Value, len(values))
+	for key, list := range values {
+		items := make([]core.Value, len(list))
+		for i, item := range list {
+			items[i] = core.STR(item)
+		}
+		entries[key] = core.LIST(items)
+	}
+	return core.DICT(entries)
+}
+
+// Convert a dict to query values, list and tuple entries giving multiple
+// values for the same key
+func dictToValues(value core.Value) (core.Result, url.Values) {
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	values := url.Values{}
+	for key, entry := range entries {
+		items := []core.Value{entry}
+		if entry.Type() == core.ValueType_LIST || entry.Type() == core.ValueType_TUPLE {
+			_, items = helena_dialect.ValueToArray(entry)
+		}
+		for _, item := range items {
+			result, s := core.ValueToString(item)
+			if result.Code != core.ResultCode_OK {
+				return result, nil
+			}
+			values.Add(key, s)
+		}
+	}
+	return core.OK(core.NIL), values
+}
+
+func urlToDict(u *url.URL) core.Value {
+	password, _ := u.User.Password()
+	return core.DICT(map[string]core.Value{
+		"scheme":   core.STR(u.Scheme),
+		"username": core.STR(u.User.Username()),
+		"password": core.STR(password),
+		"host":     core.STR(u.Host),
+		"hostname": core.STR(u.Hostname()),
+		"port":     core.STR(u.Port()),
+		"path":     core.STR(u.Path),
+		"query":    core.STR(u.RawQuery),
+		"fragment": core.STR(u.Fragment),
+	})
+}
+
+type UrlCmd struct{}
+
+func (UrlCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "url method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/net/url
+	switch method {
+	case "QueryEscape", "PathEscape":
+		// https://pkg.go.dev/net/url#QueryEscape
+		// https://pkg.go.dev/net/url#PathEscape
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "url ` + method + ` s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if method == "QueryEscape" {
+			return core.OK(core.STR(url.QueryEscape(s)))
+		}
+		return core.OK(core.STR(url.PathEscape(s)))
+
+	case "QueryUnescape", "PathUnescape":
+		// https://pkg.go.dev/net/url#QueryUnescape
+		// https://pkg.go.dev/net/url#PathUnescape
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "url ` + method + ` s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		unescape := url.QueryUnescape
+		if method == "PathUnescape" {
+			unescape = url.PathUnescape
+		}
+		unescaped, err := unescape(s)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.STR(unescaped))
+
+	case "ParseQuery":
+		// https://pkg.go.dev/net/url#ParseQuery
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "url ParseQuery query"`)
+		}
+		result, query := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(valuesToDict(values))
+
+	case "EncodeQuery":
+		// https://pkg.go.dev/net/url#Values.Encode
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "url EncodeQuery values"`)
+		}
+		result, values := dictToValues(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(values.Encode()))
+
+	case "Parse":
+		// https://pkg.go.dev/net/url#Parse
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "url Parse rawURL"`)
+		}
+		result, rawURL := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(urlToDict(u))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
@@ -0,0 +1,437 @@
+package go_crypto
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"helena/core"
+	"helena/helena_dialect"
+	"io"
+	"math/big"
+	"strings"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+// Source of randomness for random bytes and UUIDs, can be replaced for
+// reproducible results
+var Rand io.Reader = rand.Reader
+
+//
+// Byte slices
+//
+// Binary results such as digests and decoded data are returned as bytes
+// values. Functions that take data accept both bytes values and strings
+//
+
+type BytesValue struct {
+	bytes []byte
+}
+
+var BytesValueType core.CustomValueType = core.CustomValueType{Name: "go:Bytes"}
+
+func NewBytesValue(bytes []byte) BytesValue {
+	return BytesValue{bytes: bytes}
+}
+
+func (BytesValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+
+func (BytesValue) CustomType() core.CustomValueType {
+	return BytesValueType
+}
+
+func (value BytesValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("Bytes " + hex.EncodeToString(value.bytes))
+}
+
+// Bytes values are immutable and can be shared across interpreters
+func (BytesValue) Shareable() {}
+
+func (value BytesValue) Bytes() []byte {
+	return value.bytes
+}
+
+func (value BytesValue) Command() core.Command {
+	return bytesCommand{value}
+}
+
+func valueToBytes(value core.Value) (core.Result, []byte) {
+	if bytes, ok := value.(BytesValue); ok {
+		return core.OK(core.NIL), bytes.bytes
+	}
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	return core.OK(core.NIL), []byte(s)
+}
+
+type bytesCommand struct {
+	value BytesValue
+}
+
+var bytesSubcommands = helena_dialect.NewSubcommands([]string{
+	"subcommands",
+	"length",
+	"string",
+	"hex",
+})
+
+func (cmd bytesCommand) Execute(args []core.Value, context any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<bytes> subcommands")
+		}
+		return core.OK(bytesSubcommands.List)
+
+	case "length":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<bytes> length")
+		}
+		return core.OK(core.INT(int64(len(cmd.value.bytes))))
+
+	case "string":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<bytes> string")
+		}
+		return core.OK(core.STR(string(cmd.value.bytes)))
+
+	case "hex":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<bytes> hex")
+		}
+		return core.OK(core.STR(hex.EncodeToString(cmd.value.bytes)))
+
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+
+//
+// Hashes
+//
+
+type Sha256Cmd struct{}
+
+func (Sha256Cmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "sha256 method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/crypto/sha256
+	switch method {
+	case "Sum256":
+		// https://pkg.go.dev/crypto/sha256#Sum256
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "sha256 Sum256 data"`)
+		}
+		result, data := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		sum := sha256.Sum256(data)
+		return core.OK(NewBytesValue(sum[:]))
+
+	case "Sum224":
+		// https://pkg.go.dev/crypto/sha256#Sum224
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "sha256 Sum224 data"`)
+		}
+		result, data := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		sum := sha256.Sum224(data)
+		return core.OK(NewBytesValue(sum[:]))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+type Sha1Cmd struct{}
+
+func (Sha1Cmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "sha1 method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/crypto/sha1
+	switch method {
+	case "Sum":
+		// https://pkg.go.dev/crypto/sha1#Sum
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "sha1 Sum data"`)
+		}
+		result, data := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		sum := sha1.Sum(data)
+		return core.OK(NewBytesValue(sum[:]))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+type Md5Cmd struct{}
+
+func (Md5Cmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "md5 method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/crypto/md5
+	switch method {
+	case "Sum":
+		// https://pkg.go.dev/crypto/md5#Sum
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "md5 Sum data"`)
+		}
+		result, data := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		sum := md5.Sum(data)
+		return core.OK(NewBytesValue(sum[:]))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+// Hash functions available to HMAC, by command name
+var hashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+type HmacCmd struct{}
+
+func (HmacCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "hmac method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/crypto/hmac
+	switch method {
+	case "Sum":
+		// https://pkg.go.dev/crypto/hmac#New
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "hmac Sum hash key message"`)
+		}
+		name, ok := asString(args[2])
+		if !ok {
+			return core.ERROR("invalid hash name")
+		}
+		newHash, ok := hashes[name]
+		if !ok {
+			return core.ERROR(`unsupported hash "` + name + `"`)
+		}
+		result, key := valueToBytes(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, message := valueToBytes(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		mac := hmac.New(newHash, key)
+		mac.Write(message)
+		return core.OK(NewBytesValue(mac.Sum(nil)))
+
+	case "Equal":
+		// https://pkg.go.dev/crypto/hmac#Equal
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "hmac Equal mac1 mac2"`)
+		}
+		result, mac1 := valueToBytes(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, mac2 := valueToBytes(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.BOOL(hmac.Equal(mac1, mac2)))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// Random generation
+//
+
+type RandCmd struct{}
+
+func (RandCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "rand method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/crypto/rand
+	switch method {
+	case "Read":
+		// https://pkg.go.dev/crypto/rand#Read
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "rand Read n"`)
+		}
+		result, n := core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if n < 0 {
+			return core.ERROR("negative length")
+		}
+		bytes := make([]byte, n)
+		if _, err := io.ReadFull(Rand, bytes); err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(NewBytesValue(bytes))
+
+	case "Int":
+		// https://pkg.go.dev/crypto/rand#Int
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "rand Int max"`)
+		}
+		result, max := core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if max <= 0 {
+			return core.ERROR("argument to Int is <= 0")
+		}
+		n, err := rand.Int(Rand, big.NewInt(max))
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.INT(n.Int64()))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// UUIDs
+//
+// UUIDs are strings in the canonical 8-4-4-4-12 hexadecimal form, see
+// RFC 9562
+//
+
+func formatUuid(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func parseUuid(s string) ([]byte, bool) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return nil, false
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(b) != 16 {
+		return nil, false
+	}
+	return b, true
+}
+
+type UuidCmd struct{}
+
+func (UuidCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "uuid method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	switch method {
+	case "New":
+		// Random (version 4) UUID
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "uuid New"`)
+		}
+		b := make([]byte, 16)
+		if _, err := io.ReadFull(Rand, b); err != nil {
+			return core.ERROR(err.Error())
+		}
+		b[6] = (b[6] & 0x0f) | 0x40
+		b[8] = (b[8] & 0x3f) | 0x80
+		return core.OK(core.STR(formatUuid(b)))
+
+	case "Parse":
+		// Validate and return the canonical lowercase form
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "uuid Parse s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		b, ok := parseUuid(s)
+		if !ok {
+			return core.ERROR(`invalid UUID "` + s + `"`)
+		}
+		return core.OK(core.STR(formatUuid(b)))
+
+	case "Version":
+		// Version number from the high nibble of byte 6
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "uuid Version s"`)
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		b, ok := parseUuid(s)
+		if !ok {
+			return core.ERROR(`invalid UUID "` + s + `"`)
+		}
+		return core.OK(core.INT(int64(b[6] >> 4)))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
@@ -0,0 +1,32 @@
+package go_crypto
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+)
+
+/**
+ * Main static module entry point.
+ */
+func Initmodule() *helena_dialect.Module {
+	scope := helena_dialect.NewRootScope(nil)
+	exports := &helena_dialect.Exports{}
+	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Hashing, random generation and encoding from the Go crypto, encoding and net/url packages")
+	exportCommand(module, "sha256", Sha256Cmd{})
+	exportCommand(module, "sha1", Sha1Cmd{})
+	exportCommand(module, "md5", Md5Cmd{})
+	exportCommand(module, "hmac", HmacCmd{})
+	exportCommand(module, "rand", RandCmd{})
+	exportCommand(module, "uuid", UuidCmd{})
+	exportCommand(module, "hex", HexCmd{})
+	exportCommand(module, "base64", Base64Cmd{})
+	exportCommand(module, "base32", Base32Cmd{})
+	exportCommand(module, "url", UrlCmd{})
+	return module
+}
+
+func exportCommand(module *helena_dialect.Module, name string, cmd core.Command) {
+	module.Scope.RegisterNamedCommand(name, cmd)
+	(*module.Exports)[name] = core.STR(name)
+}
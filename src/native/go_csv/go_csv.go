@@ -0,0 +1,679 @@
+package go_csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"helena/core"
+	"helena/helena_dialect"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+func valueToRune(value core.Value, name string) (core.Result, rune) {
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK || utf8.RuneCountInString(s) != 1 {
+		return core.ERROR("invalid " + name), 0
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return core.OK(core.NIL), r
+}
+
+func valueToStrings(value core.Value) (core.Result, []string) {
+	result, values := helena_dialect.ValueToArray(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	strings := make([]string, len(values))
+	for i, value := range values {
+		result, s := core.ValueToString(value)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		strings[i] = s
+	}
+	return core.OK(core.NIL), strings
+}
+
+func stringsToList(strings []string) core.Value {
+	values := make([]core.Value, len(strings))
+	for i, s := range strings {
+		values[i] = core.STR(s)
+	}
+	return core.LIST(values)
+}
+
+//
+// Reading
+//
+// Records are returned as lists of strings, or as dicts keyed by column name
+// when the `header` option is given. The header is either `true` to use the
+// first record, or a list of column names
+//
+
+type readerOptions struct {
+	comma            rune
+	comment          rune
+	lazyQuotes       bool
+	trimLeadingSpace bool
+	fieldsPerRecord  *int
+	useHeader        bool
+	header           []string
+}
+
+func valueToReaderOptions(value core.Value) (core.Result, readerOptions) {
+	options := readerOptions{}
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid options"), options
+	}
+	for key, value := range entries {
+		switch key {
+		case "comma":
+			result, comma := valueToRune(value, "comma")
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.comma = comma
+
+		case "comment":
+			result, comment := valueToRune(value, "comment")
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.comment = comment
+
+		case "lazyQuotes":
+			result, lazyQuotes := core.ValueToBoolean(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.lazyQuotes = lazyQuotes
+
+		case "trimLeadingSpace":
+			result, trimLeadingSpace := core.ValueToBoolean(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.trimLeadingSpace = trimLeadingSpace
+
+		case "fieldsPerRecord":
+			result, fieldsPerRecord := core.ValueToInteger(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid fieldsPerRecord"), options
+			}
+			n := int(fieldsPerRecord)
+			options.fieldsPerRecord = &n
+
+		case "header":
+			if value.Type() == core.ValueType_LIST || value.Type() == core.ValueType_TUPLE {
+				result, header := valueToStrings(value)
+				if result.Code != core.ResultCode_OK {
+					return core.ERROR("invalid header"), options
+				}
+				options.header = header
+				options.useHeader = true
+				continue
+			}
+			result, useHeader := core.ValueToBoolean(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid header"), options
+			}
+			options.useHeader = useHeader
+
+		default:
+			return core.ERROR(`unknown option "` + key + `"`), options
+		}
+	}
+	return core.OK(core.NIL), options
+}
+
+type csvReader struct {
+	reader    *csv.Reader
+	file      *os.File
+	useHeader bool
+	header    []string
+}
+
+func newReader(r io.Reader, options readerOptions) *csvReader {
+	reader := csv.NewReader(r)
+	if options.comma != 0 {
+		reader.Comma = options.comma
+	}
+	reader.Comment = options.comment
+	reader.LazyQuotes = options.lazyQuotes
+	reader.TrimLeadingSpace = options.trimLeadingSpace
+	if options.fieldsPerRecord != nil {
+		reader.FieldsPerRecord = *options.fieldsPerRecord
+	} else if options.header != nil {
+		reader.FieldsPerRecord = len(options.header)
+	}
+	return &csvReader{
+		reader:    reader,
+		useHeader: options.useHeader,
+		header:    options.header,
+	}
+}
+
+func openReader(path string, options readerOptions) (core.Result, *csvReader) {
+	file, err := os.Open(path)
+	if err != nil {
+		return core.ERROR(err.Error()), nil
+	}
+	reader := newReader(file, options)
+	reader.file = file
+	return core.OK(core.NIL), reader
+}
+
+func (r *csvReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// Read the header record if needed, nil at EOF
+func (r *csvReader) readHeader() (core.Result, []string) {
+	if !r.useHeader || r.header != nil {
+		return core.OK(core.NIL), r.header
+	}
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		r.close()
+		return core.OK(core.NIL), nil
+	}
+	if err != nil {
+		return core.ERROR(err.Error()), nil
+	}
+	r.header = record
+	return core.OK(core.NIL), r.header
+}
+
+// Read the next record, nil at EOF. The file, if any, is closed at EOF
+func (r *csvReader) read() (core.Result, core.Value) {
+	result, _ := r.readHeader()
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	if r.useHeader && r.header == nil {
+		return core.OK(core.NIL), nil
+	}
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		r.close()
+		return core.OK(core.NIL), nil
+	}
+	if err != nil {
+		return core.ERROR(err.Error()), nil
+	}
+	if !r.useHeader {
+		return core.OK(core.NIL), stringsToList(record)
+	}
+	entries := make(map[string]core.Value, len(r.header))
+	for i, name := range r.header {
+		if i < len(record) {
+			entries[name] = core.STR(record[i])
+		}
+	}
+	return core.OK(core.NIL), core.DICT(entries)
+}
+
+func (r *csvReader) readAll() core.Result {
+	defer r.close()
+	records := []core.Value{}
+	for {
+		result, record := r.read()
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if record == nil {
+			return core.OK(core.LIST(records))
+		}
+		records = append(records, record)
+	}
+}
+
+//
+// Streaming
+//
+// Records are read one at a time and passed to the callback, so that large
+// inputs are never loaded entirely. The callback can yield, and break to
+// stop early
+//
+
+type streamState struct {
+	scope    *helena_dialect.Scope
+	callback core.Value
+	reader   *csvReader
+	count    int64
+}
+
+func startStream(scope *helena_dialect.Scope, reader *csvReader, callback core.Value) core.Result {
+	return nextRecord(&streamState{scope, callback, reader, 0})
+}
+
+func nextRecord(state *streamState) core.Result {
+	result, record := state.reader.read()
+	if result.Code != core.ResultCode_OK {
+		state.reader.close()
+		return result
+	}
+	if record == nil {
+		return core.OK(core.INT(state.count))
+	}
+	program := state.scope.CompileArgs([]core.Value{state.callback, record})
+	return helena_dialect.CreateContinuationValueWithCallback(
+		state.scope,
+		program,
+		state,
+		func(result core.Result, data any) core.Result {
+			state := data.(*streamState)
+			switch result.Code {
+			case core.ResultCode_OK:
+				state.count++
+				return nextRecord(state)
+			case core.ResultCode_BREAK:
+				state.reader.close()
+				return core.OK(core.INT(state.count))
+			default:
+				state.reader.close()
+				return result
+			}
+		},
+	)
+}
+
+var ReaderValueType = core.CustomValueType{Name: "go:Reader"}
+
+type ReaderValue struct {
+	reader *csvReader
+}
+
+func (value ReaderValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value ReaderValue) CustomType() core.CustomValueType {
+	return ReaderValueType
+}
+func (value ReaderValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("Reader")
+}
+func (value ReaderValue) Command() core.Command {
+	return readerCommand{value}
+}
+
+type readerCommand struct {
+	value ReaderValue
+}
+
+func (cmd readerCommand) Execute(args []core.Value, context any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	reader := cmd.value.reader
+	// https://pkg.go.dev/encoding/csv#Reader
+	switch method {
+	case "Read":
+		// https://pkg.go.dev/encoding/csv#Reader.Read
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<reader> Read"`)
+		}
+		result, record := reader.read()
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if record == nil {
+			return core.OK(core.NIL)
+		}
+		return core.OK(record)
+
+	case "ReadAll":
+		// https://pkg.go.dev/encoding/csv#Reader.ReadAll
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<reader> ReadAll"`)
+		}
+		return reader.readAll()
+
+	case "ForEach":
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<reader> ForEach callback"`)
+		}
+		return startStream(context.(*helena_dialect.Scope), reader, args[2])
+
+	case "Header":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<reader> Header"`)
+		}
+		result, header := reader.readHeader()
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if header == nil {
+			return core.OK(core.NIL)
+		}
+		return core.OK(stringsToList(header))
+
+	case "Close":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<reader> Close"`)
+		}
+		reader.close()
+		return core.OK(core.NIL)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// Writing
+//
+// Records are lists of fields or dicts keyed by column name. Dict columns
+// are given by the `header` option, or else by the sorted keys of the first
+// dict record; the header record is written first unless `header` is false
+//
+
+type writerOptions struct {
+	comma    rune
+	useCRLF  bool
+	quoteAll bool
+	noHeader bool
+	header   []string
+}
+
+func valueToWriterOptions(value core.Value) (core.Result, writerOptions) {
+	options := writerOptions{}
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid options"), options
+	}
+	for key, value := range entries {
+		switch key {
+		case "comma":
+			result, comma := valueToRune(value, "comma")
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.comma = comma
+
+		case "useCRLF":
+			result, useCRLF := core.ValueToBoolean(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.useCRLF = useCRLF
+
+		case "quote":
+			result, quote := core.ValueToString(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid quote"), options
+			}
+			switch quote {
+			case "minimal":
+				options.quoteAll = false
+			case "all":
+				options.quoteAll = true
+			default:
+				return core.ERROR(`invalid quote "` + quote + `"`), options
+			}
+
+		case "header":
+			if value.Type() == core.ValueType_LIST || value.Type() == core.ValueType_TUPLE {
+				result, header := valueToStrings(value)
+				if result.Code != core.ResultCode_OK {
+					return core.ERROR("invalid header"), options
+				}
+				options.header = header
+				continue
+			}
+			result, useHeader := core.ValueToBoolean(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid header"), options
+			}
+			options.noHeader = !useHeader
+
+		default:
+			return core.ERROR(`unknown option "` + key + `"`), options
+		}
+	}
+	return core.OK(core.NIL), options
+}
+
+func valueToRecords(value core.Value, options writerOptions) (core.Result, [][]string) {
+	result, values := helena_dialect.ValueToArray(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid records"), nil
+	}
+	header := options.header
+	records := make([][]string, 0, len(values)+1)
+	for _, value := range values {
+		if value.Type() != core.ValueType_DICTIONARY {
+			result, record := valueToStrings(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid record"), nil
+			}
+			records = append(records, record)
+			continue
+		}
+		entries := value.(core.DictionaryValue).Map
+		if header == nil {
+			header = make([]string, 0, len(entries))
+			for key := range entries {
+				header = append(header, key)
+			}
+			slices.Sort(header)
+		}
+		record := make([]string, len(header))
+		for i, name := range header {
+			entry, ok := entries[name]
+			if !ok {
+				continue
+			}
+			result, s := core.ValueToString(entry)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid record"), nil
+			}
+			record[i] = s
+		}
+		records = append(records, record)
+	}
+	if header != nil && !options.noHeader {
+		records = slices.Insert(records, 0, header)
+	}
+	return core.OK(core.NIL), records
+}
+
+func writeRecords(w io.Writer, records [][]string, options writerOptions) error {
+	writer := csv.NewWriter(w)
+	if options.comma != 0 {
+		writer.Comma = options.comma
+	}
+	writer.UseCRLF = options.useCRLF
+	if !options.quoteAll {
+		return writer.WriteAll(records)
+	}
+
+	// Let a Go writer validate the delimiter before quoting fields ourselves
+	check := csv.NewWriter(io.Discard)
+	check.Comma = writer.Comma
+	if err := check.Write(nil); err != nil {
+		return err
+	}
+	eol := "\n"
+	if options.useCRLF {
+		eol = "\r\n"
+	}
+	var line strings.Builder
+	for _, record := range records {
+		line.Reset()
+		for i, field := range record {
+			if i > 0 {
+				line.WriteRune(writer.Comma)
+			}
+			if options.useCRLF {
+				field = strings.ReplaceAll(field, "\r\n", "\n")
+				field = strings.ReplaceAll(field, "\n", "\r\n")
+			}
+			line.WriteString(`"` + strings.ReplaceAll(field, `"`, `""`) + `"`)
+		}
+		line.WriteString(eol)
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type CsvCmd struct{}
+
+func (CsvCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "csv method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/encoding/csv
+	switch method {
+	case "ReadAll", "NewReader":
+		// https://pkg.go.dev/encoding/csv#Reader.ReadAll
+		// https://pkg.go.dev/encoding/csv#NewReader
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "csv ` + method + ` data ?options?"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := readerOptions{}
+		if len(args) == 4 {
+			result, options = valueToReaderOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		reader := newReader(strings.NewReader(data), options)
+		if method == "ReadAll" {
+			return reader.readAll()
+		}
+		return core.OK(ReaderValue{reader})
+
+	case "ReadFile", "Open":
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "csv ` + method + ` name ?options?"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := readerOptions{}
+		if len(args) == 4 {
+			result, options = valueToReaderOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		result, reader := openReader(name, options)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if method == "ReadFile" {
+			return reader.readAll()
+		}
+		return core.OK(ReaderValue{reader})
+
+	case "ForEach":
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "csv ForEach data ?options? callback"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := readerOptions{}
+		if len(args) == 5 {
+			result, options = valueToReaderOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		reader := newReader(strings.NewReader(data), options)
+		return startStream(context.(*helena_dialect.Scope), reader, args[len(args)-1])
+
+	case "WriteAll":
+		// https://pkg.go.dev/encoding/csv#Writer.WriteAll
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "csv WriteAll records ?options?"`)
+		}
+		options := writerOptions{}
+		if len(args) == 4 {
+			var result core.Result
+			result, options = valueToWriterOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		result, records := valueToRecords(args[2], options)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		var buffer bytes.Buffer
+		if err := writeRecords(&buffer, records, options); err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.STR(buffer.String()))
+
+	case "WriteFile":
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "csv WriteFile name records ?options?"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := writerOptions{}
+		if len(args) == 5 {
+			result, options = valueToWriterOptions(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		result, records := valueToRecords(args[3], options)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		err = writeRecords(file, records, options)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.NIL)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
@@ -0,0 +1,324 @@
+package go_csv_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_csv"
+)
+
+var _ = Describe("Go CSV", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("csv", go_csv.CsvCmd{})
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	record := func(fields ...string) core.Value {
+		values := make([]core.Value, len(fields))
+		for i, field := range fields {
+			values[i] = STR(field)
+		}
+		return LIST(values)
+	}
+	row := func(keysAndValues ...string) core.Value {
+		entries := map[string]core.Value{}
+		for i := 0; i < len(keysAndValues); i += 2 {
+			entries[keysAndValues[i]] = STR(keysAndValues[i+1])
+		}
+		return DICT(entries)
+	}
+
+	Describe("ReadAll", func() {
+		It("should return lists of fields", func() {
+			rootScope.SetNamedVariable("data", STR("a,b,c\n1,\"x, \"\"y\"\"\",3\n"))
+			Expect(evaluate("csv ReadAll $data")).To(Equal(LIST([]core.Value{
+				record("a", "b", "c"),
+				record("1", `x, "y"`, "3"),
+			})))
+			Expect(evaluate(`csv ReadAll ""`)).To(Equal(LIST([]core.Value{})))
+		})
+		It("should return dicts keyed by header", func() {
+			rootScope.SetNamedVariable("data", STR("name,age\nalice,30\nbob,25\n"))
+			Expect(evaluate("csv ReadAll $data (header true)")).To(Equal(LIST([]core.Value{
+				row("name", "alice", "age", "30"),
+				row("name", "bob", "age", "25"),
+			})))
+			Expect(evaluate("csv ReadAll $data (header (n a))")).To(Equal(LIST([]core.Value{
+				row("n", "name", "a", "age"),
+				row("n", "alice", "a", "30"),
+				row("n", "bob", "a", "25"),
+			})))
+		})
+		It("should accept reader options", func() {
+			rootScope.SetNamedVariable("data", STR("# comment\na; b\nc;d;e\n"))
+			Expect(evaluate(`csv ReadAll $data (comma ";" comment "#" trimLeadingSpace true fieldsPerRecord -1)`)).To(Equal(
+				LIST([]core.Value{record("a", "b"), record("c", "d", "e")}),
+			))
+			rootScope.SetNamedVariable("data", STR("a \"b\" c\n"))
+			Expect(evaluate("csv ReadAll $data (lazyQuotes true)")).To(Equal(
+				LIST([]core.Value{record(`a "b" c`)}),
+			))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("csv ReadAll")).To(Equal(
+					ERROR(`wrong # args: should be "csv ReadAll data ?options?"`),
+				))
+			})
+			Specify("parse errors", func() {
+				rootScope.SetNamedVariable("data", STR("a,b\nc\n"))
+				Expect(execute("csv ReadAll $data")).To(Equal(
+					ERROR("record on line 2: wrong number of fields"),
+				))
+				Expect(execute("csv ReadAll $data (header (x y z))")).To(Equal(
+					ERROR("record on line 1: wrong number of fields"),
+				))
+				rootScope.SetNamedVariable("data", STR("a \"b\" c\n"))
+				Expect(execute("csv ReadAll $data")).To(Equal(
+					ERROR(`parse error on line 1, column 3: bare " in non-quoted-field`),
+				))
+			})
+			Specify("invalid options", func() {
+				Expect(execute("csv ReadAll a (comma)")).To(Equal(ERROR("invalid options")))
+				Expect(execute("csv ReadAll a (unknown 1)")).To(Equal(
+					ERROR(`unknown option "unknown"`),
+				))
+				Expect(execute("csv ReadAll a (comma ab)")).To(Equal(ERROR("invalid comma")))
+				Expect(execute("csv ReadAll a (header maybe)")).To(Equal(ERROR("invalid header")))
+				Expect(execute("csv ReadAll a (fieldsPerRecord x)")).To(Equal(
+					ERROR("invalid fieldsPerRecord"),
+				))
+				Expect(execute("csv ReadAll a (comma \\n)")).To(Equal(
+					ERROR("csv: invalid field or comment delimiter"),
+				))
+			})
+		})
+	})
+
+	Describe("NewReader", func() {
+		Specify("type should be custom", func() {
+			value := evaluate("csv NewReader a")
+			Expect(core.IsCustomValue(value, go_csv.ReaderValueType)).To(BeTrue())
+			Expect(value.(go_csv.ReaderValue).Display(nil)).To(Equal(`{#{Reader}#}`))
+		})
+		It("should read records one at a time", func() {
+			rootScope.SetNamedVariable("data", STR("a,b\n1,2\n3,4\n"))
+			evaluate("set r [csv NewReader $data (header true)]")
+			Expect(evaluate("$r Header")).To(Equal(record("a", "b")))
+			Expect(evaluate("$r Read")).To(Equal(row("a", "1", "b", "2")))
+			Expect(evaluate("$r ReadAll")).To(Equal(LIST([]core.Value{row("a", "3", "b", "4")})))
+			Expect(evaluate("$r Read")).To(Equal(NIL))
+		})
+		It("should return nil header for empty data", func() {
+			evaluate(`set r [csv NewReader "" (header true)]`)
+			Expect(evaluate("$r Header")).To(Equal(NIL))
+			Expect(evaluate("$r Read")).To(Equal(NIL))
+		})
+		Describe("ForEach", func() {
+			It("should call back on each record", func() {
+				rootScope.SetNamedVariable("data", STR("a,1\nb,2\n"))
+				evaluate("set r [csv NewReader $data]")
+				evaluate("set l [list ()]")
+				evaluate("macro collect {record} {set l [list $l append ($record)]}")
+				Expect(execute("$r ForEach collect")).To(Equal(OK(INT(2))))
+				Expect(evaluate("get l")).To(Equal(
+					LIST([]core.Value{record("a", "1"), record("b", "2")}),
+				))
+			})
+			It("should stop on break", func() {
+				rootScope.SetNamedVariable("data", STR("a\nb\nc\n"))
+				evaluate("set r [csv NewReader $data]")
+				evaluate("macro stop {record} {if [string [list $record at 0] == b] {break}}")
+				Expect(execute("$r ForEach stop")).To(Equal(OK(INT(1))))
+			})
+			It("should stream records from coroutines", func() {
+				rootScope.SetNamedVariable("data", STR("x,y\n1,2\n3,4\n"))
+				evaluate("set r [csv NewReader $data (header true)]")
+				evaluate("set c [coroutine {$r ForEach yield}]")
+				Expect(evaluate("$c wait")).To(Equal(row("x", "1", "y", "2")))
+				Expect(evaluate("$c yield")).To(Equal(row("x", "3", "y", "4")))
+				Expect(evaluate("$c yield")).To(Equal(INT(2)))
+				Expect(evaluate("$c done")).To(Equal(TRUE))
+			})
+			It("should propagate errors", func() {
+				rootScope.SetNamedVariable("data", STR("a\nb,c\n"))
+				evaluate("set r [csv NewReader $data]")
+				Expect(execute("$r ForEach idem")).To(Equal(
+					ERROR("record on line 2: wrong number of fields"),
+				))
+				evaluate("macro fail {record} {error failed}")
+				Expect(execute("csv ForEach a fail")).To(Equal(ERROR("failed")))
+			})
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				evaluate("set r [csv NewReader a]")
+				Expect(execute("$r Read 1")).To(Equal(
+					ERROR(`wrong # args: should be "<reader> Read"`),
+				))
+				Expect(execute("$r ForEach")).To(Equal(
+					ERROR(`wrong # args: should be "<reader> ForEach callback"`),
+				))
+			})
+			Specify("unsupported methods", func() {
+				evaluate("set r [csv NewReader a]")
+				Expect(execute("$r Unknown")).To(Equal(ERROR("unsupported method Unknown")))
+				Expect(execute("$r []")).To(Equal(ERROR("invalid method name")))
+			})
+		})
+	})
+
+	Describe("ForEach", func() {
+		It("should stream records from data", func() {
+			rootScope.SetNamedVariable("data", STR("a;1\nb;2\n"))
+			evaluate(`set c [coroutine {csv ForEach $data (comma ";") yield}]`)
+			Expect(evaluate("$c wait")).To(Equal(record("a", "1")))
+			Expect(evaluate("$c yield")).To(Equal(record("b", "2")))
+			Expect(evaluate("$c yield")).To(Equal(INT(2)))
+		})
+		Specify("wrong arity", func() {
+			Expect(execute("csv ForEach a")).To(Equal(
+				ERROR(`wrong # args: should be "csv ForEach data ?options? callback"`),
+			))
+		})
+	})
+
+	Describe("WriteAll", func() {
+		It("should write lists of fields", func() {
+			Expect(evaluate(`csv WriteAll ((a b c) (1 "x, \"y\"" 3))`)).To(Equal(
+				STR("a,b,c\n1,\"x, \"\"y\"\"\",3\n"),
+			))
+			Expect(evaluate(`csv WriteAll ()`)).To(Equal(STR("")))
+		})
+		It("should write dicts with a header", func() {
+			evaluate("set records [list ([dict (name alice age 30)] [dict (name bob)])]")
+			Expect(evaluate("csv WriteAll $records")).To(Equal(
+				STR("age,name\n30,alice\n,bob\n"),
+			))
+			Expect(evaluate("csv WriteAll $records (header (name age))")).To(Equal(
+				STR("name,age\nalice,30\nbob,\n"),
+			))
+			Expect(evaluate("csv WriteAll $records (header false)")).To(Equal(
+				STR("30,alice\n,bob\n"),
+			))
+		})
+		It("should write a header before lists", func() {
+			Expect(evaluate("csv WriteAll ((1 2)) (header (a b))")).To(Equal(STR("a,b\n1,2\n")))
+		})
+		It("should accept writer options", func() {
+			Expect(evaluate(`csv WriteAll ((a b) (c "d e")) (comma ";" useCRLF true)`)).To(Equal(
+				STR("a;b\r\nc;d e\r\n"),
+			))
+			Expect(evaluate(`csv WriteAll ((a "b\"c")) (quote all)`)).To(Equal(
+				STR("\"a\",\"b\"\"c\"\n"),
+			))
+			Expect(evaluate(`csv WriteAll ((a b)) (quote minimal)`)).To(Equal(STR("a,b\n")))
+		})
+		It("should round-trip with ReadAll", func() {
+			rootScope.SetNamedVariable("data", STR("a,\"b\nc\",\" d\"\n"))
+			Expect(evaluate("csv WriteAll [csv ReadAll $data]")).To(Equal(
+				STR("a,\"b\nc\",\" d\"\n"),
+			))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("csv WriteAll")).To(Equal(
+					ERROR(`wrong # args: should be "csv WriteAll records ?options?"`),
+				))
+			})
+			Specify("invalid records", func() {
+				Expect(execute("csv WriteAll []")).To(Equal(ERROR("invalid records")))
+				Expect(execute("csv WriteAll ([])")).To(Equal(ERROR("invalid record")))
+			})
+			Specify("invalid options", func() {
+				Expect(execute("csv WriteAll () (quote some)")).To(Equal(
+					ERROR(`invalid quote "some"`),
+				))
+				Expect(execute("csv WriteAll () (unknown 1)")).To(Equal(
+					ERROR(`unknown option "unknown"`),
+				))
+				Expect(execute(`csv WriteAll ((a)) (comma "\"" quote all)`)).To(Equal(
+					ERROR("csv: invalid field or comment delimiter"),
+				))
+			})
+		})
+	})
+
+	Describe("files", func() {
+		var dir string
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			rootScope.SetNamedVariable("path", STR(filepath.Join(dir, "data.csv")))
+		})
+
+		It("should write and read files", func() {
+			Expect(execute("csv WriteFile $path ([dict (id 1 name a)] [dict (id 2 name b)])")).To(Equal(OK(NIL)))
+			data, _ := os.ReadFile(filepath.Join(dir, "data.csv"))
+			Expect(string(data)).To(Equal("id,name\n1,a\n2,b\n"))
+			Expect(evaluate("csv ReadFile $path (header true)")).To(Equal(LIST([]core.Value{
+				row("id", "1", "name", "a"),
+				row("id", "2", "name", "b"),
+			})))
+		})
+		It("should stream opened files", func() {
+			os.WriteFile(filepath.Join(dir, "data.csv"), []byte("a\nb\nc\n"), 0o644)
+			evaluate("set r [csv Open $path]")
+			evaluate("set c [coroutine {$r ForEach yield}]")
+			Expect(evaluate("$c wait")).To(Equal(record("a")))
+			Expect(evaluate("$c yield")).To(Equal(record("b")))
+			Expect(evaluate("$c yield")).To(Equal(record("c")))
+			Expect(evaluate("$c yield")).To(Equal(INT(3)))
+			Expect(execute("$r Close")).To(Equal(OK(NIL)))
+		})
+		Specify("unknown files", func() {
+			result := execute("csv ReadFile missing.csv")
+			Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+			result = execute(`csv Open ""`)
+			Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+		})
+		Specify("wrong arity", func() {
+			Expect(execute("csv WriteFile $path")).To(Equal(
+				ERROR(`wrong # args: should be "csv WriteFile name records ?options?"`),
+			))
+			Expect(execute("csv Open")).To(Equal(
+				ERROR(`wrong # args: should be "csv Open name ?options?"`),
+			))
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("csv")).To(Equal(
+				ERROR(`wrong # args: should be "csv method ?arg ...?"`),
+			))
+		})
+		Specify("unsupported methods", func() {
+			Expect(execute("csv Unknown")).To(Equal(ERROR("unsupported method Unknown")))
+			Expect(execute("csv []")).To(Equal(ERROR("invalid method name")))
+		})
+	})
+})
@@ -0,0 +1,331 @@
+package go_exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"helena/core"
+	"helena/helena_dialect"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+// Delay before closing pipes of killed commands, see exec.Cmd.WaitDelay
+const WAIT_DELAY = 100 * time.Millisecond
+
+// Maximum size of streamed lines
+const MAX_LINE_SIZE = 1024 * 1024
+
+// Command execution options
+//
+// Options are given as a dict with the following keys, all optional:
+//
+//   - `dir`: working directory
+//   - `env`: dict of environment variables added to the current environment
+//   - `stdin`: string written to the command standard input
+//   - `timeout`: delay in milliseconds after which the command is killed
+type execOptions struct {
+	dir     string
+	env     []string
+	stdin   *string
+	timeout time.Duration
+}
+
+func valueToOptions(value core.Value) (core.Result, execOptions) {
+	options := execOptions{}
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid options"), options
+	}
+	for key, value := range entries {
+		switch key {
+		case "dir":
+			result, dir := core.ValueToString(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.dir = dir
+
+		case "env":
+			result, env := helena_dialect.ValueToMap(value)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid env"), options
+			}
+			options.env = os.Environ()
+			for name, value := range env {
+				result, s := core.ValueToString(value)
+				if result.Code != core.ResultCode_OK {
+					return result, options
+				}
+				options.env = append(options.env, name+"="+s)
+			}
+
+		case "stdin":
+			result, stdin := core.ValueToString(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.stdin = &stdin
+
+		case "timeout":
+			result, ms := core.ValueToInteger(value)
+			if result.Code != core.ResultCode_OK || ms < 0 {
+				return core.ERROR("invalid timeout"), options
+			}
+			options.timeout = time.Duration(ms) * time.Millisecond
+
+		default:
+			return core.ERROR(`unknown option "` + key + `"`), options
+		}
+	}
+	return core.OK(core.NIL), options
+}
+
+// Running command
+type execution struct {
+	cmd      *exec.Cmd
+	ctx      context.Context
+	cancel   context.CancelFunc
+	timeout  time.Duration
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	canceled bool
+}
+
+// Prepare a command from its command line value
+func newExecution(value core.Value, options execOptions) (core.Result, *execution) {
+	result, values := helena_dialect.ValueToArray(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	if len(values) == 0 {
+		return core.ERROR("empty command"), nil
+	}
+	cmdline := make([]string, len(values))
+	for i, value := range values {
+		result, s := core.ValueToString(value)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		cmdline[i] = s
+	}
+
+	execution := &execution{timeout: options.timeout}
+	if options.timeout > 0 {
+		execution.ctx, execution.cancel = context.WithTimeout(context.Background(), options.timeout)
+	} else {
+		execution.ctx, execution.cancel = context.WithCancel(context.Background())
+	}
+	cmd := exec.CommandContext(execution.ctx, cmdline[0], cmdline[1:]...)
+	cmd.Dir = options.dir
+	cmd.Env = options.env
+	if options.stdin != nil {
+		cmd.Stdin = strings.NewReader(*options.stdin)
+	}
+	cmd.Stdout = &execution.stdout
+	cmd.Stderr = &execution.stderr
+	cmd.WaitDelay = WAIT_DELAY
+	execution.cmd = cmd
+	return core.OK(core.NIL), execution
+}
+
+// Stop a running command early
+func (execution *execution) kill() {
+	execution.canceled = true
+	execution.cancel()
+}
+
+// Convert the command completion to a result
+//
+// Non-zero exit codes are reported in the result dict; only commands that
+// cannot run or time out are errors
+func (execution *execution) result(err error, keys ...string) core.Result {
+	defer execution.cancel()
+	code := 0
+	if err != nil {
+		var exitError *exec.ExitError
+		switch {
+		case errors.Is(execution.ctx.Err(), context.DeadlineExceeded):
+			return core.ERROR("command timed out after " + execution.timeout.String())
+		case errors.As(err, &exitError):
+			// Also -1 for commands killed by a signal
+			code = exitError.ExitCode()
+		case execution.canceled && execution.cmd.ProcessState != nil:
+			code = execution.cmd.ProcessState.ExitCode()
+		default:
+			return core.ERROR(err.Error())
+		}
+	}
+	values := map[string]core.Value{
+		"stdout": core.STR(execution.stdout.String()),
+		"stderr": core.STR(execution.stderr.String()),
+		"code":   core.INT(int64(code)),
+	}
+	entries := map[string]core.Value{}
+	for _, key := range keys {
+		entries[key] = values[key]
+	}
+	return core.OK(core.DICT(entries))
+}
+
+//
+// Streaming
+//
+// Streamed commands read their output line by line and call the callback
+// with each line as a continuation, so that callbacks can yield in turn,
+// e.g. from coroutines
+//
+
+type streamState struct {
+	scope     *helena_dialect.Scope
+	callback  core.Value
+	execution *execution
+	scanner   *bufio.Scanner
+}
+
+func startStream(
+	scope *helena_dialect.Scope,
+	execution *execution,
+	callback core.Value,
+) core.Result {
+	cmd := execution.cmd
+	cmd.Stdout = nil
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return core.ERROR(err.Error())
+	}
+	if err := cmd.Start(); err != nil {
+		execution.cancel()
+		return core.ERROR(err.Error())
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(nil, MAX_LINE_SIZE)
+	return nextLine(&streamState{scope, callback, execution, scanner})
+}
+
+func nextLine(state *streamState) core.Result {
+	if !state.scanner.Scan() {
+		if err := state.scanner.Err(); err != nil {
+			// The command may be blocked writing the rest of its output
+			state.execution.kill()
+			finishStream(state)
+			return core.ERROR(err.Error())
+		}
+		return finishStream(state)
+	}
+	program := state.scope.CompileArgs([]core.Value{
+		state.callback,
+		core.STR(state.scanner.Text()),
+	})
+	return helena_dialect.CreateContinuationValueWithCallback(
+		state.scope,
+		program,
+		state,
+		func(result core.Result, data any) core.Result {
+			state := data.(*streamState)
+			switch result.Code {
+			case core.ResultCode_OK:
+				return nextLine(state)
+			case core.ResultCode_BREAK:
+				state.execution.kill()
+				return finishStream(state)
+			default:
+				state.execution.kill()
+				finishStream(state)
+				return result
+			}
+		},
+	)
+}
+
+func finishStream(state *streamState) core.Result {
+	// Output is either fully read or discarded by killing the command
+	err := state.execution.cmd.Wait()
+	return state.execution.result(err, "stderr", "code")
+}
+
+type ExecCmd struct{}
+
+func (ExecCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "exec method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/os/exec
+	switch method {
+	case "Run":
+		// https://pkg.go.dev/os/exec#Cmd.Run
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "exec Run command ?options?"`)
+		}
+		options := execOptions{}
+		if len(args) == 4 {
+			var result core.Result
+			result, options = valueToOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		result, execution := newExecution(args[2], options)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return helena_dialect.CreatePendingResult(func() core.Result {
+			return execution.result(execution.cmd.Run(), "stdout", "stderr", "code")
+		})
+
+	case "Stream":
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "exec Stream command callback ?options?"`)
+		}
+		options := execOptions{}
+		if len(args) == 5 {
+			var result core.Result
+			result, options = valueToOptions(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		result, execution := newExecution(args[2], options)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return startStream(context.(*helena_dialect.Scope), execution, args[3])
+
+	case "LookPath":
+		// https://pkg.go.dev/os/exec#LookPath
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "exec LookPath file"`)
+		}
+		result, file := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		path, err := exec.LookPath(file)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.STR(path))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+func (ExecCmd) Resume(result core.Result, _ any) core.Result {
+	return helena_dialect.ResumePendingResult(result)
+}
@@ -0,0 +1,29 @@
+package go_exec_test
+
+import (
+	"helena/core"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGoExec(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Go Exec Suite")
+}
+
+//
+// Helpers
+//
+
+var NIL = core.NIL
+var TRUE = core.TRUE
+var FALSE = core.FALSE
+var INT = core.INT
+var STR = core.STR
+var LIST = core.LIST
+var DICT = core.DICT
+
+var OK = core.OK
+var ERROR = core.ERROR
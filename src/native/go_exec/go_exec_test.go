@@ -0,0 +1,198 @@
+package go_exec_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_exec"
+)
+
+var _ = Describe("Go exec", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).RunAndWait()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("exec", go_exec.ExecCmd{})
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("`Run`", func() {
+		It("should capture the command output", func() {
+			Expect(execute("exec Run (echo hello world)")).To(Equal(OK(DICT(map[string]core.Value{
+				"stdout": STR("hello world\n"),
+				"stderr": STR(""),
+				"code":   INT(0),
+			}))))
+		})
+		It("should capture the standard error and exit code", func() {
+			Expect(execute(`exec Run (/bin/sh -c "echo out; echo err >&2; exit 3")`)).To(Equal(
+				OK(DICT(map[string]core.Value{
+					"stdout": STR("out\n"),
+					"stderr": STR("err\n"),
+					"code":   INT(3),
+				})),
+			))
+		})
+		It("should yield a pending result", func() {
+			process := rootScope.PrepareProcess(rootScope.Compile(*parse("exec Run (echo a)")))
+			result := process.Run()
+			Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+			Expect(result.Value).To(BeAssignableToTypeOf(&helena_dialect.PendingValue{}))
+		})
+		It("should run in the event loop without blocking other tasks", func() {
+			loop := helena_dialect.NewEventLoop(nil)
+			loop.RegisterCommands(rootScope)
+			evaluate("set log [list ()]")
+			task := loop.Spawn(rootScope, rootScope.Compile(*parse(
+				`set r [exec Run (/bin/sh -c "sleep 0.2; echo done")]; set log [list $log append ([dict $r get stdout])]`,
+			)))
+			loop.Spawn(rootScope, rootScope.Compile(*parse("set log [list $log append (other)]")))
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(task.Done()).To(BeTrue())
+			Expect(evaluate("get log")).To(Equal(LIST([]core.Value{STR("other"), STR("done\n")})))
+		})
+
+		Describe("Options", func() {
+			Specify("`stdin`", func() {
+				Expect(evaluate("dict [exec Run (cat) (stdin abc)] get stdout")).To(Equal(STR("abc")))
+			})
+			Specify("`dir`", func() {
+				dir := GinkgoT().TempDir()
+				resolved, _ := filepath.EvalSymlinks(dir)
+				rootScope.SetNamedVariable("dir", STR(dir))
+				Expect(evaluate("dict [exec Run (pwd -P) [dict (dir $dir)]] get stdout")).To(Equal(STR(resolved + "\n")))
+			})
+			Specify("`env`", func() {
+				GinkgoT().Setenv("HELENA_GO_EXEC_INHERITED", "inherited")
+				Expect(evaluate(`dict [exec Run (/bin/sh -c {echo $HELENA_GO_EXEC_TEST $HELENA_GO_EXEC_INHERITED}) (env (HELENA_GO_EXEC_TEST value))] get stdout`)).To(
+					Equal(STR("value inherited\n")),
+				)
+			})
+			Specify("`timeout`", func() {
+				start := time.Now()
+				Expect(execute("exec Run (sleep 10) (timeout 100)")).To(Equal(ERROR("command timed out after 100ms")))
+				Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+				Expect(evaluate("dict [exec Run (echo a) (timeout 5000)] get stdout")).To(Equal(STR("a\n")))
+			})
+		})
+	})
+
+	Describe("`Stream`", func() {
+		It("should call the callback with each output line", func() {
+			evaluate("set lines [list ()]")
+			evaluate("macro collect {line} {set lines [list $lines append ($line)]}")
+			Expect(execute(`exec Stream (/bin/sh -c "echo a; echo b; echo c >&2; exit 2") collect`)).To(Equal(
+				OK(DICT(map[string]core.Value{
+					"stderr": STR("c\n"),
+					"code":   INT(2),
+				})),
+			))
+			Expect(evaluate("get lines")).To(Equal(LIST([]core.Value{STR("a"), STR("b")})))
+		})
+		It("should accept callbacks with arguments", func() {
+			evaluate("set lines [list ()]")
+			evaluate("macro collect {prefix line} {set lines [list $lines append ($prefix$line)]}")
+			evaluate(`exec Stream (/bin/sh -c "echo a; echo b") (collect -)`)
+			Expect(evaluate("get lines")).To(Equal(LIST([]core.Value{STR("-a"), STR("-b")})))
+		})
+		It("should stream lines from coroutines", func() {
+			evaluate(`set c [coroutine {exec Stream (/bin/sh -c "echo a; echo b") yield}]`)
+			Expect(evaluate("$c wait")).To(Equal(STR("a")))
+			Expect(evaluate("$c wait")).To(Equal(STR("b")))
+			Expect(evaluate("dict [$c wait] get code")).To(Equal(INT(0)))
+			Expect(evaluate("$c done")).To(Equal(TRUE))
+		})
+		It("should stop the command on break", func() {
+			evaluate("set lines [list ()]")
+			evaluate("macro first {line} {set lines [list $lines append ($line)]; break}")
+			Expect(evaluate(`dict [exec Stream (/bin/sh -c "echo a; exec sleep 10") first] get code`)).To(Equal(INT(-1)))
+			Expect(evaluate("get lines")).To(Equal(LIST([]core.Value{STR("a")})))
+		})
+		It("should stop the command on error", func() {
+			evaluate("macro fail {line} {error $line}")
+			Expect(execute(`exec Stream (/bin/sh -c "echo oops; exec sleep 10") fail`)).To(Equal(ERROR("oops")))
+		})
+		It("should stop the command on read errors", func() {
+			Expect(execute(`exec Stream (head -c 2000000 /dev/zero) idem`)).To(Equal(
+				ERROR("bufio.Scanner: token too long"),
+			))
+		})
+		It("should accept options", func() {
+			evaluate("set lines [list ()]")
+			evaluate("macro collect {line} {set lines [list $lines append ($line)]}")
+			evaluate("exec Stream (cat) collect (stdin \"a\nb\")")
+			Expect(evaluate("get lines")).To(Equal(LIST([]core.Value{STR("a"), STR("b")})))
+			Expect(execute("exec Stream (sleep 10) collect (timeout 100)")).To(Equal(
+				ERROR("command timed out after 100ms"),
+			))
+		})
+	})
+
+	Specify("`LookPath`", func() {
+		Expect(execute("exec LookPath sh")).To(Equal(OK(STR(lookPath("sh")))))
+		Expect(execute("exec LookPath helena-go-exec-missing")).To(Equal(
+			ERROR(`exec: "helena-go-exec-missing": executable file not found in $PATH`),
+		))
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("exec")).To(Equal(ERROR(`wrong # args: should be "exec method ?arg ...?"`)))
+			Expect(execute("exec Run")).To(Equal(ERROR(`wrong # args: should be "exec Run command ?options?"`)))
+			Expect(execute("exec Stream (echo)")).To(Equal(ERROR(`wrong # args: should be "exec Stream command callback ?options?"`)))
+			Expect(execute("exec LookPath")).To(Equal(ERROR(`wrong # args: should be "exec LookPath file"`)))
+		})
+		Specify("empty command", func() {
+			Expect(execute("exec Run ()")).To(Equal(ERROR("empty command")))
+		})
+		Specify("missing executable", func() {
+			Expect(execute("exec Run (helena-go-exec-missing)")).To(Equal(
+				ERROR(`exec: "helena-go-exec-missing": executable file not found in $PATH`),
+			))
+			Expect(execute("exec Stream (helena-go-exec-missing) idem")).To(Equal(
+				ERROR(`exec: "helena-go-exec-missing": executable file not found in $PATH`),
+			))
+		})
+		Specify("invalid options", func() {
+			Expect(execute("exec Run (echo) (foo bar)")).To(Equal(ERROR(`unknown option "foo"`)))
+			Expect(execute("exec Run (echo) (timeout a)")).To(Equal(ERROR("invalid timeout")))
+			Expect(execute("exec Run (echo) (env a)")).To(Equal(ERROR("invalid env")))
+			Expect(execute("exec Run (echo) a")).To(Equal(ERROR("invalid options")))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("exec Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
+
+func lookPath(file string) string {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		path := filepath.Join(dir, file)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
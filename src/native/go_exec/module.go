@@ -0,0 +1,23 @@
+package go_exec
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+)
+
+/**
+ * Main static module entry point.
+ */
+func Initmodule() *helena_dialect.Module {
+	scope := helena_dialect.NewRootScope(nil)
+	exports := &helena_dialect.Exports{}
+	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Subprocess execution from the Go os/exec package")
+	exportCommand(module, "exec", ExecCmd{})
+	return module
+}
+
+func exportCommand(module *helena_dialect.Module, name string, cmd core.Command) {
+	module.Scope.RegisterNamedCommand(name, cmd)
+	(*module.Exports)[name] = core.STR(name)
+}
@@ -0,0 +1,582 @@
+package go_http
+
+import (
+	"errors"
+	"fmt"
+	"helena/core"
+	"helena/helena_dialect"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+// Client used for outgoing requests, can be replaced for testing
+var Client = http.DefaultClient
+
+func headersToValue(header http.Header) core.Value {
+	entries := make(map[string]core.Value, len(header))
+	for key, values := range header {
+		entries[key] = core.STR(strings.Join(values, ", "))
+	}
+	return core.DICT(entries)
+}
+
+func valueToHeaders(value core.Value, header http.Header) core.Result {
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid headers")
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result, s := core.ValueToString(entries[key])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		header.Set(key, s)
+	}
+	return core.OK(core.NIL)
+}
+
+//
+// Client
+//
+
+// Request options
+//
+// Options are given as a dict with the following keys, all optional:
+//
+//   - `headers`: dict of request headers
+//   - `body`: request body string
+//   - `timeout`: delay in milliseconds after which the request fails
+type requestOptions struct {
+	headers core.Value
+	body    *string
+	timeout time.Duration
+}
+
+func valueToOptions(value core.Value) (core.Result, requestOptions) {
+	options := requestOptions{}
+	result, entries := helena_dialect.ValueToMap(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid options"), options
+	}
+	for key, value := range entries {
+		switch key {
+		case "headers":
+			options.headers = value
+
+		case "body":
+			result, body := core.ValueToString(value)
+			if result.Code != core.ResultCode_OK {
+				return result, options
+			}
+			options.body = &body
+
+		case "timeout":
+			result, ms := core.ValueToInteger(value)
+			if result.Code != core.ResultCode_OK || ms < 0 {
+				return core.ERROR("invalid timeout"), options
+			}
+			options.timeout = time.Duration(ms) * time.Millisecond
+
+		default:
+			return core.ERROR(`unknown option "` + key + `"`), options
+		}
+	}
+	return core.OK(core.NIL), options
+}
+
+// Prepare a request and send it in the background
+//
+// The response is a dict with the following keys:
+//
+//   - `status`: status line, e.g. `200 OK`
+//   - `code`: status code
+//   - `headers`: dict of response headers, multiple values are comma-separated
+//   - `body`: response body string
+func doRequest(method string, url string, options requestOptions) core.Result {
+	var body io.Reader
+	if options.body != nil {
+		body = strings.NewReader(*options.body)
+	}
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return core.ERROR(err.Error())
+	}
+	if options.headers != nil {
+		result := valueToHeaders(options.headers, request.Header)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+	}
+	client := *Client
+	if options.timeout > 0 {
+		client.Timeout = options.timeout
+	}
+	return helena_dialect.CreatePendingResult(func() core.Result {
+		response, err := client.Do(request)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		defer response.Body.Close()
+		data, err := io.ReadAll(response.Body)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.DICT(map[string]core.Value{
+			"status":  core.STR(response.Status),
+			"code":    core.INT(int64(response.StatusCode)),
+			"headers": headersToValue(response.Header),
+			"body":    core.STR(string(data)),
+		}))
+	})
+}
+
+//
+// Server
+//
+// Requests are received on net/http goroutines and queued until a Helena
+// process serving the mux picks them up. Handlers are then called on the
+// serving process in a fresh child scope, so that Helena code never runs
+// concurrently
+//
+
+var ServeMuxValueType = core.CustomValueType{Name: "go:ServeMux"}
+
+type ServeMuxValue struct {
+	mux *serveMux
+}
+
+// Queued request waiting for its response
+type pendingRequest struct {
+	writer  http.ResponseWriter
+	request *http.Request
+	handler core.Value
+	params  []string
+	done    chan struct{}
+}
+
+func (*pendingRequest) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (*pendingRequest) CustomType() core.CustomValueType {
+	return core.CustomValueType{Name: "go:http.Request"}
+}
+
+type serveMux struct {
+	mux       *http.ServeMux
+	requests  chan *pendingRequest
+	closed    chan struct{}
+	closeOnce sync.Once
+	server    *http.Server
+}
+
+func NewServeMuxValue() ServeMuxValue {
+	return ServeMuxValue{&serveMux{
+		mux:      http.NewServeMux(),
+		requests: make(chan *pendingRequest),
+		closed:   make(chan struct{}),
+	}}
+}
+
+func (value ServeMuxValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value ServeMuxValue) CustomType() core.CustomValueType {
+	return ServeMuxValueType
+}
+func (value ServeMuxValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("ServeMux")
+}
+func (value ServeMuxValue) Command() core.Command {
+	return serveMuxCommand{value}
+}
+
+// Return the Go handler queuing requests for the serving processes
+func (value ServeMuxValue) Handler() http.Handler {
+	return value.mux.mux
+}
+
+// Stop serving processes and the server started by ListenAndServe if any
+func (value ServeMuxValue) Close() {
+	value.mux.close()
+}
+
+var wildcardRegexp = regexp.MustCompile(`\{([^}.$]+)(\.\.\.)?\}`)
+
+func (mux *serveMux) handle(pattern string, handler core.Value) (err error) {
+	params := []string{}
+	for _, match := range wildcardRegexp.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, match[1])
+	}
+	defer func() {
+		// Invalid or conflicting patterns panic
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	mux.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		request := &pendingRequest{w, r, handler, params, make(chan struct{})}
+		select {
+		case mux.requests <- request:
+			<-request.done
+		case <-mux.closed:
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	})
+	return nil
+}
+
+func (mux *serveMux) close() {
+	mux.closeOnce.Do(func() {
+		close(mux.closed)
+		if mux.server != nil {
+			mux.server.Close()
+		}
+	})
+}
+
+// State of serving processes waiting for the next request
+type serveState struct {
+	mux          *serveMux
+	serverErrors <-chan error
+	pending      *helena_dialect.PendingValue
+}
+
+// Yield a pending result for the next request, or nil once closed
+func (mux *serveMux) next(serverErrors <-chan error) core.Result {
+	pending := helena_dialect.StartPending(func() core.Result {
+		select {
+		case request := <-mux.requests:
+			return core.OK(request)
+		case <-mux.closed:
+			return core.OK(core.NIL)
+		case err := <-serverErrors:
+			mux.close()
+			return core.ERROR(err.Error())
+		}
+	})
+	return core.YIELD_STATE(pending, &serveState{mux, serverErrors, pending})
+}
+
+// Call the request handler in a fresh child scope and write its response
+func (mux *serveMux) serve(scope *helena_dialect.Scope, request *pendingRequest) {
+	defer close(request.done)
+	r := request.request
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(request.writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := map[string]core.Value{}
+	for key, values := range r.URL.Query() {
+		query[key] = core.STR(values[0])
+	}
+	params := map[string]core.Value{}
+	for _, name := range request.params {
+		params[name] = core.STR(r.PathValue(name))
+	}
+	value := core.DICT(map[string]core.Value{
+		"method":     core.STR(r.Method),
+		"path":       core.STR(r.URL.Path),
+		"query":      core.DICT(query),
+		"params":     core.DICT(params),
+		"headers":    headersToValue(r.Header),
+		"body":       core.STR(string(body)),
+		"host":       core.STR(r.Host),
+		"remoteAddr": core.STR(r.RemoteAddr),
+	})
+
+	child := scope.NewChildScope()
+	program := child.CompileArgs([]core.Value{request.handler, value})
+	result := child.PrepareProcess(program).RunAndWait()
+	if result.Code != core.ResultCode_OK && result.Code != core.ResultCode_RETURN {
+		http.Error(request.writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writeResponse(request.writer, result.Value)
+}
+
+// Write the handler result as a response
+//
+// Results are either a string body with status 200, or a dict with the
+// following keys, all optional:
+//
+//   - `status`: status code, 200 by default
+//   - `headers`: dict of response headers
+//   - `body`: response body string
+func writeResponse(w http.ResponseWriter, value core.Value) {
+	fail := func() {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+	if value.Type() != core.ValueType_DICTIONARY {
+		body, ok := asString(value)
+		if !ok && value != core.NIL {
+			fail()
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, body)
+		return
+	}
+
+	status := http.StatusOK
+	body := ""
+	for key, value := range value.(core.DictionaryValue).Map {
+		switch key {
+		case "status":
+			result, code := core.ValueToInteger(value)
+			if result.Code != core.ResultCode_OK || code < 100 || code > 999 {
+				fail()
+				return
+			}
+			status = int(code)
+		case "headers":
+			if result := valueToHeaders(value, w.Header()); result.Code != core.ResultCode_OK {
+				fail()
+				return
+			}
+		case "body":
+			s, ok := asString(value)
+			if !ok {
+				fail()
+				return
+			}
+			body = s
+		default:
+			fail()
+			return
+		}
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+type serveMuxCommand struct {
+	value ServeMuxValue
+}
+
+func (cmd serveMuxCommand) Execute(args []core.Value, context any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	mux := cmd.value.mux
+	switch method {
+	case "Handle":
+		// https://pkg.go.dev/net/http#ServeMux.Handle
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "<mux> Handle pattern handler"`)
+		}
+		result, pattern := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := mux.handle(pattern, args[3]); err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.NIL)
+
+	case "Serve":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<mux> Serve"`)
+		}
+		return mux.next(nil)
+
+	case "Close":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<mux> Close"`)
+		}
+		mux.close()
+		return core.OK(core.NIL)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+func (serveMuxCommand) Resume(result core.Result, context any) core.Result {
+	return resumeServing(result, context.(*helena_dialect.Scope))
+}
+
+// Serve the request the serving process was waiting for, then wait for the
+// next one
+func resumeServing(result core.Result, scope *helena_dialect.Scope) core.Result {
+	state := result.Data.(*serveState)
+	result = helena_dialect.ResumePendingResult(core.Result{
+		Code:  result.Code,
+		Value: result.Value,
+		Data:  state.pending,
+	})
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	request, ok := result.Value.(*pendingRequest)
+	if !ok {
+		// Closed
+		return core.OK(core.NIL)
+	}
+	state.mux.serve(scope, request)
+	return state.mux.next(state.serverErrors)
+}
+
+type HttpCmd struct{}
+
+func (HttpCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "http method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/net/http
+	switch method {
+	case "Get":
+		// https://pkg.go.dev/net/http#Get
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "http Get url ?options?"`)
+		}
+		result, url := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := requestOptions{}
+		if len(args) == 4 {
+			result, options = valueToOptions(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		return doRequest(http.MethodGet, url, options)
+
+	case "Post":
+		// https://pkg.go.dev/net/http#Post
+		if len(args) != 5 && len(args) != 6 {
+			return core.ERROR(`wrong # args: should be "http Post url contentType body ?options?"`)
+		}
+		result, url := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, contentType := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, body := core.ValueToString(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := requestOptions{}
+		if len(args) == 6 {
+			result, options = valueToOptions(args[5])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		headers := map[string]core.Value{"Content-Type": core.STR(contentType)}
+		if options.headers != nil {
+			result, entries := helena_dialect.ValueToMap(options.headers)
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid headers")
+			}
+			for key, value := range entries {
+				headers[key] = value
+			}
+		}
+		options.headers = core.DICT(headers)
+		options.body = &body
+		return doRequest(http.MethodPost, url, options)
+
+	case "Do":
+		// https://pkg.go.dev/net/http#Client.Do
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "http Do method url ?options?"`)
+		}
+		result, requestMethod := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, url := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		options := requestOptions{}
+		if len(args) == 5 {
+			result, options = valueToOptions(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		return doRequest(requestMethod, url, options)
+
+	case "NewServeMux":
+		// https://pkg.go.dev/net/http#NewServeMux
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "http NewServeMux"`)
+		}
+		return core.OK(NewServeMuxValue())
+
+	case "ListenAndServe":
+		// https://pkg.go.dev/net/http#ListenAndServe
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "http ListenAndServe addr mux"`)
+		}
+		result, addr := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		value, ok := args[3].(ServeMuxValue)
+		if !ok {
+			return core.ERROR("invalid mux value")
+		}
+		mux := value.mux
+		if mux.server != nil {
+			return core.ERROR("mux is already listening")
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		mux.server = &http.Server{Handler: mux.mux}
+		serverErrors := make(chan error, 1)
+		go func() {
+			if err := mux.server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				serverErrors <- err
+			}
+		}()
+		return mux.next(serverErrors)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+func (HttpCmd) Resume(result core.Result, context any) core.Result {
+	if _, ok := result.Data.(*serveState); ok {
+		return resumeServing(result, context.(*helena_dialect.Scope))
+	}
+	return helena_dialect.ResumePendingResult(result)
+}
@@ -0,0 +1,356 @@
+package go_http_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_http"
+)
+
+var _ = Describe("Go http", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *helena_dialect.Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).RunAndWait()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("http", go_http.HttpCmd{})
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("Client", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("X-Method", r.Method)
+				w.Header().Set("X-Token", r.Header.Get("X-Token"))
+				w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, string(body))
+			})
+			mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+				}
+			})
+			mux.HandleFunc("/missing", http.NotFound)
+			server = httptest.NewServer(mux)
+			DeferCleanup(server.Close)
+			rootScope.SetNamedVariable("url", STR(server.URL))
+		})
+
+		Describe("`Get`", func() {
+			It("should return the response as a dict", func() {
+				evaluate("set response [http Get ${url}/echo]")
+				Expect(evaluate("dict $response get status")).To(Equal(STR("200 OK")))
+				Expect(evaluate("dict $response get code")).To(Equal(INT(200)))
+				Expect(evaluate("dict $response get body")).To(Equal(STR("")))
+				Expect(evaluate("dict [dict $response get headers] get X-Method")).To(Equal(STR("GET")))
+				Expect(evaluate("dict [dict $response get headers] get Content-Type")).To(Equal(STR("text/plain")))
+			})
+			It("should send headers", func() {
+				evaluate("set response [http Get ${url}/echo [dict (headers [dict (x-token secret)])]]")
+				Expect(evaluate("dict [dict $response get headers] get X-Token")).To(Equal(STR("secret")))
+			})
+			It("should report error status codes", func() {
+				Expect(evaluate("dict [http Get ${url}/missing] get code")).To(Equal(INT(404)))
+			})
+			It("should yield a pending result", func() {
+				process := prepareScript("http Get ${url}/echo")
+				result := process.Run()
+				Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+				Expect(result.Value).To(BeAssignableToTypeOf(&helena_dialect.PendingValue{}))
+				process.YieldBack(result.Value.(helena_dialect.WaitableValue).Wait())
+				Expect(process.Run().Code).To(Equal(core.ResultCode_OK))
+			})
+		})
+		Describe("`Post`", func() {
+			It("should send the body with its content type", func() {
+				evaluate(`set response [http Post ${url}/echo application/json """{"a":1}"""]`)
+				Expect(evaluate("dict $response get body")).To(Equal(STR(`{"a":1}`)))
+				Expect(evaluate("dict [dict $response get headers] get X-Method")).To(Equal(STR("POST")))
+				Expect(evaluate("dict [dict $response get headers] get X-Content-Type")).To(Equal(STR("application/json")))
+			})
+			It("should accept options", func() {
+				evaluate("set response [http Post ${url}/echo text/plain hello [dict (headers [dict (X-Token t)])]]")
+				Expect(evaluate("dict [dict $response get headers] get X-Token")).To(Equal(STR("t")))
+			})
+		})
+		Describe("`Do`", func() {
+			It("should send requests with any method", func() {
+				evaluate("set response [http Do PUT ${url}/echo [dict (body data)]]")
+				Expect(evaluate("dict $response get body")).To(Equal(STR("data")))
+				Expect(evaluate("dict [dict $response get headers] get X-Method")).To(Equal(STR("PUT")))
+			})
+		})
+		Describe("Timeouts", func() {
+			It("should fail slow requests", func() {
+				result := execute("http Get ${url}/slow [dict (timeout 50)]")
+				Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+				Expect(asString(result.Value)).To(ContainSubstring("Client.Timeout exceeded"))
+			})
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("http Get")).To(Equal(ERROR(`wrong # args: should be "http Get url ?options?"`)))
+				Expect(execute("http Post a b")).To(Equal(
+					ERROR(`wrong # args: should be "http Post url contentType body ?options?"`),
+				))
+				Expect(execute("http Do GET")).To(Equal(ERROR(`wrong # args: should be "http Do method url ?options?"`)))
+			})
+			Specify("invalid options", func() {
+				Expect(execute("http Get $url a")).To(Equal(ERROR("invalid options")))
+				Expect(execute("http Get $url (foo bar)")).To(Equal(ERROR(`unknown option "foo"`)))
+				Expect(execute("http Get $url (timeout -1)")).To(Equal(ERROR("invalid timeout")))
+				Expect(execute("http Get $url (headers a)")).To(Equal(ERROR("invalid headers")))
+			})
+			Specify("invalid requests", func() {
+				Expect(execute(`http Do "BAD METHOD" $url`)).To(Equal(ERROR(`net/http: invalid method "BAD METHOD"`)))
+			})
+		})
+	})
+
+	Describe("Server", func() {
+		var mux go_http.ServeMuxValue
+		var server *httptest.Server
+
+		// Serve requests in the background until stopped
+		//
+		// Scripts must not be evaluated until then, as the serving process
+		// runs on another goroutine
+		serve := func() (stop func()) {
+			process := prepareScript("$mux Serve")
+			done := make(chan core.Result, 1)
+			go func() { done <- process.RunAndWait() }()
+			var once sync.Once
+			stop = func() {
+				once.Do(func() {
+					mux.Close()
+					Eventually(done).Should(Receive(Equal(OK(NIL))))
+				})
+			}
+			DeferCleanup(stop)
+			return stop
+		}
+		get := func(path string) (int, string, http.Header) {
+			response, err := http.Get(server.URL + path)
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			body, _ := io.ReadAll(response.Body)
+			return response.StatusCode, string(body), response.Header
+		}
+
+		BeforeEach(func() {
+			evaluate("set mux [http NewServeMux]")
+			mux = evaluate("get mux").(go_http.ServeMuxValue)
+			server = httptest.NewServer(mux.Handler())
+			DeferCleanup(server.Close)
+		})
+
+		Describe("go:ServeMuxValue", func() {
+			Specify("type should be custom", func() {
+				Expect(core.IsCustomValue(mux, go_http.ServeMuxValueType)).To(BeTrue())
+			})
+			Specify("display", func() {
+				Expect(mux.Display(nil)).To(Equal(`{#{ServeMux}#}`))
+			})
+		})
+
+		It("should route requests to handler commands", func() {
+			evaluate("proc hello {request} {idem hello}")
+			evaluate(`$mux Handle "GET /hello" hello`)
+			evaluate(`$mux Handle "/items/{id}" (dict)`)
+			serve()
+
+			code, body, header := get("/hello")
+			Expect(code).To(Equal(200))
+			Expect(body).To(Equal("hello"))
+			Expect(header.Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+
+			code, _, _ = get("/unknown")
+			Expect(code).To(Equal(404))
+		})
+		It("should pass requests as dicts", func() {
+			evaluate(`proc describe {request} {
+				set params [dict $request get params]
+				set query [dict $request get query]
+				idem "[dict $request get method] [dict $request get path] [dict $params get id] [dict $query get q] [dict $request get body]"
+			}`)
+			evaluate(`$mux Handle "/items/{id}" describe`)
+			serve()
+
+			response, err := http.Post(server.URL+"/items/42?q=search", "text/plain", strings.NewReader("payload"))
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			body, _ := io.ReadAll(response.Body)
+			Expect(string(body)).To(Equal("POST /items/42 42 search payload"))
+		})
+		It("should evaluate each request in a fresh child scope", func() {
+			// Constants cannot be redefined in the same scope
+			evaluate(`macro leaky {request} {
+				let leaked [dict $request get path]
+				idem "path $leaked"
+			}`)
+			evaluate(`$mux Handle "/leaky/{x}" leaky`)
+			stop := serve()
+
+			_, body, _ := get("/leaky/a")
+			Expect(body).To(Equal("path /leaky/a"))
+			_, body, _ = get("/leaky/b")
+			Expect(body).To(Equal("path /leaky/b"))
+			stop()
+			Expect(execute("get leaked")).To(Equal(ERROR(`cannot get "leaked": no such variable`)))
+		})
+		It("should accept dict responses", func() {
+			evaluate(`proc created {request} {
+				dict (status [+ 201 0] headers [dict (X-Id 1 Content-Type application/json)] body "{}")
+			}`)
+			evaluate(`$mux Handle "POST /items" created`)
+			serve()
+
+			response, err := http.Post(server.URL+"/items", "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			body, _ := io.ReadAll(response.Body)
+			Expect(response.StatusCode).To(Equal(201))
+			Expect(response.Header.Get("X-Id")).To(Equal("1"))
+			Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(string(body)).To(Equal("{}"))
+		})
+		It("should report handler errors as internal server errors", func() {
+			evaluate(`proc failing {request} {error "secret details"}`)
+			evaluate(`proc invalid {request} {dict (foo bar)}`)
+			evaluate(`$mux Handle "/failing" failing`)
+			evaluate(`$mux Handle "/invalid" invalid`)
+			serve()
+
+			code, body, _ := get("/failing")
+			Expect(code).To(Equal(500))
+			Expect(body).To(Equal("Internal Server Error\n"))
+			code, _, _ = get("/invalid")
+			Expect(code).To(Equal(500))
+		})
+		It("should serve Helena clients from event loops", func() {
+			loop := helena_dialect.NewEventLoop(nil)
+			loop.RegisterCommands(rootScope)
+			rootScope.SetNamedVariable("url", STR(server.URL))
+			evaluate("proc ping {request} {idem pong}")
+			evaluate(`$mux Handle "/ping" ping`)
+			evaluate(`spawn {$mux Serve}`)
+			evaluate(`spawn {
+				set response [http Get ${url}/ping]
+				$mux Close
+			}`)
+			Expect(loop.Run()).To(Equal(OK(NIL)))
+			Expect(evaluate("dict $response get body")).To(Equal(STR("pong")))
+		})
+		It("should reject requests once closed", func() {
+			evaluate("proc ok {request} {idem ok}")
+			evaluate(`$mux Handle "/" ok`)
+			evaluate("$mux Close")
+			code, _, _ := get("/")
+			Expect(code).To(Equal(503))
+			Expect(execute("$mux Serve")).To(Equal(OK(NIL)))
+		})
+
+		Describe("`ListenAndServe`", func() {
+			It("should listen on the given address", func() {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).NotTo(HaveOccurred())
+				addr := listener.Addr().String()
+				listener.Close()
+
+				rootScope.SetNamedVariable("addr", STR(addr))
+				evaluate("proc ok {request} {idem ok}")
+				evaluate(`$mux Handle "/" ok`)
+				process := prepareScript("http ListenAndServe $addr $mux")
+				done := make(chan core.Result, 1)
+				go func() { done <- process.RunAndWait() }()
+
+				var response *http.Response
+				Eventually(func() error {
+					response, err = http.Get("http://" + addr + "/")
+					return err
+				}).Should(Succeed())
+				body, _ := io.ReadAll(response.Body)
+				response.Body.Close()
+				Expect(string(body)).To(Equal("ok"))
+
+				mux.Close()
+				Eventually(done).Should(Receive(Equal(OK(NIL))))
+			})
+			Specify("listening errors", func() {
+				result := execute("http ListenAndServe foo $mux")
+				Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+				Expect(asString(result.Value)).To(ContainSubstring("missing port in address"))
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("$mux Handle a")).To(Equal(ERROR(`wrong # args: should be "<mux> Handle pattern handler"`)))
+				Expect(execute("$mux Serve a")).To(Equal(ERROR(`wrong # args: should be "<mux> Serve"`)))
+				Expect(execute("$mux Close a")).To(Equal(ERROR(`wrong # args: should be "<mux> Close"`)))
+				Expect(execute("http NewServeMux a")).To(Equal(ERROR(`wrong # args: should be "http NewServeMux"`)))
+				Expect(execute("http ListenAndServe a")).To(Equal(
+					ERROR(`wrong # args: should be "http ListenAndServe addr mux"`),
+				))
+			})
+			Specify("invalid patterns", func() {
+				Expect(execute(`$mux Handle "/{a" a`)).To(Equal(
+					ERROR(`parsing "/{a": at offset 1: bad wildcard segment (must end with '}')`),
+				))
+				evaluate(`$mux Handle "/a" a`)
+				result := execute(`$mux Handle "/a" a`)
+				Expect(result.Code).To(Equal(core.ResultCode_ERROR))
+				Expect(asString(result.Value)).To(ContainSubstring("conflicts with pattern"))
+			})
+			Specify("invalid mux value", func() {
+				Expect(execute("http ListenAndServe :0 a")).To(Equal(ERROR("invalid mux value")))
+			})
+			Specify("unsupported method", func() {
+				Expect(execute("$mux Foo")).To(Equal(ERROR("unsupported method Foo")))
+			})
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("http")).To(Equal(ERROR(`wrong # args: should be "http method ?arg ...?"`)))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("http Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
@@ -0,0 +1,363 @@
+package go_json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"helena/core"
+	"helena/helena_dialect"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+//
+// Decoding
+//
+// JSON objects become dicts, arrays become lists, and null becomes nil.
+// Integer numbers become integers when they fit in 64 bits, so that no
+// precision is lost, other numbers become reals
+//
+
+func numberToValue(number json.Number) core.Value {
+	s := number.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return core.INT(i)
+		}
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return core.REAL(f)
+}
+
+func decodedToValue(decoded any) core.Value {
+	switch v := decoded.(type) {
+	case nil:
+		return core.NIL
+	case bool:
+		return core.BOOL(v)
+	case json.Number:
+		return numberToValue(v)
+	case string:
+		return core.STR(v)
+	case []any:
+		values := make([]core.Value, len(v))
+		for i, item := range v {
+			values[i] = decodedToValue(item)
+		}
+		return core.LIST(values)
+	case map[string]any:
+		entries := make(map[string]core.Value, len(v))
+		for key, item := range v {
+			entries[key] = decodedToValue(item)
+		}
+		return core.DICT(entries)
+	}
+	panic("unreachable")
+}
+
+// Return the 1-based line and column of the character where the decoder
+// stopped, i.e. the last of the offset first bytes
+func position(data string, offset int64) (line int, column int) {
+	end := int(min(offset, int64(len(data))))
+	if end < 1 {
+		return 1, 1
+	}
+	before := data[:end-1]
+	line = 1 + strings.Count(before, "\n")
+	column = 1 + utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:])
+	return
+}
+
+// Convert a decoding error to a result with the error position in data
+func decodingError(data string, err error) core.Result {
+	message := err.Error()
+	offset := int64(len(data))
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		message = "unexpected end of JSON input"
+	}
+	return positionError(data, message, offset)
+}
+
+func positionError(data string, message string, offset int64) core.Result {
+	line, column := position(data, offset)
+	return core.ERROR(fmt.Sprintf("%s at line %d, column %d", message, line, column))
+}
+
+func newDecoder(data string) *json.Decoder {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	return decoder
+}
+
+func unmarshal(data string) core.Result {
+	// Validate first to report errors after the top-level value
+	var raw json.RawMessage
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return decodingError(data, err)
+	}
+	var decoded any
+	if err := newDecoder(data).Decode(&decoded); err != nil {
+		return decodingError(data, err)
+	}
+	return core.OK(decodedToValue(decoded))
+}
+
+//
+// Encoding
+//
+// Integers, reals, booleans and nil keep their JSON type, lists and tuples
+// become arrays, dicts become objects with sorted keys, and other values are
+// encoded as strings
+//
+
+func valueToEncoded(value core.Value) (core.Result, any) {
+	switch value.Type() {
+	case core.ValueType_NIL:
+		return core.OK(core.NIL), nil
+	case core.ValueType_BOOLEAN:
+		return core.OK(core.NIL), value.(core.BooleanValue).Value
+	case core.ValueType_INTEGER:
+		return core.OK(core.NIL), value.(core.IntegerValue).Value
+	case core.ValueType_REAL:
+		return core.OK(core.NIL), value.(core.RealValue).Value
+	case core.ValueType_LIST, core.ValueType_TUPLE:
+		_, values := helena_dialect.ValueToArray(value)
+		items := make([]any, len(values))
+		for i, value := range values {
+			result, item := valueToEncoded(value)
+			if result.Code != core.ResultCode_OK {
+				return result, nil
+			}
+			items[i] = item
+		}
+		return core.OK(core.NIL), items
+	case core.ValueType_DICTIONARY:
+		entries := value.(core.DictionaryValue).Map
+		object := make(map[string]any, len(entries))
+		for key, value := range entries {
+			result, item := valueToEncoded(value)
+			if result.Code != core.ResultCode_OK {
+				return result, nil
+			}
+			object[key] = item
+		}
+		return core.OK(core.NIL), object
+	}
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	return core.OK(core.NIL), s
+}
+
+// Encode a value, without escaping HTML characters unlike json.Marshal
+func marshal(value core.Value, prefix string, indent string) core.Result {
+	result, encoded := valueToEncoded(value)
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, indent)
+	if err := encoder.Encode(encoded); err != nil {
+		return core.ERROR(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return core.OK(core.STR(strings.TrimSuffix(buffer.String(), "\n")))
+}
+
+//
+// Streaming
+//
+// Streamed arrays are decoded element by element and each element is passed
+// to the callback as a continuation, so that callbacks can yield in turn,
+// e.g. from coroutines
+//
+
+type streamState struct {
+	scope    *helena_dialect.Scope
+	callback core.Value
+	data     string
+	decoder  *json.Decoder
+	count    int64
+}
+
+func startStream(scope *helena_dialect.Scope, data string, callback core.Value) core.Result {
+	decoder := newDecoder(data)
+	token, err := decoder.Token()
+	if err != nil {
+		return decodingError(data, err)
+	}
+	if token != json.Delim('[') {
+		return positionError(data, "expected JSON array", decoder.InputOffset())
+	}
+	return nextElement(&streamState{scope, callback, data, decoder, 0})
+}
+
+func nextElement(state *streamState) core.Result {
+	if !state.decoder.More() {
+		return finishStream(state)
+	}
+	var decoded any
+	if err := state.decoder.Decode(&decoded); err != nil {
+		return decodingError(state.data, err)
+	}
+	program := state.scope.CompileArgs([]core.Value{
+		state.callback,
+		decodedToValue(decoded),
+	})
+	return helena_dialect.CreateContinuationValueWithCallback(
+		state.scope,
+		program,
+		state,
+		func(result core.Result, data any) core.Result {
+			state := data.(*streamState)
+			switch result.Code {
+			case core.ResultCode_OK:
+				state.count++
+				return nextElement(state)
+			case core.ResultCode_BREAK:
+				return core.OK(core.INT(state.count))
+			default:
+				return result
+			}
+		},
+	)
+}
+
+func finishStream(state *streamState) core.Result {
+	if _, err := state.decoder.Token(); err != nil {
+		return decodingError(state.data, err)
+	}
+	if _, err := state.decoder.Token(); err == nil {
+		return positionError(state.data, "invalid data after top-level value", state.decoder.InputOffset())
+	} else if err != io.EOF {
+		return decodingError(state.data, err)
+	}
+	return core.OK(core.INT(state.count))
+}
+
+type JsonCmd struct{}
+
+func (JsonCmd) Execute(args []core.Value, context any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "json method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/encoding/json
+	switch method {
+	case "Marshal":
+		// https://pkg.go.dev/encoding/json#Marshal
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "json Marshal value"`)
+		}
+		return marshal(args[2], "", "")
+
+	case "MarshalIndent":
+		// https://pkg.go.dev/encoding/json#MarshalIndent
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "json MarshalIndent value prefix indent"`)
+		}
+		result, prefix := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, indent := core.ValueToString(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return marshal(args[2], prefix, indent)
+
+	case "Unmarshal":
+		// https://pkg.go.dev/encoding/json#Unmarshal
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "json Unmarshal data"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return unmarshal(data)
+
+	case "Valid":
+		// https://pkg.go.dev/encoding/json#Valid
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "json Valid data"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.BOOL(json.Valid([]byte(data))))
+
+	case "Compact":
+		// https://pkg.go.dev/encoding/json#Compact
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "json Compact data"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		var buffer bytes.Buffer
+		if err := json.Compact(&buffer, []byte(data)); err != nil {
+			return decodingError(data, err)
+		}
+		return core.OK(core.STR(buffer.String()))
+
+	case "Indent":
+		// https://pkg.go.dev/encoding/json#Indent
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "json Indent data prefix indent"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, prefix := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, indent := core.ValueToString(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		var buffer bytes.Buffer
+		if err := json.Indent(&buffer, []byte(data), prefix, indent); err != nil {
+			return decodingError(data, err)
+		}
+		return core.OK(core.STR(buffer.String()))
+
+	case "DecodeArray":
+		// https://pkg.go.dev/encoding/json#example-Decoder.Decode-Stream
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "json DecodeArray data callback"`)
+		}
+		result, data := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return startStream(context.(*helena_dialect.Scope), data, args[3])
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
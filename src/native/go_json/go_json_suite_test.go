@@ -0,0 +1,31 @@
+package go_json_test
+
+import (
+	"helena/core"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGoJson(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Go Json Suite")
+}
+
+//
+// Helpers
+//
+
+var NIL = core.NIL
+var TRUE = core.TRUE
+var FALSE = core.FALSE
+var INT = core.INT
+var REAL = core.REAL
+var STR = core.STR
+var LIST = core.LIST
+var DICT = core.DICT
+var TUPLE = core.TUPLE
+
+var OK = core.OK
+var ERROR = core.ERROR
@@ -0,0 +1,259 @@
+package go_json_test
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_json"
+)
+
+var _ = Describe("Go json", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *helena_dialect.Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("json", go_json.JsonCmd{})
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("`Unmarshal`", func() {
+		It("should convert objects to dicts", func() {
+			rootScope.SetNamedVariable("data", STR(`{"name": "helena", "tags": ["a", "b"], "nested": {"ok": true}}`))
+			Expect(evaluate("json Unmarshal $data")).To(Equal(DICT(map[string]core.Value{
+				"name":   STR("helena"),
+				"tags":   LIST([]core.Value{STR("a"), STR("b")}),
+				"nested": DICT(map[string]core.Value{"ok": TRUE}),
+			})))
+		})
+		It("should convert scalars", func() {
+			rootScope.SetNamedVariable("data", STR(`"aé\n\""`))
+			Expect(evaluate("json Unmarshal $data")).To(Equal(STR("aé\n\"")))
+			Expect(evaluate("json Unmarshal true")).To(Equal(TRUE))
+			Expect(evaluate("json Unmarshal false")).To(Equal(FALSE))
+			Expect(evaluate("json Unmarshal null")).To(Equal(NIL))
+		})
+		It("should preserve integer precision", func() {
+			Expect(evaluate("json Unmarshal 9007199254740993")).To(Equal(INT(9007199254740993)))
+			Expect(evaluate("json Unmarshal -9223372036854775808")).To(Equal(INT(math.MinInt64)))
+			Expect(evaluate("json Unmarshal 42")).To(Equal(INT(42)))
+		})
+		It("should convert other numbers to reals", func() {
+			Expect(evaluate("json Unmarshal 1.5")).To(Equal(REAL(1.5)))
+			Expect(evaluate("json Unmarshal 1e3")).To(Equal(REAL(1000)))
+			Expect(evaluate("json Unmarshal 1.0")).To(Equal(REAL(1)))
+			Expect(evaluate("json Unmarshal 9223372036854775808")).To(Equal(REAL(9223372036854775808)))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("json Unmarshal")).To(Equal(ERROR(`wrong # args: should be "json Unmarshal data"`)))
+				Expect(execute("json Unmarshal a b")).To(Equal(ERROR(`wrong # args: should be "json Unmarshal data"`)))
+			})
+			Specify("invalid string value", func() {
+				Expect(execute("json Unmarshal []")).To(Equal(ERROR("value has no string representation")))
+			})
+			Specify("error positions", func() {
+				rootScope.SetNamedVariable("data", STR("{\n  \"a\": 1,\n  \"b\": x\n}"))
+				Expect(execute("json Unmarshal $data")).To(Equal(
+					ERROR("invalid character 'x' looking for beginning of value at line 3, column 8"),
+				))
+				rootScope.SetNamedVariable("data", STR(`[1, 2`))
+				Expect(execute("json Unmarshal $data")).To(Equal(
+					ERROR("unexpected end of JSON input at line 1, column 5"),
+				))
+				rootScope.SetNamedVariable("data", STR(`{"é": 1} 2`))
+				Expect(execute("json Unmarshal $data")).To(Equal(
+					ERROR("invalid character '2' after top-level value at line 1, column 10"),
+				))
+				Expect(execute(`json Unmarshal ""`)).To(Equal(
+					ERROR("unexpected end of JSON input at line 1, column 1"),
+				))
+			})
+		})
+	})
+
+	Describe("`Marshal`", func() {
+		It("should encode typed values", func() {
+			Expect(evaluate("json Marshal [+ 1 2]")).To(Equal(STR("3")))
+			Expect(evaluate("json Marshal [* 1.5 1]")).To(Equal(STR("1.5")))
+			Expect(evaluate("json Marshal [true]")).To(Equal(STR("true")))
+			Expect(evaluate("json Marshal []")).To(Equal(STR("null")))
+		})
+		It("should encode other values as strings", func() {
+			Expect(evaluate("json Marshal 123")).To(Equal(STR(`"123"`)))
+			Expect(evaluate(`json Marshal "<a & b>"`)).To(Equal(STR(`"<a & b>"`)))
+			Expect(evaluate("json Marshal {a b}")).To(Equal(STR(`"a b"`)))
+		})
+		It("should encode lists and tuples as arrays", func() {
+			Expect(evaluate("json Marshal [list (a [+ 1 0] ())]")).To(Equal(STR(`["a",1,[]]`)))
+			Expect(evaluate("json Marshal (a (b c))")).To(Equal(STR(`["a",["b","c"]]`)))
+		})
+		It("should encode dicts as objects with sorted keys", func() {
+			Expect(evaluate("json Marshal [dict (b [+ 2 0] a [dict (c [])])]")).To(Equal(
+				STR(`{"a":{"c":null},"b":2}`),
+			))
+		})
+		It("should round-trip with `Unmarshal`", func() {
+			rootScope.SetNamedVariable("data", STR(`{"a":[1,2.5,"x",null,true],"b":{"c":{}}}`))
+			Expect(evaluate("json Marshal [json Unmarshal $data]")).To(Equal(evaluate("get data")))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("json Marshal")).To(Equal(ERROR(`wrong # args: should be "json Marshal value"`)))
+			})
+			Specify("unsupported values", func() {
+				rootScope.SetNamedVariable("nan", core.REAL(math.NaN()))
+				Expect(execute("json Marshal $nan")).To(Equal(ERROR("unsupported value: NaN")))
+				rootScope.SetNamedVariable("cmd", core.NewCommandValue(nil))
+				Expect(execute("json Marshal (a [list ($cmd)])")).To(Equal(
+					ERROR("value has no string representation"),
+				))
+			})
+		})
+	})
+
+	Describe("Pretty-printing", func() {
+		Specify("`MarshalIndent`", func() {
+			Expect(evaluate(`json MarshalIndent [dict (a (b c))] "" "  "`)).To(Equal(
+				STR("{\n  \"a\": [\n    \"b\",\n    \"c\"\n  ]\n}"),
+			))
+			Expect(evaluate(`json MarshalIndent (a) "> " "\t"`)).To(Equal(
+				STR("[\n> \t\"a\"\n> ]"),
+			))
+		})
+		Specify("`Indent`", func() {
+			Expect(evaluate(`json Indent """{"a":[1]}""" "" "  "`)).To(Equal(
+				STR("{\n  \"a\": [\n    1\n  ]\n}"),
+			))
+		})
+		Specify("`Compact`", func() {
+			rootScope.SetNamedVariable("data", STR("{\n  \"a\": [ 1, 2 ]\n}"))
+			Expect(evaluate("json Compact $data")).To(Equal(STR(`{"a":[1,2]}`)))
+		})
+		Specify("`Valid`", func() {
+			Expect(evaluate(`json Valid """{"a":1}"""`)).To(Equal(TRUE))
+			Expect(evaluate(`json Valid """{"a":}"""`)).To(Equal(FALSE))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("json MarshalIndent a b")).To(Equal(
+					ERROR(`wrong # args: should be "json MarshalIndent value prefix indent"`),
+				))
+				Expect(execute("json Indent a b")).To(Equal(
+					ERROR(`wrong # args: should be "json Indent data prefix indent"`),
+				))
+				Expect(execute("json Compact")).To(Equal(ERROR(`wrong # args: should be "json Compact data"`)))
+				Expect(execute("json Valid")).To(Equal(ERROR(`wrong # args: should be "json Valid data"`)))
+			})
+			Specify("error positions", func() {
+				Expect(execute(`json Indent """{"a":}""" "" "  "`)).To(Equal(
+					ERROR("invalid character '}' looking for beginning of value at line 1, column 6"),
+				))
+				Expect(execute(`json Compact """[1 2]"""`)).To(Equal(
+					ERROR("invalid character '2' after array value at line 1, column 4"),
+				))
+			})
+		})
+	})
+
+	Describe("`DecodeArray`", func() {
+		BeforeEach(func() {
+			rootScope.SetNamedVariable("data", STR(`[1, {"a": "b"}, [true, null], "c"]`))
+		})
+
+		It("should call the callback for each element", func() {
+			evaluate("set l [list ()]")
+			evaluate("macro collect {value} {set l [list $l append ($value)]}")
+			Expect(execute("json DecodeArray $data collect")).To(Equal(OK(INT(4))))
+			Expect(evaluate("get l")).To(Equal(LIST([]core.Value{
+				INT(1),
+				DICT(map[string]core.Value{"a": STR("b")}),
+				LIST([]core.Value{TRUE, NIL}),
+				STR("c"),
+			})))
+		})
+		It("should stream elements from coroutines", func() {
+			evaluate("set c [coroutine {json DecodeArray $data yield}]")
+			Expect(evaluate("$c wait")).To(Equal(INT(1)))
+			Expect(evaluate("$c yield")).To(Equal(DICT(map[string]core.Value{"a": STR("b")})))
+			Expect(evaluate("$c yield")).To(Equal(LIST([]core.Value{TRUE, NIL})))
+			Expect(evaluate("$c yield")).To(Equal(STR("c")))
+			Expect(evaluate("$c yield")).To(Equal(INT(4)))
+			Expect(evaluate("$c done")).To(Equal(TRUE))
+		})
+		It("should stop on break", func() {
+			evaluate("set l [list ()]")
+			evaluate(`macro collect {value} {
+				if [string $value == c] {break}
+				set l [list $l append ($value)]
+			}`)
+			rootScope.SetNamedVariable("data", STR(`["a", "b", "c", "d", "e"]`))
+			Expect(execute("json DecodeArray $data collect")).To(Equal(OK(INT(2))))
+			Expect(evaluate("get l")).To(Equal(LIST([]core.Value{STR("a"), STR("b")})))
+		})
+		It("should accept empty arrays", func() {
+			Expect(execute(`json DecodeArray """[]""" idem`)).To(Equal(OK(INT(0))))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("json DecodeArray a")).To(Equal(
+					ERROR(`wrong # args: should be "json DecodeArray data callback"`),
+				))
+			})
+			Specify("callback errors", func() {
+				Expect(execute("json DecodeArray $data (error msg)")).To(Equal(
+					ERROR(`wrong # args: should be "error message"`),
+				))
+			})
+			Specify("error positions", func() {
+				Expect(execute(`json DecodeArray """{"a": 1}""" idem`)).To(Equal(
+					ERROR("expected JSON array at line 1, column 1"),
+				))
+				rootScope.SetNamedVariable("data", STR("[1,\n 2\n 3]"))
+				Expect(execute("json DecodeArray $data idem")).To(Equal(
+					ERROR("invalid character '3' after array element at line 3, column 2"),
+				))
+				Expect(execute(`json DecodeArray """[1, 2""" idem`)).To(Equal(
+					ERROR("unexpected end of JSON input at line 1, column 5"),
+				))
+				Expect(execute(`json DecodeArray """[1] 2""" idem`)).To(Equal(
+					ERROR("invalid data after top-level value at line 1, column 5"),
+				))
+			})
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("json")).To(Equal(ERROR(`wrong # args: should be "json method ?arg ...?"`)))
+		})
+		Specify("invalid method name", func() {
+			Expect(execute("json []")).To(Equal(ERROR("invalid method name")))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("json Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
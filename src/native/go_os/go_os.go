@@ -1,8 +1,12 @@
 package go_os
 
 import (
+	"errors"
 	"helena/core"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func asString(value core.Value) (s string, ok bool) {
@@ -13,6 +17,141 @@ func asString(value core.Value) (s string, ok bool) {
 	return "", false
 }
 
+// Function called by `os Exit`, can be replaced for testing
+var Exit = os.Exit
+
+// Default permissions of created files
+const DEFAULT_FILE_PERM fs.FileMode = 0o666
+
+// Default permissions of created directories
+const DEFAULT_DIR_PERM fs.FileMode = 0o777
+
+// Convert an os error to a Helena error
+//
+// Error values are dicts with the following keys:
+//
+//   - `message`: the Go error string, e.g. `open /x: no such file or directory`
+//   - `op`: the failed operation, e.g. `open`, if any
+//   - `path`: the file path involved, if any
+//   - `kind`: `NotExist`, `Exist`, `Permission`, or empty for other errors
+//
+// Errors are classified with `os IsNotExist` and friends from their `kind`
+func osError(err error) core.Result {
+	op, path := "", ""
+	var pathError *fs.PathError
+	var linkError *os.LinkError
+	var syscallError *os.SyscallError
+	switch {
+	case errors.As(err, &pathError):
+		op, path = pathError.Op, pathError.Path
+	case errors.As(err, &linkError):
+		op, path = linkError.Op, linkError.Old
+	case errors.As(err, &syscallError):
+		op = syscallError.Syscall
+	}
+	return core.Result{
+		Code: core.ResultCode_ERROR,
+		Value: core.DICT(map[string]core.Value{
+			"message": core.STR(err.Error()),
+			"op":      core.STR(op),
+			"path":    core.STR(path),
+			"kind":    core.STR(errorKind(err)),
+		}),
+	}
+}
+
+// Error kinds, keyed by the `os Is*` method that recognizes them
+var errorKinds = []struct {
+	method string
+	kind   string
+	err    error
+}{
+	{"IsNotExist", "NotExist", fs.ErrNotExist},
+	{"IsExist", "Exist", fs.ErrExist},
+	{"IsPermission", "Permission", fs.ErrPermission},
+}
+
+func errorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ""
+}
+
+// Return whether an error value returned by osError is of the kind
+// recognized by the given method
+func isErrorKind(value core.Value, method string) bool {
+	dict, ok := value.(core.DictionaryValue)
+	if !ok {
+		return false
+	}
+	kind, ok := dict.Map["kind"].(core.StringValue)
+	if !ok {
+		return false
+	}
+	for _, k := range errorKinds {
+		if k.method == method {
+			return kind.Value == k.kind
+		}
+	}
+	return false
+}
+
+// Convert a file permission argument
+//
+// Permissions are either integers or octal strings such as `755` or `0o755`
+func valueToPerm(value core.Value) (core.Result, fs.FileMode) {
+	if i, ok := value.(core.IntegerValue); ok {
+		if i.Value < 0 || i.Value > int64(fs.ModePerm) {
+			return core.ERROR("invalid permissions"), 0
+		}
+		return core.OK(core.NIL), fs.FileMode(i.Value)
+	}
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid permissions"), 0
+	}
+	perm, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || perm > uint64(fs.ModePerm) {
+		return core.ERROR("invalid permissions"), 0
+	}
+	return core.OK(core.NIL), fs.FileMode(perm)
+}
+
+// Convert a file info to a dict value
+//
+// Keys are `name`, `size`, `mode` (as displayed by `ls -l`), `perm` (as
+// integer), `modTime` (in Unix seconds) and `isDir`
+func fileInfoValue(info fs.FileInfo) core.Value {
+	return core.DICT(map[string]core.Value{
+		"name":    core.STR(info.Name()),
+		"size":    core.INT(info.Size()),
+		"mode":    core.STR(info.Mode().String()),
+		"perm":    core.INT(int64(info.Mode().Perm())),
+		"modTime": core.INT(info.ModTime().Unix()),
+		"isDir":   core.BOOL(info.IsDir()),
+	})
+}
+
+// Write data to a file, truncating it unless appending
+func writeFile(name string, data string, perm fs.FileMode, append bool) error {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if append {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(data)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	return err
+}
+
 type OsCmd struct{}
 
 func (OsCmd) Execute(args []core.Value, _ any) core.Result {
@@ -25,17 +164,355 @@ func (OsCmd) Execute(args []core.Value, _ any) core.Result {
 	}
 	// https://pkg.go.dev/os
 	switch method {
+	//
+	// Files
+	//
+
 	case "ReadFile":
-		if len(args) < 3 {
+		// https://pkg.go.dev/os#ReadFile
+		if len(args) != 3 {
 			return core.ERROR(`wrong # args: should be "os ReadFile name"`)
 		}
-		name, _ := asString((args[2]))
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
 		data, err := os.ReadFile(name)
 		if err != nil {
-			return core.ERROR(err.Error())
+			return osError(err)
 		}
 		return core.OK(core.STR(string(data)))
 
+	case "WriteFile", "AppendFile":
+		// https://pkg.go.dev/os#WriteFile
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "os ` + method + ` name data ?perm?"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, data := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		perm := DEFAULT_FILE_PERM
+		if len(args) == 5 {
+			result, perm = valueToPerm(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		if err := writeFile(name, data, perm, method == "AppendFile"); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "Stat", "Lstat":
+		// https://pkg.go.dev/os#Stat
+		// https://pkg.go.dev/os#Lstat
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os ` + method + ` name"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		stat := os.Stat
+		if method == "Lstat" {
+			stat = os.Lstat
+		}
+		info, err := stat(name)
+		if err != nil {
+			return osError(err)
+		}
+		return core.OK(fileInfoValue(info))
+
+	case "Remove":
+		// https://pkg.go.dev/os#Remove
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os Remove name"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.Remove(name); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "RemoveAll":
+		// https://pkg.go.dev/os#RemoveAll
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os RemoveAll path"`)
+		}
+		result, path := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "Rename":
+		// https://pkg.go.dev/os#Rename
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "os Rename oldpath newpath"`)
+		}
+		result, oldpath := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, newpath := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.Rename(oldpath, newpath); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	//
+	// Directories
+	//
+
+	case "ReadDir":
+		// https://pkg.go.dev/os#ReadDir
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os ReadDir name"`)
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return osError(err)
+		}
+		values := make([]core.Value, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed since the directory was read
+				continue
+			}
+			if err != nil {
+				return osError(err)
+			}
+			values = append(values, fileInfoValue(info))
+		}
+		return core.OK(core.LIST(values))
+
+	case "Mkdir", "MkdirAll":
+		// https://pkg.go.dev/os#Mkdir
+		// https://pkg.go.dev/os#MkdirAll
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "os ` + method + ` path ?perm?"`)
+		}
+		result, path := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		perm := DEFAULT_DIR_PERM
+		if len(args) == 4 {
+			result, perm = valueToPerm(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		mkdir := os.Mkdir
+		if method == "MkdirAll" {
+			mkdir = os.MkdirAll
+		}
+		if err := mkdir(path, perm); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "MkdirTemp":
+		// https://pkg.go.dev/os#MkdirTemp
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "os MkdirTemp dir pattern"`)
+		}
+		result, dir := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, pattern := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		name, err := os.MkdirTemp(dir, pattern)
+		if err != nil {
+			return osError(err)
+		}
+		return core.OK(core.STR(name))
+
+	case "TempDir":
+		// https://pkg.go.dev/os#TempDir
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "os TempDir"`)
+		}
+		return core.OK(core.STR(os.TempDir()))
+
+	case "Getwd":
+		// https://pkg.go.dev/os#Getwd
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "os Getwd"`)
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return osError(err)
+		}
+		return core.OK(core.STR(dir))
+
+	case "Chdir":
+		// https://pkg.go.dev/os#Chdir
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os Chdir dir"`)
+		}
+		result, dir := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.Chdir(dir); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	//
+	// Environment
+	//
+
+	case "Getenv":
+		// https://pkg.go.dev/os#Getenv
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os Getenv key"`)
+		}
+		result, key := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(os.Getenv(key)))
+
+	case "LookupEnv":
+		// https://pkg.go.dev/os#LookupEnv
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os LookupEnv key"`)
+		}
+		result, key := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return core.OK(core.NIL)
+		}
+		return core.OK(core.STR(value))
+
+	case "Setenv":
+		// https://pkg.go.dev/os#Setenv
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "os Setenv key value"`)
+		}
+		result, key := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, value := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "Unsetenv":
+		// https://pkg.go.dev/os#Unsetenv
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os Unsetenv key"`)
+		}
+		result, key := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if err := os.Unsetenv(key); err != nil {
+			return osError(err)
+		}
+		return core.OK(core.NIL)
+
+	case "Environ":
+		// https://pkg.go.dev/os#Environ
+		//
+		// Returns a dict instead of a list of key=value strings
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "os Environ"`)
+		}
+		environ := map[string]core.Value{}
+		for _, entry := range os.Environ() {
+			key, value, _ := strings.Cut(entry, "=")
+			environ[key] = core.STR(value)
+		}
+		return core.OK(core.DICT(environ))
+
+	//
+	// Process
+	//
+
+	case "Hostname":
+		// https://pkg.go.dev/os#Hostname
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "os Hostname"`)
+		}
+		name, err := os.Hostname()
+		if err != nil {
+			return osError(err)
+		}
+		return core.OK(core.STR(name))
+
+	case "Args":
+		// https://pkg.go.dev/os#Args
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "os Args"`)
+		}
+		values := make([]core.Value, len(os.Args))
+		for i, arg := range os.Args {
+			values[i] = core.STR(arg)
+		}
+		return core.OK(core.LIST(values))
+
+	case "Exit":
+		// https://pkg.go.dev/os#Exit
+		if len(args) != 2 && len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os Exit ?code?"`)
+		}
+		code := int64(0)
+		if len(args) == 3 {
+			var result core.Result
+			result, code = core.ValueToInteger(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+		}
+		Exit(int(code))
+		return core.OK(core.NIL)
+
+	//
+	// Errors
+	//
+
+	case "IsNotExist", "IsExist", "IsPermission":
+		// https://pkg.go.dev/os#IsNotExist
+		// https://pkg.go.dev/os#IsExist
+		// https://pkg.go.dev/os#IsPermission
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "os ` + method + ` error"`)
+		}
+		return core.OK(core.BOOL(isErrorKind(args[2], method)))
+
 	default:
 		return core.ERROR("unsupported method " + method)
 	}
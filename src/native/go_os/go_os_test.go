@@ -0,0 +1,270 @@
+package go_os_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_os"
+)
+
+var _ = Describe("Go os", func() {
+	var rootScope *helena_dialect.Scope
+	var dir string
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("os", go_os.OsCmd{})
+
+		dir = GinkgoT().TempDir()
+		rootScope.SetNamedVariable("dir", STR(dir))
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("Files", func() {
+		Specify("`ReadFile`", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("content"), 0o644)).To(Succeed())
+			Expect(execute("os ReadFile ${dir}/a.txt")).To(Equal(OK(STR("content"))))
+		})
+		Specify("`WriteFile`", func() {
+			Expect(execute("os WriteFile ${dir}/a.txt content")).To(Equal(OK(NIL)))
+			Expect(execute("os WriteFile ${dir}/a.txt new")).To(Equal(OK(NIL)))
+			data, _ := os.ReadFile(filepath.Join(dir, "a.txt"))
+			Expect(string(data)).To(Equal("new"))
+		})
+		Specify("`AppendFile`", func() {
+			Expect(execute("os AppendFile ${dir}/a.txt abc")).To(Equal(OK(NIL)))
+			Expect(execute("os AppendFile ${dir}/a.txt def")).To(Equal(OK(NIL)))
+			Expect(evaluate("os ReadFile ${dir}/a.txt")).To(Equal(STR("abcdef")))
+		})
+		Specify("permissions", func() {
+			evaluate("os WriteFile ${dir}/a.txt content 600")
+			Expect(evaluate("dict [os Stat ${dir}/a.txt] get perm")).To(Equal(INT(0o600)))
+			evaluate("os WriteFile ${dir}/b.txt content 0o640")
+			Expect(evaluate("dict [os Stat ${dir}/b.txt] get perm")).To(Equal(INT(0o640)))
+			evaluate("os WriteFile ${dir}/c.txt content [+ 384 0]")
+			Expect(evaluate("dict [os Stat ${dir}/c.txt] get mode")).To(Equal(STR("-rw-------")))
+		})
+		Specify("`Stat`", func() {
+			evaluate("os WriteFile ${dir}/a.txt content")
+			info, _ := os.Stat(filepath.Join(dir, "a.txt"))
+			Expect(evaluate("os Stat ${dir}/a.txt")).To(Equal(DICT(map[string]core.Value{
+				"name":    STR("a.txt"),
+				"size":    INT(7),
+				"mode":    STR(info.Mode().String()),
+				"perm":    INT(int64(info.Mode().Perm())),
+				"modTime": INT(info.ModTime().Unix()),
+				"isDir":   FALSE,
+			})))
+			Expect(evaluate("dict [os Stat $dir] get isDir")).To(Equal(TRUE))
+		})
+		Specify("`Lstat`", func() {
+			evaluate("os WriteFile ${dir}/a.txt content")
+			Expect(os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link"))).To(Succeed())
+			Expect(asString(evaluate("dict [os Stat ${dir}/link] get mode"))).To(HavePrefix("-"))
+			Expect(asString(evaluate("dict [os Lstat ${dir}/link] get mode"))).To(HavePrefix("L"))
+		})
+		Specify("`Remove`", func() {
+			evaluate("os WriteFile ${dir}/a.txt content")
+			Expect(execute("os Remove ${dir}/a.txt")).To(Equal(OK(NIL)))
+			Expect(filepath.Join(dir, "a.txt")).NotTo(BeAnExistingFile())
+		})
+		Specify("`RemoveAll`", func() {
+			evaluate("os MkdirAll ${dir}/a/b/c")
+			Expect(execute("os RemoveAll ${dir}/a")).To(Equal(OK(NIL)))
+			Expect(filepath.Join(dir, "a")).NotTo(BeADirectory())
+		})
+		Specify("`Rename`", func() {
+			evaluate("os WriteFile ${dir}/a.txt content")
+			Expect(execute("os Rename ${dir}/a.txt ${dir}/b.txt")).To(Equal(OK(NIL)))
+			Expect(evaluate("os ReadFile ${dir}/b.txt")).To(Equal(STR("content")))
+			Expect(filepath.Join(dir, "a.txt")).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Describe("Directories", func() {
+		Specify("`ReadDir`", func() {
+			evaluate("os WriteFile ${dir}/b.txt content")
+			evaluate("os Mkdir ${dir}/a")
+			Expect(evaluate("list [os ReadDir $dir] length")).To(Equal(INT(2)))
+			Expect(evaluate("dict [list [os ReadDir $dir] at 0] get name")).To(Equal(STR("a")))
+			Expect(evaluate("dict [list [os ReadDir $dir] at 0] get isDir")).To(Equal(TRUE))
+			Expect(evaluate("dict [list [os ReadDir $dir] at 1] get name")).To(Equal(STR("b.txt")))
+			Expect(evaluate("dict [list [os ReadDir $dir] at 1] get size")).To(Equal(INT(7)))
+		})
+		Specify("`Mkdir`", func() {
+			Expect(execute("os Mkdir ${dir}/a")).To(Equal(OK(NIL)))
+			Expect(filepath.Join(dir, "a")).To(BeADirectory())
+			Expect(execute("os Mkdir ${dir}/b 700")).To(Equal(OK(NIL)))
+			Expect(evaluate("dict [os Stat ${dir}/b] get perm")).To(Equal(INT(0o700)))
+		})
+		Specify("`MkdirAll`", func() {
+			Expect(execute("os MkdirAll ${dir}/a/b/c")).To(Equal(OK(NIL)))
+			Expect(filepath.Join(dir, "a", "b", "c")).To(BeADirectory())
+			Expect(execute("os MkdirAll ${dir}/a/b/c")).To(Equal(OK(NIL)))
+		})
+		Specify("`MkdirTemp`", func() {
+			name := evaluate("os MkdirTemp $dir tmp-*")
+			Expect(asString(name)).To(HavePrefix(filepath.Join(dir, "tmp-")))
+			Expect(asString(name)).To(BeADirectory())
+		})
+		Specify("`TempDir`", func() {
+			Expect(evaluate("os TempDir")).To(Equal(STR(os.TempDir())))
+		})
+		Specify("`Getwd` / `Chdir`", func() {
+			wd, _ := os.Getwd()
+			DeferCleanup(os.Chdir, wd)
+			Expect(execute("os Getwd")).To(Equal(OK(STR(wd))))
+			Expect(execute("os Chdir $dir")).To(Equal(OK(NIL)))
+			resolved, _ := filepath.EvalSymlinks(dir)
+			Expect(filepath.EvalSymlinks(asString(evaluate("os Getwd")))).To(Equal(resolved))
+		})
+	})
+
+	Describe("Environment", func() {
+		BeforeEach(func() {
+			GinkgoT().Setenv("HELENA_GO_OS_TEST", "value")
+		})
+
+		Specify("`Getenv`", func() {
+			Expect(evaluate("os Getenv HELENA_GO_OS_TEST")).To(Equal(STR("value")))
+			Expect(evaluate("os Getenv HELENA_GO_OS_UNDEFINED")).To(Equal(STR("")))
+		})
+		Specify("`LookupEnv`", func() {
+			Expect(evaluate("os LookupEnv HELENA_GO_OS_TEST")).To(Equal(STR("value")))
+			Expect(evaluate("os LookupEnv HELENA_GO_OS_UNDEFINED")).To(Equal(NIL))
+		})
+		Specify("`Setenv`", func() {
+			Expect(execute("os Setenv HELENA_GO_OS_TEST other")).To(Equal(OK(NIL)))
+			Expect(os.Getenv("HELENA_GO_OS_TEST")).To(Equal("other"))
+		})
+		Specify("`Unsetenv`", func() {
+			Expect(execute("os Unsetenv HELENA_GO_OS_TEST")).To(Equal(OK(NIL)))
+			_, ok := os.LookupEnv("HELENA_GO_OS_TEST")
+			Expect(ok).To(BeFalse())
+		})
+		Specify("`Environ`", func() {
+			Expect(evaluate("dict [os Environ] get HELENA_GO_OS_TEST")).To(Equal(STR("value")))
+		})
+	})
+
+	Describe("Process", func() {
+		Specify("`Hostname`", func() {
+			hostname, _ := os.Hostname()
+			Expect(evaluate("os Hostname")).To(Equal(STR(hostname)))
+		})
+		Specify("`Args`", func() {
+			Expect(evaluate("list [os Args] length")).To(Equal(INT(int64(len(os.Args)))))
+			Expect(evaluate("list [os Args] at 0")).To(Equal(STR(os.Args[0])))
+		})
+		Specify("`Exit`", func() {
+			codes := []int{}
+			DeferCleanup(func(exit func(int)) { go_os.Exit = exit }, go_os.Exit)
+			go_os.Exit = func(code int) { codes = append(codes, code) }
+			Expect(execute("os Exit")).To(Equal(OK(NIL)))
+			Expect(execute("os Exit 3")).To(Equal(OK(NIL)))
+			Expect(codes).To(Equal([]int{0, 3}))
+		})
+	})
+
+	Describe("Errors", func() {
+		It("should return structured errors", func() {
+			path := filepath.Join(dir, "missing")
+			Expect(execute("os ReadFile ${dir}/missing")).To(Equal(core.Result{
+				Code: core.ResultCode_ERROR,
+				Value: DICT(map[string]core.Value{
+					"message": STR("open " + path + ": no such file or directory"),
+					"op":      STR("open"),
+					"path":    STR(path),
+					"kind":    STR("NotExist"),
+				}),
+			}))
+		})
+		It("should report the old path of link errors", func() {
+			Expect(evaluate("catch {os Rename ${dir}/missing ${dir}/a} error err {idem $err}")).To(Equal(
+				DICT(map[string]core.Value{
+					"message": STR("rename " + filepath.Join(dir, "missing") + " " + filepath.Join(dir, "a") + ": no such file or directory"),
+					"op":      STR("rename"),
+					"path":    STR(filepath.Join(dir, "missing")),
+					"kind":    STR("NotExist"),
+				}),
+			))
+		})
+		Specify("`IsNotExist`", func() {
+			Expect(evaluate("catch {os Stat ${dir}/missing} error msg {os IsNotExist $msg}")).To(Equal(TRUE))
+			Expect(evaluate("catch {os Mkdir $dir} error msg {os IsNotExist $msg}")).To(Equal(FALSE))
+		})
+		Specify("`IsExist`", func() {
+			Expect(evaluate("catch {os Mkdir $dir} error msg {os IsExist $msg}")).To(Equal(TRUE))
+			evaluate("os MkdirAll ${dir}/a/b")
+			Expect(evaluate("catch {os Remove ${dir}/a} error msg {os IsExist $msg}")).To(Equal(TRUE))
+		})
+		Specify("`IsPermission`", func() {
+			if os.Geteuid() == 0 {
+				Skip("permissions are not enforced for root")
+			}
+			evaluate("os Mkdir ${dir}/locked 500")
+			Expect(evaluate("catch {os WriteFile ${dir}/locked/a.txt content} error msg {os IsPermission $msg}")).To(Equal(TRUE))
+		})
+		It("should classify errors by kind", func() {
+			Expect(evaluate("os IsPermission [dict (kind Permission)]")).To(Equal(TRUE))
+			Expect(evaluate("os IsNotExist [dict (kind Permission)]")).To(Equal(FALSE))
+			Expect(evaluate("os IsExist [dict (kind Exist)]")).To(Equal(TRUE))
+		})
+		It("should not classify error messages", func() {
+			Expect(evaluate(`os IsNotExist "open /x: no such file or directory"`)).To(Equal(FALSE))
+			Expect(evaluate("os IsExist [dict (message {file exists})]")).To(Equal(FALSE))
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("os")).To(Equal(ERROR(`wrong # args: should be "os method ?arg ...?"`)))
+			Expect(execute("os ReadFile")).To(Equal(ERROR(`wrong # args: should be "os ReadFile name"`)))
+			Expect(execute("os WriteFile a")).To(Equal(ERROR(`wrong # args: should be "os WriteFile name data ?perm?"`)))
+			Expect(execute("os AppendFile a")).To(Equal(ERROR(`wrong # args: should be "os AppendFile name data ?perm?"`)))
+			Expect(execute("os Stat")).To(Equal(ERROR(`wrong # args: should be "os Stat name"`)))
+			Expect(execute("os Rename a")).To(Equal(ERROR(`wrong # args: should be "os Rename oldpath newpath"`)))
+			Expect(execute("os MkdirAll")).To(Equal(ERROR(`wrong # args: should be "os MkdirAll path ?perm?"`)))
+			Expect(execute("os Setenv a")).To(Equal(ERROR(`wrong # args: should be "os Setenv key value"`)))
+			Expect(execute("os Getwd a")).To(Equal(ERROR(`wrong # args: should be "os Getwd"`)))
+			Expect(execute("os Exit 1 2")).To(Equal(ERROR(`wrong # args: should be "os Exit ?code?"`)))
+			Expect(execute("os IsNotExist")).To(Equal(ERROR(`wrong # args: should be "os IsNotExist error"`)))
+		})
+		Specify("invalid permissions", func() {
+			Expect(execute("os WriteFile ${dir}/a.txt content 9")).To(Equal(ERROR("invalid permissions")))
+			Expect(execute("os Mkdir ${dir}/a 10000")).To(Equal(ERROR("invalid permissions")))
+			Expect(execute("os Mkdir ${dir}/a [idem -1]")).To(Equal(ERROR("invalid permissions")))
+			Expect(execute("os Mkdir ${dir}/a ()")).To(Equal(ERROR("invalid permissions")))
+		})
+		Specify("invalid arguments", func() {
+			Expect(execute("os ReadFile ()")).To(Equal(ERROR("value has no string representation")))
+			Expect(execute("os Exit a")).To(Equal(ERROR(`invalid integer "a"`)))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("os Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
+
+func asString(value core.Value) (s string) { _, s = core.ValueToString(value); return }
@@ -12,6 +12,7 @@ func Initmodule() *helena_dialect.Module {
 	scope := helena_dialect.NewRootScope(nil)
 	exports := &helena_dialect.Exports{}
 	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Operating system functions from the Go os package")
 	exportCommand(module, "os", OsCmd{})
 	return module
 }
@@ -2,7 +2,10 @@ package go_regexp
 
 import (
 	"helena/core"
+	"helena/helena_dialect"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 func asString(value core.Value) (s string, ok bool) {
@@ -38,9 +41,133 @@ func (value RegexpValue) Display(fn core.DisplayFunction) string {
 	return core.UndisplayableValueWithLabel("Regexp " + value.regexp.String())
 }
 
+func (value RegexpValue) Command() core.Command {
+	return regexpCommand{value}
+}
+
+// Return the submatches as a dict keyed by subexpression names
+//
+// Unnamed subexpressions, including the whole match, are keyed by index
+func submatchDict(re *regexp.Regexp, match []string) core.Value {
+	entries := make(map[string]core.Value, len(match))
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		entries[name] = core.STR(match[i])
+	}
+	return core.DICT(entries)
+}
+
+// Convert a match value as returned by FindStringSubmatchIndex
+//
+// Indices come in start/end pairs within src, or -1 for unmatched
+// subexpressions
+func valueToIndices(value core.Value, src string) (core.Result, []int) {
+	result, values := helena_dialect.ValueToArray(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	indices := make([]int, len(values))
+	for i, value := range values {
+		result, index := core.ValueToInteger(value)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		indices[i] = int(index)
+	}
+	if len(indices)%2 != 0 {
+		return core.ERROR("invalid match value: odd number of indices"), nil
+	}
+	for i := 0; i < len(indices); i += 2 {
+		start, end := indices[i], indices[i+1]
+		if start == -1 && end == -1 {
+			continue
+		}
+		if start < 0 || end < start || end > len(src) {
+			return core.ERROR("invalid match value: indices " + strconv.Itoa(start) + " " + strconv.Itoa(end) + " out of range"), nil
+		}
+	}
+	return core.OK(core.NIL), indices
+}
+
+// Expand the template for each match in src, see Regexp.Expand example
+func expandAll(re *regexp.Regexp, template string, src string) string {
+	result := []byte{}
+	for _, match := range re.FindAllStringSubmatchIndex(src, -1) {
+		result = re.ExpandString(result, template, src, match)
+	}
+	return string(result)
+}
+
+//
+// Replacement callbacks
+//
+// Each match is replaced by the result of the callback called with the
+// matched string. Callbacks are continuations so that they can yield in turn,
+// e.g. from procs or coroutines
+//
+
+type replaceState struct {
+	scope    *helena_dialect.Scope
+	callback core.Value
+	src      string
+	matches  [][]int
+	index    int
+	last     int
+	builder  strings.Builder
+}
+
+func replaceAllStringFunc(
+	scope *helena_dialect.Scope,
+	re *regexp.Regexp,
+	src string,
+	callback core.Value,
+) core.Result {
+	return nextReplacement(&replaceState{
+		scope:    scope,
+		callback: callback,
+		src:      src,
+		matches:  re.FindAllStringIndex(src, -1),
+	})
+}
+
+func nextReplacement(state *replaceState) core.Result {
+	if state.index >= len(state.matches) {
+		state.builder.WriteString(state.src[state.last:])
+		return core.OK(core.STR(state.builder.String()))
+	}
+	match := state.matches[state.index]
+	program := state.scope.CompileArgs([]core.Value{
+		state.callback,
+		core.STR(state.src[match[0]:match[1]]),
+	})
+	return helena_dialect.CreateContinuationValueWithCallback(
+		state.scope,
+		program,
+		state,
+		func(result core.Result, data any) core.Result {
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			state := data.(*replaceState)
+			result, repl := core.ValueToString(result.Value)
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			match := state.matches[state.index]
+			state.builder.WriteString(state.src[state.last:match[0]])
+			state.builder.WriteString(repl)
+			state.last = match[1]
+			state.index++
+			return nextReplacement(state)
+		},
+	)
+}
+
 type RegexpCmd struct{}
 
-func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
+func (RegexpCmd) Execute(args []core.Value, context any) core.Result {
 	if len(args) < 2 {
 		return core.ERROR(`wrong # args: should be "regexp method ?arg ...?"`)
 	}
@@ -92,6 +219,30 @@ func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
 		}
 		return core.OK(NewRegexpValue(re))
 
+	case "ExpandString":
+		// https://pkg.go.dev/regexp#Regexp.ExpandString
+		if len(args) != 6 {
+			return core.ERROR(`wrong # args: should be "regexp ExpandString re template src match"`)
+		}
+		re, ok := args[2].(RegexpValue)
+		if !ok {
+			return core.ERROR("invalid regexp value")
+		}
+		result, template := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, src := core.ValueToString(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, match := valueToIndices(args[5], src)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		s := re.regexp.ExpandString(nil, template, src, match)
+		return core.OK(core.STR(string(s)))
+
 	case "FindAllString":
 		// https://pkg.go.dev/regexp#Regexp.FindAllString
 		if len(args) != 5 {
@@ -180,6 +331,32 @@ func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
 		}
 		return core.OK(core.LIST(values))
 
+	case "FindAllStringSubmatchDict":
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "regexp FindAllStringSubmatchDict re s n"`)
+		}
+		re, ok := args[2].(RegexpValue)
+		if !ok {
+			return core.ERROR("invalid regexp value")
+		}
+		result, s := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, n := core.ValueToInteger(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		matches := re.regexp.FindAllStringSubmatch(s, int(n))
+		if matches == nil {
+			return core.OK(core.NIL)
+		}
+		values := make([]core.Value, len(matches))
+		for i, m := range matches {
+			values[i] = submatchDict(re.regexp, m)
+		}
+		return core.OK(core.LIST(values))
+
 	case "FindAllStringSubmatchIndex":
 		// https://pkg.go.dev/regexp#Regexp.FindAllStringSubmatchIndex
 		if len(args) != 5 {
@@ -271,6 +448,24 @@ func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
 		}
 		return core.OK(core.LIST(values))
 
+	case "FindStringSubmatchDict":
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "regexp FindStringSubmatchDict re s"`)
+		}
+		re, ok := args[2].(RegexpValue)
+		if !ok {
+			return core.ERROR("invalid regexp value")
+		}
+		result, s := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		match := re.regexp.FindStringSubmatch(s)
+		if match == nil {
+			return core.OK(core.NIL)
+		}
+		return core.OK(submatchDict(re.regexp, match))
+
 	case "FindStringSubmatchIndex":
 		if len(args) != 4 {
 			return core.ERROR(`wrong # args: should be "regexp FindStringSubmatchIndex re s"`)
@@ -387,7 +582,22 @@ func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
 
 	case "ReplaceAllStringFunc":
 		// https://pkg.go.dev/regexp#Regexp.ReplaceAllStringFunc
-		return core.ERROR("not implemented")
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "regexp ReplaceAllStringFunc re src callback"`)
+		}
+		re, ok := args[2].(RegexpValue)
+		if !ok {
+			return core.ERROR("invalid regexp value")
+		}
+		result, src := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		scope, ok := context.(*helena_dialect.Scope)
+		if !ok {
+			return core.ERROR("callbacks need a Helena scope")
+		}
+		return replaceAllStringFunc(scope, re.regexp, src, args[4])
 
 	case "Split":
 		// https://pkg.go.dev/regexp#Regexp.Split
@@ -460,3 +670,209 @@ func (RegexpCmd) Execute(args []core.Value, _ any) core.Result {
 		return core.ERROR(`unknown method "` + method + `"`)
 	}
 }
+
+//
+// Regexp value ensemble, e.g. `$re match $s`
+//
+
+type regexpCommand struct {
+	value RegexpValue
+}
+
+var regexpSubcommands = helena_dialect.NewSubcommands([]string{
+	"subcommands",
+	"string",
+	"names",
+	"match",
+	"find",
+	"findAll",
+	"submatch",
+	"submatchAll",
+	"split",
+	"expand",
+	"replace",
+	"replaceLiteral",
+	"replaceFunc",
+})
+
+func (cmd regexpCommand) Execute(args []core.Value, context any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	re := cmd.value.regexp
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<regexp> subcommands")
+		}
+		return core.OK(regexpSubcommands.List)
+
+	case "string":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<regexp> string")
+		}
+		return core.OK(core.STR(re.String()))
+
+	case "names":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("<regexp> names")
+		}
+		names := re.SubexpNames()
+		values := make([]core.Value, len(names))
+		for i, name := range names {
+			values[i] = core.STR(name)
+		}
+		return core.OK(core.LIST(values))
+
+	case "match":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("<regexp> match s")
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.BOOL(re.MatchString(s)))
+
+	case "find":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("<regexp> find s")
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		match := re.FindStringIndex(s)
+		if match == nil {
+			return core.OK(core.NIL)
+		}
+		return core.OK(core.STR(s[match[0]:match[1]]))
+
+	case "findAll":
+		if len(args) != 3 && len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> findAll s ?n?")
+		}
+		result, s, n := stringAndCount(args)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		matches := re.FindAllString(s, n)
+		values := make([]core.Value, len(matches))
+		for i, m := range matches {
+			values[i] = core.STR(m)
+		}
+		return core.OK(core.LIST(values))
+
+	case "submatch":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("<regexp> submatch s")
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		match := re.FindStringSubmatch(s)
+		if match == nil {
+			return core.OK(core.NIL)
+		}
+		return core.OK(submatchDict(re, match))
+
+	case "submatchAll":
+		if len(args) != 3 && len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> submatchAll s ?n?")
+		}
+		result, s, n := stringAndCount(args)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		matches := re.FindAllStringSubmatch(s, n)
+		values := make([]core.Value, len(matches))
+		for i, m := range matches {
+			values[i] = submatchDict(re, m)
+		}
+		return core.OK(core.LIST(values))
+
+	case "split":
+		if len(args) != 3 && len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> split s ?n?")
+		}
+		result, s, n := stringAndCount(args)
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		parts := re.Split(s, n)
+		values := make([]core.Value, len(parts))
+		for i, part := range parts {
+			values[i] = core.STR(part)
+		}
+		return core.OK(core.LIST(values))
+
+	case "expand":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> expand template s")
+		}
+		result, template := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, s := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(expandAll(re, template, s)))
+
+	case "replace", "replaceLiteral":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> " + subcommand + " s repl")
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, repl := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if subcommand == "replaceLiteral" {
+			return core.OK(core.STR(re.ReplaceAllLiteralString(s, repl)))
+		}
+		return core.OK(core.STR(re.ReplaceAllString(s, repl)))
+
+	case "replaceFunc":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("<regexp> replaceFunc s callback")
+		}
+		result, s := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		scope, ok := context.(*helena_dialect.Scope)
+		if !ok {
+			return core.ERROR("callbacks need a Helena scope")
+		}
+		return replaceAllStringFunc(scope, re, s, args[3])
+
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+
+// Get the string and optional match count of `<regexp> subcommand s ?n?`
+func stringAndCount(args []core.Value) (core.Result, string, int) {
+	result, s := core.ValueToString(args[2])
+	if result.Code != core.ResultCode_OK {
+		return result, "", 0
+	}
+	if len(args) < 4 {
+		return core.OK(core.NIL), s, -1
+	}
+	result, n := core.ValueToInteger(args[3])
+	if result.Code != core.ResultCode_OK {
+		return result, "", 0
+	}
+	return core.OK(core.NIL), s, int(n)
+}
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"helena/core"
+	"helena/helena_dialect"
 	"helena/native/go_regexp"
 )
 
@@ -874,6 +875,136 @@ var _ = Describe("Go regexp", func() {
 			})
 		})
 
+		Describe("`ExpandString`", func() {
+			Specify("Go documentation example", func() {
+				// https://pkg.go.dev/regexp#example-Regexp.ExpandString
+				variableResolver.register(
+					"pattern",
+					evaluate(`go:regexp Compile "(?m)(?P<key>\\w+):\\s+(?P<value>\\w+)$"`),
+				)
+				variableResolver.register("content", STR("\n\t# comment line\n\toption1: value1\n\toption2: value2\n"))
+				variableResolver.register(
+					"match",
+					evaluate(`go:regexp FindStringSubmatchIndex $pattern $content`),
+				)
+				Expect(evaluate(`go:regexp ExpandString $pattern """$key=$value""" $content $match`)).To(Equal(
+					STR("option1=value1"),
+				))
+			})
+			It("should skip unmatched subexpressions", func() {
+				Expect(evaluate("go:regexp ExpandString [go:regexp Compile {(a)|(b)}] {[$1][$2]} b (0 1 -1 -1 0 1)")).To(Equal(
+					STR("[][b]"),
+				))
+			})
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					Expect(execute("go:regexp ExpandString a b c")).To(Equal(
+						ERROR(`wrong # args: should be "regexp ExpandString re template src match"`),
+					))
+					Expect(execute("go:regexp ExpandString a b c d e")).To(Equal(
+						ERROR(`wrong # args: should be "regexp ExpandString re template src match"`),
+					))
+				})
+				Specify("invalid regexp value", func() {
+					Expect(execute("go:regexp ExpandString a b c d")).To(Equal(
+						ERROR("invalid regexp value"),
+					))
+				})
+				Specify("invalid match value", func() {
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] a b a")).To(Equal(
+						ERROR("invalid list"),
+					))
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] a b (a)")).To(Equal(
+						ERROR(`invalid integer "a"`),
+					))
+				})
+				Specify("invalid match indices", func() {
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] {$1} abc (0 1 0)")).To(Equal(
+						ERROR("invalid match value: odd number of indices"),
+					))
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] {$1} abc (0 10 0 20)")).To(Equal(
+						ERROR("invalid match value: indices 0 10 out of range"),
+					))
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] {$1} abc (2 1)")).To(Equal(
+						ERROR("invalid match value: indices 2 1 out of range"),
+					))
+					Expect(execute("go:regexp ExpandString [go:regexp Compile {}] {$1} abc (-1 2)")).To(Equal(
+						ERROR("invalid match value: indices -1 2 out of range"),
+					))
+				})
+			})
+		})
+		Describe("`FindStringSubmatchDict`", func() {
+			It("should key submatches by name or index", func() {
+				variableResolver.register(
+					"re",
+					evaluate(`go:regexp Compile """(?P<first>[a-zA-Z]+) ([a-zA-Z]+)"""`),
+				)
+				Expect(evaluate(`go:regexp FindStringSubmatchDict $re "Alan Turing"`)).To(Equal(
+					DICT(map[string]core.Value{
+						"0":     STR("Alan Turing"),
+						"first": STR("Alan"),
+						"2":     STR("Turing"),
+					}),
+				))
+			})
+			It("should return nil when nothing matches", func() {
+				Expect(evaluate(`go:regexp FindStringSubmatchDict [go:regexp Compile a] b`)).To(Equal(NIL))
+			})
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					Expect(execute("go:regexp FindStringSubmatchDict a")).To(Equal(
+						ERROR(`wrong # args: should be "regexp FindStringSubmatchDict re s"`),
+					))
+				})
+				Specify("invalid regexp value", func() {
+					Expect(execute("go:regexp FindStringSubmatchDict a b")).To(Equal(
+						ERROR("invalid regexp value"),
+					))
+				})
+			})
+		})
+		Describe("`FindAllStringSubmatchDict`", func() {
+			It("should return a list of dicts", func() {
+				variableResolver.register(
+					"re",
+					evaluate(`go:regexp Compile "(?P<key>\\w+)=(?P<value>\\w+)"`),
+				)
+				Expect(evaluate(`go:regexp FindAllStringSubmatchDict $re "a=1 b=2 c=3" 2`)).To(Equal(
+					LIST([]core.Value{
+						DICT(map[string]core.Value{"0": STR("a=1"), "key": STR("a"), "value": STR("1")}),
+						DICT(map[string]core.Value{"0": STR("b=2"), "key": STR("b"), "value": STR("2")}),
+					}),
+				))
+				Expect(evaluate(`go:regexp FindAllStringSubmatchDict $re "" -1`)).To(Equal(NIL))
+			})
+			Describe("Exceptions", func() {
+				Specify("wrong arity", func() {
+					Expect(execute("go:regexp FindAllStringSubmatchDict a b")).To(Equal(
+						ERROR(`wrong # args: should be "regexp FindAllStringSubmatchDict re s n"`),
+					))
+				})
+				Specify("invalid regexp value", func() {
+					Expect(execute("go:regexp FindAllStringSubmatchDict a b c")).To(Equal(
+						ERROR("invalid regexp value"),
+					))
+				})
+			})
+		})
+		Describe("`ReplaceAllStringFunc`", func() {
+			Specify("Exceptions", func() {
+				Expect(execute("go:regexp ReplaceAllStringFunc a b")).To(Equal(
+					ERROR(`wrong # args: should be "regexp ReplaceAllStringFunc re src callback"`),
+				))
+				Expect(execute("go:regexp ReplaceAllStringFunc a b c")).To(Equal(
+					ERROR("invalid regexp value"),
+				))
+				Expect(execute("go:regexp ReplaceAllStringFunc [go:regexp Compile a] b c")).To(Equal(
+					ERROR("callbacks need a Helena scope"),
+				))
+			})
+		})
+
 		Describe("Exceptions", func() {
 			Specify("wrong arity", func() {
 				Expect(execute("go:regexp")).To(Equal(
@@ -889,3 +1020,170 @@ var _ = Describe("Go regexp", func() {
 
 	})
 })
+
+var _ = Describe("Go regexp in Helena scopes", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *helena_dialect.Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("regexp", go_regexp.RegexpCmd{})
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("`ReplaceAllStringFunc`", func() {
+		It("should call the callback for each match", func() {
+			evaluate(`set re [regexp Compile "a+"]`)
+			evaluate(`proc upper {s} {string $s length}`)
+			Expect(evaluate(`regexp ReplaceAllStringFunc $re "baaacaad" upper`)).To(Equal(STR("b3c2d")))
+			Expect(evaluate(`regexp ReplaceAllStringFunc $re "bcd" upper`)).To(Equal(STR("bcd")))
+		})
+		It("should accept command prefixes", func() {
+			evaluate(`set re [regexp Compile """[0-9]+"""]`)
+			Expect(evaluate(`regexp ReplaceAllStringFunc $re "a1b22" (string "<" append)`)).To(Equal(STR("a<1b<22")))
+		})
+		It("should support yielding callbacks", func() {
+			evaluate(`set re [regexp Compile """[a-z]"""]`)
+			evaluate(`proc repl {s} {yield $s}`)
+			process := prepareScript(`regexp ReplaceAllStringFunc $re "a-b" repl`)
+
+			result := process.Run()
+			Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+			Expect(result.Value).To(Equal(STR("a")))
+			process.YieldBack(STR("A"))
+
+			result = process.Run()
+			Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+			Expect(result.Value).To(Equal(STR("b")))
+			process.YieldBack(STR("B"))
+
+			Expect(process.Run()).To(Equal(OK(STR("A-B"))))
+		})
+		Describe("Exceptions", func() {
+			Specify("callback errors", func() {
+				evaluate(`set re [regexp Compile "a"]`)
+				evaluate(`proc fail {s} {error "cannot replace $s"}`)
+				Expect(execute(`regexp ReplaceAllStringFunc $re "bab" fail`)).To(Equal(ERROR("cannot replace a")))
+			})
+			Specify("invalid callback results", func() {
+				evaluate(`set re [regexp Compile "a"]`)
+				evaluate(`proc bad {s} {list ($s)}`)
+				Expect(execute(`regexp ReplaceAllStringFunc $re "bab" bad`)).To(Equal(
+					ERROR("value has no string representation"),
+				))
+			})
+		})
+	})
+
+	Describe("RegexpValue commands", func() {
+		BeforeEach(func() {
+			evaluate(`set re [regexp Compile """(?P<key>\w+)=(?P<value>\w+)"""]`)
+		})
+
+		Specify("usage", func() {
+			Expect(evaluate(`$re`)).To(Equal(evaluate(`get re`)))
+			Expect(evaluate(`$re subcommands`)).To(Equal(LIST([]core.Value{
+				STR("subcommands"), STR("string"), STR("names"), STR("match"),
+				STR("find"), STR("findAll"), STR("submatch"), STR("submatchAll"),
+				STR("split"), STR("expand"), STR("replace"), STR("replaceLiteral"),
+				STR("replaceFunc"),
+			})))
+		})
+		Specify("`string`", func() {
+			Expect(evaluate(`$re string`)).To(Equal(STR(`(?P<key>\w+)=(?P<value>\w+)`)))
+		})
+		Specify("`names`", func() {
+			Expect(evaluate(`$re names`)).To(Equal(LIST([]core.Value{STR(""), STR("key"), STR("value")})))
+		})
+		Specify("`match`", func() {
+			Expect(evaluate(`$re match "a=1"`)).To(Equal(TRUE))
+			Expect(evaluate(`$re match "a"`)).To(Equal(FALSE))
+		})
+		Specify("`find`", func() {
+			Expect(evaluate(`$re find "x a=1 b=2"`)).To(Equal(STR("a=1")))
+			Expect(evaluate(`$re find "x"`)).To(Equal(NIL))
+		})
+		Specify("`findAll`", func() {
+			Expect(evaluate(`$re findAll "a=1 b=2 c=3"`)).To(Equal(
+				LIST([]core.Value{STR("a=1"), STR("b=2"), STR("c=3")}),
+			))
+			Expect(evaluate(`$re findAll "a=1 b=2 c=3" 2`)).To(Equal(
+				LIST([]core.Value{STR("a=1"), STR("b=2")}),
+			))
+			Expect(evaluate(`$re findAll "x"`)).To(Equal(LIST([]core.Value{})))
+		})
+		Specify("`submatch`", func() {
+			Expect(evaluate(`$re submatch "x a=1"`)).To(Equal(DICT(map[string]core.Value{
+				"0": STR("a=1"), "key": STR("a"), "value": STR("1"),
+			})))
+			Expect(evaluate(`dict [$re submatch "b=2"] get key`)).To(Equal(STR("b")))
+			Expect(evaluate(`$re submatch "x"`)).To(Equal(NIL))
+		})
+		Specify("`submatchAll`", func() {
+			Expect(evaluate(`$re submatchAll "a=1 b=2"`)).To(Equal(LIST([]core.Value{
+				DICT(map[string]core.Value{"0": STR("a=1"), "key": STR("a"), "value": STR("1")}),
+				DICT(map[string]core.Value{"0": STR("b=2"), "key": STR("b"), "value": STR("2")}),
+			})))
+			Expect(evaluate(`$re submatchAll "a=1 b=2" 1`)).To(Equal(LIST([]core.Value{
+				DICT(map[string]core.Value{"0": STR("a=1"), "key": STR("a"), "value": STR("1")}),
+			})))
+		})
+		Specify("`split`", func() {
+			evaluate(`set sep [regexp Compile """,\s*"""]`)
+			Expect(evaluate(`$sep split "a, b,c"`)).To(Equal(LIST([]core.Value{STR("a"), STR("b"), STR("c")})))
+			Expect(evaluate(`$sep split "a, b,c" 2`)).To(Equal(LIST([]core.Value{STR("a"), STR("b,c")})))
+		})
+		Specify("`expand`", func() {
+			Expect(evaluate(`$re expand """$value:$key;""" "a=1 b=2"`)).To(Equal(STR("1:a;2:b;")))
+			Expect(evaluate(`$re expand """$value""" "x"`)).To(Equal(STR("")))
+		})
+		Specify("`replace`", func() {
+			Expect(evaluate(`$re replace "a=1 b=2" """${value}=$key"""`)).To(Equal(STR("1=a 2=b")))
+		})
+		Specify("`replaceLiteral`", func() {
+			Expect(evaluate(`$re replaceLiteral "a=1 b=2" """$key"""`)).To(Equal(STR("$key $key")))
+		})
+		Specify("`replaceFunc`", func() {
+			evaluate(`proc swap {s} {[regexp Compile "(.*)=(.*)"] replace $s """$2=$1"""}`)
+			Expect(evaluate(`$re replaceFunc "a=1 b=2" swap`)).To(Equal(STR("1=a 2=b")))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute(`$re subcommands a`)).To(Equal(ERROR(`wrong # args: should be "<regexp> subcommands"`)))
+				Expect(execute(`$re match`)).To(Equal(ERROR(`wrong # args: should be "<regexp> match s"`)))
+				Expect(execute(`$re findAll a b c`)).To(Equal(ERROR(`wrong # args: should be "<regexp> findAll s ?n?"`)))
+				Expect(execute(`$re expand a`)).To(Equal(ERROR(`wrong # args: should be "<regexp> expand template s"`)))
+				Expect(execute(`$re replaceLiteral a`)).To(Equal(ERROR(`wrong # args: should be "<regexp> replaceLiteral s repl"`)))
+				Expect(execute(`$re replaceFunc a`)).To(Equal(ERROR(`wrong # args: should be "<regexp> replaceFunc s callback"`)))
+			})
+			Specify("invalid subcommand name", func() {
+				Expect(execute(`$re []`)).To(Equal(ERROR("invalid subcommand name")))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute(`$re unknownSubcommand`)).To(Equal(ERROR(`unknown subcommand "unknownSubcommand"`)))
+			})
+			Specify("invalid values", func() {
+				Expect(execute(`$re match []`)).To(Equal(ERROR("value has no string representation")))
+				Expect(execute(`$re findAll a b`)).To(Equal(ERROR(`invalid integer "b"`)))
+			})
+		})
+	})
+})
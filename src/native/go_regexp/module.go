@@ -12,6 +12,7 @@ func Initmodule() *helena_dialect.Module {
 	scope := helena_dialect.NewRootScope(nil)
 	exports := &helena_dialect.Exports{}
 	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Regular expressions from the Go regexp package")
 	exportCommand(module, "regexp", RegexpCmd{})
 	return module
 }
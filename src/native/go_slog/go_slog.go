@@ -1,8 +1,13 @@
 package go_slog
 
 import (
+	"context"
 	"helena/core"
+	"helena/helena_dialect"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 )
 
 func asString(value core.Value) (s string, ok bool) {
@@ -13,6 +18,383 @@ func asString(value core.Value) (s string, ok bool) {
 	return "", false
 }
 
+// Output of loggers writing to the standard error, can be replaced for testing
+var Stderr io.Writer = os.Stderr
+
+// Output of loggers writing to the standard output, can be replaced for
+// testing
+var Stdout io.Writer = os.Stdout
+
+//
+// Attributes
+//
+
+// Convert a value to an attribute value
+//
+// Dicts become groups, lists and tuples become slices
+func valueToAttrValue(value core.Value) (core.Result, slog.Value) {
+	switch value.Type() {
+	case core.ValueType_NIL:
+		return core.OK(core.NIL), slog.AnyValue(nil)
+	case core.ValueType_BOOLEAN:
+		return core.OK(core.NIL), slog.BoolValue(value.(core.BooleanValue).Value)
+	case core.ValueType_INTEGER:
+		return core.OK(core.NIL), slog.Int64Value(value.(core.IntegerValue).Value)
+	case core.ValueType_REAL:
+		return core.OK(core.NIL), slog.Float64Value(value.(core.RealValue).Value)
+	case core.ValueType_DICTIONARY:
+		result, attrs := valueToAttrs(value)
+		if result.Code != core.ResultCode_OK {
+			return result, slog.Value{}
+		}
+		return core.OK(core.NIL), slog.GroupValue(attrs...)
+	case core.ValueType_LIST, core.ValueType_TUPLE:
+		_, values := helena_dialect.ValueToArray(value)
+		items := make([]any, len(values))
+		for i, value := range values {
+			result, item := valueToAttrValue(value)
+			if result.Code != core.ResultCode_OK {
+				return result, slog.Value{}
+			}
+			items[i] = item.Any()
+		}
+		return core.OK(core.NIL), slog.AnyValue(items)
+	}
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return result, slog.Value{}
+	}
+	return core.OK(core.NIL), slog.StringValue(s)
+}
+
+// Convert a value to a list of attributes
+//
+// Attributes are given either as a dict, in key order, or as a list or tuple
+// of key-value pairs, in list order. Dict values become groups
+func valueToAttrs(value core.Value) (core.Result, []slog.Attr) {
+	var keys []string
+	var values []core.Value
+	if value.Type() == core.ValueType_DICTIONARY {
+		entries := value.(core.DictionaryValue).Map
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			values = append(values, entries[key])
+		}
+	} else {
+		result, pairs := helena_dialect.ValueToArray(value)
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid attributes"), nil
+		}
+		if len(pairs)%2 != 0 {
+			return core.ERROR("invalid key-value list"), nil
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			result, key := core.ValueToString(pairs[i])
+			if result.Code != core.ResultCode_OK {
+				return core.ERROR("invalid key"), nil
+			}
+			keys = append(keys, key)
+			values = append(values, pairs[i+1])
+		}
+	}
+	attrs := make([]slog.Attr, len(keys))
+	for i, key := range keys {
+		result, value := valueToAttrValue(values[i])
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		attrs[i] = slog.Attr{Key: key, Value: value}
+	}
+	return core.OK(core.NIL), attrs
+}
+
+// Convert a value to a level
+//
+// Levels are either names such as `info` or `WARN+2`, or integers
+func valueToLevel(value core.Value) (core.Result, slog.Level) {
+	if i, ok := value.(core.IntegerValue); ok {
+		return core.OK(core.NIL), slog.Level(i.Value)
+	}
+	result, s := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid level"), 0
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return core.ERROR(`invalid level "` + s + `"`), 0
+	}
+	return core.OK(core.NIL), level
+}
+
+//
+// Loggers
+//
+
+var LoggerValueType core.CustomValueType = core.CustomValueType{Name: "go:Logger"}
+
+// Logger with bound attributes and groups
+//
+// Logger values act as commands with the same logging methods as `slog`
+type LoggerValue struct {
+	logger *slog.Logger
+
+	// Level of loggers created with `slog New`, nil otherwise
+	level *slog.LevelVar
+
+	// Output file of loggers created with `slog New`, if any, shared with
+	// derived loggers
+	file *os.File
+}
+
+func NewLoggerValue(logger *slog.Logger) LoggerValue {
+	return LoggerValue{logger: logger}
+}
+
+func (value LoggerValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value LoggerValue) CustomType() core.CustomValueType {
+	return LoggerValueType
+}
+func (value LoggerValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("Logger")
+}
+func (value LoggerValue) Command() core.Command {
+	return loggerCommand{value}
+}
+
+// Return the wrapped Go logger
+func (value LoggerValue) Logger() *slog.Logger {
+	return value.logger
+}
+
+// Close the output file of the logger, if any
+//
+// Later records of the logger and its derived loggers are discarded
+func (value LoggerValue) Close() error {
+	if value.file == nil {
+		return nil
+	}
+	return value.file.Close()
+}
+
+// Create a logger from options
+//
+// Options are given as a dict with the following keys, all optional:
+//
+//   - `handler`: `text` (default) or `json`
+//   - `output`: `stderr` (default), `stdout` or a file path to append to;
+//     files stay open until the logger is closed with `<logger> Close`
+//   - `level`: minimum level, `info` by default
+//   - `source`: whether to add the Go source position, false by default
+func newLogger(value core.Value) core.Result {
+	options := &slog.HandlerOptions{Level: new(slog.LevelVar)}
+	handler := "text"
+	outputPath := "stderr"
+	if value != nil {
+		result, entries := helena_dialect.ValueToMap(value)
+		if result.Code != core.ResultCode_OK {
+			return core.ERROR("invalid options")
+		}
+		for key, value := range entries {
+			switch key {
+			case "handler":
+				handler, _ = asString(value)
+				if handler != "text" && handler != "json" {
+					return core.ERROR(`invalid handler, should be "text" or "json"`)
+				}
+
+			case "output":
+				path, ok := asString(value)
+				if !ok {
+					return core.ERROR("invalid output")
+				}
+				outputPath = path
+
+			case "level":
+				result, level := valueToLevel(value)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				options.Level.(*slog.LevelVar).Set(level)
+
+			case "source":
+				result, source := core.ValueToBoolean(value)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				options.AddSource = source
+
+			default:
+				return core.ERROR(`unknown option "` + key + `"`)
+			}
+		}
+	}
+	var output io.Writer
+	var file *os.File
+	switch outputPath {
+	case "stderr":
+		output = Stderr
+	case "stdout":
+		output = Stdout
+	default:
+		f, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		output, file = f, f
+	}
+	var logger *slog.Logger
+	if handler == "json" {
+		logger = slog.New(slog.NewJSONHandler(output, options))
+	} else {
+		logger = slog.New(slog.NewTextHandler(output, options))
+	}
+	return core.OK(LoggerValue{logger, options.Level.(*slog.LevelVar), file})
+}
+
+// Execute the logging methods common to `slog` and logger values
+func executeLoggerMethod(
+	value LoggerValue,
+	prefix string,
+	method string,
+	args []core.Value,
+) (core.Result, bool) {
+	logger := value.logger
+	switch method {
+	case "Debug", "Info", "Warn", "Error":
+		// https://pkg.go.dev/log/slog#Logger.Info
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "` + prefix + ` ` + method + ` msg ?attrs?"`), true
+		}
+		level := map[string]slog.Level{
+			"Debug": slog.LevelDebug,
+			"Info":  slog.LevelInfo,
+			"Warn":  slog.LevelWarn,
+			"Error": slog.LevelError,
+		}[method]
+		return log(logger, level, args[2], args[3:]), true
+
+	case "Log":
+		// https://pkg.go.dev/log/slog#Logger.Log
+		if len(args) != 4 && len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "` + prefix + ` Log level msg ?attrs?"`), true
+		}
+		result, level := valueToLevel(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result, true
+		}
+		return log(logger, level, args[3], args[4:]), true
+
+	case "Enabled":
+		// https://pkg.go.dev/log/slog#Logger.Enabled
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "` + prefix + ` Enabled level"`), true
+		}
+		result, level := valueToLevel(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result, true
+		}
+		return core.OK(core.BOOL(logger.Enabled(context.Background(), level))), true
+
+	case "With":
+		// https://pkg.go.dev/log/slog#Logger.With
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "` + prefix + ` With attrs"`), true
+		}
+		result, attrs := valueToAttrs(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result, true
+		}
+		return core.OK(LoggerValue{
+			slog.New(logger.Handler().WithAttrs(attrs)),
+			value.level,
+			value.file,
+		}), true
+
+	case "WithGroup":
+		// https://pkg.go.dev/log/slog#Logger.WithGroup
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "` + prefix + ` WithGroup name"`), true
+		}
+		result, name := core.ValueToString(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result, true
+		}
+		return core.OK(LoggerValue{logger.WithGroup(name), value.level, value.file}), true
+	}
+	return core.OK(core.NIL), false
+}
+
+func log(logger *slog.Logger, level slog.Level, msgValue core.Value, attrsValues []core.Value) core.Result {
+	msg, ok := asString(msgValue)
+	if !ok {
+		return core.ERROR("invalid message")
+	}
+	var attrs []slog.Attr
+	if len(attrsValues) > 0 {
+		var result core.Result
+		result, attrs = valueToAttrs(attrsValues[0])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+	}
+	logger.LogAttrs(context.Background(), level, msg, attrs...)
+	return core.OK(core.NIL)
+}
+
+type loggerCommand struct {
+	value LoggerValue
+}
+
+func (cmd loggerCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	if result, ok := executeLoggerMethod(cmd.value, "<logger>", method, args); ok {
+		return result
+	}
+	switch method {
+	case "SetLevel":
+		// https://pkg.go.dev/log/slog#LevelVar.Set
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<logger> SetLevel level"`)
+		}
+		if cmd.value.level == nil {
+			return core.ERROR("logger level cannot be changed")
+		}
+		result, level := valueToLevel(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		cmd.value.level.Set(level)
+		return core.OK(core.NIL)
+
+	case "Close":
+		// https://pkg.go.dev/os#File.Close
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<logger> Close"`)
+		}
+		if err := cmd.value.Close(); err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(core.NIL)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
 type SlogCmd struct{}
 
 func (SlogCmd) Execute(args []core.Value, _ any) core.Result {
@@ -23,32 +405,53 @@ func (SlogCmd) Execute(args []core.Value, _ any) core.Result {
 	if !ok {
 		return core.ERROR("invalid method name")
 	}
-	// TODO Attrs and Values https://pkg.go.dev/log/slog@go1.22.2#hdr-Attrs_and_Values
+	// https://pkg.go.dev/log/slog
+	if result, ok := executeLoggerMethod(NewLoggerValue(slog.Default()), "slog", method, args); ok {
+		return result
+	}
 	switch method {
-	case "Debug":
-		if len(args) < 3 {
-			return core.ERROR(`wrong # args: should be "slog Debug msg"`)
-		}
-		msg, _ := asString((args[2]))
-		slog.Debug(msg)
-	case "Error":
-		if len(args) < 3 {
-			return core.ERROR(`wrong # args: should be "slog Error msg"`)
-		}
-		msg, _ := asString((args[2]))
-		slog.Error(msg, "toto", 1)
-	case "Info":
-		if len(args) < 3 {
-			return core.ERROR(`wrong # args: should be "slog Info msg"`)
-		}
-		msg, _ := asString((args[2]))
-		slog.Info(msg)
-	case "Warn":
-		if len(args) < 3 {
-			return core.ERROR(`wrong # args: should be "slog Warn msg"`)
-		}
-		msg, _ := asString((args[2]))
-		slog.Warn(msg)
+	case "New":
+		// https://pkg.go.dev/log/slog#New
+		switch len(args) {
+		case 2:
+			return newLogger(nil)
+		case 3:
+			return newLogger(args[2])
+		default:
+			return core.ERROR(`wrong # args: should be "slog New ?options?"`)
+		}
+
+	case "Default":
+		// https://pkg.go.dev/log/slog#Default
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "slog Default"`)
+		}
+		return core.OK(NewLoggerValue(slog.Default()))
+
+	case "SetDefault":
+		// https://pkg.go.dev/log/slog#SetDefault
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "slog SetDefault logger"`)
+		}
+		logger, ok := args[2].(LoggerValue)
+		if !ok {
+			return core.ERROR("invalid logger value")
+		}
+		slog.SetDefault(logger.logger)
+		return core.OK(core.NIL)
+
+	case "SetLogLoggerLevel":
+		// https://pkg.go.dev/log/slog#SetLogLoggerLevel
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "slog SetLogLoggerLevel level"`)
+		}
+		result, level := valueToLevel(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(slog.SetLogLoggerLevel(level).String()))
+
+	default:
+		return core.ERROR("unsupported method " + method)
 	}
-	return core.OK(core.NIL)
 }
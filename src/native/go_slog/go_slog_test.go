@@ -0,0 +1,278 @@
+package go_slog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_slog"
+)
+
+var _ = Describe("Go slog", func() {
+	var rootScope *helena_dialect.Scope
+	var stderr *bytes.Buffer
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	// Return the logged JSON records without their time
+	records := func() []map[string]any {
+		records := []map[string]any{}
+		decoder := json.NewDecoder(stderr)
+		for {
+			record := map[string]any{}
+			if err := decoder.Decode(&record); err == io.EOF {
+				break
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			delete(record, "time")
+			records = append(records, record)
+		}
+		return records
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		rootScope.RegisterNamedCommand("slog", go_slog.SlogCmd{})
+
+		stderr = &bytes.Buffer{}
+		DeferCleanup(func(w io.Writer) { go_slog.Stderr = w }, go_slog.Stderr)
+		go_slog.Stderr = stderr
+		DeferCleanup(slog.SetDefault, slog.Default())
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("go:LoggerValue", func() {
+		Specify("type should be custom", func() {
+			value := go_slog.NewLoggerValue(slog.Default())
+			Expect(core.IsCustomValue(value, go_slog.LoggerValueType)).To(BeTrue())
+		})
+		Specify("display", func() {
+			value := go_slog.NewLoggerValue(slog.Default())
+			Expect(value.Display(nil)).To(Equal(`{#{Logger}#}`))
+		})
+	})
+
+	Describe("Loggers", func() {
+		Specify("`New`", func() {
+			evaluate("set logger [slog New]")
+			Expect(execute("$logger Info hello")).To(Equal(OK(NIL)))
+			Expect(stderr.String()).To(MatchRegexp(`^time=\S+ level=INFO msg=hello\n$`))
+		})
+		Specify("JSON handler", func() {
+			evaluate("set logger [slog New (handler json)]")
+			evaluate("$logger Warn hello")
+			Expect(records()).To(Equal([]map[string]any{{"level": "WARN", "msg": "hello"}}))
+		})
+		Specify("file output", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "log.json")
+			rootScope.SetNamedVariable("path", STR(path))
+			evaluate("set logger [slog New [dict (handler json output $path)]]")
+			evaluate("$logger Info first")
+			evaluate("set other [slog New [dict (handler json output $path)]]")
+			evaluate("$other Info second")
+			Expect(execute("$logger Close")).To(Equal(OK(NIL)))
+			Expect(execute("$other Close")).To(Equal(OK(NIL)))
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			stderr.Write(data)
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "INFO", "msg": "first"},
+				{"level": "INFO", "msg": "second"},
+			}))
+		})
+		Specify("`Close`", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "log.json")
+			rootScope.SetNamedVariable("path", STR(path))
+			evaluate("set logger [slog New [dict (handler json output $path)]]")
+			evaluate("set child [$logger WithGroup g]")
+			Expect(execute("$child Close")).To(Equal(OK(NIL)))
+			Expect(execute("$logger Info discarded")).To(Equal(OK(NIL)))
+			Expect(execute("$logger Close")).To(Equal(ERROR("close " + path + ": file already closed")))
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(BeEmpty())
+		})
+		It("should not close standard outputs", func() {
+			Expect(execute("[slog New] Close")).To(Equal(OK(NIL)))
+			Expect(execute("[slog Default] Close")).To(Equal(OK(NIL)))
+		})
+		Specify("`Default` / `SetDefault`", func() {
+			evaluate("slog SetDefault [slog New (handler json)]")
+			Expect(execute("slog Info hello")).To(Equal(OK(NIL)))
+			evaluate("[slog Default] Info world")
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "INFO", "msg": "hello"},
+				{"level": "INFO", "msg": "world"},
+			}))
+		})
+		Specify("`Log`", func() {
+			evaluate("set logger [slog New (handler json level debug)]")
+			evaluate("$logger Log debug a")
+			evaluate("$logger Log WARN+2 b")
+			evaluate("$logger Log [+ 0 12] c")
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "DEBUG", "msg": "a"},
+				{"level": "WARN+2", "msg": "b"},
+				{"level": "ERROR+4", "msg": "c"},
+			}))
+		})
+	})
+
+	Describe("Attributes", func() {
+		BeforeEach(func() {
+			evaluate("set logger [slog New (handler json)]")
+		})
+
+		Specify("tuples", func() {
+			evaluate("$logger Info hello (user alice count [+ 1 2] ratio [* 0.5 1] ok [true] none [])")
+			Expect(records()).To(Equal([]map[string]any{{
+				"level": "INFO",
+				"msg":   "hello",
+				"user":  "alice",
+				"count": 3.0,
+				"ratio": 0.5,
+				"ok":    true,
+				"none":  nil,
+			}}))
+		})
+		Specify("dicts", func() {
+			evaluate("$logger Info hello [dict (b 2 a 1)]")
+			Expect(stderr.String()).To(ContainSubstring(`"a":"1","b":"2"`))
+		})
+		Specify("groups", func() {
+			evaluate("$logger Info hello (request [dict (method GET path /)])")
+			Expect(records()).To(Equal([]map[string]any{{
+				"level":   "INFO",
+				"msg":     "hello",
+				"request": map[string]any{"method": "GET", "path": "/"},
+			}}))
+		})
+		Specify("lists", func() {
+			evaluate("$logger Info hello (tags [list (a b)])")
+			Expect(records()).To(Equal([]map[string]any{{
+				"level": "INFO",
+				"msg":   "hello",
+				"tags":  []any{"a", "b"},
+			}}))
+		})
+		Specify("text handler", func() {
+			evaluate("set logger [slog New]")
+			evaluate("$logger Error failed (code 42 request [dict (id 1)])")
+			Expect(stderr.String()).To(HaveSuffix(" level=ERROR msg=failed code=42 request.id=1\n"))
+		})
+	})
+
+	Describe("`With` / `WithGroup`", func() {
+		It("should return loggers with bound attributes", func() {
+			evaluate("set logger [slog New (handler json)]")
+			evaluate("set child [$logger With (service api)]")
+			Expect(core.IsCustomValue(evaluate("get child"), go_slog.LoggerValueType)).To(BeTrue())
+			evaluate("$child Info hello (id 1)")
+			evaluate("$logger Info world")
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "INFO", "msg": "hello", "service": "api", "id": "1"},
+				{"level": "INFO", "msg": "world"},
+			}))
+		})
+		It("should return loggers with groups", func() {
+			evaluate("set logger [[slog New (handler json)] WithGroup request]")
+			evaluate("$logger Info hello (id 1)")
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "INFO", "msg": "hello", "request": map[string]any{"id": "1"}},
+			}))
+		})
+		It("should apply to the default logger", func() {
+			evaluate("slog SetDefault [slog New (handler json)]")
+			evaluate("[slog With (service api)] Info hello")
+			Expect(records()).To(Equal([]map[string]any{
+				{"level": "INFO", "msg": "hello", "service": "api"},
+			}))
+		})
+	})
+
+	Describe("Levels", func() {
+		Specify("`Enabled`", func() {
+			evaluate("set logger [slog New (level warn)]")
+			Expect(evaluate("$logger Enabled info")).To(Equal(FALSE))
+			Expect(evaluate("$logger Enabled WARN")).To(Equal(TRUE))
+			Expect(evaluate("$logger Enabled error")).To(Equal(TRUE))
+		})
+		Specify("filtering", func() {
+			evaluate("set logger [slog New (handler json level warn)]")
+			evaluate("$logger Info skipped")
+			evaluate("$logger Warn logged")
+			Expect(records()).To(Equal([]map[string]any{{"level": "WARN", "msg": "logged"}}))
+		})
+		Specify("`SetLevel`", func() {
+			evaluate("set logger [slog New (handler json)]")
+			evaluate("set child [$logger With (a 1)]")
+			Expect(evaluate("$child Enabled debug")).To(Equal(FALSE))
+			Expect(execute("$logger SetLevel debug")).To(Equal(OK(NIL)))
+			Expect(evaluate("$child Enabled debug")).To(Equal(TRUE))
+			Expect(execute("[slog Default] SetLevel debug")).To(Equal(ERROR("logger level cannot be changed")))
+		})
+		Specify("`SetLogLoggerLevel`", func() {
+			DeferCleanup(slog.SetLogLoggerLevel, slog.LevelInfo)
+			Expect(evaluate("slog Enabled debug")).To(Equal(FALSE))
+			Expect(evaluate("slog SetLogLoggerLevel debug")).To(Equal(STR("INFO")))
+			Expect(evaluate("slog Enabled debug")).To(Equal(TRUE))
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("slog")).To(Equal(ERROR(`wrong # args: should be "slog method ?arg ...?"`)))
+			Expect(execute("slog Info")).To(Equal(ERROR(`wrong # args: should be "slog Info msg ?attrs?"`)))
+			Expect(execute("slog Log info")).To(Equal(ERROR(`wrong # args: should be "slog Log level msg ?attrs?"`)))
+			Expect(execute("slog With")).To(Equal(ERROR(`wrong # args: should be "slog With attrs"`)))
+			Expect(execute("slog New a b")).To(Equal(ERROR(`wrong # args: should be "slog New ?options?"`)))
+			Expect(execute("[slog New] Warn")).To(Equal(ERROR(`wrong # args: should be "<logger> Warn msg ?attrs?"`)))
+			Expect(execute("[slog New] SetLevel")).To(Equal(ERROR(`wrong # args: should be "<logger> SetLevel level"`)))
+			Expect(execute("[slog New] Close a")).To(Equal(ERROR(`wrong # args: should be "<logger> Close"`)))
+		})
+		Specify("invalid attributes", func() {
+			Expect(execute("slog Info msg (a)")).To(Equal(ERROR("invalid key-value list")))
+			Expect(execute("slog Info msg a")).To(Equal(ERROR("invalid attributes")))
+			Expect(execute("slog Info msg (() b)")).To(Equal(ERROR("invalid key")))
+		})
+		Specify("invalid level", func() {
+			Expect(execute("slog Enabled foo")).To(Equal(ERROR(`invalid level "foo"`)))
+			Expect(execute("slog New (level foo)")).To(Equal(ERROR(`invalid level "foo"`)))
+		})
+		Specify("invalid options", func() {
+			Expect(execute("slog New (foo bar)")).To(Equal(ERROR(`unknown option "foo"`)))
+			Expect(execute("slog New (handler xml)")).To(Equal(ERROR(`invalid handler, should be "text" or "json"`)))
+			Expect(execute("slog New a")).To(Equal(ERROR("invalid options")))
+		})
+		Specify("invalid logger value", func() {
+			Expect(execute("slog SetDefault a")).To(Equal(ERROR("invalid logger value")))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("slog Foo")).To(Equal(ERROR("unsupported method Foo")))
+			Expect(execute("[slog New] Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
@@ -0,0 +1,4 @@
+// Package go_strings binds the Go strings package as the go:strings module
+package go_strings
+
+//go:generate go run helena/cmd/helena-bindgen -package strings -tests go_strings_test.go
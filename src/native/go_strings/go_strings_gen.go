@@ -0,0 +1,1953 @@
+// Code generated by helena-bindgen; DO NOT EDIT.
+
+package go_strings
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+	"strings"
+)
+
+/**
+ * Main static module entry point.
+ */
+func Initmodule() *helena_dialect.Module {
+	scope := helena_dialect.NewRootScope(nil)
+	exports := &helena_dialect.Exports{}
+	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Bindings for the Go strings package")
+	exportCommand(module, "strings", StringsCmd{})
+	exportCommand(module, "Builder", BuilderCmd{})
+	exportCommand(module, "Reader", ReaderCmd{})
+	exportCommand(module, "Replacer", ReplacerCmd{})
+	return module
+}
+
+func exportCommand(module *helena_dialect.Module, name string, cmd core.Command) {
+	module.Scope.RegisterNamedCommand(name, cmd)
+	(*module.Exports)[name] = core.STR(name)
+}
+
+func listValue[T any](values []T, fn func(T) core.Value) core.Value {
+	if values == nil {
+		return core.NIL
+	}
+	list := make([]core.Value, len(values))
+	for i, value := range values {
+		list[i] = fn(value)
+	}
+	return core.LIST(list)
+}
+
+type BuilderValue struct {
+	value *strings.Builder
+}
+
+var BuilderValueType core.CustomValueType = core.CustomValueType{Name: "go:strings.Builder"}
+
+func NewBuilderValue(value *strings.Builder) BuilderValue {
+	return BuilderValue{value}
+}
+
+func (BuilderValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+
+func (BuilderValue) CustomType() core.CustomValueType {
+	return BuilderValueType
+}
+
+func (value BuilderValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("strings.Builder")
+}
+
+// Return the wrapped Go value
+func (value BuilderValue) Value() *strings.Builder {
+	return value.value
+}
+
+func builderValueOrNil(value *strings.Builder) core.Value {
+	if value == nil {
+		return core.NIL
+	}
+	return NewBuilderValue(value)
+}
+
+type ReaderValue struct {
+	value *strings.Reader
+}
+
+var ReaderValueType core.CustomValueType = core.CustomValueType{Name: "go:strings.Reader"}
+
+func NewReaderValue(value *strings.Reader) ReaderValue {
+	return ReaderValue{value}
+}
+
+func (ReaderValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+
+func (ReaderValue) CustomType() core.CustomValueType {
+	return ReaderValueType
+}
+
+func (value ReaderValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("strings.Reader")
+}
+
+// Return the wrapped Go value
+func (value ReaderValue) Value() *strings.Reader {
+	return value.value
+}
+
+func readerValueOrNil(value *strings.Reader) core.Value {
+	if value == nil {
+		return core.NIL
+	}
+	return NewReaderValue(value)
+}
+
+type ReplacerValue struct {
+	value *strings.Replacer
+}
+
+var ReplacerValueType core.CustomValueType = core.CustomValueType{Name: "go:strings.Replacer"}
+
+func NewReplacerValue(value *strings.Replacer) ReplacerValue {
+	return ReplacerValue{value}
+}
+
+func (ReplacerValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+
+func (ReplacerValue) CustomType() core.CustomValueType {
+	return ReplacerValueType
+}
+
+func (value ReplacerValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("strings.Replacer")
+}
+
+// Return the wrapped Go value
+func (value ReplacerValue) Value() *strings.Replacer {
+	return value.value
+}
+
+func replacerValueOrNil(value *strings.Replacer) core.Value {
+	if value == nil {
+		return core.NIL
+	}
+	return NewReplacerValue(value)
+}
+
+var stringsCmdSubcommands = helena_dialect.NewSubcommands([]string{
+	"Clone",
+	"Compare",
+	"Contains",
+	"ContainsAny",
+	"ContainsRune",
+	"Count",
+	"Cut",
+	"CutPrefix",
+	"CutSuffix",
+	"EqualFold",
+	"Fields",
+	"HasPrefix",
+	"HasSuffix",
+	"Index",
+	"IndexAny",
+	"IndexByte",
+	"IndexRune",
+	"Join",
+	"LastIndex",
+	"LastIndexAny",
+	"LastIndexByte",
+	"NewReader",
+	"NewReplacer",
+	"Repeat",
+	"Replace",
+	"ReplaceAll",
+	"Split",
+	"SplitAfter",
+	"SplitAfterN",
+	"SplitN",
+	"Title",
+	"ToLower",
+	"ToTitle",
+	"ToUpper",
+	"ToValidUTF8",
+	"Trim",
+	"TrimLeft",
+	"TrimPrefix",
+	"TrimRight",
+	"TrimSpace",
+	"TrimSuffix",
+})
+
+type StringsCmd struct{}
+
+func (StringsCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR("strings method ?arg ...?")
+	}
+	result, method := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid method name")
+	}
+	switch method {
+	case "Clone":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings Clone s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.Clone(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "Compare":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Compare a b")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Compare(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "Contains":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Contains s substr")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Contains(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "ContainsAny":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings ContainsAny s chars")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.ContainsAny(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "ContainsRune":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings ContainsRune s r")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 rune
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(rune(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a1 = rune(v)
+		}
+		r0 := strings.ContainsRune(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "Count":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Count s substr")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Count(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "Cut":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Cut s sep")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0, r1, r2 := strings.Cut(a0, a1)
+		return core.OK(core.LIST([]core.Value{core.STR(string(r0)), core.STR(string(r1)), core.BOOL(bool(r2))}))
+
+	case "CutPrefix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings CutPrefix s prefix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0, r1 := strings.CutPrefix(a0, a1)
+		return core.OK(core.LIST([]core.Value{core.STR(string(r0)), core.BOOL(bool(r1))}))
+
+	case "CutSuffix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings CutSuffix s suffix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0, r1 := strings.CutSuffix(a0, a1)
+		return core.OK(core.LIST([]core.Value{core.STR(string(r0)), core.BOOL(bool(r1))}))
+
+	case "EqualFold":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings EqualFold s t")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.EqualFold(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "Fields":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings Fields s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.Fields(a0)
+		return core.OK(listValue(r0, func(value string) core.Value { return core.STR(string(value)) }))
+
+	case "HasPrefix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings HasPrefix s prefix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.HasPrefix(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "HasSuffix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings HasSuffix s suffix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.HasSuffix(a0, a1)
+		return core.OK(core.BOOL(bool(r0)))
+
+	case "Index":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Index s substr")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Index(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "IndexAny":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings IndexAny s chars")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.IndexAny(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "IndexByte":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings IndexByte s c")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 byte
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if v < 0 || uint64(byte(v)) != uint64(v) {
+				return core.ERROR("integer out of range")
+			}
+			a1 = byte(v)
+		}
+		r0 := strings.IndexByte(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "IndexRune":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings IndexRune s r")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 rune
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(rune(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a1 = rune(v)
+		}
+		r0 := strings.IndexRune(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "Join":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Join elems sep")
+		}
+		var a0 []string
+		{
+			result, values2 := helena_dialect.ValueToArray(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = make([]string, len(values2))
+			for i2, value2 := range values2 {
+				{
+					result, v := core.ValueToString(value2)
+					if result.Code != core.ResultCode_OK {
+						return result
+					}
+					a0[i2] = string(v)
+				}
+			}
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Join(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "LastIndex":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings LastIndex s substr")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.LastIndex(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "LastIndexAny":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings LastIndexAny s chars")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.LastIndexAny(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "LastIndexByte":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings LastIndexByte s c")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 byte
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if v < 0 || uint64(byte(v)) != uint64(v) {
+				return core.ERROR("integer out of range")
+			}
+			a1 = byte(v)
+		}
+		r0 := strings.LastIndexByte(a0, a1)
+		return core.OK(core.INT(int64(r0)))
+
+	case "NewReader":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings NewReader s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.NewReader(a0)
+		return core.OK(readerValueOrNil(r0))
+
+	case "NewReplacer":
+		if len(args) < 2 {
+			return helena_dialect.ARITY_ERROR("strings NewReplacer ?oldnew ...?")
+		}
+		a0 := make([]string, len(args)-2)
+		for i, arg := range args[2:] {
+			{
+				result, v := core.ValueToString(arg)
+				if result.Code != core.ResultCode_OK {
+					return result
+				}
+				a0[i] = string(v)
+			}
+		}
+		r0 := strings.NewReplacer(a0...)
+		return core.OK(replacerValueOrNil(r0))
+
+	case "Repeat":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Repeat s count")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 int
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a1 = int(v)
+		}
+		r0 := strings.Repeat(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "Replace":
+		if len(args) != 6 {
+			return helena_dialect.ARITY_ERROR("strings Replace s old new n")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		var a2 string
+		{
+			result, v := core.ValueToString(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a2 = string(v)
+		}
+		var a3 int
+		{
+			result, v := core.ValueToInteger(args[5])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a3 = int(v)
+		}
+		r0 := strings.Replace(a0, a1, a2, a3)
+		return core.OK(core.STR(string(r0)))
+
+	case "ReplaceAll":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR("strings ReplaceAll s old new")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		var a2 string
+		{
+			result, v := core.ValueToString(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a2 = string(v)
+		}
+		r0 := strings.ReplaceAll(a0, a1, a2)
+		return core.OK(core.STR(string(r0)))
+
+	case "Split":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Split s sep")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Split(a0, a1)
+		return core.OK(listValue(r0, func(value string) core.Value { return core.STR(string(value)) }))
+
+	case "SplitAfter":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings SplitAfter s sep")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.SplitAfter(a0, a1)
+		return core.OK(listValue(r0, func(value string) core.Value { return core.STR(string(value)) }))
+
+	case "SplitAfterN":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR("strings SplitAfterN s sep n")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		var a2 int
+		{
+			result, v := core.ValueToInteger(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a2 = int(v)
+		}
+		r0 := strings.SplitAfterN(a0, a1, a2)
+		return core.OK(listValue(r0, func(value string) core.Value { return core.STR(string(value)) }))
+
+	case "SplitN":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR("strings SplitN s sep n")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		var a2 int
+		{
+			result, v := core.ValueToInteger(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a2 = int(v)
+		}
+		r0 := strings.SplitN(a0, a1, a2)
+		return core.OK(listValue(r0, func(value string) core.Value { return core.STR(string(value)) }))
+
+	case "Title":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings Title s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.Title(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "ToLower":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings ToLower s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.ToLower(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "ToTitle":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings ToTitle s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.ToTitle(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "ToUpper":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings ToUpper s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.ToUpper(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "ToValidUTF8":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings ToValidUTF8 s replacement")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.ToValidUTF8(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "Trim":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings Trim s cutset")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.Trim(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "TrimLeft":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings TrimLeft s cutset")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.TrimLeft(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "TrimPrefix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings TrimPrefix s prefix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.TrimPrefix(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "TrimRight":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings TrimRight s cutset")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.TrimRight(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	case "TrimSpace":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("strings TrimSpace s")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		r0 := strings.TrimSpace(a0)
+		return core.OK(core.STR(string(r0)))
+
+	case "TrimSuffix":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("strings TrimSuffix s suffix")
+		}
+		var a0 string
+		{
+			result, v := core.ValueToString(args[2])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a0 = string(v)
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := strings.TrimSuffix(a0, a1)
+		return core.OK(core.STR(string(r0)))
+
+	default:
+		return core.ERROR(`unknown method "` + method + `"`)
+	}
+}
+
+func (StringsCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR("strings method ?arg ...?"))
+	}
+	result, method := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid method name")
+	}
+	var signature string
+	maxArgs := -1
+	switch method {
+	case "Clone":
+		signature, maxArgs = "strings Clone s", 3
+	case "Compare":
+		signature, maxArgs = "strings Compare a b", 4
+	case "Contains":
+		signature, maxArgs = "strings Contains s substr", 4
+	case "ContainsAny":
+		signature, maxArgs = "strings ContainsAny s chars", 4
+	case "ContainsRune":
+		signature, maxArgs = "strings ContainsRune s r", 4
+	case "Count":
+		signature, maxArgs = "strings Count s substr", 4
+	case "Cut":
+		signature, maxArgs = "strings Cut s sep", 4
+	case "CutPrefix":
+		signature, maxArgs = "strings CutPrefix s prefix", 4
+	case "CutSuffix":
+		signature, maxArgs = "strings CutSuffix s suffix", 4
+	case "EqualFold":
+		signature, maxArgs = "strings EqualFold s t", 4
+	case "Fields":
+		signature, maxArgs = "strings Fields s", 3
+	case "HasPrefix":
+		signature, maxArgs = "strings HasPrefix s prefix", 4
+	case "HasSuffix":
+		signature, maxArgs = "strings HasSuffix s suffix", 4
+	case "Index":
+		signature, maxArgs = "strings Index s substr", 4
+	case "IndexAny":
+		signature, maxArgs = "strings IndexAny s chars", 4
+	case "IndexByte":
+		signature, maxArgs = "strings IndexByte s c", 4
+	case "IndexRune":
+		signature, maxArgs = "strings IndexRune s r", 4
+	case "Join":
+		signature, maxArgs = "strings Join elems sep", 4
+	case "LastIndex":
+		signature, maxArgs = "strings LastIndex s substr", 4
+	case "LastIndexAny":
+		signature, maxArgs = "strings LastIndexAny s chars", 4
+	case "LastIndexByte":
+		signature, maxArgs = "strings LastIndexByte s c", 4
+	case "NewReader":
+		signature, maxArgs = "strings NewReader s", 3
+	case "NewReplacer":
+		signature = "strings NewReplacer ?oldnew ...?"
+	case "Repeat":
+		signature, maxArgs = "strings Repeat s count", 4
+	case "Replace":
+		signature, maxArgs = "strings Replace s old new n", 6
+	case "ReplaceAll":
+		signature, maxArgs = "strings ReplaceAll s old new", 5
+	case "Split":
+		signature, maxArgs = "strings Split s sep", 4
+	case "SplitAfter":
+		signature, maxArgs = "strings SplitAfter s sep", 4
+	case "SplitAfterN":
+		signature, maxArgs = "strings SplitAfterN s sep n", 5
+	case "SplitN":
+		signature, maxArgs = "strings SplitN s sep n", 5
+	case "Title":
+		signature, maxArgs = "strings Title s", 3
+	case "ToLower":
+		signature, maxArgs = "strings ToLower s", 3
+	case "ToTitle":
+		signature, maxArgs = "strings ToTitle s", 3
+	case "ToUpper":
+		signature, maxArgs = "strings ToUpper s", 3
+	case "ToValidUTF8":
+		signature, maxArgs = "strings ToValidUTF8 s replacement", 4
+	case "Trim":
+		signature, maxArgs = "strings Trim s cutset", 4
+	case "TrimLeft":
+		signature, maxArgs = "strings TrimLeft s cutset", 4
+	case "TrimPrefix":
+		signature, maxArgs = "strings TrimPrefix s prefix", 4
+	case "TrimRight":
+		signature, maxArgs = "strings TrimRight s cutset", 4
+	case "TrimSpace":
+		signature, maxArgs = "strings TrimSpace s", 3
+	case "TrimSuffix":
+		signature, maxArgs = "strings TrimSuffix s suffix", 4
+	default:
+		return core.ERROR(`unknown method "` + method + `"`)
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+var builderCmdSubcommands = helena_dialect.NewSubcommands([]string{
+	"subcommands",
+	"new",
+	"Cap",
+	"Grow",
+	"Len",
+	"Reset",
+	"String",
+	"Write",
+	"WriteByte",
+	"WriteRune",
+	"WriteString",
+})
+
+type BuilderCmd struct{}
+
+func (BuilderCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR("Builder subcommand ?arg ...?")
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Builder subcommands")
+		}
+		return core.OK(builderCmdSubcommands.List)
+
+	case "new":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Builder new")
+		}
+		return core.OK(NewBuilderValue(&strings.Builder{}))
+
+	case "Cap":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Builder Cap b")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.Cap()
+		return core.OK(core.INT(int64(r0)))
+
+	case "Grow":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Builder Grow b n")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		var a1 int
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a1 = int(v)
+		}
+		a0.Grow(a1)
+		return core.OK(core.NIL)
+
+	case "Len":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Builder Len b")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.Len()
+		return core.OK(core.INT(int64(r0)))
+
+	case "Reset":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Builder Reset b")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		a0.Reset()
+		return core.OK(core.NIL)
+
+	case "String":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Builder String b")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.String()
+		return core.OK(core.STR(string(r0)))
+
+	case "Write":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Builder Write b p")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		var a1 []byte
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = []byte(v)
+		}
+		r0, r1 := a0.Write(a1)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "WriteByte":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Builder WriteByte b c")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		var a1 byte
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if v < 0 || uint64(byte(v)) != uint64(v) {
+				return core.ERROR("integer out of range")
+			}
+			a1 = byte(v)
+		}
+		r0 := a0.WriteByte(a1)
+		if r0 != nil {
+			return core.ERROR(r0.Error())
+		}
+		return core.OK(core.NIL)
+
+	case "WriteRune":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Builder WriteRune b r")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		var a1 rune
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(rune(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a1 = rune(v)
+		}
+		r0, r1 := a0.WriteRune(a1)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "WriteString":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Builder WriteString b s")
+		}
+		var a0 *strings.Builder
+		{
+			v, ok := args[2].(BuilderValue)
+			if !ok {
+				return core.ERROR("invalid Builder value")
+			}
+			a0 = v.value
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0, r1 := a0.WriteString(a1)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+
+func (BuilderCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR("Builder subcommand ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid subcommand name")
+	}
+	var signature string
+	maxArgs := -1
+	switch subcommand {
+	case "subcommands":
+		signature, maxArgs = "Builder subcommands", 2
+	case "new":
+		signature, maxArgs = "Builder new", 2
+	case "Cap":
+		signature, maxArgs = "Builder Cap b", 3
+	case "Grow":
+		signature, maxArgs = "Builder Grow b n", 4
+	case "Len":
+		signature, maxArgs = "Builder Len b", 3
+	case "Reset":
+		signature, maxArgs = "Builder Reset b", 3
+	case "String":
+		signature, maxArgs = "Builder String b", 3
+	case "Write":
+		signature, maxArgs = "Builder Write b p", 4
+	case "WriteByte":
+		signature, maxArgs = "Builder WriteByte b c", 4
+	case "WriteRune":
+		signature, maxArgs = "Builder WriteRune b r", 4
+	case "WriteString":
+		signature, maxArgs = "Builder WriteString b s", 4
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+var readerCmdSubcommands = helena_dialect.NewSubcommands([]string{
+	"subcommands",
+	"new",
+	"Len",
+	"Read",
+	"ReadAt",
+	"ReadByte",
+	"ReadRune",
+	"Reset",
+	"Seek",
+	"Size",
+	"UnreadByte",
+	"UnreadRune",
+})
+
+type ReaderCmd struct{}
+
+func (ReaderCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR("Reader subcommand ?arg ...?")
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Reader subcommands")
+		}
+		return core.OK(readerCmdSubcommands.List)
+
+	case "new":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Reader new")
+		}
+		return core.OK(NewReaderValue(&strings.Reader{}))
+
+	case "Len":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader Len r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.Len()
+		return core.OK(core.INT(int64(r0)))
+
+	case "Read":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Reader Read r b")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		var a1 []byte
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = []byte(v)
+		}
+		r0, r1 := a0.Read(a1)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "ReadAt":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR("Reader ReadAt r b off")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		var a1 []byte
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = []byte(v)
+		}
+		var a2 int64
+		{
+			result, v := core.ValueToInteger(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a2 = int64(v)
+		}
+		r0, r1 := a0.ReadAt(a1, a2)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "ReadByte":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader ReadByte r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0, r1 := a0.ReadByte()
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "ReadRune":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader ReadRune r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0, r1, r2 := a0.ReadRune()
+		if r2 != nil {
+			return core.ERROR(r2.Error())
+		}
+		return core.OK(core.LIST([]core.Value{core.INT(int64(r0)), core.INT(int64(r1))}))
+
+	case "Reset":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Reader Reset r s")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		a0.Reset(a1)
+		return core.OK(core.NIL)
+
+	case "Seek":
+		if len(args) != 5 {
+			return helena_dialect.ARITY_ERROR("Reader Seek r offset whence")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		var a1 int64
+		{
+			result, v := core.ValueToInteger(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = int64(v)
+		}
+		var a2 int
+		{
+			result, v := core.ValueToInteger(args[4])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			if int64(int(v)) != v {
+				return core.ERROR("integer out of range")
+			}
+			a2 = int(v)
+		}
+		r0, r1 := a0.Seek(a1, a2)
+		if r1 != nil {
+			return core.ERROR(r1.Error())
+		}
+		return core.OK(core.INT(int64(r0)))
+
+	case "Size":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader Size r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.Size()
+		return core.OK(core.INT(int64(r0)))
+
+	case "UnreadByte":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader UnreadByte r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.UnreadByte()
+		if r0 != nil {
+			return core.ERROR(r0.Error())
+		}
+		return core.OK(core.NIL)
+
+	case "UnreadRune":
+		if len(args) != 3 {
+			return helena_dialect.ARITY_ERROR("Reader UnreadRune r")
+		}
+		var a0 *strings.Reader
+		{
+			v, ok := args[2].(ReaderValue)
+			if !ok {
+				return core.ERROR("invalid Reader value")
+			}
+			a0 = v.value
+		}
+		r0 := a0.UnreadRune()
+		if r0 != nil {
+			return core.ERROR(r0.Error())
+		}
+		return core.OK(core.NIL)
+
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+
+func (ReaderCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR("Reader subcommand ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid subcommand name")
+	}
+	var signature string
+	maxArgs := -1
+	switch subcommand {
+	case "subcommands":
+		signature, maxArgs = "Reader subcommands", 2
+	case "new":
+		signature, maxArgs = "Reader new", 2
+	case "Len":
+		signature, maxArgs = "Reader Len r", 3
+	case "Read":
+		signature, maxArgs = "Reader Read r b", 4
+	case "ReadAt":
+		signature, maxArgs = "Reader ReadAt r b off", 5
+	case "ReadByte":
+		signature, maxArgs = "Reader ReadByte r", 3
+	case "ReadRune":
+		signature, maxArgs = "Reader ReadRune r", 3
+	case "Reset":
+		signature, maxArgs = "Reader Reset r s", 4
+	case "Seek":
+		signature, maxArgs = "Reader Seek r offset whence", 5
+	case "Size":
+		signature, maxArgs = "Reader Size r", 3
+	case "UnreadByte":
+		signature, maxArgs = "Reader UnreadByte r", 3
+	case "UnreadRune":
+		signature, maxArgs = "Reader UnreadRune r", 3
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+var replacerCmdSubcommands = helena_dialect.NewSubcommands([]string{
+	"subcommands",
+	"new",
+	"Replace",
+})
+
+type ReplacerCmd struct{}
+
+func (ReplacerCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return helena_dialect.ARITY_ERROR("Replacer subcommand ?arg ...?")
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return helena_dialect.INVALID_SUBCOMMAND_ERROR()
+	}
+	switch subcommand {
+	case "subcommands":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Replacer subcommands")
+		}
+		return core.OK(replacerCmdSubcommands.List)
+
+	case "new":
+		if len(args) != 2 {
+			return helena_dialect.ARITY_ERROR("Replacer new")
+		}
+		return core.OK(NewReplacerValue(&strings.Replacer{}))
+
+	case "Replace":
+		if len(args) != 4 {
+			return helena_dialect.ARITY_ERROR("Replacer Replace r s")
+		}
+		var a0 *strings.Replacer
+		{
+			v, ok := args[2].(ReplacerValue)
+			if !ok {
+				return core.ERROR("invalid Replacer value")
+			}
+			a0 = v.value
+		}
+		var a1 string
+		{
+			result, v := core.ValueToString(args[3])
+			if result.Code != core.ResultCode_OK {
+				return result
+			}
+			a1 = string(v)
+		}
+		r0 := a0.Replace(a1)
+		return core.OK(core.STR(string(r0)))
+
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+}
+
+func (ReplacerCmd) Help(args []core.Value, _ core.CommandHelpOptions, _ any) core.Result {
+	if len(args) < 2 {
+		return core.OK(core.STR("Replacer subcommand ?arg ...?"))
+	}
+	result, subcommand := core.ValueToString(args[1])
+	if result.Code != core.ResultCode_OK {
+		return core.ERROR("invalid subcommand name")
+	}
+	var signature string
+	maxArgs := -1
+	switch subcommand {
+	case "subcommands":
+		signature, maxArgs = "Replacer subcommands", 2
+	case "new":
+		signature, maxArgs = "Replacer new", 2
+	case "Replace":
+		signature, maxArgs = "Replacer Replace r s", 4
+	default:
+		return helena_dialect.UNKNOWN_SUBCOMMAND_ERROR(subcommand)
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return helena_dialect.ARITY_ERROR(signature)
+	}
+	return core.OK(core.STR(signature))
+}
+
+// Unsupported signatures:
+//   - ContainsFunc
+//   - FieldsFunc
+//   - IndexFunc
+//   - LastIndexFunc
+//   - Map
+//   - ToLowerSpecial
+//   - ToTitleSpecial
+//   - ToUpperSpecial
+//   - TrimFunc
+//   - TrimLeftFunc
+//   - TrimRightFunc
+//   - Reader.WriteTo
+//   - Replacer.WriteString
+// Requires a Go version later than go1.23:
+//   - CutLast
+//   - FieldsFuncSeq
+//   - FieldsSeq
+//   - Lines
+//   - SplitAfterSeq
+//   - SplitSeq
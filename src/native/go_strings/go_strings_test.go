@@ -0,0 +1,313 @@
+package go_strings_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_strings"
+)
+
+func TestGoStrings(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "go:strings Suite")
+}
+
+var _ = Describe("go:strings", func() {
+	var rootScope *helena_dialect.Scope
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	execute := func(script string) core.Result {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script))).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		module := go_strings.Initmodule()
+		for name := range *module.Exports {
+			rootScope.RegisterNamedCommand(name, module.Scope.ResolveNamedCommand(name))
+		}
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("strings", func() {
+		Specify("usage", func() {
+			Expect(evaluate(`help strings`)).To(Equal(core.STR(`strings method ?arg ...?`)))
+			Expect(evaluate(`help strings Clone`)).To(Equal(core.STR(`strings Clone s`)))
+			Expect(evaluate(`help strings Compare`)).To(Equal(core.STR(`strings Compare a b`)))
+			Expect(evaluate(`help strings Contains`)).To(Equal(core.STR(`strings Contains s substr`)))
+			Expect(evaluate(`help strings ContainsAny`)).To(Equal(core.STR(`strings ContainsAny s chars`)))
+			Expect(evaluate(`help strings ContainsRune`)).To(Equal(core.STR(`strings ContainsRune s r`)))
+			Expect(evaluate(`help strings Count`)).To(Equal(core.STR(`strings Count s substr`)))
+			Expect(evaluate(`help strings Cut`)).To(Equal(core.STR(`strings Cut s sep`)))
+			Expect(evaluate(`help strings CutPrefix`)).To(Equal(core.STR(`strings CutPrefix s prefix`)))
+			Expect(evaluate(`help strings CutSuffix`)).To(Equal(core.STR(`strings CutSuffix s suffix`)))
+			Expect(evaluate(`help strings EqualFold`)).To(Equal(core.STR(`strings EqualFold s t`)))
+			Expect(evaluate(`help strings Fields`)).To(Equal(core.STR(`strings Fields s`)))
+			Expect(evaluate(`help strings HasPrefix`)).To(Equal(core.STR(`strings HasPrefix s prefix`)))
+			Expect(evaluate(`help strings HasSuffix`)).To(Equal(core.STR(`strings HasSuffix s suffix`)))
+			Expect(evaluate(`help strings Index`)).To(Equal(core.STR(`strings Index s substr`)))
+			Expect(evaluate(`help strings IndexAny`)).To(Equal(core.STR(`strings IndexAny s chars`)))
+			Expect(evaluate(`help strings IndexByte`)).To(Equal(core.STR(`strings IndexByte s c`)))
+			Expect(evaluate(`help strings IndexRune`)).To(Equal(core.STR(`strings IndexRune s r`)))
+			Expect(evaluate(`help strings Join`)).To(Equal(core.STR(`strings Join elems sep`)))
+			Expect(evaluate(`help strings LastIndex`)).To(Equal(core.STR(`strings LastIndex s substr`)))
+			Expect(evaluate(`help strings LastIndexAny`)).To(Equal(core.STR(`strings LastIndexAny s chars`)))
+			Expect(evaluate(`help strings LastIndexByte`)).To(Equal(core.STR(`strings LastIndexByte s c`)))
+			Expect(evaluate(`help strings NewReader`)).To(Equal(core.STR(`strings NewReader s`)))
+			Expect(evaluate(`help strings NewReplacer`)).To(Equal(core.STR(`strings NewReplacer ?oldnew ...?`)))
+			Expect(evaluate(`help strings Repeat`)).To(Equal(core.STR(`strings Repeat s count`)))
+			Expect(evaluate(`help strings Replace`)).To(Equal(core.STR(`strings Replace s old new n`)))
+			Expect(evaluate(`help strings ReplaceAll`)).To(Equal(core.STR(`strings ReplaceAll s old new`)))
+			Expect(evaluate(`help strings Split`)).To(Equal(core.STR(`strings Split s sep`)))
+			Expect(evaluate(`help strings SplitAfter`)).To(Equal(core.STR(`strings SplitAfter s sep`)))
+			Expect(evaluate(`help strings SplitAfterN`)).To(Equal(core.STR(`strings SplitAfterN s sep n`)))
+			Expect(evaluate(`help strings SplitN`)).To(Equal(core.STR(`strings SplitN s sep n`)))
+			Expect(evaluate(`help strings Title`)).To(Equal(core.STR(`strings Title s`)))
+			Expect(evaluate(`help strings ToLower`)).To(Equal(core.STR(`strings ToLower s`)))
+			Expect(evaluate(`help strings ToTitle`)).To(Equal(core.STR(`strings ToTitle s`)))
+			Expect(evaluate(`help strings ToUpper`)).To(Equal(core.STR(`strings ToUpper s`)))
+			Expect(evaluate(`help strings ToValidUTF8`)).To(Equal(core.STR(`strings ToValidUTF8 s replacement`)))
+			Expect(evaluate(`help strings Trim`)).To(Equal(core.STR(`strings Trim s cutset`)))
+			Expect(evaluate(`help strings TrimLeft`)).To(Equal(core.STR(`strings TrimLeft s cutset`)))
+			Expect(evaluate(`help strings TrimPrefix`)).To(Equal(core.STR(`strings TrimPrefix s prefix`)))
+			Expect(evaluate(`help strings TrimRight`)).To(Equal(core.STR(`strings TrimRight s cutset`)))
+			Expect(evaluate(`help strings TrimSpace`)).To(Equal(core.STR(`strings TrimSpace s`)))
+			Expect(evaluate(`help strings TrimSuffix`)).To(Equal(core.STR(`strings TrimSuffix s suffix`)))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute(`strings`)).To(Equal(core.ERROR(`wrong # args: should be "strings method ?arg ...?"`)))
+				Expect(execute(`strings Clone`)).To(Equal(core.ERROR(`wrong # args: should be "strings Clone s"`)))
+				Expect(execute(`strings Compare`)).To(Equal(core.ERROR(`wrong # args: should be "strings Compare a b"`)))
+				Expect(execute(`strings Contains`)).To(Equal(core.ERROR(`wrong # args: should be "strings Contains s substr"`)))
+				Expect(execute(`strings ContainsAny`)).To(Equal(core.ERROR(`wrong # args: should be "strings ContainsAny s chars"`)))
+				Expect(execute(`strings ContainsRune`)).To(Equal(core.ERROR(`wrong # args: should be "strings ContainsRune s r"`)))
+				Expect(execute(`strings Count`)).To(Equal(core.ERROR(`wrong # args: should be "strings Count s substr"`)))
+				Expect(execute(`strings Cut`)).To(Equal(core.ERROR(`wrong # args: should be "strings Cut s sep"`)))
+				Expect(execute(`strings CutPrefix`)).To(Equal(core.ERROR(`wrong # args: should be "strings CutPrefix s prefix"`)))
+				Expect(execute(`strings CutSuffix`)).To(Equal(core.ERROR(`wrong # args: should be "strings CutSuffix s suffix"`)))
+				Expect(execute(`strings EqualFold`)).To(Equal(core.ERROR(`wrong # args: should be "strings EqualFold s t"`)))
+				Expect(execute(`strings Fields`)).To(Equal(core.ERROR(`wrong # args: should be "strings Fields s"`)))
+				Expect(execute(`strings HasPrefix`)).To(Equal(core.ERROR(`wrong # args: should be "strings HasPrefix s prefix"`)))
+				Expect(execute(`strings HasSuffix`)).To(Equal(core.ERROR(`wrong # args: should be "strings HasSuffix s suffix"`)))
+				Expect(execute(`strings Index`)).To(Equal(core.ERROR(`wrong # args: should be "strings Index s substr"`)))
+				Expect(execute(`strings IndexAny`)).To(Equal(core.ERROR(`wrong # args: should be "strings IndexAny s chars"`)))
+				Expect(execute(`strings IndexByte`)).To(Equal(core.ERROR(`wrong # args: should be "strings IndexByte s c"`)))
+				Expect(execute(`strings IndexRune`)).To(Equal(core.ERROR(`wrong # args: should be "strings IndexRune s r"`)))
+				Expect(execute(`strings Join`)).To(Equal(core.ERROR(`wrong # args: should be "strings Join elems sep"`)))
+				Expect(execute(`strings LastIndex`)).To(Equal(core.ERROR(`wrong # args: should be "strings LastIndex s substr"`)))
+				Expect(execute(`strings LastIndexAny`)).To(Equal(core.ERROR(`wrong # args: should be "strings LastIndexAny s chars"`)))
+				Expect(execute(`strings LastIndexByte`)).To(Equal(core.ERROR(`wrong # args: should be "strings LastIndexByte s c"`)))
+				Expect(execute(`strings NewReader`)).To(Equal(core.ERROR(`wrong # args: should be "strings NewReader s"`)))
+				Expect(execute(`strings Repeat`)).To(Equal(core.ERROR(`wrong # args: should be "strings Repeat s count"`)))
+				Expect(execute(`strings Replace`)).To(Equal(core.ERROR(`wrong # args: should be "strings Replace s old new n"`)))
+				Expect(execute(`strings ReplaceAll`)).To(Equal(core.ERROR(`wrong # args: should be "strings ReplaceAll s old new"`)))
+				Expect(execute(`strings Split`)).To(Equal(core.ERROR(`wrong # args: should be "strings Split s sep"`)))
+				Expect(execute(`strings SplitAfter`)).To(Equal(core.ERROR(`wrong # args: should be "strings SplitAfter s sep"`)))
+				Expect(execute(`strings SplitAfterN`)).To(Equal(core.ERROR(`wrong # args: should be "strings SplitAfterN s sep n"`)))
+				Expect(execute(`strings SplitN`)).To(Equal(core.ERROR(`wrong # args: should be "strings SplitN s sep n"`)))
+				Expect(execute(`strings Title`)).To(Equal(core.ERROR(`wrong # args: should be "strings Title s"`)))
+				Expect(execute(`strings ToLower`)).To(Equal(core.ERROR(`wrong # args: should be "strings ToLower s"`)))
+				Expect(execute(`strings ToTitle`)).To(Equal(core.ERROR(`wrong # args: should be "strings ToTitle s"`)))
+				Expect(execute(`strings ToUpper`)).To(Equal(core.ERROR(`wrong # args: should be "strings ToUpper s"`)))
+				Expect(execute(`strings ToValidUTF8`)).To(Equal(core.ERROR(`wrong # args: should be "strings ToValidUTF8 s replacement"`)))
+				Expect(execute(`strings Trim`)).To(Equal(core.ERROR(`wrong # args: should be "strings Trim s cutset"`)))
+				Expect(execute(`strings TrimLeft`)).To(Equal(core.ERROR(`wrong # args: should be "strings TrimLeft s cutset"`)))
+				Expect(execute(`strings TrimPrefix`)).To(Equal(core.ERROR(`wrong # args: should be "strings TrimPrefix s prefix"`)))
+				Expect(execute(`strings TrimRight`)).To(Equal(core.ERROR(`wrong # args: should be "strings TrimRight s cutset"`)))
+				Expect(execute(`strings TrimSpace`)).To(Equal(core.ERROR(`wrong # args: should be "strings TrimSpace s"`)))
+				Expect(execute(`strings TrimSuffix`)).To(Equal(core.ERROR(`wrong # args: should be "strings TrimSuffix s suffix"`)))
+			})
+		})
+
+		Describe("Functions", func() {
+			It("should convert string arguments and results", func() {
+				Expect(evaluate(`strings ToUpper hello`)).To(Equal(core.STR("HELLO")))
+				Expect(evaluate(`strings TrimSpace "  a b  "`)).To(Equal(core.STR("a b")))
+				Expect(evaluate(`strings Repeat ab 3`)).To(Equal(core.STR("ababab")))
+			})
+			It("should convert boolean and integer results", func() {
+				Expect(evaluate(`strings HasPrefix hello he`)).To(Equal(core.TRUE))
+				Expect(evaluate(`strings Contains hello z`)).To(Equal(core.FALSE))
+				Expect(evaluate(`strings Index hello l`)).To(Equal(core.INT(2)))
+				Expect(evaluate(`strings Compare a b`)).To(Equal(core.INT(-1)))
+			})
+			It("should convert list arguments and results", func() {
+				Expect(evaluate(`strings Split a,b,c ,`)).To(Equal(
+					core.LIST([]core.Value{core.STR("a"), core.STR("b"), core.STR("c")}),
+				))
+				Expect(evaluate(`strings Join (a b c) -`)).To(Equal(core.STR("a-b-c")))
+			})
+			It("should return multiple results as lists", func() {
+				Expect(evaluate(`strings Cut key=value =`)).To(Equal(
+					core.LIST([]core.Value{core.STR("key"), core.STR("value"), core.TRUE}),
+				))
+			})
+			It("should return bound struct values", func() {
+				Expect(evaluate(`strings NewReader abc`).(core.CustomValue).CustomType()).To(
+					Equal(go_strings.ReaderValueType),
+				)
+			})
+		})
+
+		Describe("Exceptions", func() {
+			Specify("invalid argument types", func() {
+				Expect(execute(`strings Repeat ab x`)).To(Equal(core.ERROR(`invalid integer "x"`)))
+				Expect(execute(`strings Join a -`)).To(Equal(core.ERROR("invalid list")))
+			})
+			Specify("integer out of range", func() {
+				Expect(execute(`strings ContainsRune abc 4294967296`)).To(Equal(
+					core.ERROR("integer out of range"),
+				))
+			})
+			Specify("unknown method", func() {
+				Expect(execute(`strings unknownMethod`)).To(Equal(
+					core.ERROR(`unknown method "unknownMethod"`),
+				))
+			})
+		})
+	})
+
+	Describe("Builder", func() {
+		Specify("usage", func() {
+			Expect(evaluate(`help Builder`)).To(Equal(core.STR(`Builder subcommand ?arg ...?`)))
+			Expect(evaluate(`help Builder Cap`)).To(Equal(core.STR(`Builder Cap b`)))
+			Expect(evaluate(`help Builder Grow`)).To(Equal(core.STR(`Builder Grow b n`)))
+			Expect(evaluate(`help Builder Len`)).To(Equal(core.STR(`Builder Len b`)))
+			Expect(evaluate(`help Builder Reset`)).To(Equal(core.STR(`Builder Reset b`)))
+			Expect(evaluate(`help Builder String`)).To(Equal(core.STR(`Builder String b`)))
+			Expect(evaluate(`help Builder Write`)).To(Equal(core.STR(`Builder Write b p`)))
+			Expect(evaluate(`help Builder WriteByte`)).To(Equal(core.STR(`Builder WriteByte b c`)))
+			Expect(evaluate(`help Builder WriteRune`)).To(Equal(core.STR(`Builder WriteRune b r`)))
+			Expect(evaluate(`help Builder WriteString`)).To(Equal(core.STR(`Builder WriteString b s`)))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute(`Builder`)).To(Equal(core.ERROR(`wrong # args: should be "Builder subcommand ?arg ...?"`)))
+				Expect(execute(`Builder Cap`)).To(Equal(core.ERROR(`wrong # args: should be "Builder Cap b"`)))
+				Expect(execute(`Builder Grow`)).To(Equal(core.ERROR(`wrong # args: should be "Builder Grow b n"`)))
+				Expect(execute(`Builder Len`)).To(Equal(core.ERROR(`wrong # args: should be "Builder Len b"`)))
+				Expect(execute(`Builder Reset`)).To(Equal(core.ERROR(`wrong # args: should be "Builder Reset b"`)))
+				Expect(execute(`Builder String`)).To(Equal(core.ERROR(`wrong # args: should be "Builder String b"`)))
+				Expect(execute(`Builder Write`)).To(Equal(core.ERROR(`wrong # args: should be "Builder Write b p"`)))
+				Expect(execute(`Builder WriteByte`)).To(Equal(core.ERROR(`wrong # args: should be "Builder WriteByte b c"`)))
+				Expect(execute(`Builder WriteRune`)).To(Equal(core.ERROR(`wrong # args: should be "Builder WriteRune b r"`)))
+				Expect(execute(`Builder WriteString`)).To(Equal(core.ERROR(`wrong # args: should be "Builder WriteString b s"`)))
+			})
+		})
+
+		Describe("Values", func() {
+			It("should create new values", func() {
+				value := evaluate(`Builder new`)
+				Expect(value.(go_strings.BuilderValue).Value().Len()).To(Equal(0))
+				Expect(value.(go_strings.BuilderValue).Display(nil)).To(Equal(`{#{strings.Builder}#}`))
+			})
+			It("should pass values by reference to pointer methods", func() {
+				evaluate(`set b [Builder new]`)
+				Expect(evaluate(`Builder WriteString $b abc`)).To(Equal(core.INT(3)))
+				evaluate(`Builder WriteString $b def`)
+				Expect(evaluate(`Builder Len $b`)).To(Equal(core.INT(6)))
+				Expect(evaluate(`Builder String $b`)).To(Equal(core.STR("abcdef")))
+			})
+		})
+
+		Specify("subcommands", func() {
+			Expect(evaluate(`list [Builder subcommands] range 0 2`)).To(Equal(
+				core.LIST([]core.Value{core.STR("subcommands"), core.STR("new"), core.STR("Cap")}),
+			))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("invalid values", func() {
+				Expect(execute(`Builder Len abc`)).To(Equal(core.ERROR("invalid Builder value")))
+				Expect(execute(`Builder Len [Reader new]`)).To(Equal(core.ERROR("invalid Builder value")))
+			})
+			Specify("unknown subcommand", func() {
+				Expect(execute(`Builder unknownSubcommand`)).To(Equal(
+					core.ERROR(`unknown subcommand "unknownSubcommand"`),
+				))
+			})
+		})
+	})
+
+	Describe("Reader", func() {
+		Specify("usage", func() {
+			Expect(evaluate(`help Reader`)).To(Equal(core.STR(`Reader subcommand ?arg ...?`)))
+			Expect(evaluate(`help Reader Len`)).To(Equal(core.STR(`Reader Len r`)))
+			Expect(evaluate(`help Reader Read`)).To(Equal(core.STR(`Reader Read r b`)))
+			Expect(evaluate(`help Reader ReadAt`)).To(Equal(core.STR(`Reader ReadAt r b off`)))
+			Expect(evaluate(`help Reader ReadByte`)).To(Equal(core.STR(`Reader ReadByte r`)))
+			Expect(evaluate(`help Reader ReadRune`)).To(Equal(core.STR(`Reader ReadRune r`)))
+			Expect(evaluate(`help Reader Reset`)).To(Equal(core.STR(`Reader Reset r s`)))
+			Expect(evaluate(`help Reader Seek`)).To(Equal(core.STR(`Reader Seek r offset whence`)))
+			Expect(evaluate(`help Reader Size`)).To(Equal(core.STR(`Reader Size r`)))
+			Expect(evaluate(`help Reader UnreadByte`)).To(Equal(core.STR(`Reader UnreadByte r`)))
+			Expect(evaluate(`help Reader UnreadRune`)).To(Equal(core.STR(`Reader UnreadRune r`)))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute(`Reader`)).To(Equal(core.ERROR(`wrong # args: should be "Reader subcommand ?arg ...?"`)))
+				Expect(execute(`Reader Len`)).To(Equal(core.ERROR(`wrong # args: should be "Reader Len r"`)))
+				Expect(execute(`Reader Read`)).To(Equal(core.ERROR(`wrong # args: should be "Reader Read r b"`)))
+				Expect(execute(`Reader ReadAt`)).To(Equal(core.ERROR(`wrong # args: should be "Reader ReadAt r b off"`)))
+				Expect(execute(`Reader ReadByte`)).To(Equal(core.ERROR(`wrong # args: should be "Reader ReadByte r"`)))
+				Expect(execute(`Reader ReadRune`)).To(Equal(core.ERROR(`wrong # args: should be "Reader ReadRune r"`)))
+				Expect(execute(`Reader Reset`)).To(Equal(core.ERROR(`wrong # args: should be "Reader Reset r s"`)))
+				Expect(execute(`Reader Seek`)).To(Equal(core.ERROR(`wrong # args: should be "Reader Seek r offset whence"`)))
+				Expect(execute(`Reader Size`)).To(Equal(core.ERROR(`wrong # args: should be "Reader Size r"`)))
+				Expect(execute(`Reader UnreadByte`)).To(Equal(core.ERROR(`wrong # args: should be "Reader UnreadByte r"`)))
+				Expect(execute(`Reader UnreadRune`)).To(Equal(core.ERROR(`wrong # args: should be "Reader UnreadRune r"`)))
+			})
+		})
+
+		It("should read from strings", func() {
+			evaluate(`set r [strings NewReader héllo]`)
+			Expect(evaluate(`Reader Size $r`)).To(Equal(core.INT(6)))
+			Expect(evaluate(`Reader ReadByte $r`)).To(Equal(core.INT('h')))
+			Expect(evaluate(`Reader ReadRune $r`)).To(Equal(
+				core.LIST([]core.Value{core.INT('é'), core.INT(2)}),
+			))
+			Expect(evaluate(`Reader Len $r`)).To(Equal(core.INT(3)))
+		})
+		It("should return Go errors", func() {
+			evaluate(`set r [strings NewReader ""]`)
+			Expect(execute(`Reader ReadByte $r`)).To(Equal(core.ERROR("EOF")))
+		})
+	})
+
+	Describe("Replacer", func() {
+		Specify("usage", func() {
+			Expect(evaluate(`help Replacer`)).To(Equal(core.STR(`Replacer subcommand ?arg ...?`)))
+			Expect(evaluate(`help Replacer Replace`)).To(Equal(core.STR(`Replacer Replace r s`)))
+		})
+
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute(`Replacer`)).To(Equal(core.ERROR(`wrong # args: should be "Replacer subcommand ?arg ...?"`)))
+				Expect(execute(`Replacer Replace`)).To(Equal(core.ERROR(`wrong # args: should be "Replacer Replace r s"`)))
+			})
+		})
+
+		It("should replace strings", func() {
+			evaluate(`set r [strings NewReplacer a 1 b 2]`)
+			Expect(evaluate(`Replacer Replace $r abcab`)).To(Equal(core.STR("12c12")))
+		})
+	})
+})
@@ -0,0 +1,576 @@
+package go_time
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+	"time"
+)
+
+func asString(value core.Value) (s string, ok bool) {
+	result, s := core.ValueToString(value)
+	if result.Code == core.ResultCode_OK {
+		return s, true
+	}
+	return "", false
+}
+
+// Named layouts accepted in place of literal layouts
+//
+// https://pkg.go.dev/time#pkg-constants
+var layouts = map[string]string{
+	"Layout":      time.Layout,
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+func valueToLayout(value core.Value) (core.Result, string) {
+	result, layout := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return result, ""
+	}
+	if named, ok := layouts[layout]; ok {
+		return core.OK(core.NIL), named
+	}
+	return core.OK(core.NIL), layout
+}
+
+// Convert a time zone name to a location, e.g. `UTC`, `Local` or
+// `Europe/Paris` from the embedded tzdata
+func valueToLocation(value core.Value) (core.Result, *time.Location) {
+	result, name := core.ValueToString(value)
+	if result.Code != core.ResultCode_OK {
+		return result, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return core.ERROR(err.Error()), nil
+	}
+	return core.OK(core.NIL), location
+}
+
+func valueToTime(value core.Value) (core.Result, time.Time) {
+	if value, ok := value.(TimeValue); ok {
+		return core.OK(core.NIL), value.time
+	}
+	return core.ERROR("invalid time value"), time.Time{}
+}
+
+// Convert a duration value or string such as `1h30m` to a duration
+func valueToDuration(value core.Value) (core.Result, time.Duration) {
+	if value, ok := value.(DurationValue); ok {
+		return core.OK(core.NIL), value.duration
+	}
+	s, ok := asString(value)
+	if !ok {
+		return core.ERROR("invalid duration value"), 0
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return core.ERROR(`invalid duration "` + s + `"`), 0
+	}
+	return core.OK(core.NIL), duration
+}
+
+func valuesToIntegers(values []core.Value) (core.Result, []int) {
+	integers := make([]int, len(values))
+	for i, value := range values {
+		result, integer := core.ValueToInteger(value)
+		if result.Code != core.ResultCode_OK {
+			return result, nil
+		}
+		integers[i] = int(integer)
+	}
+	return core.OK(core.NIL), integers
+}
+
+//
+// Times
+//
+
+var TimeValueType = core.CustomValueType{Name: "go:Time"}
+
+type TimeValue struct {
+	time time.Time
+}
+
+func NewTimeValue(t time.Time) TimeValue {
+	return TimeValue{time: t}
+}
+
+func (value TimeValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value TimeValue) CustomType() core.CustomValueType {
+	return TimeValueType
+}
+func (value TimeValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("Time " + value.time.Format(time.RFC3339Nano))
+}
+func (value TimeValue) Command() core.Command {
+	return timeCommand{value}
+}
+
+// Return the wrapped Go time
+func (value TimeValue) Time() time.Time {
+	return value.time
+}
+
+type timeCommand struct {
+	value TimeValue
+}
+
+func (cmd timeCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	t := cmd.value.time
+	// https://pkg.go.dev/time#Time
+	switch method {
+	case "String", "Unix", "UnixMilli", "UnixNano", "IsZero", "Location",
+		"Year", "Month", "Day", "Hour", "Minute", "Second", "Nanosecond",
+		"Weekday", "YearDay", "UTC", "Local":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<time> ` + method + `"`)
+		}
+		switch method {
+		case "String":
+			return core.OK(core.STR(t.String()))
+		case "Unix":
+			return core.OK(core.INT(t.Unix()))
+		case "UnixMilli":
+			return core.OK(core.INT(t.UnixMilli()))
+		case "UnixNano":
+			return core.OK(core.INT(t.UnixNano()))
+		case "IsZero":
+			return core.OK(core.BOOL(t.IsZero()))
+		case "Location":
+			return core.OK(core.STR(t.Location().String()))
+		case "Year":
+			return core.OK(core.INT(int64(t.Year())))
+		case "Month":
+			return core.OK(core.INT(int64(t.Month())))
+		case "Day":
+			return core.OK(core.INT(int64(t.Day())))
+		case "Hour":
+			return core.OK(core.INT(int64(t.Hour())))
+		case "Minute":
+			return core.OK(core.INT(int64(t.Minute())))
+		case "Second":
+			return core.OK(core.INT(int64(t.Second())))
+		case "Nanosecond":
+			return core.OK(core.INT(int64(t.Nanosecond())))
+		case "Weekday":
+			return core.OK(core.INT(int64(t.Weekday())))
+		case "YearDay":
+			return core.OK(core.INT(int64(t.YearDay())))
+		case "UTC":
+			return core.OK(NewTimeValue(t.UTC()))
+		default: // "Local"
+			return core.OK(NewTimeValue(t.Local()))
+		}
+
+	case "Format":
+		// https://pkg.go.dev/time#Time.Format
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<time> Format layout"`)
+		}
+		result, layout := valueToLayout(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(core.STR(t.Format(layout)))
+
+	case "In":
+		// https://pkg.go.dev/time#Time.In
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<time> In location"`)
+		}
+		result, location := valueToLocation(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewTimeValue(t.In(location)))
+
+	case "Add", "Truncate", "Round":
+		// https://pkg.go.dev/time#Time.Add
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<time> ` + method + ` duration"`)
+		}
+		result, d := valueToDuration(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		switch method {
+		case "Add":
+			return core.OK(NewTimeValue(t.Add(d)))
+		case "Truncate":
+			return core.OK(NewTimeValue(t.Truncate(d)))
+		default: // "Round"
+			return core.OK(NewTimeValue(t.Round(d)))
+		}
+
+	case "AddDate":
+		// https://pkg.go.dev/time#Time.AddDate
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "<time> AddDate years months days"`)
+		}
+		result, values := valuesToIntegers(args[2:])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewTimeValue(t.AddDate(values[0], values[1], values[2])))
+
+	case "Sub", "Before", "After", "Equal", "Compare":
+		// https://pkg.go.dev/time#Time.Sub
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<time> ` + method + ` time"`)
+		}
+		result, u := valueToTime(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		switch method {
+		case "Sub":
+			return core.OK(NewDurationValue(t.Sub(u)))
+		case "Before":
+			return core.OK(core.BOOL(t.Before(u)))
+		case "After":
+			return core.OK(core.BOOL(t.After(u)))
+		case "Equal":
+			return core.OK(core.BOOL(t.Equal(u)))
+		default: // "Compare"
+			return core.OK(core.INT(int64(t.Compare(u))))
+		}
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// Durations
+//
+// Durations can also be given as strings accepted by time.ParseDuration,
+// e.g. `300ms` or `1h30m`
+//
+
+var DurationValueType = core.CustomValueType{Name: "go:Duration"}
+
+type DurationValue struct {
+	duration time.Duration
+}
+
+func NewDurationValue(d time.Duration) DurationValue {
+	return DurationValue{duration: d}
+}
+
+func (value DurationValue) Type() core.ValueType {
+	return core.ValueType_CUSTOM
+}
+func (value DurationValue) CustomType() core.CustomValueType {
+	return DurationValueType
+}
+func (value DurationValue) Display(fn core.DisplayFunction) string {
+	if fn != nil {
+		return fn(value)
+	}
+	return core.UndisplayableValueWithLabel("Duration " + value.duration.String())
+}
+func (value DurationValue) Command() core.Command {
+	return durationCommand{value}
+}
+
+// Return the wrapped Go duration
+func (value DurationValue) Duration() time.Duration {
+	return value.duration
+}
+
+type durationCommand struct {
+	value DurationValue
+}
+
+func (cmd durationCommand) Execute(args []core.Value, _ any) core.Result {
+	if len(args) == 1 {
+		return core.OK(cmd.value)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	d := cmd.value.duration
+	// https://pkg.go.dev/time#Duration
+	switch method {
+	case "String", "Nanoseconds", "Microseconds", "Milliseconds",
+		"Seconds", "Minutes", "Hours", "Abs":
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "<duration> ` + method + `"`)
+		}
+		switch method {
+		case "String":
+			return core.OK(core.STR(d.String()))
+		case "Nanoseconds":
+			return core.OK(core.INT(d.Nanoseconds()))
+		case "Microseconds":
+			return core.OK(core.INT(d.Microseconds()))
+		case "Milliseconds":
+			return core.OK(core.INT(d.Milliseconds()))
+		case "Seconds":
+			return core.OK(core.REAL(d.Seconds()))
+		case "Minutes":
+			return core.OK(core.REAL(d.Minutes()))
+		case "Hours":
+			return core.OK(core.REAL(d.Hours()))
+		default: // "Abs"
+			return core.OK(NewDurationValue(d.Abs()))
+		}
+
+	// Add, Sub and Compare stand for Go arithmetic and comparison operators
+	case "Truncate", "Round", "Add", "Sub", "Compare":
+		// https://pkg.go.dev/time#Duration.Truncate
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<duration> ` + method + ` duration"`)
+		}
+		result, e := valueToDuration(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		switch method {
+		case "Truncate":
+			return core.OK(NewDurationValue(d.Truncate(e)))
+		case "Round":
+			return core.OK(NewDurationValue(d.Round(e)))
+		case "Add":
+			return core.OK(NewDurationValue(d + e))
+		case "Sub":
+			return core.OK(NewDurationValue(d - e))
+		default: // "Compare"
+			switch {
+			case d < e:
+				return core.OK(core.INT(-1))
+			case d > e:
+				return core.OK(core.INT(1))
+			default:
+				return core.OK(core.INT(0))
+			}
+		}
+
+	case "Mul":
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "<duration> Mul n"`)
+		}
+		result, n := core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewDurationValue(d * time.Duration(n)))
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+
+//
+// Sleeping
+//
+// Sleeping commands yield a pending result that completes once the clock has
+// advanced past the duration, so that event loops can run other tasks in the
+// meantime
+//
+
+func sleep(clock helena_dialect.Clock, d time.Duration) core.Result {
+	// Register the timer now so that fake clocks can be advanced right away
+	channel := clock.After(d)
+	return helena_dialect.CreatePendingResult(func() core.Result {
+		<-channel
+		return core.OK(core.NIL)
+	})
+}
+
+type SleepCmd struct{ clock helena_dialect.Clock }
+
+func (cmd SleepCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) != 2 {
+		return core.ERROR(`wrong # args: should be "sleep duration"`)
+	}
+	result, d := valueToDuration(args[1])
+	if result.Code != core.ResultCode_OK {
+		return result
+	}
+	return sleep(cmd.clock, d)
+}
+func (SleepCmd) Resume(result core.Result, _ any) core.Result {
+	return helena_dialect.ResumePendingResult(result)
+}
+
+type TimeCmd struct{ clock helena_dialect.Clock }
+
+func (cmd TimeCmd) Execute(args []core.Value, _ any) core.Result {
+	if len(args) < 2 {
+		return core.ERROR(`wrong # args: should be "time method ?arg ...?"`)
+	}
+	method, ok := asString(args[1])
+	if !ok {
+		return core.ERROR("invalid method name")
+	}
+	// https://pkg.go.dev/time
+	switch method {
+	case "Now":
+		// https://pkg.go.dev/time#Now
+		if len(args) != 2 {
+			return core.ERROR(`wrong # args: should be "time Now"`)
+		}
+		return core.OK(NewTimeValue(cmd.clock.Now()))
+
+	case "Since", "Until":
+		// https://pkg.go.dev/time#Since
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "time ` + method + ` t"`)
+		}
+		result, t := valueToTime(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		if method == "Since" {
+			return core.OK(NewDurationValue(cmd.clock.Now().Sub(t)))
+		}
+		return core.OK(NewDurationValue(t.Sub(cmd.clock.Now())))
+
+	case "Unix":
+		// https://pkg.go.dev/time#Unix
+		if len(args) != 3 && len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "time Unix sec ?nsec?"`)
+		}
+		result, values := valuesToIntegers(args[2:])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		nsec := 0
+		if len(values) == 2 {
+			nsec = values[1]
+		}
+		return core.OK(NewTimeValue(time.Unix(int64(values[0]), int64(nsec))))
+
+	case "UnixMilli":
+		// https://pkg.go.dev/time#UnixMilli
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "time UnixMilli msec"`)
+		}
+		result, msec := core.ValueToInteger(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewTimeValue(time.UnixMilli(msec)))
+
+	case "Date":
+		// https://pkg.go.dev/time#Date
+		if len(args) != 10 {
+			return core.ERROR(`wrong # args: should be "time Date year month day hour min sec nsec location"`)
+		}
+		result, values := valuesToIntegers(args[2:9])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, location := valueToLocation(args[9])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewTimeValue(time.Date(
+			values[0], time.Month(values[1]), values[2],
+			values[3], values[4], values[5], values[6],
+			location,
+		)))
+
+	case "Parse":
+		// https://pkg.go.dev/time#Parse
+		if len(args) != 4 {
+			return core.ERROR(`wrong # args: should be "time Parse layout value"`)
+		}
+		result, layout := valueToLayout(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, value := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(NewTimeValue(t))
+
+	case "ParseInLocation":
+		// https://pkg.go.dev/time#ParseInLocation
+		if len(args) != 5 {
+			return core.ERROR(`wrong # args: should be "time ParseInLocation layout value location"`)
+		}
+		result, layout := valueToLayout(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, value := core.ValueToString(args[3])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		result, location := valueToLocation(args[4])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		t, err := time.ParseInLocation(layout, value, location)
+		if err != nil {
+			return core.ERROR(err.Error())
+		}
+		return core.OK(NewTimeValue(t))
+
+	case "ParseDuration":
+		// https://pkg.go.dev/time#ParseDuration
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "time ParseDuration s"`)
+		}
+		result, d := valueToDuration(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return core.OK(NewDurationValue(d))
+
+	case "Sleep":
+		// https://pkg.go.dev/time#Sleep
+		if len(args) != 3 {
+			return core.ERROR(`wrong # args: should be "time Sleep duration"`)
+		}
+		result, d := valueToDuration(args[2])
+		if result.Code != core.ResultCode_OK {
+			return result
+		}
+		return sleep(cmd.clock, d)
+
+	default:
+		return core.ERROR("unsupported method " + method)
+	}
+}
+func (TimeCmd) Resume(result core.Result, _ any) core.Result {
+	return helena_dialect.ResumePendingResult(result)
+}
@@ -0,0 +1,283 @@
+package go_time_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"helena/core"
+	"helena/helena_dialect"
+	"helena/native/go_time"
+)
+
+var _ = Describe("Go time", func() {
+	var rootScope *helena_dialect.Scope
+	var clock *helena_dialect.FakeClock
+
+	var tokenizer core.Tokenizer
+	var parser *core.Parser
+
+	parse := func(script string) *core.Script {
+		return parser.ParseTokens(tokenizer.Tokenize(script), nil).Script
+	}
+	prepareScript := func(script string) *helena_dialect.Process {
+		return rootScope.PrepareProcess(rootScope.Compile(*parse(script)))
+	}
+	execute := func(script string) core.Result {
+		return prepareScript(script).Run()
+	}
+	evaluate := func(script string) core.Value {
+		return execute(script).Value
+	}
+
+	// 2009-11-10 23:00:00 UTC
+	now := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		rootScope = helena_dialect.NewRootScope(nil)
+		helena_dialect.InitCommands(rootScope)
+		clock = helena_dialect.NewFakeClock(now)
+		module := go_time.NewModule(&go_time.ModuleOptions{Clock: clock})
+		for name := range *module.Exports {
+			rootScope.RegisterNamedCommand(name, module.Scope.ResolveNamedCommand(name))
+		}
+
+		tokenizer = core.Tokenizer{}
+		parser = core.NewParser(nil)
+	})
+
+	Describe("go:TimeValue", func() {
+		Specify("type should be custom", func() {
+			value := go_time.NewTimeValue(now)
+			Expect(core.IsCustomValue(value, go_time.TimeValueType)).To(BeTrue())
+		})
+		Specify("display", func() {
+			value := go_time.NewTimeValue(now)
+			Expect(value.Display(nil)).To(Equal(`{#{Time 2009-11-10T23:00:00Z}#}`))
+		})
+	})
+	Describe("go:DurationValue", func() {
+		Specify("type should be custom", func() {
+			value := go_time.NewDurationValue(time.Second)
+			Expect(core.IsCustomValue(value, go_time.DurationValueType)).To(BeTrue())
+		})
+		Specify("display", func() {
+			value := go_time.NewDurationValue(90 * time.Minute)
+			Expect(value.Display(nil)).To(Equal(`{#{Duration 1h30m0s}#}`))
+		})
+	})
+
+	Describe("`Now`", func() {
+		It("should use the injected clock", func() {
+			Expect(evaluate("time Now")).To(Equal(go_time.NewTimeValue(now)))
+			clock.Advance(time.Minute)
+			Expect(evaluate("[time Now] Format Kitchen")).To(Equal(STR("11:01PM")))
+		})
+		It("should keep clocks per module", func() {
+			other := go_time.NewModule(&go_time.ModuleOptions{
+				Clock: helena_dialect.NewFakeClock(now.Add(time.Hour)),
+			})
+			rootScope.RegisterNamedCommand("other", other.Scope.ResolveNamedCommand("time"))
+			Expect(evaluate("[other Now] Format Kitchen")).To(Equal(STR("12:00AM")))
+			Expect(evaluate("[time Now] Format Kitchen")).To(Equal(STR("11:00PM")))
+		})
+		It("should default to the system clock", func() {
+			module := go_time.Initmodule()
+			rootScope.RegisterNamedCommand("system", module.Scope.ResolveNamedCommand("time"))
+			Expect(evaluate("[system Now] UnixMilli").(core.IntegerValue).Value).To(BeNumerically("~", time.Now().UnixMilli(), 1000))
+		})
+	})
+	Describe("`Since` / `Until`", func() {
+		It("should measure durations with the clock", func() {
+			evaluate("set start [time Now]")
+			clock.Advance(1500 * time.Millisecond)
+			Expect(evaluate("[time Since $start] String")).To(Equal(STR("1.5s")))
+			Expect(evaluate("[time Until $start] Milliseconds")).To(Equal(INT(-1500)))
+		})
+	})
+
+	Describe("Parsing and formatting", func() {
+		Specify("named layouts", func() {
+			evaluate("set t [time Parse RFC3339 2006-01-02T15:04:05+07:00]")
+			Expect(evaluate("$t Format RFC1123Z")).To(Equal(STR("Mon, 02 Jan 2006 15:04:05 +0700")))
+			Expect(evaluate("$t Format DateOnly")).To(Equal(STR("2006-01-02")))
+			Expect(evaluate("[$t UTC] Format DateTime")).To(Equal(STR("2006-01-02 08:04:05")))
+		})
+		Specify("literal layouts", func() {
+			evaluate(`set t [time Parse "Jan 2, 2006 at 3:04pm (MST)" "Feb 3, 2013 at 7:54pm (PST)"]`)
+			Expect(evaluate(`$t Format "2006/01/02 15h04"`)).To(Equal(STR("2013/02/03 19h54")))
+		})
+		Specify("`ParseInLocation`", func() {
+			evaluate(`set t [time ParseInLocation DateTime "2012-07-09 05:02:00" Europe/Berlin]`)
+			Expect(evaluate("$t Format RFC3339")).To(Equal(STR("2012-07-09T05:02:00+02:00")))
+			Expect(evaluate("$t Location")).To(Equal(STR("Europe/Berlin")))
+		})
+		Specify("`String`", func() {
+			Expect(evaluate("[time Now] String")).To(Equal(STR("2009-11-10 23:00:00 +0000 UTC")))
+		})
+		Describe("Exceptions", func() {
+			Specify("invalid values", func() {
+				Expect(execute("time Parse DateOnly 2006-13-01")).To(Equal(
+					ERROR(`parsing time "2006-13-01": month out of range`),
+				))
+				Expect(execute("time ParseInLocation DateOnly 2006-01-01 Nowhere/Town")).To(Equal(
+					ERROR("unknown time zone Nowhere/Town"),
+				))
+			})
+		})
+	})
+
+	Describe("Components", func() {
+		Specify("`Date`", func() {
+			evaluate("set t [time Date 2024 2 29 13 14 15 16 UTC]")
+			Expect(evaluate("$t Year")).To(Equal(INT(2024)))
+			Expect(evaluate("$t Month")).To(Equal(INT(2)))
+			Expect(evaluate("$t Day")).To(Equal(INT(29)))
+			Expect(evaluate("$t Hour")).To(Equal(INT(13)))
+			Expect(evaluate("$t Minute")).To(Equal(INT(14)))
+			Expect(evaluate("$t Second")).To(Equal(INT(15)))
+			Expect(evaluate("$t Nanosecond")).To(Equal(INT(16)))
+			Expect(evaluate("$t Weekday")).To(Equal(INT(int64(time.Thursday))))
+			Expect(evaluate("$t YearDay")).To(Equal(INT(60)))
+			Expect(evaluate("$t IsZero")).To(Equal(FALSE))
+		})
+		Specify("time zones", func() {
+			evaluate("set t [[time Date 2024 7 1 12 0 0 0 UTC] In America/New_York]")
+			Expect(evaluate("$t Hour")).To(Equal(INT(8)))
+			Expect(evaluate("$t Location")).To(Equal(STR("America/New_York")))
+			Expect(evaluate("[$t In Asia/Tokyo] Format RFC3339")).To(Equal(STR("2024-07-01T21:00:00+09:00")))
+		})
+	})
+
+	Describe("Unix conversions", func() {
+		Specify("from Unix times", func() {
+			Expect(evaluate("[time Unix 1257894000] Equal [time Now]")).To(Equal(TRUE))
+			Expect(evaluate("[[time Unix 0 1500] UTC] Format RFC3339Nano")).To(Equal(STR("1970-01-01T00:00:00.0000015Z")))
+			Expect(evaluate("[time UnixMilli 1257894000123] UnixMilli")).To(Equal(INT(1257894000123)))
+		})
+		Specify("to Unix times", func() {
+			Expect(evaluate("[time Now] Unix")).To(Equal(INT(1257894000)))
+			Expect(evaluate("[time Now] UnixMilli")).To(Equal(INT(1257894000000)))
+			Expect(evaluate("[time Now] UnixNano")).To(Equal(INT(1257894000000000000)))
+		})
+	})
+
+	Describe("Arithmetic", func() {
+		Specify("times", func() {
+			evaluate("set t [time Now]")
+			Expect(evaluate("[$t Add 1h30m] Format Kitchen")).To(Equal(STR("12:30AM")))
+			Expect(evaluate("[$t Add [time ParseDuration -1h]] Hour")).To(Equal(INT(22)))
+			Expect(evaluate("[$t AddDate 0 1 22] Format DateOnly")).To(Equal(STR("2010-01-01")))
+			Expect(evaluate("[[$t Add 45m] Sub $t] String")).To(Equal(STR("45m0s")))
+			Expect(evaluate("[[$t Add 45m] Truncate 1h] Equal $t")).To(Equal(TRUE))
+			Expect(evaluate("[[$t Add 45m] Round 1h] Hour")).To(Equal(INT(0)))
+		})
+		Specify("durations", func() {
+			evaluate("set d [time ParseDuration 1h15m30.5s]")
+			Expect(evaluate("$d Hours")).To(Equal(REAL(1.2584722222222222)))
+			Expect(evaluate("$d Minutes")).To(Equal(REAL(75.50833333333334)))
+			Expect(evaluate("$d Seconds")).To(Equal(REAL(4530.5)))
+			Expect(evaluate("$d Milliseconds")).To(Equal(INT(4530500)))
+			Expect(evaluate("$d Microseconds")).To(Equal(INT(4530500000)))
+			Expect(evaluate("$d Nanoseconds")).To(Equal(INT(4530500000000)))
+			Expect(evaluate("[$d Truncate 1m] String")).To(Equal(STR("1h15m0s")))
+			Expect(evaluate("[$d Round 1h] String")).To(Equal(STR("1h0m0s")))
+			Expect(evaluate("[$d Add 30s] String")).To(Equal(STR("1h16m0.5s")))
+			Expect(evaluate("[[$d Sub 2h] Abs] String")).To(Equal(STR("44m29.5s")))
+			Expect(evaluate("[$d Mul 2] String")).To(Equal(STR("2h31m1s")))
+		})
+	})
+
+	Describe("Comparison", func() {
+		Specify("times", func() {
+			evaluate("set t [time Now]")
+			evaluate("set u [$t Add 1s]")
+			Expect(evaluate("$t Before $u")).To(Equal(TRUE))
+			Expect(evaluate("$t After $u")).To(Equal(FALSE))
+			Expect(evaluate("$t Equal [$u Add -1s]")).To(Equal(TRUE))
+			Expect(evaluate("$t Compare $u")).To(Equal(INT(-1)))
+			Expect(evaluate("$u Compare $t")).To(Equal(INT(1)))
+			Expect(evaluate("$t Equal [$t In Asia/Tokyo]")).To(Equal(TRUE))
+		})
+		Specify("durations", func() {
+			evaluate("set d [time ParseDuration 90s]")
+			Expect(evaluate("$d Compare 1m")).To(Equal(INT(1)))
+			Expect(evaluate("$d Compare 1m30s")).To(Equal(INT(0)))
+			Expect(evaluate("$d Compare [time ParseDuration 2m]")).To(Equal(INT(-1)))
+		})
+	})
+
+	Describe("Sleeping", func() {
+		It("should yield a pending result instead of blocking", func() {
+			process := prepareScript("sleep 10s; idem done")
+			result := process.Run()
+			Expect(result.Code).To(Equal(core.ResultCode_YIELD))
+			Expect(result.Value).To(BeAssignableToTypeOf(&helena_dialect.PendingValue{}))
+
+			clock.Advance(10 * time.Second)
+			process.YieldBack(result.Value.(helena_dialect.WaitableValue).Wait())
+			Expect(process.Run()).To(Equal(OK(STR("done"))))
+		})
+		It("should let event loops run other tasks", func() {
+			loop := helena_dialect.NewEventLoop(clock)
+			loop.RegisterCommands(rootScope)
+			evaluate("set l [list ()]")
+			spawn := func(script string) *helena_dialect.Task {
+				return loop.Spawn(rootScope, rootScope.Compile(*parse(script)))
+			}
+			spawn("time Sleep 2s; set l [list $l append (slow)]")
+			spawn("sleep 1s; set l [list $l append (fast)]")
+			Expect(loop.RunPending()).To(Equal(OK(NIL)))
+			Expect(evaluate("get l")).To(Equal(LIST([]core.Value{})))
+
+			done := make(chan core.Result)
+			go func() { done <- loop.Run() }()
+			Eventually(func() time.Time { clock.Advance(100 * time.Millisecond); return clock.Now() }).
+				Should(BeTemporally(">=", now.Add(2*time.Second)))
+			Eventually(done).Should(Receive(Equal(OK(NIL))))
+			Expect(evaluate("get l")).To(Equal(LIST([]core.Value{STR("fast"), STR("slow")})))
+		})
+		Describe("Exceptions", func() {
+			Specify("wrong arity", func() {
+				Expect(execute("sleep")).To(Equal(ERROR(`wrong # args: should be "sleep duration"`)))
+				Expect(execute("time Sleep")).To(Equal(ERROR(`wrong # args: should be "time Sleep duration"`)))
+			})
+			Specify("invalid duration", func() {
+				Expect(execute("sleep 10")).To(Equal(ERROR(`invalid duration "10"`)))
+				Expect(execute("sleep []")).To(Equal(ERROR("invalid duration value")))
+			})
+		})
+	})
+
+	Describe("Exceptions", func() {
+		Specify("wrong arity", func() {
+			Expect(execute("time")).To(Equal(ERROR(`wrong # args: should be "time method ?arg ...?"`)))
+			Expect(execute("time Now a")).To(Equal(ERROR(`wrong # args: should be "time Now"`)))
+			Expect(execute("time Unix")).To(Equal(ERROR(`wrong # args: should be "time Unix sec ?nsec?"`)))
+			Expect(execute("time Date 2000 1 1")).To(Equal(
+				ERROR(`wrong # args: should be "time Date year month day hour min sec nsec location"`),
+			))
+			Expect(execute("[time Now] Format")).To(Equal(ERROR(`wrong # args: should be "<time> Format layout"`)))
+			Expect(execute("[time Now] Year 1")).To(Equal(ERROR(`wrong # args: should be "<time> Year"`)))
+			Expect(execute("[time Now] Sub")).To(Equal(ERROR(`wrong # args: should be "<time> Sub time"`)))
+			Expect(execute("[time Now] AddDate 1")).To(Equal(
+				ERROR(`wrong # args: should be "<time> AddDate years months days"`),
+			))
+			Expect(execute("[time ParseDuration 1s] Round")).To(Equal(
+				ERROR(`wrong # args: should be "<duration> Round duration"`),
+			))
+		})
+		Specify("invalid values", func() {
+			Expect(execute("time Since a")).To(Equal(ERROR("invalid time value")))
+			Expect(execute("[time Now] Before 1s")).To(Equal(ERROR("invalid time value")))
+			Expect(execute("[time Now] Add a")).To(Equal(ERROR(`invalid duration "a"`)))
+			Expect(execute("time Unix a")).To(Equal(ERROR(`invalid integer "a"`)))
+		})
+		Specify("unsupported method", func() {
+			Expect(execute("time Foo")).To(Equal(ERROR("unsupported method Foo")))
+			Expect(execute("[time Now] Foo")).To(Equal(ERROR("unsupported method Foo")))
+			Expect(execute("[time ParseDuration 1s] Foo")).To(Equal(ERROR("unsupported method Foo")))
+		})
+	})
+})
@@ -0,0 +1,43 @@
+package go_time
+
+import (
+	"helena/core"
+	"helena/helena_dialect"
+
+	// Time zones are available even on systems without tzdata
+	_ "time/tzdata"
+)
+
+/**
+ * Main static module entry point.
+ */
+func Initmodule() *helena_dialect.Module {
+	return NewModule(nil)
+}
+
+type ModuleOptions struct {
+	// Source of the current time for `Now`, `Since`, `Until` and sleeping
+	// commands, SystemClock if nil; e.g. a helena_dialect.FakeClock for
+	// deterministic tests
+	Clock helena_dialect.Clock
+}
+
+// Create a go:time module with the given options
+func NewModule(options *ModuleOptions) *helena_dialect.Module {
+	clock := helena_dialect.SystemClock
+	if options != nil && options.Clock != nil {
+		clock = options.Clock
+	}
+	scope := helena_dialect.NewRootScope(nil)
+	exports := &helena_dialect.Exports{}
+	module := helena_dialect.NewModule(scope, exports)
+	module.SetDoc("Times and durations from the Go time package")
+	exportCommand(module, "time", TimeCmd{clock})
+	exportCommand(module, "sleep", SleepCmd{clock})
+	return module
+}
+
+func exportCommand(module *helena_dialect.Module, name string, cmd core.Command) {
+	module.Scope.RegisterNamedCommand(name, cmd)
+	(*module.Exports)[name] = core.STR(name)
+}